@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,7 +11,38 @@ import (
 	"github.com/lixinio/weixin/wxopen"
 )
 
-func serveData(serverApi *wxopen.WxOpen, apps wxopen.ReleaseApps) utils.XmlHandlerFunc {
+// 授权相关的事件(ticket, 授权, 取消授权, 授权更新)由 LifecycleHandler 处理
+func newLifecycleHandler(
+	serverApi *wxopen.WxOpen, store wxopen.AuthorizationStore, apps wxopen.ReleaseApps,
+) *wxopen.LifecycleHandler {
+	printReleaseApp := func(appid string) {
+		if app, ok := apps[appid]; ok {
+			fmt.Printf("release app %s %v\n", app.UserName, app.IsMp)
+		}
+	}
+	return wxopen.NewLifecycleHandler(
+		serverApi, store,
+		wxopen.LifecycleOptWithOnAuthorized(func(ctx context.Context, a *wxopen.Authorization) error {
+			printReleaseApp(a.AuthorizerAppid)
+			fmt.Printf("EventAuthorized : %s %v\n", a.AuthorizerAppid, a.FuncInfo)
+			return nil
+		}),
+		wxopen.LifecycleOptWithOnRevoked(func(ctx context.Context, a *wxopen.Authorization) error {
+			printReleaseApp(a.AuthorizerAppid)
+			fmt.Printf("EventUnauthorized : %s\n", a.AuthorizerAppid)
+			return nil
+		}),
+		wxopen.LifecycleOptWithOnPermissionsChanged(func(
+			ctx context.Context, a *wxopen.Authorization, added, removed []int,
+		) error {
+			printReleaseApp(a.AuthorizerAppid)
+			fmt.Printf("EventUpdateAuthorized : %s %v %v\n", a.AuthorizerAppid, added, removed)
+			return nil
+		}),
+	)
+}
+
+func serveData(serverApi *wxopen.WxOpen) utils.XmlHandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, body []byte) error {
 		_, content, err := serverApi.ParseXML(body)
 		if err != nil {
@@ -18,41 +50,14 @@ func serveData(serverApi *wxopen.WxOpen, apps wxopen.ReleaseApps) utils.XmlHandl
 			return err
 		}
 
-		switch v := content.(type) {
-		case *wxopen.EventComponentVerifyTicket:
-			fmt.Printf("ComponentVerifyTicket : %s\n", v.ComponentVerifyTicket)
-			// todo save to db
-			// 刷新Ticket到Cache
-			serverApi.UpdateTicket(r.Context(), v.ComponentVerifyTicket)
-		case *wxopen.EventAuthorized:
-			if app, ok := apps[v.AuthorizerAppid]; ok {
-				fmt.Printf("release app %s %v\n", app.UserName, app.IsMp)
-				// ignore
-			}
-			fmt.Printf("EventAuthorized : %s\n", v.AuthorizerAppid)
-		case *wxopen.EventUnauthorized:
-			if app, ok := apps[v.AuthorizerAppid]; ok {
-				fmt.Printf("release app %s %v\n", app.UserName, app.IsMp)
-				// ignore
-			}
-			fmt.Printf("EventUnauthorized : %s\n", v.AuthorizerAppid)
-		case *wxopen.EventUpdateAuthorized:
-			if app, ok := apps[v.AuthorizerAppid]; ok {
-				fmt.Printf("release app %s %v\n", app.UserName, app.IsMp)
-				// ignore
-			}
-			fmt.Printf("EventUpdateAuthorized : %s\n", v.AuthorizerAppid)
-		default:
-			fmt.Printf("I don't know about type %T!\n", v)
-		}
-
+		fmt.Printf("I don't know about type %T!\n", content)
 		_, err = io.WriteString(w, "success")
 		return err
 	}
 }
 
-func weixinCallback(serverApi *wxopen.WxOpen, apps wxopen.ReleaseApps) http.HandlerFunc {
-	f := serveData(serverApi, apps)
+func weixinCallback(serverApi *wxopen.WxOpen, lifecycle *wxopen.LifecycleHandler) http.HandlerFunc {
+	f := lifecycle.XmlHandler(serveData(serverApi))
 	return func(w http.ResponseWriter, r *http.Request) {
 		if strings.ToLower(r.Method) == "post" {
 			if err := serverApi.ServeData(w, r, f); err != nil {
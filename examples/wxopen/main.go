@@ -54,7 +54,7 @@ func auth(wo *wxopen.WxOpen, mobile bool) http.HandlerFunc {
 	}
 }
 
-func callback(wo *wxopen.WxOpen, manager TokenCacheManager) http.HandlerFunc {
+func callback(wo *wxopen.WxOpen) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		code := r.URL.Query().Get("auth_code")
 		authorizationInfo, err := wo.QueryAuth(r.Context(), code)
@@ -62,26 +62,8 @@ func callback(wo *wxopen.WxOpen, manager TokenCacheManager) http.HandlerFunc {
 			panic(err)
 		}
 
-		authorizer, err := manager.GetTokenCache(
-			wo.Config.Appid, authorizationInfo.AuthorizerAppid,
-		)
-		if err != nil {
-			panic(err)
-		}
-
-		// 保存refresh token
-		err = authorizer.SetRefreshToken(r.Context(), authorizationInfo.AuthorizerRefreshToken)
-		if err != nil {
-			panic(err)
-		}
-
-		// 保存 access token
-		err = authorizer.SetAccessToken(
-			r.Context(),
-			authorizationInfo.AuthorizerAccessToken,
-			authorizationInfo.ExpiresIn,
-		)
-		if err != nil {
+		// 保存 refresh token 和 access token
+		if err = wo.SaveAuthorization(r.Context(), authorizationInfo); err != nil {
 			panic(err)
 		}
 
@@ -156,9 +138,6 @@ func loginCallback(wo *wxopen.WxOpen, wxopenOA *authorizer.Authorizer) http.Hand
 func main() {
 	// 缓存
 	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	// 存储授权后的access token, refresh token
-	// 生产环境应该存储到DB
-	manager := NewAuthorizerRefreshTokenManager(redis, redis)
 	// 开放平台
 	wxopenApi := wxopen.New(redis, redis, &wxopen.Config{
 		Appid:          test.WxOpenAppid,
@@ -166,19 +145,16 @@ func main() {
 		Token:          test.WxOpenToken,
 		EncodingAESKey: test.WxOpenEncodingAESKey,
 	}, nil)
+	// 存储授权后的 refresh token
+	// 生产环境应该存储到DB, 参考 utils.NewSQLRefreshTokenStore
+	wxopenApi.SetRefreshTokenStore(utils.NewCacheRefreshTokenStore(redis))
+	wxopenApi.SetAuthorizerFactory(authorizer.WxOpenFactory)
 
 	// 初始化测试的授权服务号
-	oaTokenCache, err := manager.GetTokenCache(wxopenApi.Config.Appid, test.WxOpenOAAppid)
+	wxopenOA, err := authorizer.FromWxOpen(wxopenApi, test.WxOpenOAAppid)
 	if err != nil {
 		panic(err)
 	}
-	wxopenOA := authorizer.New(
-		redis, redis,
-		wxopenApi.Config.Appid,
-		test.WxOpenOAAppid,
-		GetAuthorizerAccessToken(wxopenApi, oaTokenCache, test.WxOpenOAAppid),
-		nil,
-	)
 
 	// server
 	serverApi := server_api.NewApi(
@@ -200,7 +176,11 @@ func main() {
 
 	http.HandleFunc(
 		fmt.Sprintf("/gateway/component/%s/notify", wxopenApi.Config.Appid),
-		weixinCallback(wxopenApi, wxopen.ReleaseAppIDS),
+		weixinCallback(wxopenApi, newLifecycleHandler(
+			wxopenApi,
+			wxopen.NewCacheAuthorizationStore(redis, wxopenApi.Config.Appid),
+			wxopen.ReleaseAppIDS,
+		)),
 	)
 	http.HandleFunc(
 		fmt.Sprintf(
@@ -225,7 +205,7 @@ func main() {
 	http.HandleFunc("/", index(wxopenApi))
 	http.HandleFunc("/auth", auth(wxopenApi, false))
 	http.HandleFunc("/auth/mobile", auth(wxopenApi, true))
-	http.HandleFunc("/auth/callback", callback(wxopenApi, manager))
+	http.HandleFunc("/auth/callback", callback(wxopenApi))
 	http.HandleFunc("/login", login(wxopenApi, wxopenOA))
 	http.HandleFunc("/login/callback", loginCallback(wxopenApi, wxopenOA))
 
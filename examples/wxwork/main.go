@@ -76,6 +76,7 @@ func main() {
 		test.AgentID,
 		test.AgentToken,
 		test.AgentEncodingAESKey,
+		test.CorpID,
 	)
 
 	http.HandleFunc("/", index(agent))
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
@@ -26,14 +27,19 @@ type AccessTokenCache struct {
 	accessTokenLock     Lock              // 避免刷新token冲突
 	accessTokenGetter   AccessTokenGetter // 获取token对象
 	tokenRefreshHandler TokenRefreshHandler
+	localCache          *LocalTokenCache // 可选的进程内缓存
+	telemetry           *telemetry       // 为空时使用全局的 provider, 由 NewClient 设置
 }
 
+var _ ClientAccessTokenInvalidator = (*AccessTokenCache)(nil)
+
 type (
 	refreshTokenHandler func(context.Context) (string, int, error)
 	TokenRefreshHandler func(context.Context, string, int)
 	cacheOption         func(*option)
 	option              struct {
 		tokenRefreshHandler TokenRefreshHandler
+		localCache          *LocalTokenCache
 	}
 )
 
@@ -43,6 +49,13 @@ func CacheClientTokenOptWithExpireBefore(tokenRefreshHandler TokenRefreshHandler
 	}
 }
 
+// CacheClientTokenOptWithLocalCache 在 cache 之前增加一级进程内缓存
+func CacheClientTokenOptWithLocalCache(localCache *LocalTokenCache) cacheOption {
+	return func(jct *option) {
+		jct.localCache = localCache
+	}
+}
+
 func NewAccessTokenCache(
 	accessTokenGetter AccessTokenGetter,
 	cache Cache,
@@ -59,6 +72,7 @@ func NewAccessTokenCache(
 		accessTokenLock:     locker,
 		cache:               cache,
 		tokenRefreshHandler: to.tokenRefreshHandler,
+		localCache:          to.localCache,
 	}
 }
 
@@ -66,9 +80,16 @@ func NewAccessTokenCache(
 func (atc *AccessTokenCache) GetAccessToken(
 	ctx context.Context,
 ) (accessToken string, err error) {
+	if atc.localCache != nil {
+		if accessToken, ok := atc.localCache.get(atc.accessTokenGetter.GetAccessTokenKey()); ok {
+			return accessToken, nil
+		}
+	}
+
 	accessToken, err = atc.getCachedAccessToken(ctx)
 	if err == nil && accessToken != "" {
 		// 直接从缓存获取
+		atc.setLocalAccessToken(ctx, accessToken)
 		return accessToken, nil
 	} else if err != nil {
 		// 出错了， 直接报错， 而不是用不缓存的Token， 因为获取Token有次数限制
@@ -77,7 +98,7 @@ func (atc *AccessTokenCache) GetAccessToken(
 
 	return atc.updateAccessToken(
 		ctx,
-		atc.accessTokenGetter.GetAccessToken,
+		atc.fetchAccessToken,
 		true,
 	)
 }
@@ -89,34 +110,154 @@ func (atc *AccessTokenCache) ClearAccessToken(ctx context.Context) error {
 		return err
 	}
 	defer closer()
-	return atc.cache.Delete(ctx, atc.accessTokenGetter.GetAccessTokenKey())
+	if err = atc.cache.Delete(ctx, atc.accessTokenGetter.GetAccessTokenKey()); err != nil {
+		return err
+	}
+	return atc.invalidateLocalAccessToken(ctx)
+}
+
+// 微信返回token失效时调用(ClientAccessTokenInvalidator), 只有缓存的token就是失效的token时才清除,
+// 避免并发的请求把别人刚刷新的token删掉
+func (atc *AccessTokenCache) InvalidateAccessToken(
+	ctx context.Context, accessToken string,
+) error {
+	closer, err := atc.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	if atc.localCache != nil {
+		// 本地缓存可能是旧的
+		atc.localCache.delete(atc.accessTokenGetter.GetAccessTokenKey())
+	}
+	cachedAccessToken, err := atc.getCachedAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	if cachedAccessToken == "" || cachedAccessToken != accessToken {
+		// 已经被清除或者刷新了
+		return nil
+	}
+	if err = atc.cache.Delete(ctx, atc.accessTokenGetter.GetAccessTokenKey()); err != nil {
+		return err
+	}
+	return atc.invalidateLocalAccessToken(ctx)
+}
+
+// 从 cache 获取到token之后, 按照剩余时间缓存到本地
+func (atc *AccessTokenCache) setLocalAccessToken(ctx context.Context, accessToken string) {
+	if atc.localCache == nil {
+		return
+	}
+	accessTokenCacheKey := atc.accessTokenGetter.GetAccessTokenKey()
+	ttl, err := atc.cache.TTL(ctx, accessTokenCacheKey)
+	if err != nil || ttl < 0 {
+		// 永不过期(-1)的token不缓存到本地, 避免无法感知变化
+		return
+	}
+	atc.localCache.set(accessTokenCacheKey, accessToken, time.Duration(ttl)*time.Second)
+}
+
+// 删除本地缓存, 并通知其他实例
+func (atc *AccessTokenCache) invalidateLocalAccessToken(ctx context.Context) error {
+	if atc.localCache == nil {
+		return nil
+	}
+	return atc.localCache.invalidate(ctx, atc.accessTokenGetter.GetAccessTokenKey())
 }
 
 // 强制刷新Token, 为了避免Token到期争抢刷新, 一般会有定时任务在Token过期之前的某个时刻强制刷新
+// 参考 TokenRefresher
 func (atc *AccessTokenCache) RefreshAccessToken(
 	ctx context.Context, beforeTTL int,
 ) (accessToken string, err error) {
 	if beforeTTL == 0 {
 		beforeTTL = defaultExpireBefore
 	}
-	ttl, err := atc.cache.TTL(ctx, atc.accessTokenGetter.GetAccessTokenKey())
-	if err != nil {
-		return "", err
+	// 未更新返回空token
+	accessToken, _, err = atc.refreshAccessTokenBefore(ctx, beforeTTL)
+	return
+}
+
+// 剩余时间小于 beforeTTL 时刷新, 返回新的token(未更新为空)和检查时的剩余时间
+func (atc *AccessTokenCache) refreshAccessTokenBefore(
+	ctx context.Context, beforeTTL int,
+) (accessToken string, ttl int, err error) {
+	accessTokenCacheKey := atc.accessTokenGetter.GetAccessTokenKey()
+	if ttl, err = atc.cache.TTL(ctx, accessTokenCacheKey); err != nil {
+		return
 	}
 	// 如果不存在, 返回-2
 	if ttl > beforeTTL {
-		// 未更新
-		return "", nil
+		return
 	}
 
+	closer, err := atc.lock(ctx)
+	if err != nil {
+		return
+	}
+	defer closer()
+
+	// 多个实例同时检查, 可能已经被别人刷新了
+	if ttl, err = atc.cache.TTL(ctx, accessTokenCacheKey); err != nil || ttl > beforeTTL {
+		return
+	}
+
+	accessToken, err = atc.refreshAccessToken(ctx, atc.fetchAccessToken)
+	return
+}
+
+// useTelemetry 使用 Client 的 TracerProvider / MeterProvider 记录token刷新
+func (atc *AccessTokenCache) useTelemetry(t *telemetry) {
+	atc.telemetry = t
+}
+
+// fetchAccessToken 从微信服务器获取token
+func (atc *AccessTokenCache) fetchAccessToken(
+	ctx context.Context,
+) (accessToken string, expiresIn int, err error) {
+	return atc.fetchAccessTokenWith(ctx, atc.accessTokenGetter.GetAccessToken)
+}
+
+// fetchAccessTokenWith 调用 fetch 从微信服务器获取token, 只有这里记录token刷新的 span 和 metric
+func (atc *AccessTokenCache) fetchAccessTokenWith(
+	ctx context.Context, fetch refreshTokenHandler,
+) (accessToken string, expiresIn int, err error) {
+	t := atc.telemetry
+	if t == nil {
+		t = defaultTelemetry()
+	}
+	t.refreshToken(
+		ctx, atc.accessTokenGetter.GetAccessTokenKey(),
+		func(ctx context.Context) error {
+			accessToken, expiresIn, err = fetch(ctx)
+			return err
+		},
+	)
+	return
+}
+
+// ForceRefreshAccessToken 加锁之后调用 fetch 从微信服务器获取新的token并更新缓存,
+// 不检查缓存中是否已有token; fetch 为空时使用 AccessTokenGetter.GetAccessToken
+func (atc *AccessTokenCache) ForceRefreshAccessToken(
+	ctx context.Context, fetch func(context.Context) (string, int, error),
+) (accessToken string, err error) {
+	if fetch == nil {
+		fetch = atc.accessTokenGetter.GetAccessToken
+	}
 	return atc.updateAccessToken(
 		ctx,
-		atc.accessTokenGetter.GetAccessToken,
+		func(ctx context.Context) (string, int, error) {
+			return atc.fetchAccessTokenWith(ctx, fetch)
+		},
 		false,
 	)
 }
 
 func (atc *AccessTokenCache) lock(ctx context.Context) (func(), error) {
+	// 每次加锁独立的持有者, 锁过期之后不会释放掉同一个进程内别人重新获取的锁
+	ctx = NewLockContext(ctx)
 	lockKey := atc.accessTokenGetter.GetAccessTokenLockKey()
 	locked, err := atc.accessTokenLock.LockTimeout(
 		ctx,
@@ -125,9 +266,11 @@ func (atc *AccessTokenCache) lock(ctx context.Context) (func(), error) {
 		defaultLockRetryTime,
 		defaultLockRetryTimeout,
 	)
-	if err != nil || !locked {
-		// 出错或者加锁失败
+	if err != nil {
 		return nil, err
+	} else if !locked {
+		// 加锁超时
+		return nil, fmt.Errorf("lock access token key %s, %w", lockKey, ErrorLockTimeout)
 	}
 	return func() {
 		atc.accessTokenLock.UnLock(ctx, lockKey)
@@ -197,9 +340,8 @@ func (atc *AccessTokenCache) refreshAccessToken(
 	ctx context.Context,
 	handler refreshTokenHandler,
 ) (accessToken string, err error) {
-	// 从服务器获取Token
-	expiresIn := 0
-	accessToken, expiresIn, err = handler(ctx)
+	// 从服务器获取Token(或者外部更新的Token)
+	accessToken, expiresIn, err := handler(ctx)
 	if err != nil {
 		// 失败
 		return
@@ -223,6 +365,11 @@ func (atc *AccessTokenCache) refreshAccessToken(
 		// 因为如果缓存出了问题， 下次刷新Token也会失败， 不会导致token配额用尽
 		return accessToken, nil
 	}
+
+	if atc.localCache != nil {
+		// 先更新本地缓存再通知, 其他实例丢弃旧的token, 自己收到通知时保留新的token
+		atc.localCache.replace(ctx, accessTokenCacheKey, accessToken, time.Duration(expires)*time.Second)
+	}
 	return
 }
 
@@ -20,6 +20,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/sha1"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -203,3 +204,34 @@ func CalcSignature(datas ...string) string {
 	_, _ = io.WriteString(h, strings.Join(datas, ""))
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
+
+// SignatureEqual 常量时间比较签名, 避免时序攻击
+func SignatureEqual(signature, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) == 1
+}
+
+// AESDecryptMsgWithReceiverID 消息解密, 并校验明文尾部的 receiverId(appId/corpId/suiteId)
+// receiverIDs 为允许的值, 为空不校验
+func AESDecryptMsgWithReceiverID(
+	base64CipherText string,
+	encodingAESKey string,
+	receiverIDs ...string,
+) (rawXMLMsg []byte, receiverID string, err error) {
+	_, rawXMLMsg, appId, err := AESDecryptMsg(base64CipherText, encodingAESKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	receiverID = string(appId)
+	if len(receiverIDs) == 0 {
+		return rawXMLMsg, receiverID, nil
+	}
+	for _, id := range receiverIDs {
+		if id == receiverID {
+			return rawXMLMsg, receiverID, nil
+		}
+	}
+	return nil, receiverID, fmt.Errorf(
+		"receiver id '%s' not in %v, %w", receiverID, receiverIDs, ErrorReceiverIDMismatch,
+	)
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testEncodingAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+
+func TestAESDecryptMsgWithReceiverID(t *testing.T) {
+	msg := []byte("<xml><Content>hello</Content></xml>")
+	cipherText, err := AESEncryptMsg([]byte(GetRandString(16)), msg, "wxcorp", testEncodingAESKey)
+	require.Equal(t, nil, err)
+
+	plain, receiverID, err := AESDecryptMsgWithReceiverID(cipherText, testEncodingAESKey)
+	require.Equal(t, nil, err)
+	require.Equal(t, msg, plain)
+	require.Equal(t, "wxcorp", receiverID)
+
+	plain, _, err = AESDecryptMsgWithReceiverID(cipherText, testEncodingAESKey, "suite", "wxcorp")
+	require.Equal(t, nil, err)
+	require.Equal(t, msg, plain)
+
+	_, receiverID, err = AESDecryptMsgWithReceiverID(cipherText, testEncodingAESKey, "other")
+	require.True(t, errors.Is(err, ErrorReceiverIDMismatch))
+	require.Equal(t, "wxcorp", receiverID)
+}
+
+func TestSignatureEqual(t *testing.T) {
+	signature := CalcSignature("token", "1348831860", "nonce")
+	require.True(t, SignatureEqual(signature, CalcSignature("nonce", "token", "1348831860")))
+	require.False(t, SignatureEqual(signature, ""))
+	require.False(t, SignatureEqual(signature, CalcSignature("token", "1348831861", "nonce")))
+}
@@ -15,3 +15,8 @@ type Cache interface {
 	Delete(context.Context, string) error
 	TTL(context.Context, string) (int, error)
 }
+
+// CacheSetNX 可选接口, 不存在时才设置(原子操作), utils/redis 和 utils/memory 都实现了该接口
+type CacheSetNX interface {
+	SetNX(context.Context, string, interface{}, time.Duration) (bool, error)
+}
@@ -0,0 +1,176 @@
+package utils
+
+// 异步处理回调, 回调需要在5秒内响应, 先回复再处理
+// 校验/解密之后, 把消息交给队列(进程内的 AsyncDispatcher 或者用户自己的 kafka 等), 立即回复
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	ErrorAsyncQueueFull = errors.New("async callback queue full")
+	ErrorAsyncShutdown  = errors.New("async callback dispatcher shutdown")
+)
+
+const (
+	defaultAsyncWorkers   = 8
+	defaultAsyncQueueSize = 1024
+)
+
+// CallbackQueue 回调消息的队列, 入队成功之后才回复微信, 失败则不回复, 等待微信重试
+type CallbackQueue interface {
+	Enqueue(ctx context.Context, body []byte) error
+}
+
+// NewAsyncXmlHandler 入队之后立即回复 reply, 用于 ServeData
+func NewAsyncXmlHandler(queue CallbackQueue, reply string) XmlHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) error {
+		// 请求结束之后 ctx 会被取消, 只保留其中的值(trace等)
+		if err := queue.Enqueue(context.WithoutCancel(r.Context()), body); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, reply)
+		return err
+	}
+}
+
+// AsyncProcessor 异步处理(解密之后的)回调消息
+type AsyncProcessor func(ctx context.Context, body []byte) error
+
+type (
+	AsyncOption  func(*asyncOptions)
+	asyncOptions struct {
+		workers      int
+		queueSize    int
+		errorHandler func(ctx context.Context, body []byte, err error)
+	}
+)
+
+// AsyncOptWithWorkers 并发处理的数量
+func AsyncOptWithWorkers(workers int) AsyncOption {
+	return func(o *asyncOptions) {
+		o.workers = workers
+	}
+}
+
+// AsyncOptWithQueueSize 等待处理的消息数量, 超过之后入队失败
+func AsyncOptWithQueueSize(queueSize int) AsyncOption {
+	return func(o *asyncOptions) {
+		o.queueSize = queueSize
+	}
+}
+
+// AsyncOptWithErrorHandler 处理失败(包括panic)的回调, 已经回复过微信, 不会重试
+func AsyncOptWithErrorHandler(
+	errorHandler func(ctx context.Context, body []byte, err error),
+) AsyncOption {
+	return func(o *asyncOptions) {
+		o.errorHandler = errorHandler
+	}
+}
+
+type asyncItem struct {
+	ctx  context.Context
+	body []byte
+}
+
+// AsyncDispatcher 进程内的队列和协程池, 实现 CallbackQueue
+type AsyncDispatcher struct {
+	processor AsyncProcessor
+	options   asyncOptions
+	mu        sync.RWMutex
+	closed    bool
+	items     chan *asyncItem
+	wg        sync.WaitGroup
+}
+
+var _ CallbackQueue = (*AsyncDispatcher)(nil)
+
+func NewAsyncDispatcher(processor AsyncProcessor, options ...AsyncOption) *AsyncDispatcher {
+	to := asyncOptions{
+		workers:   defaultAsyncWorkers,
+		queueSize: defaultAsyncQueueSize,
+	}
+	for _, o := range options {
+		o(&to)
+	}
+	if to.workers <= 0 {
+		to.workers = defaultAsyncWorkers
+	}
+	if to.queueSize < 0 {
+		to.queueSize = 0
+	}
+
+	d := &AsyncDispatcher{
+		processor: processor,
+		options:   to,
+		items:     make(chan *asyncItem, to.queueSize),
+	}
+	d.wg.Add(to.workers)
+	for i := 0; i < to.workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// Enqueue 入队, 队列满或者已经关闭返回错误, 不会阻塞
+func (d *AsyncDispatcher) Enqueue(ctx context.Context, body []byte) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrorAsyncShutdown
+	}
+
+	select {
+	case d.items <- &asyncItem{ctx: ctx, body: body}:
+		return nil
+	default:
+		return ErrorAsyncQueueFull
+	}
+}
+
+func (d *AsyncDispatcher) work() {
+	defer d.wg.Done()
+	for item := range d.items {
+		if err := d.process(item); err != nil && d.options.errorHandler != nil {
+			d.options.errorHandler(item.ctx, item.body, err)
+		}
+	}
+}
+
+func (d *AsyncDispatcher) process(item *asyncItem) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("async callback panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return d.processor(item.ctx, item.body)
+}
+
+// Shutdown 停止接收新的消息, 等待队列中的消息处理完, 或者 ctx 取消
+func (d *AsyncDispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.items)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
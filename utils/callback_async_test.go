@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncDispatcher(t *testing.T) {
+	release := make(chan struct{})
+	var (
+		mu        sync.Mutex
+		processed []string
+		failures  []error
+	)
+	dispatcher := NewAsyncDispatcher(
+		func(_ context.Context, body []byte) error {
+			<-release
+			if string(body) == "panic" {
+				panic("boom")
+			}
+			mu.Lock()
+			processed = append(processed, string(body))
+			mu.Unlock()
+			return nil
+		},
+		AsyncOptWithWorkers(1),
+		AsyncOptWithQueueSize(2),
+		AsyncOptWithErrorHandler(func(_ context.Context, _ []byte, err error) {
+			mu.Lock()
+			failures = append(failures, err)
+			mu.Unlock()
+		}),
+	)
+
+	handler := NewAsyncXmlHandler(dispatcher, "success")
+	serve := func(body string) (string, error) {
+		w := httptest.NewRecorder()
+		err := handler(w, httptest.NewRequest(http.MethodPost, "/", nil), []byte(body))
+		return w.Body.String(), err
+	}
+
+	// 立即回复, 不等待处理
+	output, err := serve("panic")
+	require.Equal(t, nil, err)
+	require.Equal(t, "success", output)
+	require.Eventually(t, func() bool {
+		// worker 已经取走第一个消息
+		return len(dispatcher.items) == 0
+	}, time.Second, time.Millisecond)
+
+	for _, body := range []string{"a", "b"} {
+		_, err = serve(body)
+		require.Equal(t, nil, err)
+	}
+
+	// 队列满了, 不回复, 等待微信重试
+	output, err = serve("c")
+	require.True(t, errors.Is(err, ErrorAsyncQueueFull))
+	require.Equal(t, "", output)
+
+	// 关闭时处理完队列中的消息
+	close(release)
+	require.Equal(t, nil, dispatcher.Shutdown(context.Background()))
+	require.Equal(t, []string{"a", "b"}, processed)
+	require.Len(t, failures, 1)
+
+	_, err = serve("d")
+	require.True(t, errors.Is(err, ErrorAsyncShutdown))
+}
+
+func TestAsyncDispatcherShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	dispatcher := NewAsyncDispatcher(func(context.Context, []byte) error {
+		<-release
+		return nil
+	})
+	require.Equal(t, nil, dispatcher.Enqueue(context.Background(), []byte("a")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.True(t, errors.Is(dispatcher.Shutdown(ctx), context.DeadlineExceeded))
+}
@@ -0,0 +1,190 @@
+package utils
+
+// 回调的签名/加解密, 公众号/小程序, 企业微信, 开放平台, 企业微信服务商 共用
+// https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/api/Before_Develop/Message_encryption_and_decryption.html
+// https://developer.work.weixin.qq.com/document/path/90968
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var ErrorSignatureMismatch = errors.New("signature mismatch")
+
+type CDATA string
+
+func (c CDATA) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		string `xml:",cdata"`
+	}{string(c)}, start)
+}
+
+/*
+启用 加密模式 后 收到的 消息格式
+<xml>
+
+	<ToUserName><![CDATA[]]></ToUserName>
+	<Encrypt><![CDATA[]]></Encrypt>
+	<AgentID><![CDATA[]]></AgentID>
+
+</xml>
+*/
+type EncryptMessage struct {
+	XMLName    xml.Name `xml:"xml" json:"-"`
+	ToUserName string
+	Encrypt    string
+	AgentID    string `json:",omitempty"`
+}
+
+/*
+加密处理后 的 回复 消息体
+<xml>
+<Encrypt></Encrypt>
+<MsgSignature></MsgSignature>
+<TimeStamp></TimeStamp>
+<Nonce></Nonce>
+</xml>
+*/
+type ReplyEncryptMessage struct {
+	XMLName      xml.Name `xml:"xml" json:"-"`
+	Encrypt      CDATA
+	MsgSignature CDATA
+	TimeStamp    string
+	Nonce        CDATA
+}
+
+// CallbackCrypto 回调的签名校验和加解密
+type CallbackCrypto struct {
+	token          string
+	encodingAESKey string
+	receiverID     string   // 加密回复使用的 appid/corpid/suiteid
+	receiverIDs    []string // 解密之后允许的 receiverId, 为空不校验
+}
+
+// NewCallbackCrypto receiverID 用于加密回复, receiverIDs 用于校验解密之后的 receiverId
+func NewCallbackCrypto(
+	token, encodingAESKey, receiverID string, receiverIDs ...string,
+) *CallbackCrypto {
+	return &CallbackCrypto{
+		token:          token,
+		encodingAESKey: encodingAESKey,
+		receiverID:     receiverID,
+		receiverIDs:    receiverIDs,
+	}
+}
+
+// Sign 签名, sha1(sort(token, timestamp, nonce, data...))
+func (c *CallbackCrypto) Sign(timestamp, nonce string, data ...string) string {
+	return CalcSignature(append([]string{c.token, timestamp, nonce}, data...)...)
+}
+
+// VerifySignature 常量时间比较签名, 出错时不能返回正确的签名
+func (c *CallbackCrypto) VerifySignature(signature, timestamp, nonce string, data ...string) error {
+	if !SignatureEqual(signature, c.Sign(timestamp, nonce, data...)) {
+		return ErrorSignatureMismatch
+	}
+	return nil
+}
+
+// Decrypt 解密并校验 receiverId
+func (c *CallbackCrypto) Decrypt(cipherText string) ([]byte, string, error) {
+	return AESDecryptMsgWithReceiverID(cipherText, c.encodingAESKey, c.receiverIDs...)
+}
+
+// Encrypt 使用 receiverID 加密
+func (c *CallbackCrypto) Encrypt(plain []byte, receiverID string) (string, error) {
+	return AESEncryptMsg([]byte(GetRandString(16)), plain, receiverID, c.encodingAESKey)
+}
+
+// VerifyEcho 校验URL
+// 有 msg_signature(企业微信/服务商): 校验签名之后解密 echostr
+// 没有 msg_signature(公众号/小程序): 校验 signature 之后原样返回 echostr
+func (c *CallbackCrypto) VerifyEcho(query url.Values) ([]byte, error) {
+	timestamp, nonce := query.Get("timestamp"), query.Get("nonce")
+	echoStr := query.Get("echostr")
+	if echoStr == "" {
+		return nil, errors.New("empty echostr")
+	}
+
+	if msgSignature := query.Get("msg_signature"); msgSignature != "" {
+		if err := c.VerifySignature(msgSignature, timestamp, nonce, echoStr); err != nil {
+			return nil, err
+		}
+		msg, _, err := c.Decrypt(echoStr)
+		return msg, err
+	}
+
+	if err := c.VerifySignature(query.Get("signature"), timestamp, nonce); err != nil {
+		return nil, err
+	}
+	return []byte(echoStr), nil
+}
+
+// OpenEnvelope 校验 msg_signature 并解密, 返回明文和 receiverId
+func (c *CallbackCrypto) OpenEnvelope(
+	ctx context.Context, query url.Values, envelope *EncryptMessage,
+) (plain []byte, receiverID string, err error) {
+	_, span := StartCallbackSpan(ctx, "decrypt", attribute.String("weixin.tousername", envelope.ToUserName))
+	defer func() {
+		span.SetAttributes(attribute.String("weixin.receiver_id", receiverID))
+		EndSpan(span, err)
+	}()
+
+	if err = c.VerifySignature(
+		query.Get("msg_signature"), query.Get("timestamp"), query.Get("nonce"), envelope.Encrypt,
+	); err != nil {
+		return nil, "", err
+	}
+	return c.Decrypt(envelope.Encrypt)
+}
+
+// EncryptReply 加密被动回复, receiverID 为空时使用缺省值
+func (c *CallbackCrypto) EncryptReply(plain []byte, receiverID string) (*ReplyEncryptMessage, error) {
+	if receiverID == "" {
+		receiverID = c.receiverID
+	}
+	cipherText, err := c.Encrypt(plain, receiverID)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := GetRandString(6)
+	return &ReplyEncryptMessage{
+		Encrypt:      CDATA(cipherText),
+		MsgSignature: CDATA(c.Sign(timestamp, nonce, cipherText)),
+		TimeStamp:    timestamp,
+		Nonce:        CDATA(nonce),
+	}, nil
+}
+
+// WriteReply xml 序列化并加密 reply 之后写入, reply 为 nil 时写入 fallback
+func (c *CallbackCrypto) WriteReply(
+	w http.ResponseWriter, reply interface{}, receiverID, fallback string,
+) error {
+	if reply == nil {
+		_, err := io.WriteString(w, fallback)
+		return err
+	}
+
+	output, err := xml.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	message, err := c.EncryptReply(output, receiverID)
+	if err != nil {
+		return err
+	}
+	if output, err = xml.Marshal(message); err != nil {
+		return err
+	}
+	_, err = w.Write(output)
+	return err
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackCryptoEcho(t *testing.T) {
+	crypto := NewCallbackCrypto("token", testEncodingAESKey, "wxcorp", "wxcorp")
+
+	// 公众号, 原样返回
+	query := url.Values{}
+	query.Set("timestamp", "1348831860")
+	query.Set("nonce", "nonce")
+	query.Set("echostr", "echo")
+	query.Set("signature", crypto.Sign("1348831860", "nonce"))
+	echo, err := crypto.VerifyEcho(query)
+	require.Equal(t, nil, err)
+	require.Equal(t, []byte("echo"), echo)
+
+	query.Set("signature", "invalid")
+	_, err = crypto.VerifyEcho(query)
+	require.True(t, errors.Is(err, ErrorSignatureMismatch))
+	// 不能泄露正确的签名
+	require.False(t, strings.Contains(err.Error(), crypto.Sign("1348831860", "nonce")))
+
+	// 企业微信, 解密 echostr
+	echoStr, err := crypto.Encrypt([]byte("plain echo"), "wxcorp")
+	require.Equal(t, nil, err)
+	query.Set("echostr", echoStr)
+	query.Set("msg_signature", crypto.Sign("1348831860", "nonce", echoStr))
+	echo, err = crypto.VerifyEcho(query)
+	require.Equal(t, nil, err)
+	require.Equal(t, []byte("plain echo"), echo)
+
+	// receiveid 不一致
+	echoStr, err = crypto.Encrypt([]byte("plain echo"), "other")
+	require.Equal(t, nil, err)
+	query.Set("echostr", echoStr)
+	query.Set("msg_signature", crypto.Sign("1348831860", "nonce", echoStr))
+	_, err = crypto.VerifyEcho(query)
+	require.True(t, errors.Is(err, ErrorReceiverIDMismatch))
+}
+
+func TestCallbackCryptoReply(t *testing.T) {
+	crypto := NewCallbackCrypto("token", testEncodingAESKey, "wxsuite")
+
+	recorder := httptest.NewRecorder()
+	require.Equal(t, nil, crypto.WriteReply(recorder, nil, "", "success"))
+	require.Equal(t, "success", recorder.Body.String())
+
+	type reply struct {
+		XMLName xml.Name `xml:"xml"`
+		Content CDATA
+	}
+	recorder = httptest.NewRecorder()
+	require.Equal(t, nil, crypto.WriteReply(recorder, &reply{Content: "hello"}, "wxcorp", ""))
+
+	// 回复可以按照回调的格式解开
+	message := &EncryptMessage{}
+	require.Equal(t, nil, xml.Unmarshal(recorder.Body.Bytes(), message))
+	encrypted := &struct {
+		MsgSignature string
+		TimeStamp    string
+		Nonce        string
+	}{}
+	require.Equal(t, nil, xml.Unmarshal(recorder.Body.Bytes(), encrypted))
+
+	query := url.Values{}
+	query.Set("timestamp", encrypted.TimeStamp)
+	query.Set("nonce", encrypted.Nonce)
+	query.Set("msg_signature", encrypted.MsgSignature)
+	plain, receiverID, err := crypto.OpenEnvelope(context.Background(), query, message)
+	require.Equal(t, nil, err)
+	require.Equal(t, "wxcorp", receiverID)
+	require.Equal(t, "<xml><Content><![CDATA[hello]]></Content></xml>", string(plain))
+
+	query.Set("msg_signature", "invalid")
+	_, _, err = crypto.OpenEnvelope(context.Background(), query, message)
+	require.True(t, errors.Is(err, ErrorSignatureMismatch))
+}
@@ -0,0 +1,176 @@
+package utils
+
+// 回调去重, 5秒内没有响应, 微信会重试三次, 处理慢的回调会被重复处理(重复发券, 重复发消息)
+// 开始处理时记录key, 重复的回调直接回复, 处理失败删除key, 允许重试
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDedupeTTL       = 10 * time.Minute // 微信的重试在一分钟之内, 保留更久一点
+	defaultDedupeKeyPrefix = "weixin.callback.dedupe"
+	defaultDedupeReply     = "success"
+)
+
+// DedupeKeyFunc 从(解密之后的)回调内容生成去重的key, 空串表示不去重
+type DedupeKeyFunc func(body []byte) (string, error)
+
+// 生成去重key需要的字段, 兼容 消息/事件, 第三方平台/服务商 的推送
+type dedupeFields struct {
+	XMLName         xml.Name `xml:"xml"`
+	ToUserName      string
+	FromUserName    string
+	CreateTime      string
+	MsgId           string
+	MsgID           string
+	Event           string
+	ChangeType      string
+	EventKey        string
+	InfoType        string
+	TimeStamp       string
+	AuthorizerAppid string // 开放平台
+	AuthCorpId      string // 企业微信服务商
+	SuiteId         string // 企业微信服务商
+}
+
+// DefaultDedupeKey 缺省的key
+//
+//	消息: ToUserName + MsgId
+//	事件: ToUserName + FromUserName + CreateTime + Event (+ ChangeType + EventKey)
+//	第三方平台/服务商: InfoType + TimeStamp(CreateTime) (+ AuthorizerAppid / SuiteId + AuthCorpId)
+func DefaultDedupeKey(body []byte) (string, error) {
+	fields := &dedupeFields{}
+	if err := xml.Unmarshal(body, fields); err != nil {
+		return "", err
+	}
+
+	var parts []string
+	switch {
+	case fields.MsgId != "" || fields.MsgID != "":
+		parts = []string{"msg", fields.ToUserName, fields.MsgId + fields.MsgID}
+	case fields.InfoType != "":
+		timestamp := fields.TimeStamp
+		if timestamp == "" {
+			// 开放平台使用 CreateTime
+			timestamp = fields.CreateTime
+		}
+		parts = []string{
+			"info", fields.InfoType, timestamp,
+			fields.AuthorizerAppid, fields.SuiteId, fields.AuthCorpId,
+		}
+	case fields.Event != "":
+		parts = []string{
+			"event", fields.ToUserName, fields.FromUserName, fields.CreateTime,
+			fields.Event, fields.ChangeType, fields.EventKey,
+		}
+	default:
+		// 无法识别, 不去重
+		return "", nil
+	}
+	return strings.Join(parts, "."), nil
+}
+
+type (
+	DedupeOption  func(*dedupeOptions)
+	dedupeOptions struct {
+		ttl       time.Duration
+		keyPrefix string
+		keyFunc   DedupeKeyFunc
+		reply     string
+	}
+)
+
+// DedupeOptWithTTL key的保留时长
+func DedupeOptWithTTL(ttl time.Duration) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.ttl = ttl
+	}
+}
+
+// DedupeOptWithKeyPrefix key在缓存中的前缀, 多个应用共用缓存时可以区分
+func DedupeOptWithKeyPrefix(keyPrefix string) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.keyPrefix = keyPrefix
+	}
+}
+
+// DedupeOptWithKeyFunc 自定义key
+func DedupeOptWithKeyFunc(keyFunc DedupeKeyFunc) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.keyFunc = keyFunc
+	}
+}
+
+// DedupeOptWithReply 重复的回调回复的内容, 缺省 success, 企业微信应用回调可以设置为空串
+func DedupeOptWithReply(reply string) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.reply = reply
+	}
+}
+
+// NewDedupeXmlHandler 为 XmlHandlerFunc 增加去重
+// cache 实现了 CacheSetNX 时原子的记录key, 否则检查和记录不是原子操作, 并发的重复回调有极小的概率都被处理
+func NewDedupeXmlHandler(
+	cache Cache, next XmlHandlerFunc, options ...DedupeOption,
+) XmlHandlerFunc {
+	to := dedupeOptions{
+		ttl:       defaultDedupeTTL,
+		keyPrefix: defaultDedupeKeyPrefix,
+		keyFunc:   DefaultDedupeKey,
+		reply:     defaultDedupeReply,
+	}
+	for _, o := range options {
+		o(&to)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, body []byte) error {
+		key, err := to.keyFunc(body)
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			return next(w, r, body)
+		}
+
+		ctx := r.Context()
+		cacheKey := to.keyPrefix + "." + key
+		claimed, err := claimDedupeKey(ctx, cache, cacheKey, to.ttl)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			// 处理中或者已经处理过
+			_, err = io.WriteString(w, to.reply)
+			return err
+		}
+
+		if err = next(w, r, body); err != nil {
+			// 允许微信重试, 请求可能已经取消, 不使用请求的ctx
+			cache.Delete(context.Background(), cacheKey)
+			return err
+		}
+		return nil
+	}
+}
+
+// claimDedupeKey 记录key, 返回 false 表示key已经存在
+func claimDedupeKey(
+	ctx context.Context, cache Cache, key string, ttl time.Duration,
+) (bool, error) {
+	if setNX, ok := cache.(CacheSetNX); ok {
+		return setNX.SetNX(ctx, key, "1", ttl)
+	}
+
+	if cache.IsExist(ctx, key) {
+		return false, nil
+	}
+	if err := cache.Set(ctx, key, "1", ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
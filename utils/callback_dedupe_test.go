@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultDedupeKey(t *testing.T) {
+	testcases := []struct {
+		body string
+		key  string
+	}{
+		{
+			body: `<xml><ToUserName>to</ToUserName><FromUserName>from</FromUserName>
+<CreateTime>1348831860</CreateTime><MsgType>text</MsgType><MsgId>123</MsgId></xml>`,
+			key: "msg.to.123",
+		},
+		{
+			body: `<xml><ToUserName>to</ToUserName><FromUserName>from</FromUserName>
+<CreateTime>123456789</CreateTime><MsgType>event</MsgType><Event>subscribe</Event></xml>`,
+			key: "event.to.from.123456789.subscribe..",
+		},
+		{
+			body: `<xml><AppId>appid</AppId><CreateTime>1413192605</CreateTime>
+<InfoType>authorized</InfoType><AuthorizerAppid>wx123</AuthorizerAppid></xml>`,
+			key: "info.authorized.1413192605.wx123..",
+		},
+		{
+			body: `<xml><SuiteId>suite</SuiteId><InfoType>suite_ticket</InfoType>
+<TimeStamp>1403610513</TimeStamp></xml>`,
+			key: "info.suite_ticket.1403610513..suite.",
+		},
+		{
+			body: `<xml><ToUserName>to</ToUserName></xml>`,
+			key:  "",
+		},
+	}
+
+	for _, testcase := range testcases {
+		key, err := DefaultDedupeKey([]byte(testcase.body))
+		require.Equal(t, nil, err)
+		require.Equal(t, testcase.key, key)
+	}
+}
+
+func TestDedupeXmlHandler(t *testing.T) {
+	calls := 0
+	var failed error
+	handler := NewDedupeXmlHandler(
+		memory.NewMemory(),
+		func(w http.ResponseWriter, _ *http.Request, _ []byte) error {
+			calls++
+			if failed != nil {
+				return failed
+			}
+			_, err := io.WriteString(w, "processed")
+			return err
+		},
+	)
+
+	serve := func(body string) (string, error) {
+		w := httptest.NewRecorder()
+		err := handler(w, httptest.NewRequest(http.MethodPost, "/", nil), []byte(body))
+		return w.Body.String(), err
+	}
+
+	msg := `<xml><ToUserName>to</ToUserName><MsgType>text</MsgType><MsgId>1</MsgId></xml>`
+	output, err := serve(msg)
+	require.Equal(t, nil, err)
+	require.Equal(t, "processed", output)
+
+	// 重试
+	output, err = serve(msg)
+	require.Equal(t, nil, err)
+	require.Equal(t, "success", output)
+	require.Equal(t, 1, calls)
+
+	// 处理失败, 可以重试
+	failed = errors.New("failed")
+	msg2 := `<xml><ToUserName>to</ToUserName><MsgType>text</MsgType><MsgId>2</MsgId></xml>`
+	_, err = serve(msg2)
+	require.True(t, errors.Is(err, failed))
+	failed = nil
+	output, err = serve(msg2)
+	require.Equal(t, nil, err)
+	require.Equal(t, "processed", output)
+	require.Equal(t, 3, calls)
+}
+
+func TestDedupeXmlHandlerConcurrent(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	handler := NewDedupeXmlHandler(
+		memory.NewMemory(),
+		func(w http.ResponseWriter, _ *http.Request, _ []byte) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return nil
+		},
+	)
+
+	// 并发的重复回调只处理一次
+	msg := []byte(`<xml><ToUserName>to</ToUserName><MsgType>text</MsgType><MsgId>1</MsgId></xml>`)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			require.Equal(t, nil, handler(w, httptest.NewRequest(http.MethodPost, "/", nil), msg))
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
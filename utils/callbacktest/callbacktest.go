@@ -0,0 +1,278 @@
+// Package callbacktest 模拟微信的回调推送, 用于测试回调的处理
+//
+// 按照 公众号/小程序, 企业微信, 开放平台, 企业微信服务商 的格式生成签名(加密)的请求,
+// 通过 httptest 交给 http.Handler 处理, 并解密/校验被动回复
+package callbacktest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+var ErrorReplyNotEncrypted = errors.New("reply not encrypted")
+
+type kind int
+
+const (
+	kindOfficialAccount kind = iota
+	kindWxWork
+	kindWxOpen
+	kindWxWorkSuite
+)
+
+type (
+	Option  func(*options)
+	options struct {
+		path    string
+		plain   bool
+		agentID string
+		now     func() time.Time
+	}
+)
+
+// OptWithPath 请求的路径, 缺省 /callback
+func OptWithPath(path string) Option {
+	return func(o *options) {
+		o.path = path
+	}
+}
+
+// OptWithPlainText 公众号/小程序 明文模式, 不加密
+func OptWithPlainText() Option {
+	return func(o *options) {
+		o.plain = true
+	}
+}
+
+// OptWithAgentID 企业微信 加密消息中的 AgentID
+func OptWithAgentID(agentID int) Option {
+	return func(o *options) {
+		o.agentID = strconv.Itoa(agentID)
+	}
+}
+
+// OptWithNow 请求的时间戳, 用于测试防重放
+func OptWithNow(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// Simulator 模拟微信服务器推送回调
+type Simulator struct {
+	handler    http.Handler
+	kind       kind
+	receiverID string
+	crypto     *utils.CallbackCrypto
+	options    options
+}
+
+func newSimulator(
+	handler http.Handler, kind kind,
+	token, encodingAESKey, receiverID string,
+	opts []Option,
+) *Simulator {
+	to := options{
+		path: "/callback",
+		now:  time.Now,
+	}
+	for _, o := range opts {
+		o(&to)
+	}
+	return &Simulator{
+		handler:    handler,
+		kind:       kind,
+		receiverID: receiverID,
+		crypto:     utils.NewCallbackCrypto(token, encodingAESKey, receiverID),
+		options:    to,
+	}
+}
+
+// NewOfficialAccount 公众号/小程序, 第三方平台代收消息时 appid 为第三方平台的 appid
+func NewOfficialAccount(
+	handler http.Handler, token, encodingAESKey, appid string, opts ...Option,
+) *Simulator {
+	return newSimulator(handler, kindOfficialAccount, token, encodingAESKey, appid, opts)
+}
+
+// NewWxWork 企业微信应用回调, receiveid 为 corpid
+func NewWxWork(
+	handler http.Handler, token, encodingAESKey, corpID string, opts ...Option,
+) *Simulator {
+	return newSimulator(handler, kindWxWork, token, encodingAESKey, corpID, opts)
+}
+
+// NewWxOpen 开放平台授权事件, receiveid 为第三方平台的 appid
+func NewWxOpen(
+	handler http.Handler, token, encodingAESKey, componentAppid string, opts ...Option,
+) *Simulator {
+	return newSimulator(handler, kindWxOpen, token, encodingAESKey, componentAppid, opts)
+}
+
+// NewWxWorkSuite 企业微信服务商指令回调, receiveid 为 suiteid
+func NewWxWorkSuite(
+	handler http.Handler, token, encodingAESKey, suiteID string, opts ...Option,
+) *Simulator {
+	return newSimulator(handler, kindWxWorkSuite, token, encodingAESKey, suiteID, opts)
+}
+
+func (s *Simulator) encrypted() bool {
+	return s.kind != kindOfficialAccount || !s.options.plain
+}
+
+// marshal message 为 []byte/string 时原样使用, 其他类型(server_api 等包中的消息/事件)序列化为xml
+func marshal(message interface{}) ([]byte, error) {
+	switch m := message.(type) {
+	case []byte:
+		return m, nil
+	case string:
+		return []byte(m), nil
+	default:
+		return xml.Marshal(message)
+	}
+}
+
+// NewRequest 生成签名(加密)之后的回调请求
+func (s *Simulator) NewRequest(message interface{}) (*http.Request, error) {
+	body, err := marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := strconv.FormatInt(s.options.now().Unix(), 10)
+	nonce := utils.GetRandString(10)
+	query := url.Values{}
+	query.Set("timestamp", timestamp)
+	query.Set("nonce", nonce)
+	if s.kind == kindOfficialAccount || s.kind == kindWxOpen {
+		query.Set("signature", s.crypto.Sign(timestamp, nonce))
+	}
+
+	if s.encrypted() {
+		cipherText, err := s.crypto.Encrypt(body, s.receiverID)
+		if err != nil {
+			return nil, err
+		}
+		envelope := &utils.EncryptMessage{
+			ToUserName: s.receiverID,
+			Encrypt:    cipherText,
+		}
+		if s.kind == kindWxWork {
+			envelope.AgentID = s.options.agentID
+		}
+		if body, err = xml.Marshal(envelope); err != nil {
+			return nil, err
+		}
+		query.Set("msg_signature", s.crypto.Sign(timestamp, nonce, cipherText))
+		if s.kind == kindOfficialAccount || s.kind == kindWxOpen {
+			query.Set("encrypt_type", "aes")
+		}
+	}
+
+	r := httptest.NewRequest(
+		http.MethodPost, s.options.path+"?"+query.Encode(), bytes.NewReader(body),
+	)
+	r.Header.Set("Content-Type", "text/xml")
+	return r, nil
+}
+
+// Send 推送 message, 返回解密之后的回复
+func (s *Simulator) Send(message interface{}) (*Response, error) {
+	r, err := s.NewRequest(message)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, r)
+	response := &Response{Recorder: recorder, Body: recorder.Body.Bytes()}
+	if recorder.Code != http.StatusOK || !s.encrypted() {
+		return response, nil
+	}
+
+	if err = s.decryptReply(response); err != nil && !errors.Is(err, ErrorReplyNotEncrypted) {
+		return nil, err
+	}
+	return response, nil
+}
+
+// decryptReply 校验签名并解密被动回复, 回复 success/空串 时不处理
+func (s *Simulator) decryptReply(response *Response) error {
+	reply := &struct {
+		XMLName      xml.Name `xml:"xml"`
+		Encrypt      string
+		MsgSignature string
+		TimeStamp    string
+		Nonce        string
+	}{}
+	if err := xml.Unmarshal(response.Body, reply); err != nil || reply.Encrypt == "" {
+		return ErrorReplyNotEncrypted
+	}
+
+	if err := s.crypto.VerifySignature(
+		reply.MsgSignature, reply.TimeStamp, reply.Nonce, reply.Encrypt,
+	); err != nil {
+		return fmt.Errorf("reply %w", err)
+	}
+	body, receiverID, err := s.crypto.Decrypt(reply.Encrypt)
+	if err != nil {
+		return err
+	}
+	response.Encrypted = true
+	response.ReceiverID = receiverID
+	response.Body = body
+	return nil
+}
+
+// Echo 校验URL, 企业微信/服务商 的 echostr 为加密之后的 echo, 返回处理之后的回复
+func (s *Simulator) Echo(echo string) (*httptest.ResponseRecorder, error) {
+	timestamp := strconv.FormatInt(s.options.now().Unix(), 10)
+	nonce := utils.GetRandString(10)
+	query := url.Values{}
+	query.Set("timestamp", timestamp)
+	query.Set("nonce", nonce)
+
+	if s.kind == kindOfficialAccount {
+		query.Set("echostr", echo)
+		query.Set("signature", s.crypto.Sign(timestamp, nonce))
+	} else {
+		echoStr, err := s.crypto.Encrypt([]byte(echo), s.receiverID)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("echostr", echoStr)
+		query.Set("msg_signature", s.crypto.Sign(timestamp, nonce, echoStr))
+	}
+
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(
+		recorder, httptest.NewRequest(http.MethodGet, s.options.path+"?"+query.Encode(), nil),
+	)
+	return recorder, nil
+}
+
+// Response 回调的响应
+type Response struct {
+	Recorder   *httptest.ResponseRecorder
+	Body       []byte // 被动回复, 加密的回复为解密之后的内容
+	Encrypted  bool   // 回复是否加密
+	ReceiverID string // 加密回复的 receiveid
+}
+
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// Unmarshal 解析被动回复, 例如 server_api.ReplyMessageText
+func (r *Response) Unmarshal(v interface{}) error {
+	return xml.Unmarshal(r.Body, v)
+}
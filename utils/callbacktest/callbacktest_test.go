@@ -0,0 +1,128 @@
+package callbacktest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/lixinio/weixin/utils/callbacktest"
+	"github.com/lixinio/weixin/weixin/server_api"
+	wxwork_server_api "github.com/lixinio/weixin/wxwork/server_api"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken          = "token"
+	testEncodingAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+)
+
+func newOfficialAccountHandler(t *testing.T, server *server_api.ServerApi) http.Handler {
+	mux := server_api.NewMux(server)
+	mux.OnText(func(_ context.Context, msg *server_api.MessageText) (server_api.Reply, error) {
+		return &server_api.ReplyMessageText{
+			ReplyMessage: *msg.Reply(),
+			Content:      server_api.CDATA("echo " + msg.Content),
+		}, nil
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if r.Method == http.MethodGet {
+			err = server.ServeEcho(w, r)
+		} else {
+			err = server.ServeData(w, r, mux.ServeXML)
+		}
+		require.Equal(t, nil, err)
+	})
+}
+
+func textMessage() *server_api.MessageText {
+	msg := &server_api.MessageText{Content: "hello", MsgId: "1234567890123456"}
+	msg.ToUserName = "gh_123"
+	msg.FromUserName = "openid"
+	msg.CreateTime = "1348831860"
+	msg.MsgType = server_api.MsgTypeText
+	return msg
+}
+
+func TestOfficialAccount(t *testing.T) {
+	server := server_api.NewApi("wxappid", testToken, testEncodingAESKey, nil)
+	handler := newOfficialAccountHandler(t, server)
+
+	// 加密模式
+	simulator := callbacktest.NewOfficialAccount(handler, testToken, testEncodingAESKey, "wxappid")
+	response, err := simulator.Send(textMessage())
+	require.Equal(t, nil, err)
+	require.True(t, response.Encrypted)
+	require.Equal(t, "wxappid", response.ReceiverID)
+
+	reply := &server_api.ReplyMessageText{}
+	require.Equal(t, nil, response.Unmarshal(reply))
+	require.Equal(t, server_api.CDATA("echo hello"), reply.Content)
+	require.Equal(t, server_api.CDATA("openid"), reply.ToUserName)
+
+	// 明文模式
+	simulator = callbacktest.NewOfficialAccount(
+		handler, testToken, testEncodingAESKey, "wxappid", callbacktest.OptWithPlainText(),
+	)
+	response, err = simulator.Send(textMessage())
+	require.Equal(t, nil, err)
+	require.False(t, response.Encrypted)
+	require.Equal(t, nil, response.Unmarshal(reply))
+	require.Equal(t, server_api.CDATA("echo hello"), reply.Content)
+
+	// 没有处理函数的事件
+	event := &server_api.EventSubscribe{}
+	event.MsgType = server_api.MsgTypeEvent
+	event.Event.Event = server_api.EventTypeSubscribe
+	response, err = simulator.Send(event)
+	require.Equal(t, nil, err)
+	require.Equal(t, "success", response.String())
+
+	recorder, err := simulator.Echo("echo")
+	require.Equal(t, nil, err)
+	require.Equal(t, "echo", recorder.Body.String())
+}
+
+func TestWxWork(t *testing.T) {
+	server := wxwork_server_api.NewApi(1000002, testToken, testEncodingAESKey, "wxcorp")
+	mux := wxwork_server_api.NewMux(server)
+	mux.OnText(func(
+		_ context.Context, msg *wxwork_server_api.MessageText,
+	) (wxwork_server_api.Reply, error) {
+		return &wxwork_server_api.ReplyMessageText{
+			ReplyMessage: *msg.Reply(),
+			Content:      wxwork_server_api.CDATA("echo " + msg.Content),
+		}, nil
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if r.Method == http.MethodGet {
+			err = server.ServeEcho(w, r)
+		} else {
+			err = server.ServeData(w, r, mux.ServeXML)
+		}
+		require.Equal(t, nil, err)
+	})
+
+	simulator := callbacktest.NewWxWork(
+		handler, testToken, testEncodingAESKey, "wxcorp", callbacktest.OptWithAgentID(1000002),
+	)
+	msg := &wxwork_server_api.MessageText{Content: "hello"}
+	msg.ToUserName = "wxcorp"
+	msg.FromUserName = "userid"
+	msg.MsgType = wxwork_server_api.MsgTypeText
+	msg.AgentID = "1000002"
+
+	response, err := simulator.Send(msg)
+	require.Equal(t, nil, err)
+	require.True(t, response.Encrypted)
+	require.Equal(t, "wxcorp", response.ReceiverID)
+	reply := &wxwork_server_api.ReplyMessageText{}
+	require.Equal(t, nil, response.Unmarshal(reply))
+	require.Equal(t, wxwork_server_api.CDATA("echo hello"), reply.Content)
+
+	recorder, err := simulator.Echo("echo")
+	require.Equal(t, nil, err)
+	require.Equal(t, "echo", recorder.Body.String())
+}
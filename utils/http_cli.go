@@ -29,6 +29,9 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -40,6 +43,26 @@ type ClientAccessTokenGetter interface {
 	GetAccessToken(context.Context) (string, error)
 }
 
+// ClientAccessTokenInvalidator 可选接口, 微信返回token失效(40001/40014/42001等)时,
+// Client 调用该接口清除缓存的token, 然后用新的token重放一次请求
+type ClientAccessTokenInvalidator interface {
+	InvalidateAccessToken(context.Context, string) error
+}
+
+type tokenRetryContext int
+
+var tokenRetryContextKey = tokenRetryContext(0)
+
+// NewTokenRetryDisabledContext token失效时不重放请求(比如不希望重复读取上传的内容)
+func NewTokenRetryDisabledContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tokenRetryContextKey, true)
+}
+
+func isTokenRetryDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(tokenRetryContextKey).(bool)
+	return disabled
+}
+
 type EmptyClientAccessTokenGetter int
 
 type MultipartWriter func(writer *multipart.Writer) error
@@ -66,19 +89,147 @@ type Client struct {
 	userAgent         string
 	accessTokenKey    string
 	accessTokenGetter ClientAccessTokenGetter
+	httpClient        *http.Client
+	stripTransport    *AccessTokenStripTransport
+	retryPolicy       *RetryPolicy
+	appid             string
+	telemetry         *telemetry
+}
+
+type (
+	// RoundTripperMiddleware 包装 http.RoundTripper, 用于日志, 限流, 测试等
+	RoundTripperMiddleware func(http.RoundTripper) http.RoundTripper
+	// ClientOption Client的可选配置
+	ClientOption  func(*clientOptions)
+	clientOptions struct {
+		serverUrl   string
+		httpClient  *http.Client
+		transport   http.RoundTripper
+		middlewares []RoundTripperMiddleware
+		retryPolicy *RetryPolicy
+		appid       string
+		tracer      trace.TracerProvider
+		meter       metric.MeterProvider
+	}
+)
+
+// ClientOptWithHTTPClient 使用自定义的 http.Client (超时, cookie等)
+// Transport 会被包装(access token 脱敏), 原对象不会被修改
+func ClientOptWithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = httpClient
+	}
+}
+
+// ClientOptWithTransport 使用自定义的 http.RoundTripper (代理, 连接池, mTLS等)
+// 优先级高于 ClientOptWithHTTPClient 中的 Transport
+func ClientOptWithTransport(transport http.RoundTripper) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+// ClientOptWithMiddleware 在 Transport 外层追加中间件, 先添加的在最外层
+func ClientOptWithMiddleware(middlewares ...RoundTripperMiddleware) ClientOption {
+	return func(o *clientOptions) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// ClientOptWithServerUrl 替换微信服务器的地址, 用于测试(mockserver)或者代理
+func ClientOptWithServerUrl(serverUrl string) ClientOption {
+	return func(o *clientOptions) {
+		o.serverUrl = serverUrl
+	}
 }
 
-func NewClient(serverUrl string, accessTokenGetter ClientAccessTokenGetter) *Client {
-	return &Client{
+// ClientOptWithAppID 记录到 trace/metrics 中的 appid(corpid, suiteid)
+func ClientOptWithAppID(appid string) ClientOption {
+	return func(o *clientOptions) {
+		o.appid = appid
+	}
+}
+
+// ClientOptWithTracerProvider OpenTelemetry 的 TracerProvider, 缺省使用全局的
+func ClientOptWithTracerProvider(tracerProvider trace.TracerProvider) ClientOption {
+	return func(o *clientOptions) {
+		o.tracer = tracerProvider
+	}
+}
+
+// ClientOptWithMeterProvider OpenTelemetry 的 MeterProvider, 缺省使用全局的
+func ClientOptWithMeterProvider(meterProvider metric.MeterProvider) ClientOption {
+	return func(o *clientOptions) {
+		o.meter = meterProvider
+	}
+}
+
+func NewClient(
+	serverUrl string,
+	accessTokenGetter ClientAccessTokenGetter,
+	options ...ClientOption,
+) *Client {
+	co := &clientOptions{}
+	for _, o := range options {
+		o(co)
+	}
+	if co.serverUrl != "" {
+		serverUrl = strings.TrimSuffix(co.serverUrl, "/")
+	}
+
+	httpClient := &http.Client{}
+	if co.httpClient != nil {
+		// 复制一份， 避免修改调用方的对象
+		*httpClient = *co.httpClient
+	}
+
+	transport := co.transport
+	if transport == nil {
+		transport = httpClient.Transport
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// access token 脱敏放在中间件的里层, 中间件(otelhttp 等)创建的 span 也会被脱敏
+	stripTransport := NewAccessTokenStripTransport(defaultTokenKey)
+	transport = stripTransport.wrap(transport)
+	for i := len(co.middlewares) - 1; i >= 0; i-- {
+		transport = co.middlewares[i](transport)
+		mustRedactable(transport)
+	}
+	httpClient.Transport = transport
+
+	client := &Client{
 		serverUrl:         serverUrl,
 		userAgent:         userAgent,
 		accessTokenKey:    defaultTokenKey,
 		accessTokenGetter: accessTokenGetter,
+		httpClient:        httpClient,
+		stripTransport:    stripTransport,
+		retryPolicy:       co.retryPolicy,
+		appid:             co.appid,
+		telemetry:         clientTelemetry(co),
+	}
+	if co.tracer != nil || co.meter != nil {
+		// token 刷新使用和接口调用相同的 provider
+		if t, ok := accessTokenGetter.(interface{ useTelemetry(*telemetry) }); ok {
+			t.useTelemetry(client.telemetry)
+		}
+	}
+	return client
+}
+
+func clientTelemetry(co *clientOptions) *telemetry {
+	if co.tracer == nil && co.meter == nil {
+		return defaultTelemetry()
 	}
+	return newTelemetry(co.tracer, co.meter)
 }
 
 func (client *Client) UpdateAccessTokenKey(accessTokenKey string) {
 	client.accessTokenKey = accessTokenKey
+	client.stripTransport.defaultKey = accessTokenKey
 }
 
 // HTTPGet GET 请求
@@ -95,19 +246,10 @@ func (client *Client) HTTPGetWithParams(
 	querysFunc func(url.Values),
 	result interface{},
 ) (err error) {
-	newPath, err := client.applyAccessToken(ctx, uri, querysFunc, true)
-	if err != nil {
-		return
-	}
-
-	req, err := http.NewRequestWithContext(
-		ctx, http.MethodGet, client.serverUrl+newPath, nil,
+	return client.doRequest(
+		ctx, http.MethodGet, uri, querysFunc, true, nil, nil,
+		client.jsonResponseHandler(result),
 	)
-	if err != nil {
-		return
-	}
-
-	return client.httpDo(ctx, req, result)
 }
 
 // 用来 刷新Token 等不用access-token的接口
@@ -117,25 +259,13 @@ func (client *Client) HTTPGetToken(
 	querysFunc func(url.Values),
 	result interface{},
 ) (err error) {
-	newPath, err := client.applyAccessToken(ctx, uri, querysFunc, false)
-	if err != nil {
-		return
-	}
-
-	// 调用http请求
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.serverUrl+newPath, nil)
-	if err != nil {
-		return
-	}
-
-	resp, err := client.httpDoRaw(ctx, req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	return doWeixinError(resp.Body, result)
+	return client.doRequest(
+		ctx, http.MethodGet, uri, querysFunc, false, nil, nil,
+		func(resp *http.Response) error {
+			defer resp.Body.Close()
+			return doWeixinError(resp.Body, result)
+		},
+	)
 }
 
 // 素材下载， 需要根据Content-Type来判断Body， 可以是json，可能是二进制
@@ -143,37 +273,13 @@ func (client *Client) HTTPGetToken(
 func (client *Client) HTTPGetRaw(
 	ctx context.Context, uri string, querysFunc func(url.Values),
 ) (resp *http.Response, err error) {
-	newPath, err := client.applyAccessToken(ctx, uri, querysFunc, true)
-	if err != nil {
-		return
-	}
-
-	// 调用http请求
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.serverUrl+newPath, nil)
-	if err != nil {
-		return
-	}
-
-	resp, err = client.httpDoRaw(ctx, req)
+	err = client.doRequest(
+		ctx, http.MethodGet, uri, querysFunc, true, nil, nil,
+		rawResponseHandler(&resp),
+	)
 	if err != nil {
 		return nil, err
 	}
-
-	// 如果Content-Type 是 Json, 那出错了
-	if hasTextContentType(resp) {
-		defer resp.Body.Close()
-		result := &WeixinError{}
-		if err = doWeixinError(resp.Body, result); err != nil {
-			return nil, err
-		} else {
-			// wtf
-			panic(fmt.Errorf(
-				"request (%s) response invalid json response(%d: %s)",
-				req.URL.Path, result.ErrCode, result.ErrMsg,
-			))
-		}
-	}
-
 	return resp, nil
 }
 
@@ -198,50 +304,25 @@ func (client *Client) HTTPPostDownload(
 	ctx context.Context, uri string,
 	body interface{}, querysFunc func(url.Values),
 ) (resp *http.Response, err error) {
-	newPath, err := client.applyAccessToken(ctx, uri, querysFunc, true)
-	if err != nil {
-		return
-	}
-
 	payload, err := jsonMarshal(body)
 	if err != nil {
 		return nil, err
 	}
 
-	// 调用http请求
-	req, err := http.NewRequestWithContext(
-		ctx, http.MethodPost, client.serverUrl+newPath, payload,
+	err = client.doRequest(
+		ctx, http.MethodPost, uri, querysFunc, true, payload, nil,
+		rawResponseHandler(&resp),
 	)
-	if err != nil {
-		return
-	}
-
-	resp, err = client.httpDoRaw(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-
-	// 如果Content-Type 是 Json, 那出错了
-	if hasTextContentType(resp) {
-		defer resp.Body.Close()
-		result := &WeixinError{}
-		if err = doWeixinError(resp.Body, result); err != nil {
-			return nil, err
-		} else {
-			// wtf
-			panic(fmt.Errorf(
-				"request (%s) response invalid json response(%d: %s)",
-				req.URL.Path, result.ErrCode, result.ErrMsg,
-			))
-		}
-	}
-
 	return resp, nil
 }
 
 // HTTPPost POST 请求, 一次性上传, 优先使用 HttpFile
 // 发票上传接口不支持分块(Go Http Client库缺省的方式)
 // https://developer.mozilla.org/zh-CN/docs/Web/HTTP/Headers/Transfer-Encoding
+// 如果 payload 实现了 io.Seeker, token失效时可以重放请求
 func (client *Client) HTTPUpload(
 	ctx context.Context, uri string, payload io.Reader,
 	key, filename string, length int64,
@@ -265,55 +346,72 @@ func (client *Client) HTTPUpload(
 	// 尾部
 	closeBuffer := bytes.NewBufferString(fmt.Sprintf("\r\n--%s--\r\n", bodyWriter.Boundary()))
 
-	newUrl, err := client.applyAccessToken(ctx, uri, querysFunc, true)
-	if err != nil {
-		return err
+	header, trailer := bodyBuffer.Bytes(), closeBuffer.Bytes()
+	newBody := func() io.ReadCloser {
+		return io.NopCloser(io.MultiReader(
+			bytes.NewReader(header), payload, bytes.NewReader(trailer),
+		))
 	}
 
-	reader := io.MultiReader(bodyBuffer, payload, closeBuffer)
-
-	req, err := http.NewRequestWithContext(
-		ctx, http.MethodPost, client.serverUrl+newUrl, io.NopCloser(reader),
+	return client.doRequest(
+		ctx, http.MethodPost, uri, querysFunc, true, newBody(),
+		func(req *http.Request) {
+			req.TransferEncoding = []string{"identity"}
+			req.Header.Add("Content-Type", bodyWriter.FormDataContentType())
+			req.ContentLength = length + int64(len(header)) + int64(len(trailer))
+			req.GetBody = seekableGetBody(payload, newBody)
+		},
+		client.jsonResponseHandler(result),
 	)
-	if err != nil {
-		return err
-	}
-	req.TransferEncoding = []string{"identity"}
-	req.Header.Add("Content-Type", bodyWriter.FormDataContentType())
-	req.ContentLength = length + int64(closeBuffer.Len()) + int64(bodyBuffer.Len())
-
-	return client.httpDo(ctx, req, result)
 }
 
 // Upload 上传文件
 // HttpFile 上传文件, 适合没有什么定制的文件上传
+// 如果 content 实现了 io.Seeker, token失效时可以重放请求
 func (client *Client) HttpFile(
 	ctx context.Context, uri, key, filename string,
 	content io.Reader, querysFunc func(url.Values), result interface{},
 	multipartWriters ...MultipartWriter,
 ) (err error) {
-	r, w := io.Pipe()
-	m := multipart.NewWriter(w)
-	go func() {
-		defer w.Close()
-		defer m.Close()
-
-		for _, multipartWriter := range multipartWriters {
-			if err = multipartWriter(m); err != nil {
-				return
+	// 重放时使用相同的 boundary, 保证 Content-Type 一致
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	newBody := func() io.ReadCloser {
+		r, w := io.Pipe()
+		m := multipart.NewWriter(w)
+		_ = m.SetBoundary(boundary)
+		go func() {
+			var err error
+			defer func() {
+				if err == nil {
+					err = m.Close()
+				}
+				w.CloseWithError(err)
+			}()
+
+			for _, multipartWriter := range multipartWriters {
+				if err = multipartWriter(m); err != nil {
+					return
+				}
 			}
-		}
-
-		part, err := m.CreateFormFile(key, filepath.Base(filename))
-		if err != nil {
-			return
-		}
-		if _, err = io.Copy(part, content); err != nil {
-			return
-		}
-	}()
 
-	return client.HTTPPostRaw(ctx, uri, r, querysFunc, result, m.FormDataContentType(), true)
+			var part io.Writer
+			part, err = m.CreateFormFile(key, filepath.Base(filename))
+			if err != nil {
+				return
+			}
+			_, err = io.Copy(part, content)
+		}()
+		return r
+	}
+
+	return client.doRequest(
+		ctx, http.MethodPost, uri, querysFunc, true, newBody(),
+		func(req *http.Request) {
+			req.Header.Add("Content-Type", "multipart/form-data; boundary="+boundary)
+			req.GetBody = seekableGetBody(content, newBody)
+		},
+		client.jsonResponseHandler(result),
+	)
 }
 
 // HTTPPost POST 请求
@@ -349,45 +447,79 @@ func (client *Client) HTTPPostToken(
 }
 
 // HTTPPostRaw POST 请求, 不做内容的序列化， 适合特殊的文件上传
+// payload 为 *bytes.Buffer, *bytes.Reader, *strings.Reader 时, token失效可以重放请求
 func (client *Client) HTTPPostRaw(
 	ctx context.Context, uri string, payload io.Reader, querysFunc func(url.Values),
 	result interface{}, contentType string, auth bool,
 ) (err error) {
-	newPath, err := client.applyAccessToken(ctx, uri, querysFunc, auth)
-	if err != nil {
-		return
-	}
+	return client.doRequest(
+		ctx, http.MethodPost, uri, querysFunc, auth, payload,
+		func(req *http.Request) {
+			if contentType == "" {
+				req.Header.Add("Content-Type", "application/json;charset=utf-8")
+			} else {
+				req.Header.Add("Content-Type", contentType)
+			}
+		},
+		client.jsonResponseHandler(result),
+	)
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.serverUrl+newPath, payload)
-	if err != nil {
-		return
+// jsonResponseHandler 结果反序列化， 适合返回json的普通请求
+func (client *Client) jsonResponseHandler(result interface{}) func(*http.Response) error {
+	return func(response *http.Response) error {
+		defer response.Body.Close()
+		weixinResult := result
+		if result == nil {
+			// 如果上层并不关心实际的响应, 就简单的判断腾讯的Code
+			weixinResult = &WeixinError{}
+		}
+
+		return doWeixinError(response.Body, weixinResult)
 	}
+}
 
-	if contentType == "" {
-		req.Header.Add("Content-Type", "application/json;charset=utf-8")
-	} else {
-		req.Header.Add("Content-Type", contentType)
+// rawResponseHandler 不做反序列化, 只有出错(json)的时候才读取body
+func rawResponseHandler(out **http.Response) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		// 如果Content-Type 是 Json, 那出错了
+		if hasTextContentType(resp) {
+			defer resp.Body.Close()
+			result := &WeixinError{}
+			if err := doWeixinError(resp.Body, result); err != nil {
+				return err
+			} else {
+				// wtf
+				panic(fmt.Errorf(
+					"request (%s) response invalid json response(%d: %s)",
+					resp.Request.URL.Path, result.ErrCode, result.ErrMsg,
+				))
+			}
+		}
+
+		*out = resp
+		return nil
 	}
-	return client.httpDo(ctx, req, result)
 }
 
-// httpDo httpDoRaw加上结果反序列化， 适合返回json的普通请求
-func (client *Client) httpDo(
-	ctx context.Context, req *http.Request, result interface{},
-) (err error) {
-	response, err := client.httpDoRaw(ctx, req)
+// seekableGetBody 如果reader可以seek, 返回用于重放请求的 GetBody
+func seekableGetBody(
+	reader io.Reader, newBody func() io.ReadCloser,
+) func() (io.ReadCloser, error) {
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return nil
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return
+		return nil
 	}
-
-	defer response.Body.Close()
-	weixinResult := result
-	if result == nil {
-		// 如果上层并不关心实际的响应, 就简单的判断腾讯的Code
-		weixinResult = &WeixinError{}
+	return func() (io.ReadCloser, error) {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return newBody(), nil
 	}
-
-	return doWeixinError(response.Body, weixinResult)
 }
 
 func hasTextContentType(resp *http.Response) bool {
@@ -416,6 +548,13 @@ func doWeixinError(reader io.Reader, result interface{}) error {
 		fmt.Println("")
 	*/
 
+	// 重放(token失效, 重试)时 result 会被复用, 成功的响应可能没有 errcode, 先清除上次的错误
+	if we, ok := result.(WeixinErrorInterface); ok {
+		if e, ok := we.GetWeixinError().(*WeixinError); ok {
+			*e = WeixinError{}
+		}
+	}
+
 	// 直接从body反序列化， 无需先读取到内存
 	if err := json.NewDecoder(reader).Decode(result); err != nil {
 		return err
@@ -434,17 +573,99 @@ func doWeixinError(reader io.Reader, result interface{}) error {
 		return nil
 	}
 
-	if wxCode == 40014 {
-		// 不合法的access_token
-		// https://open.work.weixin.qq.com/devtool/query?e=40014
-		return fmt.Errorf("error get token %s, error %w", we.WeixinErrorMessage(), ErrorAccessToken)
-	} else if wxCode == -1 {
-		//  -1	系统繁忙，服务器暂不可用，建议稍候重试。建议重试次数不超过3次。
-		// https://open.work.weixin.qq.com/devtool/query?e=40014
-		return fmt.Errorf("error get token %s, error %w", we.WeixinErrorMessage(), ErrorSystemBusy)
-	} else {
-		return we.GetWeixinError()
+	// 错误码分类, 参考 WeixinError.Is
+	//   40014 不合法的access_token => errors.Is(err, ErrorAccessToken)
+	//   -1 系统繁忙，服务器暂不可用，建议稍候重试 => errors.Is(err, ErrorSystemBusy)
+	// https://open.work.weixin.qq.com/devtool/query?e=40014
+	return we.GetWeixinError()
+}
+
+// doRequest 构造请求并发送, 需要认证的请求如果返回token失效,
+// 清除缓存的token(ClientAccessTokenInvalidator), 用新的token重放一次
+func (client *Client) doRequest(
+	ctx context.Context,
+	method, uri string,
+	querysFunc func(url.Values),
+	auth bool,
+	body io.Reader,
+	prepare func(*http.Request),
+	handler func(*http.Response) error,
+) (err error) {
+	ctx, call := client.telemetry.startCall(ctx, method, uri, client.appid)
+	defer func() {
+		call.end(ctx, err)
+	}()
+
+	newPath, accessToken, err := client.applyAccessToken(ctx, uri, querysFunc, auth)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, client.serverUrl+newPath, body)
+	if err != nil {
+		return err
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+
+	err = client.sendWithRetry(ctx, req, handler)
+	if err == nil || !auth || !errors.Is(err, ErrorAccessToken) {
+		return err
+	}
+
+	// token失效, 尝试清除缓存的token并重放
+	invalidator, ok := client.accessTokenGetter.(ClientAccessTokenInvalidator)
+	if !ok || isTokenRetryDisabled(ctx) || !isReplayable(req) {
+		return err
+	}
+	if e := invalidator.InvalidateAccessToken(ctx, accessToken); e != nil {
+		return err
+	}
+
+	newPath, _, e := client.applyAccessToken(ctx, uri, querysFunc, auth)
+	if e != nil {
+		return err
+	}
+	retry, e := cloneRequest(ctx, req, client.serverUrl+newPath)
+	if e != nil {
+		return err
+	}
+	call.replayed()
+	return client.sendWithRetry(ctx, retry, handler)
+}
+
+func (client *Client) send(
+	ctx context.Context, req *http.Request, handler func(*http.Response) error,
+) error {
+	callTelemetryFromContext(ctx).attempt(ctx, req.URL, client.accessTokenKey)
+	resp, err := client.httpDoRaw(ctx, req)
+	if err != nil {
+		return err
+	}
+	return handler(resp)
+}
+
+// isReplayable 请求body是否可以重放
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// cloneRequest 使用新的地址(新的token)复制请求
+func cloneRequest(ctx context.Context, req *http.Request, newUrl string) (*http.Request, error) {
+	u, err := url.Parse(newUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(ctx)
+	retry.URL = u
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
 	}
+	return retry, nil
 }
 
 // httpDoRaw 执行具体的请求发送， 处理认证， user-agent, trace, 判断http code等细节
@@ -452,10 +673,9 @@ func doWeixinError(reader io.Reader, result interface{}) error {
 func (client *Client) httpDoRaw(
 	_ context.Context, req *http.Request,
 ) (resp *http.Response, err error) {
-	req.Header.Add("User-Agent", client.userAgent)
-	cli := &http.Client{Transport: NewAccessTokenStripTransport(client.accessTokenKey)}
+	req.Header.Set("User-Agent", client.userAgent)
 
-	resp, err = cli.Do(req)
+	resp, err = client.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -463,7 +683,7 @@ func (client *Client) httpDoRaw(
 	// 根据规范，有些接口返回20x，这里暂不考虑
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		_ = resp.Body.Close()
-		err = fmt.Errorf("status %s", resp.Status)
+		err = &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 		resp = nil
 		return
 	}
@@ -479,7 +699,7 @@ func (client *Client) applyAccessToken(
 	oldUrl string,
 	querysFunc func(url.Values),
 	auth bool,
-) (newUrl string, err error) {
+) (newUrl, accessToken string, err error) {
 	querys := url.Values{}
 	// 客户自定义
 	if querysFunc != nil {
@@ -488,14 +708,14 @@ func (client *Client) applyAccessToken(
 
 	// 认证
 	if auth {
-		accessToken, err := client.accessTokenGetter.GetAccessToken(ctx)
+		accessToken, err = client.accessTokenGetter.GetAccessToken(ctx)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 
 		querys.Add(client.accessTokenKey, accessToken)
 	} else if len(querys) == 0 {
-		return oldUrl, nil
+		return oldUrl, "", nil
 	}
 
 	if strings.Contains(oldUrl, "?") {
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClientOption(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "token123", r.URL.Query().Get("access_token"))
+		require.Equal(t, "outer,inner", r.Header.Get("X-Middleware"))
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	transportCalled := 0
+	middleware := func(name string) RoundTripperMiddleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if v := req.Header.Get("X-Middleware"); v != "" {
+					name = v + "," + name
+				}
+				req.Header.Set("X-Middleware", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	client := NewClient(
+		server.URL,
+		StaticClientAccessTokenGetter("token123"),
+		ClientOptWithTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			transportCalled++
+			return http.DefaultTransport.RoundTrip(req)
+		})),
+		ClientOptWithMiddleware(middleware("outer"), middleware("inner")),
+	)
+
+	err := client.HTTPGet(context.Background(), "/cgi-bin/test", nil)
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, transportCalled)
+
+	// 修改 token 名称之后, 脱敏的 key 也要同步
+	client.UpdateAccessTokenKey("component_access_token")
+	require.Equal(t, "component_access_token", client.stripTransport.defaultKey)
+}
+
+type rotateTokenGetter struct {
+	tokens      []string
+	invalidated []string
+}
+
+func (g *rotateTokenGetter) GetAccessToken(context.Context) (string, error) {
+	return g.tokens[len(g.invalidated)], nil
+}
+
+func (g *rotateTokenGetter) InvalidateAccessToken(_ context.Context, token string) error {
+	g.invalidated = append(g.invalidated, token)
+	return nil
+}
+
+func TestClientTokenRetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("access_token") == "stale" {
+			io.WriteString(w, `{"errcode":40014,"errmsg":"invalid access_token"}`)
+			return
+		}
+		if r.Method == http.MethodPost {
+			_, _, err := r.FormFile("media")
+			if err != nil {
+				body, _ := io.ReadAll(r.Body)
+				require.Equal(t, "{\"a\":1}\n", string(body))
+			}
+		}
+		if r.URL.Path == "/no_errcode" {
+			io.WriteString(w, `{"id":1}`)
+			return
+		}
+		io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	// GET 请求, 清除失效的token之后重试
+	getter := &rotateTokenGetter{tokens: []string{"stale", "fresh"}}
+	client := NewClient(server.URL, getter)
+	require.Equal(t, nil, client.HTTPGet(ctx, "/get", nil))
+	require.Equal(t, []string{"stale"}, getter.invalidated)
+
+	// 成功的响应没有 errcode, 不能保留上次的错误
+	getter = &rotateTokenGetter{tokens: []string{"stale", "fresh"}}
+	client = NewClient(server.URL, getter)
+	result := &struct {
+		WeixinError
+		ID int `json:"id"`
+	}{}
+	require.Equal(t, nil, client.HTTPGet(ctx, "/no_errcode", result))
+	require.Equal(t, 1, result.ID)
+
+	// POST json 可以重放
+	getter = &rotateTokenGetter{tokens: []string{"stale", "fresh"}}
+	client = NewClient(server.URL, getter)
+	require.Equal(t, nil, client.HTTPPostJson(ctx, "/post", map[string]int{"a": 1}, nil))
+	require.Equal(t, []string{"stale"}, getter.invalidated)
+
+	// 可以seek的文件内容, 可以重放
+	getter = &rotateTokenGetter{tokens: []string{"stale", "fresh"}}
+	client = NewClient(server.URL, getter)
+	err := client.HttpFile(ctx, "/upload", "media", "a.txt", strings.NewReader("abc"), nil, nil)
+	require.Equal(t, nil, err)
+	require.Equal(t, []string{"stale"}, getter.invalidated)
+
+	// 不能seek的内容, 不重放
+	getter = &rotateTokenGetter{tokens: []string{"stale", "fresh"}}
+	client = NewClient(server.URL, getter)
+	err = client.HttpFile(
+		ctx, "/upload", "media", "a.txt", io.LimitReader(strings.NewReader("abc"), 3), nil, nil,
+	)
+	require.True(t, errors.Is(err, ErrorAccessToken))
+	require.Equal(t, 0, len(getter.invalidated))
+
+	// 主动关闭重放
+	getter = &rotateTokenGetter{tokens: []string{"stale", "fresh"}}
+	client = NewClient(server.URL, getter)
+	err = client.HTTPGet(NewTokenRetryDisabledContext(ctx), "/get", nil)
+	require.True(t, errors.Is(err, ErrorAccessToken))
+	require.Equal(t, 0, len(getter.invalidated))
+}
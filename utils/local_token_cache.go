@@ -0,0 +1,230 @@
+package utils
+
+// 进程内的token缓存(一级缓存), 避免每次调用接口都访问 redis
+// 本地缓存的有效期很短, 并且不会超过 redis 中的剩余时间
+// 多实例部署时, 可以通过 redis pub/sub 广播token失效, 所有实例同时丢弃本地缓存
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLocalTokenCapacity = 1024             // 缺省最多缓存多少个token
+	defaultLocalTokenTTL      = 30 * time.Second // 缺省本地缓存时长
+	defaultTokenChannel       = "weixin.access_token.invalidate"
+	resubscribeInterval       = time.Second // 订阅断开之后, 重新订阅的间隔
+	tokenMessageSeparator     = "\n"        // 通知的格式 key[\nfingerprint]
+)
+
+// TokenBroadcaster 广播token失效, utils/redis 实现了该接口
+type TokenBroadcaster interface {
+	Publish(ctx context.Context, channel, message string) error
+	// 阻塞直到 ctx 取消或者出错
+	Subscribe(ctx context.Context, channel string, handler func(string)) error
+}
+
+type localToken struct {
+	key      string
+	token    string
+	expireAt time.Time
+}
+
+type (
+	LocalTokenCacheOption  func(*localTokenCacheOptions)
+	localTokenCacheOptions struct {
+		capacity    int
+		ttl         time.Duration
+		broadcaster TokenBroadcaster
+		channel     string
+	}
+)
+
+// LocalTokenCacheOptWithCapacity 最多缓存多少个token, 超过之后淘汰最久未使用的
+func LocalTokenCacheOptWithCapacity(capacity int) LocalTokenCacheOption {
+	return func(o *localTokenCacheOptions) {
+		o.capacity = capacity
+	}
+}
+
+// LocalTokenCacheOptWithTTL 本地缓存时长
+func LocalTokenCacheOptWithTTL(ttl time.Duration) LocalTokenCacheOption {
+	return func(o *localTokenCacheOptions) {
+		o.ttl = ttl
+	}
+}
+
+// LocalTokenCacheOptWithBroadcaster 通过 broadcaster 广播/接收token失效, 需要调用 Listen
+func LocalTokenCacheOptWithBroadcaster(
+	broadcaster TokenBroadcaster, channel string,
+) LocalTokenCacheOption {
+	return func(o *localTokenCacheOptions) {
+		o.broadcaster = broadcaster
+		if channel != "" {
+			o.channel = channel
+		}
+	}
+}
+
+// LocalTokenCache 进程内LRU缓存, 以 GetAccessTokenKey 为key, 可以被多个 AccessTokenCache 共享
+type LocalTokenCache struct {
+	options localTokenCacheOptions
+	mu      sync.Mutex
+	ll      *list.List // 最近使用的在前面
+	items   map[string]*list.Element
+}
+
+func NewLocalTokenCache(options ...LocalTokenCacheOption) *LocalTokenCache {
+	to := localTokenCacheOptions{
+		capacity: defaultLocalTokenCapacity,
+		ttl:      defaultLocalTokenTTL,
+		channel:  defaultTokenChannel,
+	}
+	for _, o := range options {
+		o(&to)
+	}
+	if to.capacity <= 0 {
+		to.capacity = defaultLocalTokenCapacity
+	}
+	if to.ttl <= 0 {
+		to.ttl = defaultLocalTokenTTL
+	}
+
+	return &LocalTokenCache{
+		options: to,
+		ll:      list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+func (c *LocalTokenCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	item := elem.Value.(*localToken)
+	if !time.Now().Before(item.expireAt) {
+		c.removeElement(elem)
+		return "", false
+	}
+	c.ll.MoveToFront(elem)
+	return item.token, true
+}
+
+// set 缓存token, remoteTTL 为 redis 中的剩余时间
+func (c *LocalTokenCache) set(key, token string, remoteTTL time.Duration) {
+	ttl := c.options.ttl
+	if remoteTTL < ttl {
+		ttl = remoteTTL
+	}
+	if ttl <= 0 || token == "" {
+		c.delete(key)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := &localToken{key: key, token: token, expireAt: time.Now().Add(ttl)}
+	if elem, ok := c.items[key]; ok {
+		elem.Value = item
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(item)
+	for c.ll.Len() > c.options.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *LocalTokenCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+func (c *LocalTokenCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*localToken).key)
+}
+
+// invalidate 删除本地缓存, 并通知其他实例
+func (c *LocalTokenCache) invalidate(ctx context.Context, key string) error {
+	c.delete(key)
+	return c.publish(ctx, key)
+}
+
+// replace 缓存刷新之后的token, 并通知其他实例丢弃旧的token
+// 通知中带上新token的指纹(不广播token本身), 收到通知时(包括自己发出的)保留指纹一致的token
+func (c *LocalTokenCache) replace(
+	ctx context.Context, key, token string, remoteTTL time.Duration,
+) error {
+	c.set(key, token, remoteTTL)
+	return c.publish(ctx, key+tokenMessageSeparator+tokenFingerprint(token))
+}
+
+// tokenFingerprint token 的 sha256, 用于比较是否是同一个token
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *LocalTokenCache) publish(ctx context.Context, message string) error {
+	if c.options.broadcaster == nil {
+		return nil
+	}
+	return c.options.broadcaster.Publish(ctx, c.options.channel, message)
+}
+
+// onMessage 收到失效通知, 如果通知带了新token的指纹, 只删除和新token不一致的缓存
+func (c *LocalTokenCache) onMessage(message string) {
+	key, fingerprint, replaced := strings.Cut(message, tokenMessageSeparator)
+	if !replaced {
+		c.delete(key)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok &&
+		tokenFingerprint(elem.Value.(*localToken).token) != fingerprint {
+		c.removeElement(elem)
+	}
+}
+
+// Listen 接收其他实例的失效通知, 直到 ctx 取消, 一般在单独的 goroutine 中运行
+// 订阅断开期间可能错过通知, 重新订阅时清空本地缓存
+func (c *LocalTokenCache) Listen(ctx context.Context) {
+	if c.options.broadcaster == nil {
+		return
+	}
+
+	for {
+		c.clear()
+		c.options.broadcaster.Subscribe(ctx, c.options.channel, c.onMessage)
+
+		timer := time.NewTimer(resubscribeInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *LocalTokenCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = map[string]*list.Element{}
+}
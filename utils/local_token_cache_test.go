@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// countCache 统计访问远端缓存的次数
+type countCache struct {
+	*memory.Memory
+	gets int
+}
+
+func (c *countCache) Get(ctx context.Context, key string, value interface{}) (bool, error) {
+	c.gets++
+	return c.Memory.Get(ctx, key, value)
+}
+
+// chanBroadcaster 进程内的广播
+type chanBroadcaster struct {
+	mu       sync.Mutex
+	handlers []func(string)
+	messages []string
+}
+
+func (b *chanBroadcaster) Publish(_ context.Context, _, message string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, message)
+	for _, handler := range b.handlers {
+		handler(message)
+	}
+	return nil
+}
+
+func (b *chanBroadcaster) Subscribe(ctx context.Context, _ string, handler func(string)) error {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestLocalTokenCache(t *testing.T) {
+	ctx := context.Background()
+	remote := &countCache{Memory: memory.NewMemory()}
+	getter := &countTokenGetter{key: "local"}
+	broadcaster := &chanBroadcaster{}
+
+	local1 := NewLocalTokenCache(LocalTokenCacheOptWithBroadcaster(broadcaster, ""))
+	local2 := NewLocalTokenCache(LocalTokenCacheOptWithBroadcaster(broadcaster, ""))
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go local1.Listen(listenCtx)
+	go local2.Listen(listenCtx)
+	require.Eventually(t, func() bool {
+		broadcaster.mu.Lock()
+		defer broadcaster.mu.Unlock()
+		return len(broadcaster.handlers) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	cache1 := NewAccessTokenCache(getter, remote, remote.Memory, CacheClientTokenOptWithLocalCache(local1))
+	cache2 := NewAccessTokenCache(getter, remote, remote.Memory, CacheClientTokenOptWithLocalCache(local2))
+
+	token, err := cache1.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-1", token)
+	token, err = cache2.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-1", token)
+
+	// 之后都从本地获取
+	gets := remote.gets
+	for i := 0; i < 10; i++ {
+		token, err = cache2.GetAccessToken(ctx)
+		require.Equal(t, nil, err)
+		require.Equal(t, "token-1", token)
+	}
+	require.Equal(t, gets, remote.gets)
+
+	// 一个实例发现token失效, 所有实例丢弃本地缓存
+	require.Equal(t, nil, cache1.InvalidateAccessToken(ctx, "token-1"))
+	token, err = cache2.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-2", token)
+
+	// 外部更新token
+	_, err = cache1.UpdateAccessToken(ctx, "token-x", 7200)
+	require.Equal(t, nil, err)
+	token, err = cache2.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-x", token)
+
+	// 通知中只有token的指纹, 不广播token本身
+	broadcaster.mu.Lock()
+	require.Contains(t, broadcaster.messages, "local\n"+tokenFingerprint("token-x"))
+	for _, message := range broadcaster.messages {
+		require.NotContains(t, message, "token-")
+	}
+	broadcaster.mu.Unlock()
+
+	// 本地缓存不超过远端剩余时间
+	require.Equal(t, nil, cache1.ClearAccessToken(ctx))
+	require.Equal(t, nil, remote.Set(ctx, "local", "token-short", time.Second))
+	token, err = cache2.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-short", token)
+	time.Sleep(1100 * time.Millisecond)
+	token, err = cache2.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-3", token)
+}
+
+// queueBroadcaster 异步的广播, flush 时才投递
+type queueBroadcaster struct {
+	chanBroadcaster
+	queue []string
+}
+
+func (b *queueBroadcaster) Publish(_ context.Context, _, message string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queue = append(b.queue, message)
+	return nil
+}
+
+func (b *queueBroadcaster) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, message := range b.queue {
+		for _, handler := range b.handlers {
+			handler(message)
+		}
+	}
+	b.queue = nil
+}
+
+func TestLocalTokenCacheAsyncNotify(t *testing.T) {
+	ctx := context.Background()
+	remote := &countCache{Memory: memory.NewMemory()}
+	getter := &countTokenGetter{key: "local"}
+	broadcaster := &queueBroadcaster{}
+
+	local1 := NewLocalTokenCache(LocalTokenCacheOptWithBroadcaster(broadcaster, ""))
+	local2 := NewLocalTokenCache(LocalTokenCacheOptWithBroadcaster(broadcaster, ""))
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go local1.Listen(listenCtx)
+	go local2.Listen(listenCtx)
+	require.Eventually(t, func() bool {
+		broadcaster.mu.Lock()
+		defer broadcaster.mu.Unlock()
+		return len(broadcaster.handlers) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	cache1 := NewAccessTokenCache(getter, remote, remote.Memory, CacheClientTokenOptWithLocalCache(local1))
+	cache2 := NewAccessTokenCache(getter, remote, remote.Memory, CacheClientTokenOptWithLocalCache(local2))
+	token, err := cache2.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-1", token)
+	broadcaster.flush()
+
+	// 刷新之后才投递通知, 刷新的实例保留新的token, 其他实例丢弃旧的token
+	_, err = cache1.UpdateAccessToken(ctx, "token-x", 7200)
+	require.Equal(t, nil, err)
+	broadcaster.flush()
+
+	gets := remote.gets
+	token, err = cache1.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-x", token)
+	require.Equal(t, gets, remote.gets)
+
+	token, err = cache2.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-x", token)
+	require.Equal(t, gets+1, remote.gets)
+}
+
+func TestLocalTokenCacheLRU(t *testing.T) {
+	local := NewLocalTokenCache(LocalTokenCacheOptWithCapacity(2))
+	local.set("a", "1", time.Minute)
+	local.set("b", "2", time.Minute)
+	_, ok := local.get("a")
+	require.True(t, ok)
+	local.set("c", "3", time.Minute)
+
+	_, ok = local.get("b")
+	require.False(t, ok)
+	token, ok := local.get("a")
+	require.True(t, ok)
+	require.Equal(t, "1", token)
+	token, ok = local.get("c")
+	require.True(t, ok)
+	require.Equal(t, "3", token)
+}
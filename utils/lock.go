@@ -3,6 +3,8 @@ package utils
 import (
 	"context"
 	"time"
+
+	"github.com/lixinio/weixin/utils/lockowner"
 )
 
 type Lock interface {
@@ -13,3 +15,9 @@ type Lock interface {
 	// key ， 超时时间， 等待总时间， 失败后休眠时长
 	LockTimeout(context.Context, string, time.Duration, time.Duration, time.Duration) (bool, error)
 }
+
+// NewLockContext 为一次加锁生成独立的持有者, 加锁和释放使用返回的同一个 ctx
+// 同一个 Lock 实例被多个 goroutine 共享时, 避免释放掉别人重新获取的同名锁
+func NewLockContext(ctx context.Context) context.Context {
+	return lockowner.NewContext(ctx)
+}
@@ -0,0 +1,57 @@
+package lockowner
+
+// 锁的持有者, 通过 ctx 传递给 utils.Lock 的实现(utils/redis, utils/memory)
+// 同一个 Lock 实例被多个 goroutine 共享时, 仅凭 key 无法区分是谁加的锁:
+// A 的锁过期之后 B 获取了同一个 key, A 再释放就会删掉 B 的锁
+// 每次加锁使用新的 Owner, 用同一个 ctx 释放/续期, 只会操作自己加的锁
+import (
+	"context"
+	"sync"
+)
+
+type ownerKey struct{}
+
+// Owner 一次(或者一组)加锁的持有者, 记录每个 key 的持有凭证
+type Owner struct {
+	mu      sync.Mutex
+	holders map[string]interface{}
+}
+
+// NewContext 返回携带新 Owner 的 ctx
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ownerKey{}, &Owner{holders: map[string]interface{}{}})
+}
+
+// FromContext 获取 ctx 的 Owner, 没有返回 nil
+func FromContext(ctx context.Context) *Owner {
+	owner, _ := ctx.Value(ownerKey{}).(*Owner)
+	return owner
+}
+
+// Store 记录 key 的持有凭证, 返回之前的凭证(已经过期的锁)
+func (o *Owner) Store(key string, holder interface{}) interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	old := o.holders[key]
+	o.holders[key] = holder
+	return old
+}
+
+// Load 获取 key 的持有凭证
+func (o *Owner) Load(key string) interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.holders[key]
+}
+
+// LoadAndDelete 获取并删除 key 的持有凭证
+func (o *Owner) LoadAndDelete(key string) interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	holder, ok := o.holders[key]
+	if !ok {
+		return nil
+	}
+	delete(o.holders, key)
+	return holder
+}
@@ -0,0 +1,248 @@
+package memory
+
+// 进程内的 utils.Cache / utils.Lock 实现, 适合单元测试以及单实例部署
+// 多实例部署请使用 utils/redis
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/lixinio/weixin/utils/lockowner"
+)
+
+type item struct {
+	value    interface{}
+	expireAt time.Time // 零值表示永不过期
+}
+
+func (i *item) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !now.Before(i.expireAt)
+}
+
+type lockItem struct {
+	expireAt time.Time
+	owner    *lockowner.Owner // 加锁时 ctx 携带的持有者
+}
+
+// Memory 进程内缓存
+type Memory struct {
+	mu      sync.Mutex
+	items   map[string]*item
+	locks   map[string]*lockItem // key => 锁
+	changed chan struct{}        // 有锁释放时关闭, 唤醒所有等待者
+	writes  int                  // 写入次数, 用于定期清理过期的值
+}
+
+const purgeInterval = 1024 // 每写入多少次清理一次过期的值
+
+// NewMemory 实例化
+func NewMemory() *Memory {
+	return &Memory{
+		items:   map[string]*item{},
+		locks:   map[string]*lockItem{},
+		changed: make(chan struct{}),
+	}
+}
+
+// getItem 获取未过期的值, 需要持有锁
+func (m *Memory) getItem(key string, now time.Time) (*item, bool) {
+	i, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	if i.expired(now) {
+		delete(m.items, key)
+		return nil, false
+	}
+	return i, true
+}
+
+// Get 获取一个值, value 必须是指针
+func (m *Memory) Get(
+	_ context.Context, key string, value interface{},
+) (exist bool, err error) {
+	m.mu.Lock()
+	i, ok := m.getItem(key, time.Now())
+	m.mu.Unlock()
+	if !ok {
+		// 不存在特殊处理
+		return false, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return false, fmt.Errorf("value must be pointer")
+	}
+	iv := reflect.ValueOf(i.value)
+	if !iv.Type().AssignableTo(rv.Elem().Type()) {
+		return false, fmt.Errorf(
+			"can NOT assign %s to %s", iv.Type().String(), rv.Elem().Type().String(),
+		)
+	}
+	rv.Elem().Set(iv)
+	return true, nil
+}
+
+// Set 设置一个值, timeout 为 0 表示永不过期
+func (m *Memory) Set(
+	_ context.Context, key string, val interface{}, timeout time.Duration,
+) error {
+	if val == nil {
+		return fmt.Errorf("val must NOT be nil")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setItem(key, val, timeout, time.Now())
+	return nil
+}
+
+// SetNX 不存在时才设置, 返回是否设置成功
+func (m *Memory) SetNX(
+	_ context.Context, key string, val interface{}, timeout time.Duration,
+) (bool, error) {
+	if val == nil {
+		return false, fmt.Errorf("val must NOT be nil")
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.getItem(key, now); ok {
+		return false, nil
+	}
+	m.setItem(key, val, timeout, now)
+	return true, nil
+}
+
+// setItem 需要持有锁
+func (m *Memory) setItem(key string, val interface{}, timeout time.Duration, now time.Time) {
+	i := &item{value: val}
+	if timeout > 0 {
+		i.expireAt = now.Add(timeout)
+	}
+	m.items[key] = i
+
+	// 过期的值只在访问时删除, 定期清理一次, 避免无人访问的key一直占用内存
+	m.writes++
+	if m.writes%purgeInterval == 0 {
+		for k, v := range m.items {
+			if v.expired(now) {
+				delete(m.items, k)
+			}
+		}
+	}
+}
+
+// IsExist 判断key是否存在
+func (m *Memory) IsExist(_ context.Context, key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.getItem(key, time.Now())
+	return ok
+}
+
+// Delete 删除
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+// 获得剩余时间(秒), 和redis一致: 不存在返回-2, 永不过期返回-1
+func (m *Memory) TTL(_ context.Context, key string) (int, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.getItem(key, now)
+	if !ok {
+		return -2, nil
+	}
+	if i.expireAt.IsZero() {
+		return -1, nil
+	}
+	// 四舍五入, 和redis一致
+	return int((i.expireAt.Sub(now) + time.Second/2) / time.Second), nil
+}
+
+// tryLock 尝试加锁, 需要持有锁
+// 返回 false 时, 同时返回当前锁的过期时间
+func (m *Memory) tryLock(
+	ctx context.Context, key string, expire time.Duration, now time.Time,
+) (bool, time.Time) {
+	if l, ok := m.locks[key]; ok && now.Before(l.expireAt) {
+		return false, l.expireAt
+	}
+	m.locks[key] = &lockItem{expireAt: now.Add(expire), owner: lockowner.FromContext(ctx)}
+	return true, time.Time{}
+}
+
+// Lock 加锁, 过期自动释放
+func (m *Memory) Lock(
+	ctx context.Context, key string, expire time.Duration,
+) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	locked, _ := m.tryLock(ctx, key, expire, time.Now())
+	return locked, nil
+}
+
+// LockTimeout 加锁, 失败则等待锁释放(或过期)再重试, 直到超时
+// 不需要轮询, sleep 参数被忽略
+func (m *Memory) LockTimeout(
+	ctx context.Context, key string, expire, timeout, _ time.Duration,
+) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		now := time.Now()
+
+		m.mu.Lock()
+		locked, lockExpireAt := m.tryLock(ctx, key, expire, now)
+		changed := m.changed
+		m.mu.Unlock()
+
+		if locked {
+			return true, nil
+		}
+		if !now.Before(deadline) {
+			// lock fail
+			return false, nil
+		}
+
+		// 等到 有锁释放/锁过期/超时/ctx取消
+		wakeAt := deadline
+		if lockExpireAt.Before(wakeAt) {
+			wakeAt = lockExpireAt
+		}
+		timer := time.NewTimer(wakeAt.Sub(now))
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// UnLock 释放锁, 并唤醒等待者
+// ctx 携带 lockowner.Owner 时, 只释放这个持有者加的锁
+func (m *Memory) UnLock(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if owner := lockowner.FromContext(ctx); owner != nil {
+		if l, ok := m.locks[key]; !ok || l.owner != owner {
+			// 锁已经过期, 被别人重新获取了
+			return nil
+		}
+	}
+	delete(m.locks, key)
+	close(m.changed)
+	m.changed = make(chan struct{})
+	return nil
+}
@@ -0,0 +1,205 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ utils.Cache = (*Memory)(nil)
+	_ utils.Lock  = (*Memory)(nil)
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+
+	ttl, err := memory.TTL(ctx, "key")
+	require.Equal(t, nil, err)
+	require.Equal(t, -2, ttl)
+
+	err = memory.Set(ctx, "key", "value", 60*time.Second)
+	require.Equal(t, nil, err)
+	require.True(t, memory.IsExist(ctx, "key"))
+
+	var val string
+	exist, err := memory.Get(ctx, "key", &val)
+	require.Equal(t, nil, err)
+	require.True(t, exist)
+	require.Equal(t, "value", val)
+
+	ttl, err = memory.TTL(ctx, "key")
+	require.Equal(t, nil, err)
+	require.Equal(t, 60, ttl)
+
+	var i int
+	_, err = memory.Get(ctx, "key", &i)
+	require.NotEqual(t, nil, err)
+
+	require.Equal(t, nil, memory.Delete(ctx, "key"))
+	exist, err = memory.Get(ctx, "key", &val)
+	require.Equal(t, nil, err)
+	require.False(t, exist)
+
+	// 过期
+	require.Equal(t, nil, memory.Set(ctx, "key", "value", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	require.False(t, memory.IsExist(ctx, "key"))
+	ttl, _ = memory.TTL(ctx, "key")
+	require.Equal(t, -2, ttl)
+
+	// 永不过期
+	require.Equal(t, nil, memory.Set(ctx, "key", "value", 0))
+	ttl, _ = memory.TTL(ctx, "key")
+	require.Equal(t, -1, ttl)
+
+	// 不存在时才设置
+	set, err := memory.SetNX(ctx, "key", "other", time.Minute)
+	require.Equal(t, nil, err)
+	require.False(t, set)
+	require.Equal(t, nil, memory.Delete(ctx, "key"))
+	set, err = memory.SetNX(ctx, "key", "other", time.Minute)
+	require.Equal(t, nil, err)
+	require.True(t, set)
+	_, _ = memory.Get(ctx, "key", &val)
+	require.Equal(t, "other", val)
+}
+
+func TestMemoryLock(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+
+	locked, err := memory.Lock(ctx, "lock", time.Minute)
+	require.Equal(t, nil, err)
+	require.True(t, locked)
+
+	locked, err = memory.Lock(ctx, "lock", time.Minute)
+	require.Equal(t, nil, err)
+	require.False(t, locked)
+
+	// 超时
+	locked, err = memory.LockTimeout(ctx, "lock", time.Minute, 20*time.Millisecond, 0)
+	require.Equal(t, nil, err)
+	require.False(t, locked)
+
+	// 释放之后立即唤醒
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		memory.UnLock(ctx, "lock")
+	}()
+	start := time.Now()
+	locked, err = memory.LockTimeout(ctx, "lock", time.Minute, time.Minute, time.Minute)
+	require.Equal(t, nil, err)
+	require.True(t, locked)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// 锁过期
+	locked, err = memory.LockTimeout(ctx, "lock2", 10*time.Millisecond, time.Second, 0)
+	require.True(t, locked)
+	locked, err = memory.LockTimeout(ctx, "lock2", 10*time.Millisecond, time.Second, 0)
+	require.Equal(t, nil, err)
+	require.True(t, locked)
+
+	// ctx 取消
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	locked, err = memory.LockTimeout(cancelCtx, "lock", time.Minute, time.Minute, 0)
+	require.Equal(t, context.Canceled, err)
+	require.False(t, locked)
+
+	// 并发
+	memory.UnLock(ctx, "lock")
+	counter, wg := 0, sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			locked, _ := memory.LockTimeout(ctx, "lock", time.Minute, time.Minute, 0)
+			if locked {
+				counter++
+				memory.UnLock(ctx, "lock")
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 20, counter)
+}
+
+func TestMemoryAccessTokenCache(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+	cache := utils.NewAccessTokenCache(&staticGetter{}, memory, memory)
+
+	token, err := cache.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token", token)
+
+	// 不需要刷新
+	token, err = cache.RefreshAccessToken(ctx, 0)
+	require.Equal(t, nil, err)
+	require.Equal(t, "", token)
+
+	require.Equal(t, nil, cache.ClearAccessToken(ctx))
+	token, err = cache.RefreshAccessToken(ctx, 0)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token", token)
+}
+
+type staticGetter struct{}
+
+func (*staticGetter) GetAccessToken(context.Context) (string, int, error) {
+	return "token", 7200, nil
+}
+
+func (*staticGetter) GetAccessTokenKey() string {
+	return "token"
+}
+
+func (*staticGetter) GetAccessTokenLockKey() string {
+	return "token.lock"
+}
+
+func TestMemoryLockOwner(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+
+	// 两个 goroutine 共享同一个 Memory, A 的锁过期之后 B 获取了锁
+	ctxA, ctxB := utils.NewLockContext(ctx), utils.NewLockContext(ctx)
+	aLocked, bLocked, aUnlocked := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		locked, err := memory.Lock(ctxA, "lock", 10*time.Millisecond)
+		require.Equal(t, nil, err)
+		require.True(t, locked)
+		close(aLocked)
+		<-bLocked
+		require.Equal(t, nil, memory.UnLock(ctxA, "lock"))
+		close(aUnlocked)
+	}()
+	go func() {
+		defer wg.Done()
+		<-aLocked
+		locked, err := memory.LockTimeout(ctxB, "lock", time.Minute, time.Second, 0)
+		require.Equal(t, nil, err)
+		require.True(t, locked)
+		close(bLocked)
+		<-aUnlocked
+		// A 不能释放 B 的锁
+		locked, err = memory.Lock(ctx, "lock", time.Minute)
+		require.Equal(t, nil, err)
+		require.False(t, locked)
+		require.Equal(t, nil, memory.UnLock(ctxB, "lock"))
+	}()
+	wg.Wait()
+
+	locked, err := memory.Lock(ctx, "lock", time.Minute)
+	require.Equal(t, nil, err)
+	require.True(t, locked)
+}
@@ -0,0 +1,140 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// 录制时不保存的参数
+var secretKeys = []string{
+	"secret",
+	"corpsecret",
+	"component_appsecret",
+	"suite_secret",
+	"provider_secret",
+}
+
+// Interaction 录制的一次请求/响应
+type Interaction struct {
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Query    string          `json:"query,omitempty"`
+	Body     string          `json:"body,omitempty"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+func (interaction *Interaction) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(interaction.Status)
+	_, _ = w.Write(interaction.Response)
+}
+
+// Cassette 录制的请求/响应, 可以保存为json文件, 提交到仓库用于回放
+type Cassette struct {
+	mu           sync.Mutex
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// LoadCassette 从文件加载
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cassette := &Cassette{}
+	if err = json.Unmarshal(data, cassette); err != nil {
+		return nil, err
+	}
+	return cassette, nil
+}
+
+// Save 保存到文件
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Add 添加一条记录
+func (c *Cassette) Add(interaction *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, interaction)
+}
+
+// find 优先匹配 method+path+query+body, 其次 method+path
+func (c *Cassette) find(request *Request) *Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	query := request.Query.Encode()
+	var candidate *Interaction
+	for _, interaction := range c.Interactions {
+		if interaction.Method != request.Method || interaction.Path != request.Path {
+			continue
+		}
+		if interaction.Query == query && interaction.Body == string(request.Body) {
+			return interaction
+		}
+		if candidate == nil {
+			candidate = interaction
+		}
+	}
+	return candidate
+}
+
+// record 转发到 upstream, 记录响应, token 接口的请求包含 secret, 不记录
+func (s *Server) record(w http.ResponseWriter, r *http.Request, request *Request) {
+	req, err := http.NewRequestWithContext(
+		r.Context(), r.Method, s.options.upstream+r.URL.RequestURI(), bytes.NewReader(request.Body),
+	)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, errCodeNotFound, err.Error())
+		return
+	}
+	req.Header = r.Header.Clone()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, errCodeNotFound, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, errCodeNotFound, err.Error())
+		return
+	}
+
+	if _, ok := tokenHandlers[r.URL.Path]; !ok && json.Valid(body) {
+		query := request.Query
+		for _, key := range secretKeys {
+			query.Del(key)
+		}
+		s.options.cassette.Add(&Interaction{
+			Method:   request.Method,
+			Path:     request.Path,
+			Query:    query.Encode(),
+			Body:     string(request.Body),
+			Status:   resp.StatusCode,
+			Response: body,
+		})
+	}
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,255 @@
+// Package mockserver 本地模拟的 微信/企业微信 服务器, 用于离线测试
+//
+// 实现了各种 token 的接口(校验 appid/secret, 签发/校验 token),
+// 其他接口通过 Handle/HandleJSON 注册, 或者从 Cassette 回放(可以通过 OptWithUpstream 从真实的服务器录制)
+//
+//	server := mockserver.New(mockserver.OptWithApp("appid", "secret"))
+//	defer server.Close()
+//	server.HandleJSON("/cgi-bin/user/info", map[string]interface{}{"openid": "openid"})
+//	oa := official_account.New(cache, locker, config, server.ClientOption())
+package mockserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+const (
+	defaultTokenTTL = 7200
+
+	errCodeInvalidCredential = 40001
+	errCodeInvalidAppid      = 40013
+	errCodeInvalidSecret     = 40125
+	errCodeNotFound          = -1
+)
+
+// tokenKeys 各种接口调用凭证在 query 中的名称
+var tokenKeys = []string{
+	"access_token",
+	"component_access_token",
+	"suite_access_token",
+	"provider_access_token",
+}
+
+// Request 收到的请求, token 已经被替换为空
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   []byte
+}
+
+// Unmarshal 解析 json 格式的请求 body
+func (r *Request) Unmarshal(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+type (
+	Option  func(*options)
+	options struct {
+		apps     map[string]string
+		tokenTTL int
+		cassette *Cassette
+		upstream string
+	}
+)
+
+// OptWithApp 注册 appid(corpid, suiteid, component_appid) 和 secret, 没有注册时不校验
+func OptWithApp(appid, secret string) Option {
+	return func(o *options) {
+		o.apps[appid] = secret
+	}
+}
+
+// OptWithTokenTTL 签发的 token 的有效期(秒)
+func OptWithTokenTTL(ttl int) Option {
+	return func(o *options) {
+		o.tokenTTL = ttl
+	}
+}
+
+// OptWithCassette 没有注册的接口, 从 cassette 回放
+func OptWithCassette(cassette *Cassette) Option {
+	return func(o *options) {
+		o.cassette = cassette
+	}
+}
+
+// OptWithUpstream 录制模式, 所有的请求(包括token)转发到 upstream, 响应记录到 cassette
+func OptWithUpstream(upstream string) Option {
+	return func(o *options) {
+		o.upstream = upstream
+	}
+}
+
+// Server 模拟的微信服务器
+type Server struct {
+	*httptest.Server
+	options  options
+	tokens   *tokenStore
+	mu       sync.Mutex
+	routes   map[string]http.HandlerFunc
+	requests []*Request
+}
+
+func New(opts ...Option) *Server {
+	to := options{
+		apps:     map[string]string{},
+		tokenTTL: defaultTokenTTL,
+	}
+	for _, o := range opts {
+		o(&to)
+	}
+	if to.cassette == nil {
+		to.cassette = &Cassette{}
+	}
+
+	s := &Server{
+		options: to,
+		tokens:  newTokenStore(to.tokenTTL),
+		routes:  map[string]http.HandlerFunc{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// ClientOption 把 utils.Client 指向本服务器
+func (s *Server) ClientOption() utils.ClientOption {
+	return utils.ClientOptWithServerUrl(s.URL)
+}
+
+// Cassette 回放/录制使用的 cassette
+func (s *Server) Cassette() *Cassette {
+	return s.options.cassette
+}
+
+// Handle 注册接口, 优先于 cassette, 调用之前已经校验过 token
+func (s *Server) Handle(path string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[path] = handler
+}
+
+// HandleJSON 注册接口, 固定返回 response 序列化之后的 json
+func (s *Server) HandleJSON(path string, response interface{}) {
+	s.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// Requests 收到的所有请求
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// LastRequest 最后一次访问 path 的请求, 没有时返回 nil
+func (s *Server) LastRequest(path string) *Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].Path == path {
+			return s.requests[i]
+		}
+	}
+	return nil
+}
+
+// ExpireToken 让 token 失效, 之后使用该 token 的请求返回 40001
+func (s *Server) ExpireToken(token string) {
+	s.tokens.expire(token)
+}
+
+// ExpireTokens 让所有的 token 失效
+func (s *Server) ExpireTokens() {
+	s.tokens.expireAll()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeNotFound, err.Error())
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	query := r.URL.Query()
+	token := ""
+	for _, key := range tokenKeys {
+		if value := query.Get(key); value != "" {
+			token = value
+			query.Del(key)
+		}
+	}
+
+	request := &Request{Method: r.Method, Path: r.URL.Path, Query: query, Body: body}
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	handler := s.routes[r.URL.Path]
+	s.mu.Unlock()
+
+	if s.options.upstream != "" {
+		s.record(w, r, request)
+		return
+	}
+
+	if tokenHandler, ok := tokenHandlers[r.URL.Path]; ok {
+		tokenHandler(s, w, r, request)
+		return
+	}
+
+	if token != "" && !s.tokens.valid(token) {
+		writeError(w, http.StatusOK, errCodeInvalidCredential, "invalid credential, access_token is invalid or not latest")
+		return
+	}
+
+	if handler != nil {
+		handler(w, r)
+		return
+	}
+
+	if interaction := s.options.cassette.find(request); interaction != nil {
+		interaction.write(w)
+		return
+	}
+	writeError(
+		w, http.StatusNotFound, errCodeNotFound,
+		fmt.Sprintf("mockserver: no handler for %s %s", r.Method, r.URL.Path),
+	)
+}
+
+// checkApp 校验 appid/secret
+func (s *Server) checkApp(w http.ResponseWriter, appid, secret string) bool {
+	if len(s.options.apps) == 0 {
+		return true
+	}
+	expected, ok := s.options.apps[appid]
+	if !ok {
+		writeError(w, http.StatusOK, errCodeInvalidAppid, "invalid appid")
+		return false
+	}
+	if expected != secret {
+		writeError(w, http.StatusOK, errCodeInvalidSecret, "invalid appsecret")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, errCode int64, errMsg string) {
+	writeJSON(w, status, &utils.WeixinError{ErrCode: errCode, ErrMsg: errMsg})
+}
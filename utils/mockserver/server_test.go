@@ -0,0 +1,109 @@
+package mockserver_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/lixinio/weixin/weixin/official_account"
+	"github.com/lixinio/weixin/weixin/user_api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOfficialAccount(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New(mockserver.OptWithApp("appid", "secret"))
+	defer server.Close()
+
+	server.HandleJSON("/cgi-bin/tags/create", map[string]interface{}{
+		"tag": map[string]interface{}{"id": 134, "name": "广东"},
+	})
+
+	mem := memory.NewMemory()
+	oa := official_account.New(mem, mem, &official_account.Config{
+		Appid: "appid", Secret: "secret",
+	}, server.ClientOption())
+	api := user_api.NewApi(oa.Client)
+
+	tag, err := api.CreateTag(ctx, "广东")
+	require.Equal(t, nil, err)
+	require.Equal(t, 134, tag.Tag.ID)
+
+	request := server.LastRequest("/cgi-bin/tags/create")
+	require.NotNil(t, request)
+	body := map[string]map[string]string{}
+	require.Equal(t, nil, request.Unmarshal(&body))
+	require.Equal(t, "广东", body["tag"]["name"])
+	require.Equal(t, "", request.Query.Get("access_token"))
+
+	// token 失效之后, 重新获取token并重放
+	server.ExpireTokens()
+	_, err = api.CreateTag(ctx, "广东")
+	require.Equal(t, nil, err)
+	tokenRequests := 0
+	for _, r := range server.Requests() {
+		if r.Path == "/cgi-bin/token" {
+			tokenRequests++
+		}
+	}
+	require.Equal(t, 2, tokenRequests)
+
+	// 没有注册的接口
+	_, err = api.GetTag(ctx)
+	require.NotEqual(t, nil, err)
+
+	// secret 错误
+	wrong := official_account.New(mem, mem, &official_account.Config{
+		Appid: "appid2", Secret: "secret",
+	}, server.ClientOption())
+	_, err = user_api.NewApi(wrong.Client).CreateTag(ctx, "广东")
+	require.True(t, errors.Is(err, &utils.WeixinError{ErrCode: 40013}))
+}
+
+func TestCassette(t *testing.T) {
+	ctx := context.Background()
+
+	// 录制
+	upstream := mockserver.New()
+	defer upstream.Close()
+	upstream.HandleJSON("/cgi-bin/tags/get", map[string]interface{}{
+		"tags": []map[string]interface{}{{"id": 1, "name": "星标组", "count": 0}},
+	})
+	recorder := mockserver.New(mockserver.OptWithUpstream(upstream.URL))
+	mem := memory.NewMemory()
+	oa := official_account.New(mem, mem, &official_account.Config{
+		Appid: "appid", Secret: "secret",
+	}, recorder.ClientOption())
+	_, err := user_api.NewApi(oa.Client).GetTag(ctx)
+	require.Equal(t, nil, err)
+	recorder.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.Equal(t, nil, recorder.Cassette().Save(path))
+	require.Equal(t, 1, len(recorder.Cassette().Interactions))
+
+	// 回放
+	cassette, err := mockserver.LoadCassette(path)
+	require.Equal(t, nil, err)
+	server := mockserver.New(mockserver.OptWithCassette(cassette))
+	defer server.Close()
+
+	mem = memory.NewMemory()
+	oa = official_account.New(mem, mem, &official_account.Config{
+		Appid: "appid", Secret: "secret",
+	}, server.ClientOption())
+	tags, err := user_api.NewApi(oa.Client).GetTag(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, len(tags.Tags))
+	require.Equal(t, "星标组", tags.Tags[0].Name)
+
+	resp, err := http.Get(server.URL + "/cgi-bin/unknown")
+	require.Equal(t, nil, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,165 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+// tokenStore 签发的 token, 以及每个 appid 最新的 token(稳定版接口)
+type tokenStore struct {
+	mu     sync.Mutex
+	ttl    int
+	tokens map[string]time.Time
+	latest map[string]string
+}
+
+func newTokenStore(ttl int) *tokenStore {
+	return &tokenStore{
+		ttl:    ttl,
+		tokens: map[string]time.Time{},
+		latest: map[string]string{},
+	}
+}
+
+// issue 签发新的 token, stable 时有效期内返回同一个 token
+func (ts *tokenStore) issue(kind, appid string, stable bool) (string, int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	key := kind + "." + appid
+	now := time.Now()
+	if token, ok := ts.latest[key]; ok && stable {
+		if expireAt, ok := ts.tokens[token]; ok && expireAt.After(now) {
+			return token, int(expireAt.Sub(now) / time.Second)
+		}
+	}
+
+	token := fmt.Sprintf("mock_%s_%s", kind, utils.GetRandString(32))
+	ts.tokens[token] = now.Add(time.Duration(ts.ttl) * time.Second)
+	ts.latest[key] = token
+	return token, ts.ttl
+}
+
+func (ts *tokenStore) valid(token string) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	expireAt, ok := ts.tokens[token]
+	return ok && expireAt.After(time.Now())
+}
+
+func (ts *tokenStore) expire(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.tokens, token)
+}
+
+func (ts *tokenStore) expireAll() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.tokens = map[string]time.Time{}
+	ts.latest = map[string]string{}
+}
+
+// params GET 请求的 query 和 POST 请求的 json 参数
+func params(request *Request) map[string]string {
+	result := map[string]string{}
+	for key := range request.Query {
+		result[key] = request.Query.Get(key)
+	}
+	body := map[string]interface{}{}
+	if len(request.Body) > 0 && json.Unmarshal(request.Body, &body) == nil {
+		for key, value := range body {
+			result[key] = fmt.Sprint(value)
+		}
+	}
+	return result
+}
+
+type tokenHandler func(s *Server, w http.ResponseWriter, r *http.Request, request *Request)
+
+// appTokenHandler 使用 appid/secret 换取 token 的接口
+func appTokenHandler(kind, appidKey, secretKey, tokenKey string, stable bool) tokenHandler {
+	return func(s *Server, w http.ResponseWriter, r *http.Request, request *Request) {
+		p := params(request)
+		if !s.checkApp(w, p[appidKey], p[secretKey]) {
+			return
+		}
+		token, expiresIn := s.tokens.issue(kind, p[appidKey], stable && p["force_refresh"] != "true")
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"errcode":    0,
+			"errmsg":     "ok",
+			tokenKey:     token,
+			"expires_in": expiresIn,
+		})
+	}
+}
+
+// authorizedTokenHandler 使用第三方平台/服务商的 token 换取授权方的 token
+func authorizedTokenHandler(kind, appidKey string, fields func(token string) map[string]interface{}) tokenHandler {
+	return func(s *Server, w http.ResponseWriter, r *http.Request, request *Request) {
+		valid := false
+		for _, key := range tokenKeys {
+			if token := r.URL.Query().Get(key); token != "" {
+				valid = s.tokens.valid(token)
+			}
+		}
+		if !valid {
+			writeError(w, http.StatusOK, errCodeInvalidCredential, "invalid credential")
+			return
+		}
+
+		token, expiresIn := s.tokens.issue(kind, params(request)[appidKey], false)
+		response := map[string]interface{}{
+			"errcode":    0,
+			"errmsg":     "ok",
+			"expires_in": expiresIn,
+		}
+		for key, value := range fields(token) {
+			response[key] = value
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+var tokenHandlers = map[string]tokenHandler{
+	// 公众号/小程序
+	"/cgi-bin/token": appTokenHandler(
+		"oa", "appid", "secret", "access_token", false,
+	),
+	"/cgi-bin/stable_token": appTokenHandler(
+		"oa", "appid", "secret", "access_token", true,
+	),
+	// 企业微信
+	"/cgi-bin/gettoken": appTokenHandler(
+		"corp", "corpid", "corpsecret", "access_token", false,
+	),
+	// 开放平台
+	"/cgi-bin/component/api_component_token": appTokenHandler(
+		"component", "component_appid", "component_appsecret", "component_access_token", false,
+	),
+	"/cgi-bin/component/api_authorizer_token": authorizedTokenHandler(
+		"authorizer", "authorizer_appid", func(token string) map[string]interface{} {
+			return map[string]interface{}{
+				"authorizer_access_token":  token,
+				"authorizer_refresh_token": "mock_refresh_token",
+			}
+		},
+	),
+	// 企业微信服务商
+	"/cgi-bin/service/get_suite_token": appTokenHandler(
+		"suite", "suite_id", "suite_secret", "suite_access_token", false,
+	),
+	"/cgi-bin/service/get_provider_token": appTokenHandler(
+		"provider", "corpid", "provider_secret", "provider_access_token", false,
+	),
+	"/cgi-bin/service/get_corp_token": authorizedTokenHandler(
+		"corp", "auth_corpid", func(token string) map[string]interface{} {
+			return map[string]interface{}{"access_token": token}
+		},
+	),
+}
@@ -0,0 +1,260 @@
+package redis
+
+// 集群模式, 根据key计算slot, 路由到对应的master节点, 处理 MOVED / ASK 重定向,
+// 节点连接失败(比如故障转移)时重新获取slot分布
+// https://redis.io/docs/reference/cluster-spec/
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	clusterSlots        = 16384
+	clusterMaxRedirects = 5
+)
+
+type clusterConnector struct {
+	config *Config
+	opts   *dialOptions
+
+	mu    sync.RWMutex
+	slots [clusterSlots]string   // slot => master 地址
+	pools map[string]*redis.Pool // 地址 => 连接池
+}
+
+func newClusterConnector(config *Config, opts *dialOptions) *clusterConnector {
+	return &clusterConnector{
+		config: config,
+		opts:   opts,
+		pools:  map[string]*redis.Pool{},
+	}
+}
+
+// crc16 CRC16-CCITT(XMODEM)
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// hashSlot 计算key的slot, 支持 hash tag, 比如 {user1000}.following
+func hashSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % clusterSlots
+}
+
+func (c *clusterConnector) getPool(addr string) *redis.Pool {
+	c.mu.RLock()
+	pool, ok := c.pools[addr]
+	c.mu.RUnlock()
+	if ok {
+		return pool
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool, ok = c.pools[addr]; !ok {
+		// 集群模式不支持 select db
+		pool = newPool(c.config, func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr, c.opts.redisDialOptions(false)...)
+		})
+		c.pools[addr] = pool
+	}
+	return pool
+}
+
+// refreshSlots 通过 CLUSTER SLOTS 获取slot分布
+func (c *clusterConnector) refreshSlots(ctx context.Context) error {
+	// 优先使用已知的节点, 最后使用配置的种子节点
+	c.mu.RLock()
+	addrs := make([]string, 0, len(c.pools)+len(c.opts.addrs))
+	for addr := range c.pools {
+		addrs = append(addrs, addr)
+	}
+	c.mu.RUnlock()
+	addrs = append(addrs, c.opts.addrs...)
+
+	var lastErr error
+	for _, addr := range addrs {
+		slots, err := c.fetchSlots(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.mu.Lock()
+		c.slots = slots
+		c.mu.Unlock()
+		return nil
+	}
+	return fmt.Errorf("can NOT refresh redis cluster slots, %w", lastErr)
+}
+
+func (c *clusterConnector) fetchSlots(
+	ctx context.Context, addr string,
+) (slots [clusterSlots]string, err error) {
+	conn, err := c.getPool(addr).GetContext(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ranges, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return
+	}
+
+	// [[start, end, [ip, port, id], [replica ip, port, id]...], ...]
+	for _, r := range ranges {
+		items, e := redis.Values(r, nil)
+		if e != nil || len(items) < 3 {
+			return slots, fmt.Errorf("invalid cluster slots reply %v", r)
+		}
+		start, _ := redis.Int(items[0], nil)
+		end, _ := redis.Int(items[1], nil)
+		master, e := redis.Values(items[2], nil)
+		if e != nil || len(master) < 2 {
+			return slots, fmt.Errorf("invalid cluster slots node %v", items[2])
+		}
+		ip, _ := redis.String(master[0], nil)
+		port, _ := redis.Int(master[1], nil)
+		if ip == "" {
+			// 空的ip表示和当前节点相同
+			ip, _, _ = net.SplitHostPort(addr)
+		}
+
+		nodeAddr := net.JoinHostPort(ip, strconv.Itoa(port))
+		for slot := start; slot <= end && slot < clusterSlots; slot++ {
+			slots[slot] = nodeAddr
+		}
+	}
+	return slots, nil
+}
+
+func (c *clusterConnector) slotAddr(ctx context.Context, slot int) (string, error) {
+	c.mu.RLock()
+	addr := c.slots[slot]
+	c.mu.RUnlock()
+	if addr != "" {
+		return addr, nil
+	}
+
+	if err := c.refreshSlots(ctx); err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if addr = c.slots[slot]; addr == "" {
+		return "", fmt.Errorf("redis cluster slot %d not covered", slot)
+	}
+	return addr, nil
+}
+
+// parseRedirect 解析 "MOVED 3999 127.0.0.1:6381" / "ASK 3999 127.0.0.1:6381"
+func parseRedirect(err error) (kind string, slot int, addr string, ok bool) {
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return
+	}
+	fields := strings.Fields(string(redisErr))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return
+	}
+	slot, e := strconv.Atoi(fields[1])
+	if e != nil {
+		return
+	}
+	return fields[0], slot, fields[2], true
+}
+
+// isConnError 节点不可用(比如故障转移之后旧的master下线), 需要重新获取slot分布
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (c *clusterConnector) do(
+	ctx context.Context, key string, fn func(redis.Conn) (interface{}, error),
+) (interface{}, error) {
+	slot := hashSlot(key)
+	addr, err := c.slotAddr(ctx, slot)
+	if err != nil {
+		return nil, err
+	}
+
+	asking, refreshed := false, false
+	for i := 0; ; i++ {
+		reply, sent, err := c.doAddr(ctx, addr, asking, fn)
+		if isConnError(err) {
+			if refreshed || c.refreshSlots(ctx) != nil {
+				return reply, err
+			}
+			refreshed = true
+			c.mu.RLock()
+			target := c.slots[slot]
+			c.mu.RUnlock()
+			if sent || target == "" || target == addr {
+				// 命令可能已经执行, 不重试, 下次请求使用新的路由
+				return reply, err
+			}
+			addr, asking = target, false
+			continue
+		}
+
+		kind, slot, target, ok := parseRedirect(err)
+		if !ok || i >= clusterMaxRedirects {
+			return reply, err
+		}
+
+		addr, asking = target, kind == "ASK"
+		if kind == "MOVED" {
+			// slot 已经迁移, 更新路由
+			c.mu.Lock()
+			c.slots[slot] = target
+			c.mu.Unlock()
+		}
+	}
+}
+
+// doAddr sent 表示命令是否已经发送到节点
+func (c *clusterConnector) doAddr(
+	ctx context.Context, addr string, asking bool, fn func(redis.Conn) (interface{}, error),
+) (reply interface{}, sent bool, err error) {
+	conn, err := c.getPool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	if asking {
+		if _, err := conn.Do("ASKING"); err != nil {
+			return nil, false, err
+		}
+	}
+	reply, err = fn(conn)
+	return reply, true, err
+}
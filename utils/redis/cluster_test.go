@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClusterNode 只支持 CLUSTER SLOTS 和 GET 的节点, 所有slot都在自己上面
+func fakeClusterNode(t *testing.T, value string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Equal(t, nil, err)
+	host, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+					switch strings.ToUpper(args[0]) {
+					case "CLUSTER":
+						fmt.Fprintf(
+							conn, "*1\r\n*3\r\n:0\r\n:%d\r\n*2\r\n$%d\r\n%s\r\n:%d\r\n",
+							clusterSlots-1, len(host), host, port,
+						)
+					case "GET":
+						fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+					default:
+						fmt.Fprintf(conn, "-ERR unknown command\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if _, err = reader.ReadString('\n'); err != nil { // $len
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSpace(arg))
+	}
+	return args, nil
+}
+
+func TestRedisClusterFailover(t *testing.T) {
+	ctx := context.Background()
+	node := fakeClusterNode(t, "value")
+	defer node.Close()
+
+	// 故障转移之后旧的master下线
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Equal(t, nil, err)
+	downAddr := down.Addr().String()
+	down.Close()
+
+	c := newClusterConnector(&Config{}, &dialOptions{
+		mode:  modeCluster,
+		addrs: []string{node.Addr().String()},
+	})
+	for i := range c.slots {
+		c.slots[i] = downAddr
+	}
+
+	reply, err := redis.String(c.do(ctx, "key", func(conn redis.Conn) (interface{}, error) {
+		return conn.Do("GET", "key")
+	}))
+	require.Equal(t, nil, err)
+	require.Equal(t, "value", reply)
+	require.Equal(t, node.Addr().String(), c.slots[hashSlot("key")])
+}
@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// connector 根据key获取连接并执行命令
+// 单机和哨兵模式忽略key, 集群模式根据key路由到对应的节点
+type connector interface {
+	do(ctx context.Context, key string, fn func(redis.Conn) (interface{}, error)) (interface{}, error)
+}
+
+// poolConnector 单机/哨兵模式, 使用一个连接池
+type poolConnector struct {
+	pool *redis.Pool
+}
+
+func (c *poolConnector) do(
+	ctx context.Context, _ string, fn func(redis.Conn) (interface{}, error),
+) (interface{}, error) {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return fn(conn)
+}
+
+func (opts *dialOptions) redisDialOptions(withDB bool) []redis.DialOption {
+	dialOpts := []redis.DialOption{
+		redis.DialPassword(opts.password),
+		redis.DialUseTLS(opts.tls),
+	}
+	if opts.username != "" {
+		// 显式指定时才使用 ACL 用户名 (AUTH username password)
+		dialOpts = append(dialOpts, redis.DialUsername(opts.username))
+	}
+	if withDB {
+		dialOpts = append(dialOpts, redis.DialDatabase(opts.db))
+	}
+	return dialOpts
+}
+
+func newPool(config *Config, dial func() (redis.Conn, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxActive:   config.MaxActive,
+		MaxIdle:     config.MaxIdle,
+		IdleTimeout: time.Second * time.Duration(config.IdleTimeout),
+		Dial:        dial,
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Minute {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+}
+
+func newStandaloneConnector(config *Config, opts *dialOptions) *poolConnector {
+	return &poolConnector{pool: newPool(config, func() (redis.Conn, error) {
+		return redis.Dial("tcp", opts.addrs[0], opts.redisDialOptions(true)...)
+	})}
+}
+
+// newSentinelConnector 通过哨兵发现master, 主从切换之后, 旧的连接在借出时被检测并丢弃
+// https://redis.io/docs/management/sentinel/
+func newSentinelConnector(config *Config, opts *dialOptions) *poolConnector {
+	pool := newPool(config, func() (redis.Conn, error) {
+		addr, err := sentinelMasterAddr(opts)
+		if err != nil {
+			return nil, err
+		}
+		return redis.Dial("tcp", addr, opts.redisDialOptions(true)...)
+	})
+	pool.TestOnBorrow = func(conn redis.Conn, _ time.Time) error {
+		// 主从切换之后, 旧的master变成了slave
+		role, err := redis.Values(conn.Do("ROLE"))
+		if err != nil {
+			return err
+		}
+		if len(role) == 0 {
+			return errors.New("invalid redis role reply")
+		}
+		if name, _ := redis.String(role[0], nil); name != "master" {
+			return fmt.Errorf("redis role '%s' is NOT master", name)
+		}
+		return nil
+	}
+	return &poolConnector{pool: pool}
+}
+
+// sentinelMasterAddr 依次询问哨兵, 获取master地址
+func sentinelMasterAddr(opts *dialOptions) (string, error) {
+	var lastErr error
+	for _, sentinelAddr := range opts.addrs {
+		addr, err := func() (string, error) {
+			conn, err := redis.Dial(
+				"tcp", sentinelAddr,
+				redis.DialPassword(opts.sentinelPassword),
+				redis.DialUseTLS(opts.tls),
+				redis.DialConnectTimeout(time.Second),
+				redis.DialReadTimeout(time.Second),
+			)
+			if err != nil {
+				return "", err
+			}
+			defer conn.Close()
+
+			reply, err := redis.Strings(
+				conn.Do("SENTINEL", "get-master-addr-by-name", opts.masterName),
+			)
+			if err != nil {
+				return "", err
+			}
+			if len(reply) != 2 {
+				return "", fmt.Errorf("invalid sentinel reply %v", reply)
+			}
+			return net.JoinHostPort(reply[0], reply[1]), nil
+		}()
+		if err == nil {
+			return addr, nil
+		}
+		lastErr = err
+	}
+	return "", fmt.Errorf(
+		"can NOT get master '%s' from sentinels %v, %w", opts.masterName, opts.addrs, lastErr,
+	)
+}
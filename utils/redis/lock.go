@@ -0,0 +1,218 @@
+package redis
+
+// https://redis.io/docs/manual/patterns/distributed-locks/
+// 每次加锁生成随机的token, 只有持有者才能释放/续期, 避免锁过期之后删掉别人的锁
+// ctx 携带 lockowner.Owner 时 token 记录在 Owner 中, 否则按 key 记录在 Redis 实例中,
+// 同一个实例被多个 goroutine 竞争同一个 key 时, 需要使用 Owner 区分持有者
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/lixinio/weixin/utils/lockowner"
+)
+
+const defaultLockRetrySleep = 100 * time.Millisecond // 加锁失败, 缺省的重试间隔
+
+var (
+	// 只有token一致才删除
+	unlockScript = redis.NewScript(1, `
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+else
+	return 0
+end`)
+	// 只有token一致才续期
+	extendScript = redis.NewScript(1, `
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+else
+	return 0
+end`)
+)
+
+type lockHolder struct {
+	token  string
+	expire time.Duration
+	stop   chan struct{} // 关闭以停止自动续期
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (holder *lockHolder) stopRenew() {
+	if holder != nil && holder.stop != nil {
+		close(holder.stop)
+	}
+}
+
+func (r *Redis) setHolder(ctx context.Context, key string, holder *lockHolder) {
+	if owner := lockowner.FromContext(ctx); owner != nil {
+		if old, ok := owner.Store(key, holder).(*lockHolder); ok {
+			// 之前的锁已经过期了
+			old.stopRenew()
+		}
+		return
+	}
+
+	r.locksMu.Lock()
+	defer r.locksMu.Unlock()
+	if r.locks == nil {
+		r.locks = map[string]*lockHolder{}
+	}
+	// 之前的锁已经过期了
+	r.locks[key].stopRenew()
+	r.locks[key] = holder
+}
+
+func (r *Redis) getHolder(ctx context.Context, key string) *lockHolder {
+	if owner := lockowner.FromContext(ctx); owner != nil {
+		holder, _ := owner.Load(key).(*lockHolder)
+		return holder
+	}
+
+	r.locksMu.Lock()
+	defer r.locksMu.Unlock()
+	return r.locks[key]
+}
+
+func (r *Redis) removeHolder(ctx context.Context, key string) *lockHolder {
+	var holder *lockHolder
+	if owner := lockowner.FromContext(ctx); owner != nil {
+		holder, _ = owner.LoadAndDelete(key).(*lockHolder)
+	} else {
+		r.locksMu.Lock()
+		holder = r.locks[key]
+		delete(r.locks, key)
+		r.locksMu.Unlock()
+	}
+	holder.stopRenew()
+	return holder
+}
+
+// Lock 加锁, 过期自动释放
+// https://www.programmersought.com/article/85921351841/
+// http://xiaorui.cc/archives/3028
+func (r *Redis) Lock(
+	ctx context.Context, key string, expire time.Duration,
+) (bool, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = redis.String(r.do(ctx, key, "set", token, "px", expire.Milliseconds(), "nx"))
+	if err != nil {
+		if err == redis.ErrNil {
+			// The lock was not successful, it already exists.
+			return false, nil
+		}
+		return false, err
+	}
+
+	holder := &lockHolder{token: token, expire: expire}
+	if r.lockAutoRenew && expire >= 3*time.Millisecond {
+		holder.stop = make(chan struct{})
+		go r.autoRenew(key, holder)
+	}
+	r.setHolder(ctx, key, holder)
+	return true, nil
+}
+
+// LockTimeout 加锁, 失败之后休眠 sleep 再重试, 直到超时或者 ctx 取消
+func (r *Redis) LockTimeout(
+	ctx context.Context, key string, expire, timeout, sleep time.Duration,
+) (bool, error) {
+	if sleep <= 0 {
+		sleep = defaultLockRetrySleep
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		result, err := r.Lock(ctx, key, expire)
+		if err != nil || result {
+			// error or lock success
+			return result, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			// lock fail
+			return false, nil
+		}
+		if remaining < sleep {
+			sleep = remaining
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// UnLock 释放 ctx 的持有者(或者本进程)加的锁, 不会删除别人的锁
+func (r *Redis) UnLock(ctx context.Context, key string) error {
+	holder := r.removeHolder(ctx, key)
+	if holder == nil {
+		// 不是自己加的锁
+		return nil
+	}
+
+	_, err := r.conn.do(ctx, key, func(conn redis.Conn) (interface{}, error) {
+		return unlockScript.Do(conn, key, holder.token)
+	})
+	return err
+}
+
+// ExtendLock 为 ctx 的持有者(或者本进程)加的锁续期, 锁已经过期或者被别人持有返回false
+func (r *Redis) ExtendLock(
+	ctx context.Context, key string, expire time.Duration,
+) (bool, error) {
+	holder := r.getHolder(ctx, key)
+	if holder == nil {
+		return false, nil
+	}
+
+	return r.extend(ctx, key, holder.token, expire)
+}
+
+func (r *Redis) extend(
+	ctx context.Context, key, token string, expire time.Duration,
+) (bool, error) {
+	n, err := redis.Int(r.conn.do(ctx, key, func(conn redis.Conn) (interface{}, error) {
+		return extendScript.Do(conn, key, token, expire.Milliseconds())
+	}))
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// autoRenew 每隔 1/3 过期时间续期一次, 直到释放锁或者续期失败
+func (r *Redis) autoRenew(key string, holder *lockHolder) {
+	ticker := time.NewTicker(holder.expire / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-holder.stop:
+			return
+		case <-ticker.C:
+			ok, err := r.extend(context.Background(), key, holder.token, holder.expire)
+			if err != nil || !ok {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package redis
+
+// 发布/订阅, 用于多实例之间广播token失效(utils.TokenBroadcaster)
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/lixinio/weixin/utils"
+)
+
+var _ utils.TokenBroadcaster = (*Redis)(nil)
+
+// Publish 发布消息
+func (r *Redis) Publish(ctx context.Context, channel, message string) error {
+	_, err := r.do(ctx, channel, "PUBLISH", message)
+	return err
+}
+
+// Subscribe 订阅消息, 阻塞直到 ctx 取消或者连接出错, 订阅期间占用一个连接
+func (r *Redis) Subscribe(ctx context.Context, channel string, handler func(string)) error {
+	_, err := r.conn.do(ctx, channel, func(conn redis.Conn) (interface{}, error) {
+		psc := redis.PubSubConn{Conn: conn}
+		if err := psc.Subscribe(channel); err != nil {
+			return nil, err
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				// 取消订阅之后, Receive 会收到 count 为 0 的 Subscription
+				psc.Unsubscribe()
+			case <-done:
+			}
+		}()
+
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				handler(string(v.Data))
+			case redis.Subscription:
+				if v.Count == 0 {
+					return nil, ctx.Err()
+				}
+			case error:
+				return nil, fmt.Errorf("redis subscribe '%s', %w", channel, v)
+			}
+		}
+	})
+	return err
+}
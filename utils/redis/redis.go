@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -12,63 +13,109 @@ import (
 
 // Redis redis cache
 type Redis struct {
-	conn *redis.Pool
+	conn          connector
+	lockAutoRenew bool
+	locksMu       sync.Mutex
+	locks         map[string]*lockHolder // 本进程持有的锁
 }
 
 // Config redis 连接属性
+// 可以只配置 RedisUrl, 也可以通过 Mode / Addrs 等显式配置(优先级更高)
 type Config struct {
-	RedisUrl    string
-	MaxIdle     int   `yml:"max_idle"     json:"max_idle"`
-	MaxActive   int   `yml:"max_active"   json:"max_active"`
-	IdleTimeout int32 `yml:"idle_timeout" json:"idle_timeout"` // second
+	RedisUrl         string
+	Mode             string   `yml:"mode"              json:"mode"`              // standalone(缺省) / sentinel / cluster
+	Addrs            []string `yml:"addrs"             json:"addrs"`             // 单机地址, 哨兵地址列表 或者 集群种子节点
+	MasterName       string   `yml:"master_name"       json:"master_name"`       // 哨兵模式 master 名称
+	DB               int      `yml:"db"                json:"db"`                // 集群模式不支持
+	Username         string   `yml:"username"          json:"username"`          // ACL 用户名 (redis 6+), 为空时只发送密码
+	Password         string   `yml:"password"          json:"password"`          //
+	SentinelPassword string   `yml:"sentinel_password" json:"sentinel_password"` // 哨兵的密码
+	TLS              bool     `yml:"tls"               json:"tls"`               //
+	MaxIdle          int      `yml:"max_idle"          json:"max_idle"`
+	MaxActive        int      `yml:"max_active"        json:"max_active"`
+	IdleTimeout      int32    `yml:"idle_timeout"      json:"idle_timeout"`    // second
+	LockAutoRenew    bool     `yml:"lock_auto_renew"   json:"lock_auto_renew"` // 持有锁期间自动续期
+}
+
+func (config *Config) dialOptions() (*dialOptions, error) {
+	if len(config.Addrs) == 0 {
+		opts, err := parseDialURL(config.RedisUrl)
+		if err != nil {
+			return nil, err
+		}
+		if config.Username != "" {
+			opts.username = config.Username
+		}
+		return opts, nil
+	}
+
+	opts := &dialOptions{
+		mode:             config.Mode,
+		addrs:            config.Addrs,
+		masterName:       config.MasterName,
+		db:               config.DB,
+		username:         config.Username,
+		password:         config.Password,
+		sentinelPassword: config.SentinelPassword,
+		tls:              config.TLS,
+	}
+	switch opts.mode {
+	case "":
+		opts.mode = modeStandalone
+	case modeStandalone, modeCluster:
+	case modeSentinel:
+		if opts.masterName == "" {
+			return nil, fmt.Errorf("redis sentinel without master name")
+		}
+	default:
+		return nil, fmt.Errorf("invalid redis mode '%s'", opts.mode)
+	}
+	return opts, nil
 }
 
 // NewRedis 实例化
 func NewRedis(opts *Config) *Redis {
-	redisDB, redisHost, redisPwd, err := parseRedisURL(opts.RedisUrl)
+	dialOpts, err := opts.dialOptions()
 	if err != nil {
 		panic(err)
 	}
 
-	pool := &redis.Pool{
-		MaxActive:   opts.MaxActive,
-		MaxIdle:     opts.MaxIdle,
-		IdleTimeout: time.Second * time.Duration(opts.IdleTimeout),
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp", redisHost,
-				redis.DialDatabase(redisDB),
-				redis.DialPassword(redisPwd),
-			)
-		},
-		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
-			if time.Since(t) < time.Minute {
-				return nil
-			}
-			_, err := conn.Do("PING")
-			return err
-		},
+	var conn connector
+	switch dialOpts.mode {
+	case modeSentinel:
+		conn = newSentinelConnector(opts, dialOpts)
+	case modeCluster:
+		conn = newClusterConnector(opts, dialOpts)
+	default:
+		conn = newStandaloneConnector(opts, dialOpts)
 	}
-	return &Redis{pool}
+	return &Redis{conn: conn, lockAutoRenew: opts.LockAutoRenew}
 }
 
 // SetConn 设置conn
 func (r *Redis) SetConn(conn *redis.Pool) {
-	r.conn = conn
+	r.conn = &poolConnector{pool: conn}
+}
+
+func (r *Redis) do(
+	ctx context.Context, key string, commandName string, args ...interface{},
+) (interface{}, error) {
+	return r.conn.do(ctx, key, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do(commandName, append([]interface{}{key}, args...)...)
+	})
 }
 
 // Get 获取一个值
 func (r *Redis) Get(
-	_ context.Context, key string, value interface{},
+	ctx context.Context, key string, value interface{},
 ) (exist bool, err error) {
-	conn := r.conn.Get()
-	defer conn.Close()
-
 	var data []byte
-	if data, err = redis.Bytes(conn.Do("GET", key)); err != nil {
+	if data, err = redis.Bytes(r.do(ctx, key, "GET")); err != nil {
 		if err == redis.ErrNil {
 			// 不存在特殊处理
 			return false, nil
 		}
+		return false, err
 	}
 
 	v, ok := value.(*string)
@@ -83,38 +130,47 @@ func (r *Redis) Get(
 
 // Set 设置一个值
 func (r *Redis) Set(
-	_ context.Context, key string, val interface{}, timeout time.Duration,
+	ctx context.Context, key string, val interface{}, timeout time.Duration,
 ) (err error) {
-	conn := r.conn.Get()
-	defer conn.Close()
-
 	data, ok := val.(string)
 	if !ok {
 		err = fmt.Errorf("val must be string")
 		return
 	}
 
-	_, err = conn.Do("SETEX", key, int64(timeout/time.Second), data)
+	_, err = r.do(ctx, key, "SETEX", int64(timeout/time.Second), data)
 
 	return
 }
 
-// IsExist 判断key是否存在
-func (r *Redis) IsExist(_ context.Context, key string) bool {
-	conn := r.conn.Get()
-	defer conn.Close()
+// SetNX 不存在时才设置, 返回是否设置成功
+func (r *Redis) SetNX(
+	ctx context.Context, key string, val interface{}, timeout time.Duration,
+) (bool, error) {
+	data, ok := val.(string)
+	if !ok {
+		return false, fmt.Errorf("val must be string")
+	}
+
+	if _, err := redis.String(r.do(ctx, key, "SET", data, "PX", timeout.Milliseconds(), "NX")); err != nil {
+		if err == redis.ErrNil {
+			// 已经存在
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
 
-	a, _ := conn.Do("EXISTS", key)
-	i := a.(int64)
+// IsExist 判断key是否存在
+func (r *Redis) IsExist(ctx context.Context, key string) bool {
+	i, _ := redis.Int64(r.do(ctx, key, "EXISTS"))
 	return i > 0
 }
 
 // Delete 删除
-func (r *Redis) Delete(_ context.Context, key string) error {
-	conn := r.conn.Get()
-	defer conn.Close()
-
-	if _, err := conn.Do("DEL", key); err != nil {
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	if _, err := r.do(ctx, key, "DEL"); err != nil {
 		return err
 	}
 
@@ -122,11 +178,8 @@ func (r *Redis) Delete(_ context.Context, key string) error {
 }
 
 // 获得剩余时间(秒)
-func (r *Redis) TTL(_ context.Context, key string) (int, error) {
-	conn := r.conn.Get()
-	defer conn.Close()
-
-	if reply, err := conn.Do("TTL", key); err != nil {
+func (r *Redis) TTL(ctx context.Context, key string) (int, error) {
+	if reply, err := r.do(ctx, key, "TTL"); err != nil {
 		return -1, err
 	} else {
 		if ttl, ok := reply.(int64); ok {
@@ -139,57 +192,3 @@ func (r *Redis) TTL(_ context.Context, key string) (int, error) {
 		}
 	}
 }
-
-// https://www.programmersought.com/article/85921351841/
-// http://xiaorui.cc/archives/3028
-func (r *Redis) Lock(
-	_ context.Context, key string, expire time.Duration,
-) (bool, error) {
-	conn := r.conn.Get()
-	defer conn.Close()
-
-	_, err := redis.String(conn.Do("set", key, 1, "ex", int(expire/time.Second), "nx"))
-	if err != nil {
-		if err == redis.ErrNil {
-			// The lock was not successful, it already exists.
-			return false, nil
-		}
-		return false, err
-	}
-	return true, nil
-}
-
-func (r *Redis) LockTimeout(
-	ctx context.Context, key string, expire, timeout, sleep time.Duration,
-) (bool, error) {
-	var total time.Duration = 0
-	for total < timeout {
-		result, err := r.Lock(ctx, key, expire)
-		if err == nil {
-			if result {
-				// lock success
-				return result, err
-			} else {
-				// lock fail
-				time.Sleep(sleep)
-				total += sleep
-			}
-		} else {
-			// error
-			return result, err
-		}
-	}
-	// lock fail
-	return false, nil
-}
-
-func (r *Redis) UnLock(_ context.Context, key string) error {
-	conn := r.conn.Get()
-	defer conn.Close()
-
-	_, err := conn.Do("del", key)
-	if err != nil {
-		return err
-	}
-	return nil
-}
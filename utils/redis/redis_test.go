@@ -3,9 +3,11 @@ package redis
 import (
 	"context"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/lixinio/weixin/utils/lockowner"
 	"github.com/stretchr/testify/require"
 )
 
@@ -16,6 +18,7 @@ const (
 )
 
 func TestRedis(t *testing.T) {
+	skipWithoutRedis(t)
 	ctx := context.Background()
 	redis := NewRedis(&Config{RedisUrl: "redis://127.0.0.1:6379/1"})
 	err := redis.Set(ctx, key, value, time.Second*ttl)
@@ -42,4 +45,106 @@ func TestRedis(t *testing.T) {
 	require.Equal(t, err, nil)
 	require.Less(t, ttl, 0)
 	fmt.Println("ttl", ttl)
+
+	// 不存在时才设置
+	set, err := redis.SetNX(ctx, key, value, time.Minute)
+	require.Equal(t, err, nil)
+	require.Equal(t, set, true)
+	set, err = redis.SetNX(ctx, key, "other", time.Minute)
+	require.Equal(t, err, nil)
+	require.Equal(t, set, false)
+	require.Equal(t, redis.Delete(ctx, key), nil)
+}
+
+func TestRedisLock(t *testing.T) {
+	skipWithoutRedis(t)
+	ctx := context.Background()
+	lockKey := key + ".lock"
+	redis1 := NewRedis(&Config{RedisUrl: "redis://127.0.0.1:6379/1", LockAutoRenew: true})
+	redis2 := NewRedis(&Config{RedisUrl: "redis://127.0.0.1:6379/1"})
+
+	locked, err := redis1.Lock(ctx, lockKey, time.Second)
+	require.Equal(t, err, nil)
+	require.Equal(t, locked, true)
+
+	// 其他进程不能释放别人的锁
+	err = redis2.UnLock(ctx, lockKey)
+	require.Equal(t, err, nil)
+	locked, err = redis2.Lock(ctx, lockKey, time.Second)
+	require.Equal(t, err, nil)
+	require.Equal(t, locked, false)
+
+	// 自动续期, 超过过期时间依然持有
+	time.Sleep(2 * time.Second)
+	locked, err = redis2.LockTimeout(ctx, lockKey, time.Second, 200*time.Millisecond, 50*time.Millisecond)
+	require.Equal(t, err, nil)
+	require.Equal(t, locked, false)
+
+	// ctx 取消
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	locked, err = redis2.LockTimeout(cancelCtx, lockKey, time.Second, time.Second, 50*time.Millisecond)
+	require.NotEqual(t, err, nil)
+	require.Equal(t, locked, false)
+
+	err = redis1.UnLock(ctx, lockKey)
+	require.Equal(t, err, nil)
+	locked, err = redis2.LockTimeout(ctx, lockKey, time.Second, time.Second, 50*time.Millisecond)
+	require.Equal(t, err, nil)
+	require.Equal(t, locked, true)
+	require.Equal(t, redis2.UnLock(ctx, lockKey), nil)
+}
+
+func TestRedisLockOwner(t *testing.T) {
+	skipWithoutRedis(t)
+	ctx := context.Background()
+	lockKey := key + ".owner.lock"
+	redis := NewRedis(&Config{RedisUrl: "redis://127.0.0.1:6379/1"})
+
+	// 两个持有者共享同一个 *Redis, A 的锁过期之后 B 获取了锁
+	ctxA, ctxB := lockowner.NewContext(ctx), lockowner.NewContext(ctx)
+	locked, err := redis.Lock(ctxA, lockKey, 100*time.Millisecond)
+	require.Equal(t, nil, err)
+	require.True(t, locked)
+
+	// B 在另一个 goroutine 等待, 结果交给测试 goroutine 检查
+	type lockResult struct {
+		locked bool
+		err    error
+	}
+	bLocked := make(chan lockResult, 1)
+	go func() {
+		locked, err := redis.LockTimeout(ctxB, lockKey, time.Minute, time.Second, 20*time.Millisecond)
+		bLocked <- lockResult{locked, err}
+	}()
+	result := <-bLocked
+	require.Equal(t, nil, result.err)
+	require.True(t, result.locked)
+
+	// A 不能释放/续期 B 的锁
+	require.Equal(t, nil, redis.UnLock(ctxA, lockKey))
+	extended, err := redis.ExtendLock(ctxA, lockKey, time.Minute)
+	require.Equal(t, nil, err)
+	require.False(t, extended)
+	locked, err = redis.Lock(ctx, lockKey, time.Minute)
+	require.Equal(t, nil, err)
+	require.False(t, locked)
+	extended, err = redis.ExtendLock(ctxB, lockKey, time.Minute)
+	require.Equal(t, nil, err)
+	require.True(t, extended)
+	require.Equal(t, nil, redis.UnLock(ctxB, lockKey))
+
+	locked, err = redis.Lock(ctx, lockKey, time.Second)
+	require.Equal(t, nil, err)
+	require.True(t, locked)
+	require.Equal(t, nil, redis.UnLock(ctx, lockKey))
+}
+
+// skipWithoutRedis 本地没有 redis 时跳过
+func skipWithoutRedis(t *testing.T) {
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:6379", time.Second)
+	if err != nil {
+		t.Skipf("redis 127.0.0.1:6379 unreachable, %v", err)
+	}
+	conn.Close()
 }
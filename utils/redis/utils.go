@@ -1,33 +1,103 @@
 package redis
 
 import (
+	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
 )
 
+const (
+	modeStandalone = "standalone"
+	modeSentinel   = "sentinel"
+	modeCluster    = "cluster"
+)
+
+// 连接参数, 从 Config 或者 RedisUrl 解析
+type dialOptions struct {
+	mode             string
+	addrs            []string // 单机: 1个地址; 哨兵: 哨兵地址列表; 集群: 种子节点列表
+	masterName       string   // 哨兵模式 master 名称
+	db               int
+	username         string // ACL 用户名 (redis 6+)
+	password         string
+	sentinelPassword string
+	tls              bool
+}
+
+// parseRedisURL 解析单机模式的地址, 返回 db, host, password
 func parseRedisURL(urlStr string) (int, string, string, error) {
-	redisURL, err := url.Parse(urlStr)
+	opts, err := parseDialURL(urlStr)
 	if err != nil {
 		return 0, "", "", err
 	}
+	return opts.db, opts.addrs[0], opts.password, nil
+}
+
+// parseDialURL 支持以下格式, 协议以 s 结尾(rediss)表示使用TLS
+//
+//	redis://[:password@]host:port[/db][?username=xxx]
+//	redis-sentinel://[:password@]host1:port1,host2:port2/master_name[/db][?sentinel_password=xxx]
+//	redis-cluster://[:password@]host1:port1,host2:port2
+//
+// 其他协议(比如 tcp://)和之前的版本一样按照单机模式处理, 不使用TLS
+// 和之前的版本一样, url 中 user:password@ 的用户名会被忽略(只发送密码),
+// ACL 用户名需要通过 username 参数或者 Config.Username 显式指定
+func parseDialURL(urlStr string) (*dialOptions, error) {
+	redisURL, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &dialOptions{}
+	scheme := redisURL.Scheme
+	switch scheme {
+	case "redis-sentinel", "rediss-sentinel":
+		opts.mode = modeSentinel
+	case "redis-cluster", "rediss-cluster":
+		opts.mode = modeCluster
+	default:
+		opts.mode = modeStandalone
+	}
+	opts.tls = strings.HasPrefix(scheme, "rediss")
 
-	redisPwd := ""
 	if redisURL.User != nil {
 		if password, ok := redisURL.User.Password(); ok {
-			redisPwd = password
+			opts.password = password
 		}
 	}
+	opts.username = redisURL.Query().Get("username")
+	opts.sentinelPassword = redisURL.Query().Get("sentinel_password")
+
+	for _, addr := range strings.Split(redisURL.Host, ",") {
+		if addr != "" {
+			opts.addrs = append(opts.addrs, addr)
+		}
+	}
+	if len(opts.addrs) == 0 {
+		return nil, fmt.Errorf("redis url '%s' without host", urlStr)
+	}
 
-	redisDb := 0
-	if len(redisURL.Path) > 1 {
-		db := strings.TrimPrefix(redisURL.Path, "/")
-		intVar, err := strconv.Atoi(db)
-		if err != nil {
-			return 0, "", "", err
+	paths := []string{}
+	for _, p := range strings.Split(strings.Trim(redisURL.Path, "/"), "/") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if opts.mode == modeSentinel {
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("redis sentinel url '%s' without master name", urlStr)
+		}
+		opts.masterName, paths = paths[0], paths[1:]
+	}
+	if len(paths) > 0 {
+		if opts.mode == modeCluster {
+			return nil, fmt.Errorf("redis cluster does NOT support database '%s'", paths[0])
+		}
+		if opts.db, err = strconv.Atoi(paths[0]); err != nil {
+			return nil, err
 		}
-		redisDb = intVar
 	}
 
-	return redisDb, redisURL.Host, redisPwd, nil
+	return opts, nil
 }
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 长期有效的凭证, 比如开放平台的 authorizer_refresh_token, 企业微信第三方应用的 permanent_code
+// 只在授权时下发(或者刷新token时轮换), 丢失之后只能让用户重新授权, 所以需要可靠的存储
+// 缓存的过期时间, 每次轮换都会重新设置
+const refreshTokenExpiresIn = 365 * 24 * time.Hour
+
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+// RefreshTokenStore 保存长期有效的凭证
+type RefreshTokenStore interface {
+	GetRefreshToken(ctx context.Context, key string) (string, error) // 不存在时返回 ErrRefreshTokenNotFound
+	SetRefreshToken(ctx context.Context, key, token string) error
+	DeleteRefreshToken(ctx context.Context, key string) error
+}
+
+// CacheRefreshTokenStore 保存在 Cache 中, 需要 Cache 本身是持久化的(比如开启了持久化的 redis)
+type CacheRefreshTokenStore struct {
+	cache Cache
+}
+
+func NewCacheRefreshTokenStore(cache Cache) *CacheRefreshTokenStore {
+	return &CacheRefreshTokenStore{cache: cache}
+}
+
+func (s *CacheRefreshTokenStore) GetRefreshToken(ctx context.Context, key string) (string, error) {
+	token := ""
+	ok, err := s.cache.Get(ctx, key, &token)
+	if err != nil {
+		return "", err
+	}
+	if !ok || token == "" {
+		return "", fmt.Errorf("key %s, %w", key, ErrRefreshTokenNotFound)
+	}
+	return token, nil
+}
+
+func (s *CacheRefreshTokenStore) SetRefreshToken(ctx context.Context, key, token string) error {
+	return s.cache.Set(ctx, key, token, refreshTokenExpiresIn)
+}
+
+func (s *CacheRefreshTokenStore) DeleteRefreshToken(ctx context.Context, key string) error {
+	return s.cache.Delete(ctx, key)
+}
+
+// SQLRefreshTokenQueries 不同数据库的 sql, 参数依次为 key, token
+//
+//	CREATE TABLE weixin_refresh_token (
+//		token_key  VARCHAR(191) NOT NULL PRIMARY KEY,
+//		token      VARCHAR(512) NOT NULL,
+//		updated_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+//	);
+type SQLRefreshTokenQueries struct {
+	Get    string // SELECT token WHERE key
+	Upsert string // INSERT OR UPDATE (key, token)
+	Delete string // DELETE WHERE key
+}
+
+// MySQLRefreshTokenQueries MySQL 的 sql
+func MySQLRefreshTokenQueries(table string) SQLRefreshTokenQueries {
+	return SQLRefreshTokenQueries{
+		Get: fmt.Sprintf("SELECT token FROM %s WHERE token_key = ?", table),
+		Upsert: fmt.Sprintf(
+			"INSERT INTO %s (token_key, token, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP) "+
+				"ON DUPLICATE KEY UPDATE token = VALUES(token), updated_at = CURRENT_TIMESTAMP",
+			table,
+		),
+		Delete: fmt.Sprintf("DELETE FROM %s WHERE token_key = ?", table),
+	}
+}
+
+// PostgresRefreshTokenQueries PostgreSQL 的 sql (SQLite 3.24 以上同样适用)
+func PostgresRefreshTokenQueries(table string) SQLRefreshTokenQueries {
+	return SQLRefreshTokenQueries{
+		Get: fmt.Sprintf("SELECT token FROM %s WHERE token_key = $1", table),
+		Upsert: fmt.Sprintf(
+			"INSERT INTO %s (token_key, token, updated_at) VALUES ($1, $2, CURRENT_TIMESTAMP) "+
+				"ON CONFLICT (token_key) DO UPDATE SET token = excluded.token, updated_at = CURRENT_TIMESTAMP",
+			table,
+		),
+		Delete: fmt.Sprintf("DELETE FROM %s WHERE token_key = $1", table),
+	}
+}
+
+// SQLRefreshTokenStore 保存在数据库中, 驱动由调用方引入
+type SQLRefreshTokenStore struct {
+	db      *sql.DB
+	queries SQLRefreshTokenQueries
+}
+
+func NewSQLRefreshTokenStore(db *sql.DB, queries SQLRefreshTokenQueries) *SQLRefreshTokenStore {
+	return &SQLRefreshTokenStore{db: db, queries: queries}
+}
+
+func (s *SQLRefreshTokenStore) GetRefreshToken(ctx context.Context, key string) (string, error) {
+	token := ""
+	err := s.db.QueryRowContext(ctx, s.queries.Get, key).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("key %s, %w", key, ErrRefreshTokenNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *SQLRefreshTokenStore) SetRefreshToken(ctx context.Context, key, token string) error {
+	_, err := s.db.ExecContext(ctx, s.queries.Upsert, key, token)
+	return err
+}
+
+func (s *SQLRefreshTokenStore) DeleteRefreshToken(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.queries.Delete, key)
+	return err
+}
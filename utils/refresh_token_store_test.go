@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver 按照语句的前缀模拟 SELECT/INSERT/DELETE
+type fakeDriver struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+var testFakeDriver = &fakeDriver{tokens: map[string]string{}}
+
+// sql.Register 重复注册会 panic (go test -count=2)
+func init() {
+	sql.Register("weixin-fake", testFakeDriver)
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{driver: d}, nil }
+
+type fakeConn struct{ driver *fakeDriver }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *fakeConn) ExecContext(
+	_ context.Context, query string, args []driver.NamedValue,
+) (driver.Result, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	key := args[0].Value.(string)
+	if strings.HasPrefix(query, "INSERT") {
+		c.driver.tokens[key] = args[1].Value.(string)
+	} else {
+		delete(c.driver.tokens, key)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(
+	_ context.Context, query string, args []driver.NamedValue,
+) (driver.Rows, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	rows := &fakeRows{}
+	if token, ok := c.driver.tokens[args[0].Value.(string)]; ok {
+		rows.tokens = []string{token}
+	}
+	return rows, nil
+}
+
+type fakeRows struct{ tokens []string }
+
+func (r *fakeRows) Columns() []string { return []string{"token"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.tokens) == 0 {
+		return io.EOF
+	}
+	dest[0], r.tokens = r.tokens[0], r.tokens[1:]
+	return nil
+}
+
+func testRefreshTokenStore(t *testing.T, store RefreshTokenStore) {
+	ctx := context.Background()
+	_, err := store.GetRefreshToken(ctx, "refresh-token.wxopen.appid")
+	require.True(t, errors.Is(err, ErrRefreshTokenNotFound))
+
+	require.Equal(t, nil, store.SetRefreshToken(ctx, "refresh-token.wxopen.appid", "token1"))
+	require.Equal(t, nil, store.SetRefreshToken(ctx, "refresh-token.wxopen.appid", "token2"))
+	token, err := store.GetRefreshToken(ctx, "refresh-token.wxopen.appid")
+	require.Equal(t, nil, err)
+	require.Equal(t, "token2", token)
+
+	require.Equal(t, nil, store.DeleteRefreshToken(ctx, "refresh-token.wxopen.appid"))
+	_, err = store.GetRefreshToken(ctx, "refresh-token.wxopen.appid")
+	require.True(t, errors.Is(err, ErrRefreshTokenNotFound))
+}
+
+func TestRefreshTokenStore(t *testing.T) {
+	testRefreshTokenStore(t, NewCacheRefreshTokenStore(memory.NewMemory()))
+
+	testFakeDriver.mu.Lock()
+	testFakeDriver.tokens = map[string]string{}
+	testFakeDriver.mu.Unlock()
+	db, err := sql.Open("weixin-fake", "")
+	require.Equal(t, nil, err)
+	defer db.Close()
+	testRefreshTokenStore(t, NewSQLRefreshTokenStore(db, MySQLRefreshTokenQueries("weixin_refresh_token")))
+}
@@ -0,0 +1,116 @@
+package utils
+
+// 回调的防重放, 签名只能保证内容没有被篡改, 截获的回调可以被无限次重放
+// 1. timestamp 必须在允许的时间偏差之内
+// 2. 同一个 timestamp + nonce + signature 只能处理成功一次
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var ErrorCallbackReplay = errors.New("callback replay")
+
+const (
+	defaultReplayMaxSkew   = 5 * time.Minute // 缺省允许的时间偏差
+	defaultReplayKeyPrefix = "weixin.callback.nonce"
+)
+
+type (
+	ReplayGuardOption  func(*replayGuardOptions)
+	replayGuardOptions struct {
+		maxSkew   time.Duration
+		keyPrefix string
+	}
+)
+
+// ReplayGuardOptWithMaxSkew 允许的时间偏差
+func ReplayGuardOptWithMaxSkew(maxSkew time.Duration) ReplayGuardOption {
+	return func(o *replayGuardOptions) {
+		o.maxSkew = maxSkew
+	}
+}
+
+// ReplayGuardOptWithKeyPrefix nonce 在缓存中的key前缀, 多个应用共用缓存时可以区分
+func ReplayGuardOptWithKeyPrefix(keyPrefix string) ReplayGuardOption {
+	return func(o *replayGuardOptions) {
+		o.keyPrefix = keyPrefix
+	}
+}
+
+// ReplayGuard 回调的防重放, nil 表示不检查
+type ReplayGuard struct {
+	cache   Cache
+	options replayGuardOptions
+	now     func() time.Time
+}
+
+func NewReplayGuard(cache Cache, options ...ReplayGuardOption) *ReplayGuard {
+	to := replayGuardOptions{
+		maxSkew:   defaultReplayMaxSkew,
+		keyPrefix: defaultReplayKeyPrefix,
+	}
+	for _, o := range options {
+		o(&to)
+	}
+	if to.maxSkew <= 0 {
+		to.maxSkew = defaultReplayMaxSkew
+	}
+
+	return &ReplayGuard{cache: cache, options: to, now: time.Now}
+}
+
+func (g *ReplayGuard) nonceKey(timestamp, nonce, signature string) string {
+	return fmt.Sprintf("%s.%s.%s.%s", g.options.keyPrefix, timestamp, nonce, signature)
+}
+
+// check 检查时间偏差
+func (g *ReplayGuard) check(timestamp string) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp '%s', %w", timestamp, ErrorCallbackReplay)
+	}
+	skew := g.now().Sub(time.Unix(ts, 0))
+	if skew > g.options.maxSkew || skew < -g.options.maxSkew {
+		return fmt.Errorf(
+			"timestamp '%s' out of window %s, %w", timestamp, g.options.maxSkew, ErrorCallbackReplay,
+		)
+	}
+	return nil
+}
+
+// Guard 检查通过并且记录nonce之后调用 process
+// cache 实现了 CacheSetNX 时原子的记录nonce, 并发的重放只有一个会被处理
+// 处理失败删除nonce, 微信的重试(相同的 timestamp/nonce)依然可以处理
+func (g *ReplayGuard) Guard(
+	ctx context.Context, timestamp, nonce, signature string, process func() error,
+) error {
+	if g == nil {
+		return process()
+	}
+
+	if err := g.check(timestamp); err != nil {
+		return err
+	}
+
+	// 超过时间偏差的回调会被拒绝, nonce 不需要保存更久
+	key := g.nonceKey(timestamp, nonce, signature)
+	claimed, err := claimDedupeKey(ctx, g.cache, key, 2*g.options.maxSkew)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf(
+			"nonce '%s' at '%s' already processed, %w", nonce, timestamp, ErrorCallbackReplay,
+		)
+	}
+
+	if err := process(); err != nil {
+		// 请求可能已经取消, 不使用请求的ctx
+		g.cache.Delete(context.Background(), key)
+		return err
+	}
+	return nil
+}
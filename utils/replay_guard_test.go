@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayGuard(t *testing.T) {
+	ctx := context.Background()
+	guard := NewReplayGuard(memory.NewMemory(), ReplayGuardOptWithMaxSkew(time.Minute))
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	calls := 0
+	process := func() error {
+		calls++
+		return nil
+	}
+
+	require.Equal(t, nil, guard.Guard(ctx, now, "nonce", "signature", process))
+	require.Equal(t, 1, calls)
+
+	// 重放
+	err := guard.Guard(ctx, now, "nonce", "signature", process)
+	require.True(t, errors.Is(err, ErrorCallbackReplay))
+	require.Equal(t, 1, calls)
+
+	// 超出时间窗口
+	for _, ts := range []string{
+		strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10),
+		strconv.FormatInt(time.Now().Add(2*time.Minute).Unix(), 10),
+		"invalid",
+	} {
+		err = guard.Guard(ctx, ts, "nonce2", "signature2", process)
+		require.True(t, errors.Is(err, ErrorCallbackReplay), ts)
+	}
+	require.Equal(t, 1, calls)
+
+	// 处理失败不记录, 微信重试可以再次处理
+	failed := errors.New("failed")
+	err = guard.Guard(ctx, now, "nonce3", "signature3", func() error { return failed })
+	require.True(t, errors.Is(err, failed))
+	require.Equal(t, nil, guard.Guard(ctx, now, "nonce3", "signature3", process))
+	require.Equal(t, 2, calls)
+
+	// 未开启
+	var disabled *ReplayGuard
+	require.Equal(t, nil, disabled.Guard(ctx, "invalid", "nonce", "signature", process))
+	require.Equal(t, 3, calls)
+}
+
+func TestReplayGuardConcurrent(t *testing.T) {
+	ctx := context.Background()
+	guard := NewReplayGuard(memory.NewMemory())
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	// 处理中的回调被并发重放, 只有一个会被处理
+	release := make(chan struct{})
+	results := make(chan error, 5)
+	var calls int32
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			results <- guard.Guard(ctx, now, "nonce", "signature", func() error {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return nil
+			})
+		}()
+	}
+
+	for i := 0; i < cap(results)-1; i++ {
+		err := <-results
+		require.True(t, errors.Is(err, ErrorCallbackReplay))
+	}
+	close(release)
+	require.Equal(t, nil, <-results)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
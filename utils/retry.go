@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// 请求失败的重试策略
+// -1 系统繁忙，服务器暂不可用，建议稍候重试。建议重试次数不超过3次。
+// https://developers.weixin.qq.com/doc/offiaccount/Getting_Started/Global_Return_Code.html
+type RetryPolicy struct {
+	MaxAttempts    int           // 最多请求次数(包含第一次), 小于等于1不重试
+	BaseDelay      time.Duration // 第一次重试前的等待时长, 之后指数增长
+	MaxDelay       time.Duration // 最长的等待时长
+	RetryableCodes []int64       // 需要重试的微信错误码
+	RetryHTTPError bool          // http 5xx, 连接被重置等错误是否重试
+	// POST 请求返回可重试的错误码, http 5xx, 连接中断/超时(服务器可能已经处理了请求)是否重试, 缺省不重试
+	// 通过 NewDedupeContext 提供了防重入id的请求不受影响
+	RetryPost bool
+}
+
+// NewRetryPolicy 缺省的重试策略, 最多请求3次, 重试 -1(系统繁忙) 和 http 错误
+// POST 请求只有提供了防重入id(NewDedupeContext)才重试
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		BaseDelay:      200 * time.Millisecond,
+		MaxDelay:       2 * time.Second,
+		RetryableCodes: []int64{-1},
+		RetryHTTPError: true,
+	}
+}
+
+// ClientOptWithRetryPolicy 设置重试策略, 缺省不重试
+func ClientOptWithRetryPolicy(policy *RetryPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+type retryContext int
+
+var (
+	noRetryContextKey = retryContext(0)
+	dedupeContextKey  = retryContext(1)
+)
+
+// NewNoRetryContext 不重试, 适合非幂等的请求(比如没有防重入id的消息发送)
+func NewNoRetryContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryContextKey, true)
+}
+
+// NewDedupeContext 非幂等的请求, 只有提供了防重入id(比如 client_msg_id)才允许重试
+// 服务器会根据防重入id去重, 所以连接中断/超时的 POST 请求也可以重试
+func NewDedupeContext(ctx context.Context, dedupeKey string) context.Context {
+	if dedupeKey == "" {
+		return NewNoRetryContext(ctx)
+	}
+	return context.WithValue(ctx, dedupeContextKey, dedupeKey)
+}
+
+func isRetryDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRetryContextKey).(bool)
+	return disabled
+}
+
+func dedupeKeyFromContext(ctx context.Context) string {
+	dedupeKey, _ := ctx.Value(dedupeContextKey).(string)
+	return dedupeKey
+}
+
+// httpStatusError 非200的http响应
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *httpStatusError) Error() string {
+	return "status " + e.Status
+}
+
+func (policy *RetryPolicy) shouldRetry(ctx context.Context, req *http.Request, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// 连接失败, 请求没有发出
+	if policy.RetryHTTPError && errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	// 其他错误(包括 -1 系统繁忙)服务器可能已经处理了请求, POST 需要防重入id或者显式开启
+	if req.Method == http.MethodPost &&
+		!policy.RetryPost &&
+		dedupeKeyFromContext(ctx) == "" {
+		return false
+	}
+
+	if code, ok := weixinErrorCode(err); ok {
+		for _, retryableCode := range policy.RetryableCodes {
+			if code == retryableCode {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !policy.RetryHTTPError {
+		return false
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	// 连接中断/超时
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// backoff 指数退避, 加上随机抖动 [d/2, d)
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	d := policy.BaseDelay << (attempt - 1)
+	if d <= 0 || (policy.MaxDelay > 0 && d > policy.MaxDelay) {
+		d = policy.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// sleepContext 等待, 如果 ctx 取消或者等待之后会超过 deadline, 返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// sendWithRetry 按重试策略发送请求
+func (client *Client) sendWithRetry(
+	ctx context.Context, req *http.Request, handler func(*http.Response) error,
+) error {
+	err := client.send(ctx, req, handler)
+	policy := client.retryPolicy
+	if policy == nil || isRetryDisabled(ctx) {
+		return err
+	}
+
+	for attempt := 1; err != nil && attempt < policy.MaxAttempts; attempt++ {
+		if !policy.shouldRetry(ctx, req, err) || !isReplayable(req) {
+			return err
+		}
+		if !sleepContext(ctx, policy.backoff(attempt)) {
+			return err
+		}
+
+		retry, e := cloneRequest(ctx, req, req.URL.String())
+		if e != nil {
+			return err
+		}
+		err = client.send(ctx, retry, handler)
+	}
+	return err
+}
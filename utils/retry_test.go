@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRetry(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/busy":
+			w.Header().Set("Content-Type", "application/json")
+			if requests < 3 {
+				io.WriteString(w, `{"errcode":-1,"errmsg":"system busy"}`)
+				return
+			}
+		case "/5xx":
+			if requests < 2 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		case "/drop":
+			if requests < 2 {
+				// 读取请求之后断开连接, 客户端收到 EOF
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+		case "/quota":
+			io.WriteString(w, `{"errcode":45009,"errmsg":"reach max api daily quota limit"}`)
+			return
+		}
+		io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	policy := &RetryPolicy{
+		MaxAttempts:    3,
+		BaseDelay:      time.Millisecond,
+		MaxDelay:       5 * time.Millisecond,
+		RetryableCodes: []int64{-1},
+		RetryHTTPError: true,
+	}
+	client := NewClient(
+		server.URL, StaticClientAccessTokenGetter("token"), ClientOptWithRetryPolicy(policy),
+	)
+
+	// -1 重试
+	requests = 0
+	require.Equal(t, nil, client.HTTPGet(ctx, "/busy", nil))
+	require.Equal(t, 3, requests)
+
+	// -1 时服务器可能已经处理了请求, POST 缺省不重试
+	requests = 0
+	err := client.HTTPPostJson(ctx, "/busy", map[string]int{"a": 1}, nil)
+	require.True(t, errors.Is(err, ErrorSystemBusy))
+	require.Equal(t, 1, requests)
+
+	// 提供了防重入id的 POST 重试
+	requests = 0
+	require.Equal(t, nil, client.HTTPPostJson(
+		NewDedupeContext(ctx, "msgid"), "/busy", map[string]int{"a": 1}, nil,
+	))
+	require.Equal(t, 3, requests)
+
+	// 5xx 重试
+	requests = 0
+	require.Equal(t, nil, client.HTTPGet(ctx, "/5xx", nil))
+	require.Equal(t, 2, requests)
+
+	// 其他错误码不重试
+	requests = 0
+	err = client.HTTPGet(ctx, "/quota", nil)
+	require.NotEqual(t, nil, err)
+	require.Equal(t, 1, requests)
+
+	// 非幂等请求不重试
+	requests = 0
+	err = client.HTTPGet(NewDedupeContext(ctx, ""), "/busy", nil)
+	require.True(t, errors.Is(err, ErrorSystemBusy))
+	require.Equal(t, 1, requests)
+
+	// 连接中断, GET 重试
+	requests = 0
+	require.Equal(t, nil, client.HTTPGet(ctx, "/drop", nil))
+	require.Equal(t, 2, requests)
+
+	// 连接中断, POST 缺省不重试
+	requests = 0
+	err = client.HTTPPostJson(ctx, "/drop", map[string]int{"a": 1}, nil)
+	require.NotEqual(t, nil, err)
+	require.Equal(t, 1, requests)
+
+	// 提供了防重入id的 POST 重试
+	requests = 0
+	err = client.HTTPPostJson(NewDedupeContext(ctx, "msgid"), "/drop", map[string]int{"a": 1}, nil)
+	require.Equal(t, nil, err)
+	require.Equal(t, 2, requests)
+
+	// 显式开启
+	policy.RetryPost = true
+	requests = 0
+	require.Equal(t, nil, client.HTTPPostJson(ctx, "/drop", map[string]int{"a": 1}, nil))
+	require.Equal(t, 2, requests)
+
+	// 超过 deadline 不再等待
+	policy.BaseDelay, policy.MaxDelay = time.Second, time.Second
+	requests = 0
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	err = client.HTTPGet(timeoutCtx, "/busy", nil)
+	require.True(t, errors.Is(err, ErrorSystemBusy))
+	require.Equal(t, 1, requests)
+}
@@ -0,0 +1,229 @@
+package utils
+
+// OpenTelemetry 的 trace 和 metrics
+// 缺省使用全局的 TracerProvider / MeterProvider (otel.SetTracerProvider), 没有设置时不产生任何数据
+// Client 可以通过 ClientOptWithTracerProvider / ClientOptWithMeterProvider 单独指定, 同时用于 AccessTokenCache 的 token 刷新
+//
+//	span:   每次接口调用(名称为接口路径), token 刷新, 回调解密/分发
+//	metric: 接口耗时, 错误码, token 刷新次数
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/lixinio/weixin"
+
+const (
+	AttributeAPI           = attribute.Key("weixin.api")            // 接口路径
+	AttributeAppID         = attribute.Key("weixin.appid")          // appid / corpid / suiteid
+	AttributeErrCode       = attribute.Key("weixin.errcode")        // 微信的错误码
+	AttributeAttempt       = attribute.Key("weixin.attempt")        // 第几次尝试(重试)
+	AttributeTokenReplayed = attribute.Key("weixin.token_replayed") // token 失效之后重放
+	AttributeTokenKey      = attribute.Key("weixin.token_key")      // token 在缓存中的 key
+	AttributeResult        = attribute.Key("weixin.result")         // ok / error
+	attributeMethod        = attribute.Key("http.request.method")
+	attributeURL           = attribute.Key("url.full")
+)
+
+// ClientOptionsWithAppID 在 options 之前加上 ClientOptWithAppID, 调用方的配置优先
+func ClientOptionsWithAppID(appid string, options []ClientOption) []ClientOption {
+	return append([]ClientOption{ClientOptWithAppID(appid)}, options...)
+}
+
+type telemetry struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	errcodes metric.Int64Counter
+	refresh  metric.Int64Counter
+}
+
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider) *telemetry {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+
+	// 出错时返回的是 noop 的 instrument, 可以继续使用
+	duration, _ := meter.Float64Histogram(
+		"weixin.client.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("微信接口调用耗时"),
+	)
+	errcodes, _ := meter.Int64Counter(
+		"weixin.client.errcode",
+		metric.WithDescription("微信接口返回的错误码"),
+	)
+	refresh, _ := meter.Int64Counter(
+		"weixin.token.refresh",
+		metric.WithDescription("从微信服务器刷新token的次数"),
+	)
+	return &telemetry{
+		tracer:   tp.Tracer(instrumentationName),
+		duration: duration,
+		errcodes: errcodes,
+		refresh:  refresh,
+	}
+}
+
+var (
+	globalTelemetry     *telemetry
+	globalTelemetryOnce sync.Once
+)
+
+// defaultTelemetry 全局的 provider 是代理, 之后设置的 provider 也会生效
+func defaultTelemetry() *telemetry {
+	globalTelemetryOnce.Do(func() {
+		globalTelemetry = newTelemetry(nil, nil)
+	})
+	return globalTelemetry
+}
+
+type callTelemetryContext int
+
+var callTelemetryContextKey = callTelemetryContext(0)
+
+// callTelemetry 一次接口调用, 包含重试和token重放
+type callTelemetry struct {
+	telemetry *telemetry
+	span      trace.Span
+	api       string
+	appid     string
+	start     time.Time
+	attempts  int
+}
+
+func (t *telemetry) startCall(
+	ctx context.Context, method, uri, appid string,
+) (context.Context, *callTelemetry) {
+	api := uri
+	if u, err := url.Parse(uri); err == nil {
+		api = u.Path
+	}
+	attrs := []attribute.KeyValue{AttributeAPI.String(api), attributeMethod.String(method)}
+	if appid != "" {
+		attrs = append(attrs, AttributeAppID.String(appid))
+	}
+
+	ctx, span := t.tracer.Start(
+		ctx, api, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...),
+	)
+	call := &callTelemetry{telemetry: t, span: span, api: api, appid: appid, start: time.Now()}
+	return context.WithValue(ctx, callTelemetryContextKey, call), call
+}
+
+func callTelemetryFromContext(ctx context.Context) *callTelemetry {
+	call, _ := ctx.Value(callTelemetryContextKey).(*callTelemetry)
+	return call
+}
+
+// attempt 每次发送请求(包括重试)时调用, 记录脱敏之后的url
+func (call *callTelemetry) attempt(ctx context.Context, u *url.URL, tokenKey string) {
+	if call == nil {
+		return
+	}
+	call.attempts++
+	call.span.SetAttributes(attributeURL.String(redactURL(ctx, u, tokenKey)))
+	call.span.AddEvent("attempt", trace.WithAttributes(AttributeAttempt.Int(call.attempts)))
+}
+
+// replayed token 失效之后重放
+func (call *callTelemetry) replayed() {
+	call.span.SetAttributes(AttributeTokenReplayed.Bool(true))
+}
+
+func (call *callTelemetry) end(ctx context.Context, err error) {
+	attrs := []attribute.KeyValue{AttributeAPI.String(call.api)}
+	if call.appid != "" {
+		attrs = append(attrs, AttributeAppID.String(call.appid))
+	}
+
+	errCode := int64(0)
+	var we *WeixinError
+	if errors.As(err, &we) {
+		errCode = we.ErrCode
+	}
+	if errCode != 0 {
+		call.telemetry.errcodes.Add(ctx, 1, metric.WithAttributes(
+			append(attrs, AttributeErrCode.Int64(errCode))...,
+		))
+	}
+
+	call.telemetry.duration.Record(
+		ctx, time.Since(call.start).Seconds(),
+		metric.WithAttributes(append(attrs, AttributeErrCode.Int64(errCode))...),
+	)
+
+	call.span.SetAttributes(AttributeErrCode.Int64(errCode), AttributeAttempt.Int(call.attempts))
+	EndSpan(call.span, err)
+}
+
+// refreshToken 从微信服务器刷新token
+func (t *telemetry) refreshToken(
+	ctx context.Context, tokenKey string, refresh func(context.Context) error,
+) {
+	attrs := []attribute.KeyValue{AttributeTokenKey.String(tokenKey)}
+	ctx, span := t.tracer.Start(ctx, "weixin.token.refresh", trace.WithAttributes(attrs...))
+	err := refresh(ctx)
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	t.refresh.Add(ctx, 1, metric.WithAttributes(append(attrs, AttributeResult.String(result))...))
+	EndSpan(span, err)
+}
+
+// StartCallbackSpan 回调处理(解密, 分发)的 span, 使用全局的 TracerProvider
+func StartCallbackSpan(
+	ctx context.Context, name string, attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return defaultTelemetry().tracer.Start(
+		ctx, "weixin.callback."+name,
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...),
+	)
+}
+
+// EndSpan 记录错误并结束 span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// redactURL 移除 access-token 以及 NewStripContext 指定的参数(secret等)
+func redactURL(ctx context.Context, u *url.URL, tokenKey string) string {
+	q := u.Query()
+	edit := false
+	keys := []string{tokenKey}
+	if stripKeys, ok := parseStripContext(ctx); ok {
+		keys = append(keys, stripKeys...)
+	}
+	for _, key := range keys {
+		if q.Get(key) != "" {
+			q.Set(key, "")
+			edit = true
+		}
+	}
+	if !edit {
+		return u.String()
+	}
+
+	redacted := *u
+	redacted.RawQuery = q.Encode()
+	redacted.User = nil
+	return redacted.String()
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestClientTelemetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("access_token") == "stale" {
+			io.WriteString(w, `{"errcode":40014,"errmsg":"invalid access_token"}`)
+			return
+		}
+		if r.URL.Path == "/cgi-bin/fail" {
+			io.WriteString(w, `{"errcode":40003,"errmsg":"invalid openid"}`)
+			return
+		}
+		io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	getter := &rotateTokenGetter{tokens: []string{"stale", "fresh"}}
+	client := NewClient(
+		server.URL, getter,
+		ClientOptionsWithAppID("wxappid", []ClientOption{
+			ClientOptWithTracerProvider(tp), ClientOptWithMeterProvider(mp),
+		})...,
+	)
+
+	// token 失效之后重放
+	require.Equal(t, nil, client.HTTPGet(ctx, "/cgi-bin/get", nil))
+	err := client.HTTPGet(ctx, "/cgi-bin/fail", nil)
+	code, ok := weixinErrorCode(err)
+	require.True(t, ok)
+	require.Equal(t, int64(40003), code)
+
+	spans := recorder.Ended()
+	require.Equal(t, 2, len(spans))
+
+	span := spans[0]
+	require.Equal(t, "/cgi-bin/get", span.Name())
+	require.Equal(t, "wxappid", spanAttribute(span, AttributeAppID).AsString())
+	require.Equal(t, true, spanAttribute(span, AttributeTokenReplayed).AsBool())
+	require.Equal(t, int64(2), spanAttribute(span, AttributeAttempt).AsInt64())
+	require.Equal(t, int64(0), spanAttribute(span, AttributeErrCode).AsInt64())
+	// token 不能出现在 span 中
+	require.False(t, strings.Contains(spanAttribute(span, attributeURL).AsString(), "fresh"))
+
+	span = spans[1]
+	require.Equal(t, "/cgi-bin/fail", span.Name())
+	require.Equal(t, int64(40003), spanAttribute(span, AttributeErrCode).AsInt64())
+	require.Equal(t, "Error", span.Status().Code.String())
+
+	rm := metricdata.ResourceMetrics{}
+	require.Equal(t, nil, reader.Collect(ctx, &rm))
+	metrics := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	duration, ok := metrics["weixin.client.duration"].(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Equal(t, 2, len(duration.DataPoints))
+
+	errcodes, ok := metrics["weixin.client.errcode"].(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Equal(t, 1, len(errcodes.DataPoints))
+	value, _ := errcodes.DataPoints[0].Attributes.Value(AttributeErrCode)
+	require.Equal(t, int64(40003), value.AsInt64())
+}
+
+func TestTokenRefreshTelemetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	mem := memory.NewMemory()
+	cache := NewAccessTokenCache(&countTokenGetter{key: "telemetry"}, mem, mem)
+	client := NewClient(
+		server.URL, cache, ClientOptWithTracerProvider(tp), ClientOptWithMeterProvider(mp),
+	)
+
+	// 从服务器获取token, 使用 client 的 provider
+	require.Equal(t, nil, client.HTTPGet(ctx, "/cgi-bin/get", nil))
+	// 外部更新的token不记录
+	_, err := cache.UpdateAccessToken(ctx, "token-x", 7200)
+	require.Equal(t, nil, err)
+
+	refreshSpans := 0
+	for _, span := range recorder.Ended() {
+		if span.Name() == "weixin.token.refresh" {
+			refreshSpans++
+			require.Equal(t, "telemetry", spanAttribute(span, AttributeTokenKey).AsString())
+		}
+	}
+	require.Equal(t, 1, refreshSpans)
+
+	rm := metricdata.ResourceMetrics{}
+	require.Equal(t, nil, reader.Collect(ctx, &rm))
+	var refresh metricdata.Sum[int64]
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "weixin.token.refresh" {
+				refresh, _ = m.Data.(metricdata.Sum[int64])
+			}
+		}
+	}
+	require.Equal(t, 1, len(refresh.DataPoints))
+	require.Equal(t, int64(1), refresh.DataPoints[0].Value)
+}
+
+func TestMiddlewareSpanRedaction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// 模拟 otelhttp, 创建 span 并记录完整的url
+	tracing := func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx, span := tp.Tracer("otelhttp").Start(req.Context(), "HTTP GET")
+			defer span.End()
+			span.SetAttributes(attributeURL.String(req.URL.String()))
+			return next.RoundTrip(req.WithContext(ctx))
+		})
+	}
+	client := NewClient(server.URL, &rotateTokenGetter{tokens: []string{"secret-token"}}, ClientOptWithMiddleware(tracing))
+	require.Equal(t, nil, client.HTTPGet(
+		NewStripContext(context.Background(), "secret"), "/cgi-bin/get?secret=app-secret", nil,
+	))
+
+	spans := recorder.Ended()
+	require.Equal(t, 1, len(spans))
+	u := spanAttribute(spans[0], attributeURL).AsString()
+	require.True(t, strings.Contains(u, "/cgi-bin/get"))
+	require.False(t, strings.Contains(u, "secret-token"))
+	require.False(t, strings.Contains(u, "app-secret"))
+}
@@ -0,0 +1,162 @@
+package utils
+
+// 后台定时检查token的剩余时间, 在过期之前主动刷新, 避免token到期时大量请求争抢刷新
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefresherInterval = time.Minute      // 缺省每分钟检查一次
+	defaultRefresherJitter   = 60 * time.Second // 缺省在提前刷新的时间上随机增加0~60秒
+)
+
+// TokenRefreshReport 每次检查的结果, 用于监控或者记录日志
+type TokenRefreshReport struct {
+	Name      string        // 注册时的名称
+	Key       string        // token的缓存key
+	TTL       int           // 检查时的剩余时间(秒), 不存在为-2
+	Refreshed bool          // 是否刷新了token
+	Duration  time.Duration // 本次检查(刷新)的耗时
+	Err       error
+}
+
+type (
+	TokenRefreshReporter  func(context.Context, *TokenRefreshReport)
+	TokenRefresherOption  func(*tokenRefresherOptions)
+	tokenRefresherOptions struct {
+		interval  time.Duration
+		beforeTTL int
+		jitter    time.Duration
+		reporter  TokenRefreshReporter
+	}
+)
+
+// TokenRefresherOptWithInterval 检查的间隔
+func TokenRefresherOptWithInterval(interval time.Duration) TokenRefresherOption {
+	return func(o *tokenRefresherOptions) {
+		o.interval = interval
+	}
+}
+
+// TokenRefresherOptWithBeforeTTL 剩余时间(秒)小于多少时刷新, 缺省5分钟
+func TokenRefresherOptWithBeforeTTL(beforeTTL int) TokenRefresherOption {
+	return func(o *tokenRefresherOptions) {
+		o.beforeTTL = beforeTTL
+	}
+}
+
+// TokenRefresherOptWithJitter 随机提前的时长, 避免多个实例/多个token在同一时刻刷新, 0 表示不随机
+func TokenRefresherOptWithJitter(jitter time.Duration) TokenRefresherOption {
+	return func(o *tokenRefresherOptions) {
+		o.jitter = jitter
+	}
+}
+
+// TokenRefresherOptWithReporter 每次检查之后回调, 可以用于上报监控指标和错误
+func TokenRefresherOptWithReporter(reporter TokenRefreshReporter) TokenRefresherOption {
+	return func(o *tokenRefresherOptions) {
+		o.reporter = reporter
+	}
+}
+
+// TokenRefresher 管理多个 AccessTokenCache(公众号, 第三方平台, 服务商, 授权方, jsapi ticket 等),
+// 定时检查剩余时间并主动刷新
+type TokenRefresher struct {
+	options tokenRefresherOptions
+	mu      sync.Mutex
+	caches  map[string]*AccessTokenCache
+}
+
+func NewTokenRefresher(options ...TokenRefresherOption) *TokenRefresher {
+	to := tokenRefresherOptions{
+		interval:  defaultRefresherInterval,
+		beforeTTL: defaultExpireBefore,
+		jitter:    defaultRefresherJitter,
+	}
+	for _, o := range options {
+		o(&to)
+	}
+	if to.interval <= 0 {
+		to.interval = defaultRefresherInterval
+	}
+	if to.beforeTTL <= 0 {
+		to.beforeTTL = defaultExpireBefore
+	}
+
+	return &TokenRefresher{
+		options: to,
+		caches:  map[string]*AccessTokenCache{},
+	}
+}
+
+// Register 注册需要刷新的token, 同名的会被替换
+func (tr *TokenRefresher) Register(name string, cache *AccessTokenCache) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.caches[name] = cache
+}
+
+// Unregister 取消注册, 比如授权方取消授权之后
+func (tr *TokenRefresher) Unregister(name string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	delete(tr.caches, name)
+}
+
+// Run 定时检查, 直到 ctx 取消, 一般在单独的 goroutine 中运行
+func (tr *TokenRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(tr.options.interval)
+	defer ticker.Stop()
+
+	for {
+		tr.RefreshOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshOnce 检查所有注册的token, 即将过期的刷新
+func (tr *TokenRefresher) RefreshOnce(ctx context.Context) {
+	tr.mu.Lock()
+	names := make([]string, 0, len(tr.caches))
+	caches := make([]*AccessTokenCache, 0, len(tr.caches))
+	for name, cache := range tr.caches {
+		names = append(names, name)
+		caches = append(caches, cache)
+	}
+	tr.mu.Unlock()
+
+	for i, cache := range caches {
+		if ctx.Err() != nil {
+			return
+		}
+		tr.refresh(ctx, names[i], cache)
+	}
+}
+
+func (tr *TokenRefresher) refresh(ctx context.Context, name string, cache *AccessTokenCache) {
+	beforeTTL := tr.options.beforeTTL
+	if jitter := int(tr.options.jitter / time.Second); jitter > 0 {
+		beforeTTL += rand.Intn(jitter + 1)
+	}
+
+	start := time.Now()
+	accessToken, ttl, err := cache.refreshAccessTokenBefore(ctx, beforeTTL)
+	if tr.options.reporter != nil {
+		tr.options.reporter(ctx, &TokenRefreshReport{
+			Name:      name,
+			Key:       cache.accessTokenGetter.GetAccessTokenKey(),
+			TTL:       ttl,
+			Refreshed: accessToken != "",
+			Duration:  time.Since(start),
+			Err:       err,
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type countTokenGetter struct {
+	key   string
+	count int32
+}
+
+func (g *countTokenGetter) GetAccessToken(context.Context) (string, int, error) {
+	n := atomic.AddInt32(&g.count, 1)
+	return fmt.Sprintf("token-%d", n), 7200, nil
+}
+
+func (g *countTokenGetter) GetAccessTokenKey() string {
+	return g.key
+}
+
+func (g *countTokenGetter) GetAccessTokenLockKey() string {
+	return g.key + ".lock"
+}
+
+func TestTokenRefresher(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.NewMemory()
+
+	fresh := &countTokenGetter{key: "fresh"}
+	freshCache := NewAccessTokenCache(fresh, mem, mem)
+	_, err := freshCache.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+
+	expiring := &countTokenGetter{key: "expiring"}
+	require.Equal(t, nil, mem.Set(ctx, "expiring", "token-0", 100*time.Second))
+	expiringCache := NewAccessTokenCache(expiring, mem, mem)
+
+	reports := map[string]*TokenRefreshReport{}
+	refresher := NewTokenRefresher(
+		TokenRefresherOptWithJitter(0),
+		TokenRefresherOptWithReporter(func(_ context.Context, report *TokenRefreshReport) {
+			reports[report.Name] = report
+		}),
+	)
+	refresher.Register("fresh", freshCache)
+	refresher.Register("expiring", expiringCache)
+	refresher.Register("missing", NewAccessTokenCache(&countTokenGetter{key: "missing"}, mem, mem))
+	refresher.RefreshOnce(ctx)
+
+	require.Equal(t, int32(1), fresh.count)
+	require.False(t, reports["fresh"].Refreshed)
+	require.True(t, reports["expiring"].Refreshed)
+	require.Equal(t, 100, reports["expiring"].TTL)
+	require.True(t, reports["missing"].Refreshed)
+	require.Equal(t, -2, reports["missing"].TTL)
+
+	token, err := expiringCache.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-1", token)
+
+	// 已经刷新了, 不会重复刷新
+	refresher.Unregister("missing")
+	reports = map[string]*TokenRefreshReport{}
+	refresher.RefreshOnce(ctx)
+	require.Equal(t, int32(1), expiring.count)
+	require.Len(t, reports, 2)
+
+	// ctx 取消之后退出
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		refresher.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresher not stopped")
+	}
+}
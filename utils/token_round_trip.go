@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"reflect"
+	"strings"
 
-	"go.opencensus.io/plugin/ochttp"
-	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // 在Trace的时候， 移除access-token / secret
 // 	secret : https://developers.weixin.qq.com/doc/offiaccount/OA_Web_Apps/Wechat_webpage_authorization.html
+// 脱敏的 Transport 位于中间件的里层, 中间件(otelhttp 等)创建的 OpenTelemetry span 中的url会被覆盖
+// OpenCensus(ochttp) 需要使用 opencensus 构建标签, 见 token_round_trip_opencensus.go
+// 没有构建标签时使用 ochttp 会直接 panic, 避免 access-token 被记录
 
 type (
 	AccessTokenStripTransport struct {
@@ -22,6 +26,15 @@ type (
 
 var stripKeyContextKey = stripKeyContext(0)
 
+var (
+	// stripSpanURL 替换 ochttp span 中记录的url, 由 opencensus 构建标签设置
+	stripSpanURL func(ctx context.Context, req *http.Request, defaultKey string)
+	// wrapOpenCensusTransport 把脱敏的 Transport 放到 ochttp.Transport 里层, 由 opencensus 构建标签设置
+	wrapOpenCensusTransport func(
+		transport http.RoundTripper, strip *AccessTokenStripTransport,
+	) (http.RoundTripper, bool)
+)
+
 func NewStripContext(ctx context.Context, keys ...string) context.Context {
 	return context.WithValue(ctx, stripKeyContextKey, keys)
 }
@@ -38,41 +51,17 @@ func parseStripContext(ctx context.Context) ([]string, bool) {
 }
 
 func (t *AccessTokenStripTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	resp, err := t.Base.RoundTrip(req)
 	ctx := req.Context()
-
-	span := trace.FromContext(ctx)
-	if span == nil {
-		return resp, err
-	}
-
-	u := req.URL
-	q := u.Query()
-
-	// 如果存在， 重置
-	edit := false
-	if q.Get(t.defaultKey) != "" {
-		q.Set(t.defaultKey, "")
-		edit = true
-	}
-
-	if stripKeys, ok := parseStripContext(ctx); ok {
-		for _, stripKey := range stripKeys {
-			if q.Get(stripKey) != "" {
-				q.Set(stripKey, "")
-				edit = true
-			}
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		// 覆盖原来的Url
+		if redacted := redactURL(ctx, req.URL, t.defaultKey); redacted != req.URL.String() {
+			span.SetAttributes(attributeURL.String(redacted))
 		}
 	}
-
-	if edit {
-		// 覆盖原来的Url
-		span.AddAttributes(trace.StringAttribute(
-			ochttp.URLAttribute,
-			fmt.Sprintf("%s://%s%s?%s", u.Scheme, u.Host, u.Path, q.Encode()),
-		))
+	if stripSpanURL != nil {
+		stripSpanURL(ctx, req, t.defaultKey)
 	}
-	return resp, err
+	return t.Base.RoundTrip(req)
 }
 
 func NewAccessTokenStripTransport(defaultKey string) *AccessTokenStripTransport {
@@ -81,3 +70,32 @@ func NewAccessTokenStripTransport(defaultKey string) *AccessTokenStripTransport
 		Base:       http.DefaultTransport,
 	}
 }
+
+// wrap 脱敏的 Transport 放在 transport 和中间件之间
+func (t *AccessTokenStripTransport) wrap(transport http.RoundTripper) http.RoundTripper {
+	if wrapOpenCensusTransport != nil {
+		if wrapped, ok := wrapOpenCensusTransport(transport, t); ok {
+			return wrapped
+		}
+	}
+	mustRedactable(transport)
+	t.Base = transport
+	return t
+}
+
+// mustRedactable 没有 opencensus 构建标签时, ochttp 记录的url无法脱敏
+func mustRedactable(transport http.RoundTripper) {
+	if stripSpanURL != nil {
+		return
+	}
+	tp := reflect.TypeOf(transport)
+	for tp != nil && tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp != nil && strings.HasPrefix(tp.PkgPath(), "go.opencensus.io/") {
+		panic(fmt.Errorf(
+			"transport %T records access_token in OpenCensus spans, build with -tags opencensus",
+			transport,
+		))
+	}
+}
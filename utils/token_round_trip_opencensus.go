@@ -0,0 +1,42 @@
+//go:build opencensus
+
+package utils
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+// 使用 ochttp 时, 构建时加上 -tags opencensus, 移除 span 中记录的 access-token / secret
+func init() {
+	stripSpanURL = func(ctx context.Context, req *http.Request, defaultKey string) {
+		span := trace.FromContext(ctx)
+		if span == nil {
+			return
+		}
+		// 覆盖原来的Url
+		if redacted := redactURL(ctx, req.URL, defaultKey); redacted != req.URL.String() {
+			span.AddAttributes(trace.StringAttribute(ochttp.URLAttribute, redacted))
+		}
+	}
+
+	// ochttp.Transport 作为底层 Transport 时, 脱敏需要在它创建的 span 之内
+	wrapOpenCensusTransport = func(
+		transport http.RoundTripper, strip *AccessTokenStripTransport,
+	) (http.RoundTripper, bool) {
+		oc, ok := transport.(*ochttp.Transport)
+		if !ok {
+			return nil, false
+		}
+		wrapped := *oc
+		strip.Base = oc.Base
+		if strip.Base == nil {
+			strip.Base = http.DefaultTransport
+		}
+		wrapped.Base = strip
+		return &wrapped, true
+	}
+}
@@ -0,0 +1,21 @@
+//go:build opencensus
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/plugin/ochttp"
+)
+
+func TestOpenCensusTransportWrap(t *testing.T) {
+	// 脱敏的 Transport 在 ochttp 里层, 才能拿到 ochttp 创建的 span
+	oc := &ochttp.Transport{}
+	client := NewClient("http://127.0.0.1", nil, ClientOptWithTransport(oc))
+	wrapped, ok := client.httpClient.Transport.(*ochttp.Transport)
+	require.True(t, ok)
+	require.Equal(t, client.stripTransport, wrapped.Base)
+	// 调用方的对象不会被修改
+	require.Nil(t, oc.Base)
+}
@@ -0,0 +1,20 @@
+//go:build !opencensus
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/plugin/ochttp"
+)
+
+func TestOpenCensusTransportWithoutTag(t *testing.T) {
+	// 没有 opencensus 构建标签时, ochttp 记录的url无法脱敏
+	require.Panics(t, func() {
+		NewClient("http://127.0.0.1", nil, ClientOptWithTransport(&ochttp.Transport{}))
+	})
+	require.NotPanics(t, func() {
+		NewClient("http://127.0.0.1", nil)
+	})
+}
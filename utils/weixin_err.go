@@ -3,14 +3,40 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"regexp"
 )
 
 var (
 	ErrorAccessToken = errors.New("access token error")
 	ErrorSystemBusy  = errors.New("system busy")
-	ErrorWeixinError = errors.New("system busy")
+	ErrorWeixinError = errors.New("weixin error")
+	ErrorLockTimeout = errors.New("lock timeout")
+	// 解密之后的 appId/corpId/suiteId 和配置的不一致
+	ErrorReceiverIDMismatch = errors.New("receiver id mismatch")
 )
 
+// errmsg 中携带的排查信息
+//
+//	公众号 : "invalid credential, access_token is invalid or not latest rid: 6527ad8a-7ad3c466-1ab34bb7"
+//	企业微信 : "invalid access_token, hint: [1612345678_12_abcdef], from ip: 1.2.3.4, more info at ..."
+var (
+	hintRegexp = regexp.MustCompile(`hint: \[([^\]]+)\]`)
+	ridRegexp  = regexp.MustCompile(`rid: ([0-9a-zA-Z-]+)`)
+)
+
+func isAccessTokenErrorCode(code int64) bool {
+	return ErrAccessTokenInvalid.has(code)
+}
+
+// weixinErrorCode 从错误中获取微信的错误码
+func weixinErrorCode(err error) (int64, bool) {
+	var we *WeixinError
+	if errors.As(err, &we) {
+		return we.ErrCode, true
+	}
+	return 0, false
+}
+
 type WeixinErrorInterface interface {
 	WeixinErrorCode() int64
 	WeixinErrorMessage() string
@@ -41,3 +67,53 @@ func (we *WeixinError) GetWeixinError() error {
 func (we *WeixinError) Error() string {
 	return fmt.Sprintf("%d: %s", we.ErrCode, we.ErrMsg)
 }
+
+// Is 支持 errors.Is, 可以和错误分类(ErrQuotaExceeded等), 错误码, 以及
+// ErrorAccessToken / ErrorSystemBusy / ErrorWeixinError 比较
+func (we *WeixinError) Is(target error) bool {
+	switch t := target.(type) {
+	case *ErrorKind:
+		return t.has(we.ErrCode)
+	case *WeixinError:
+		return t.ErrCode == we.ErrCode
+	}
+
+	switch target {
+	case ErrorWeixinError:
+		return true
+	case ErrorAccessToken:
+		return isAccessTokenErrorCode(we.ErrCode)
+	case ErrorSystemBusy:
+		return we.ErrCode == -1
+	}
+	return false
+}
+
+// Kind 错误码所属的分类, 不在错误码目录中返回nil
+func (we *WeixinError) Kind() *ErrorKind {
+	return errorKinds[we.ErrCode]
+}
+
+// Category 错误码的类别(认证, 配额, 权限, 参数, 服务端)
+func (we *WeixinError) Category() ErrorCategory {
+	if kind := we.Kind(); kind != nil {
+		return kind.category
+	}
+	return ErrorCategoryUnknown
+}
+
+// Hint 企业微信错误信息中的 hint, 用于向腾讯反馈问题
+func (we *WeixinError) Hint() string {
+	if m := hintRegexp.FindStringSubmatch(we.ErrMsg); len(m) == 2 {
+		return m[1]
+	}
+	return ""
+}
+
+// Rid 公众号/小程序错误信息中的 rid, 可以在 mp 后台查询请求详情
+func (we *WeixinError) Rid() string {
+	if m := ridRegexp.FindStringSubmatch(we.ErrMsg); len(m) == 2 {
+		return m[1]
+	}
+	return ""
+}
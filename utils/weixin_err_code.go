@@ -0,0 +1,175 @@
+package utils
+
+// 常见错误码目录
+// 公众号/小程序 https://developers.weixin.qq.com/doc/offiaccount/Getting_Started/Global_Return_Code.html
+// 开放平台 https://developers.weixin.qq.com/doc/oplatform/Return_codes/Return_code_descriptions_new.html
+// 企业微信 https://developer.work.weixin.qq.com/document/path/90313
+//
+// 目录是手工维护的, 没有 go:generate:
+// 上面的文档只有网页, 没有可以用来生成代码的数据源(错误码和说明也经常变化),
+// 而且只收录了需要区分处理的常见错误码, 新增错误码直接加到对应的分类中
+
+// ErrorCategory 错误码的类别
+type ErrorCategory string
+
+const (
+	ErrorCategoryUnknown    ErrorCategory = ""
+	ErrorCategoryAuth       ErrorCategory = "auth"       // 凭证错误, 一般需要刷新token或者检查配置
+	ErrorCategoryQuota      ErrorCategory = "quota"      // 调用次数/频率超限
+	ErrorCategoryPermission ErrorCategory = "permission" // 没有权限, 未授权
+	ErrorCategoryParam      ErrorCategory = "param"      // 参数错误
+	ErrorCategoryServer     ErrorCategory = "server"     // 腾讯服务端错误, 可以重试
+)
+
+// ErrorKind 一组含义相同的错误码, 用于 errors.Is(err, utils.ErrQuotaExceeded)
+type ErrorKind struct {
+	name     string
+	category ErrorCategory
+	codes    []int64
+}
+
+func newErrorKind(name string, category ErrorCategory, codes ...int64) *ErrorKind {
+	return &ErrorKind{name: name, category: category, codes: codes}
+}
+
+func (kind *ErrorKind) Error() string {
+	return kind.name
+}
+
+func (kind *ErrorKind) Category() ErrorCategory {
+	return kind.category
+}
+
+func (kind *ErrorKind) Codes() []int64 {
+	return kind.codes
+}
+
+func (kind *ErrorKind) has(code int64) bool {
+	for _, c := range kind.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrAccessTokenInvalid = newErrorKind(
+		"access token invalid", ErrorCategoryAuth,
+		40001, // 获取 access_token 时 AppSecret 错误，或者 access_token 无效
+		40014, // 不合法的 access_token
+		42001, // access_token 超时
+		40082, // 企业微信 不合法的 suite_token
+		42009, // 企业微信 suite_access_token 已过期
+	)
+	ErrInvalidAppCredential = newErrorKind(
+		"invalid app credential", ErrorCategoryAuth,
+		40013, // 不合法的 AppID
+		40125, // 不合法的 appsecret
+		40091, // 企业微信 secret不合法
+		40056, // 企业微信 不合法的agentid
+	)
+	ErrInvalidOAuthCode = newErrorKind(
+		"invalid oauth code", ErrorCategoryAuth,
+		40029, // 不合法的 oauth_code
+		40163, // oauth_code 已使用
+		41008, // 缺少 oauth code
+		42003, // oauth_code 超时
+	)
+	ErrRefreshTokenInvalid = newErrorKind(
+		"refresh token invalid", ErrorCategoryAuth,
+		40030, // 不合法的 refresh_token
+		42002, // refresh_token 超时
+		61023, // 开放平台 refresh_token 无效
+	)
+	ErrQuotaExceeded = newErrorKind(
+		"api quota exceeded", ErrorCategoryQuota,
+		45009, // 接口调用超过限制
+		45011, // API 调用太频繁，请稍候再试
+		45033, // 企业微信 接口并发调用超过限制
+		45047, // 客服接口下行条数超过上限
+		45035, // 企业微信 接口调用次数超过限制
+	)
+	ErrReplyTimeLimit = newErrorKind(
+		"reply time limit", ErrorCategoryPermission,
+		45015, // 回复时间超过限制(客服消息48小时)
+	)
+	ErrIPNotAllowed = newErrorKind(
+		"ip not allowed", ErrorCategoryPermission,
+		40164, // 调用接口的IP地址不在白名单中
+		60020, // 企业微信 不安全的访问IP
+		61004, // 开放平台 访问 IP 不在白名单中
+	)
+	ErrUnauthorizedAPI = newErrorKind(
+		"unauthorized api", ErrorCategoryPermission,
+		48001, // api 功能未授权
+		48004, // api 接口被封禁
+		61007, // 开放平台 api is unauthorized to component
+		48002, // 企业微信 API接口无权限调用
+	)
+	ErrComponentNotAuthorized = newErrorKind(
+		"component not authorized", ErrorCategoryPermission,
+		61003, // 开放平台 component is not authorized by this account
+	)
+	ErrNoPrivilege = newErrorKind(
+		"no privilege", ErrorCategoryPermission,
+		50001, // 用户未授权该 api
+		50002, // 用户受限，可能是违规后接口被封禁
+		60011, // 企业微信 指定的成员/部门/标签参数无权限
+	)
+	ErrRequireSubscribe = newErrorKind(
+		"require subscribe", ErrorCategoryPermission,
+		43004, // 需要接收者关注
+		43101, // 用户拒绝接受消息(订阅消息)
+	)
+	ErrInvalidParameter = newErrorKind(
+		"invalid parameter", ErrorCategoryParam,
+		40003, // 不合法的 OpenID
+		40007, // 不合法的媒体文件 id
+		40008, // 不合法的消息类型
+		40035, // 不合法的参数
+		40037, // 不合法的 template_id
+		41001, // 缺少 access_token 参数, 重新获取token也不能解决
+		44002, // POST 的数据包为空
+		47001, // 解析 JSON/XML 内容错误
+		46004, // 不存在的用户
+		60111, // 企业微信 userid不存在
+	)
+	ErrServerError = newErrorKind(
+		"server error", ErrorCategoryServer,
+		-1, // 系统繁忙，此时请开发者稍候再试
+	)
+)
+
+// errorKinds 错误码 => 分类, 一个错误码可能出现在多个分类中, 以先注册的为准
+var errorKinds = map[int64]*ErrorKind{}
+
+func init() {
+	for _, kind := range []*ErrorKind{
+		ErrAccessTokenInvalid,
+		ErrInvalidAppCredential,
+		ErrInvalidOAuthCode,
+		ErrRefreshTokenInvalid,
+		ErrQuotaExceeded,
+		ErrReplyTimeLimit,
+		ErrIPNotAllowed,
+		ErrUnauthorizedAPI,
+		ErrComponentNotAuthorized,
+		ErrNoPrivilege,
+		ErrRequireSubscribe,
+		ErrInvalidParameter,
+		ErrServerError,
+	} {
+		for _, code := range kind.codes {
+			if _, ok := errorKinds[code]; !ok {
+				errorKinds[code] = kind
+			}
+		}
+	}
+}
+
+// LookupErrorKind 根据错误码查找分类
+func LookupErrorKind(code int64) (*ErrorKind, bool) {
+	kind, ok := errorKinds[code]
+	return kind, ok
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeixinErrorIs(t *testing.T) {
+	err := doWeixinError(strings.NewReader(
+		`{"errcode":45009,"errmsg":"reach max api daily quota limit rid: 6527ad8a-7ad3c466-1ab34bb7"}`,
+	), &WeixinError{})
+	wrapped := fmt.Errorf("send message: %w", err)
+
+	require.True(t, errors.Is(wrapped, ErrQuotaExceeded))
+	require.True(t, errors.Is(wrapped, ErrorWeixinError))
+	require.True(t, errors.Is(wrapped, &WeixinError{ErrCode: 45009}))
+	require.False(t, errors.Is(wrapped, ErrNoPrivilege))
+	require.False(t, errors.Is(wrapped, ErrorAccessToken))
+
+	var we *WeixinError
+	require.True(t, errors.As(wrapped, &we))
+	require.Equal(t, ErrorCategoryQuota, we.Category())
+	require.Equal(t, "6527ad8a-7ad3c466-1ab34bb7", we.Rid())
+	require.Equal(t, "", we.Hint())
+
+	we = &WeixinError{
+		ErrCode: 40014,
+		ErrMsg:  "invalid access_token, hint: [1612345678_12_abcdef], from ip: 1.2.3.4",
+	}
+	require.True(t, errors.Is(we, ErrorAccessToken))
+	require.True(t, errors.Is(we, ErrAccessTokenInvalid))
+	require.Equal(t, ErrorCategoryAuth, we.Category())
+	require.Equal(t, "1612345678_12_abcdef", we.Hint())
+
+	// 缺少 access_token 参数, 刷新token也不能解决, 不重放
+	we = &WeixinError{ErrCode: 41001, ErrMsg: "access_token missing"}
+	require.False(t, errors.Is(we, ErrorAccessToken))
+	require.False(t, errors.Is(we, ErrAccessTokenInvalid))
+	require.Equal(t, ErrorCategoryParam, we.Category())
+
+	we = &WeixinError{ErrCode: -1, ErrMsg: "system error"}
+	require.True(t, errors.Is(we, ErrorSystemBusy))
+	require.Equal(t, ErrorCategoryServer, we.Category())
+
+	we = &WeixinError{ErrCode: 99999999}
+	require.Equal(t, ErrorCategoryUnknown, we.Category())
+	_, ok := LookupErrorKind(61003)
+	require.True(t, ok)
+}
@@ -33,7 +33,9 @@ func New(
 	componentAppid, appid string,
 	accessTokenGetter RefreshAccessToken,
 	tokenRefreshHandler utils.TokenRefreshHandler, // 刷新callback
+	options ...utils.ClientOption,
 ) *Authorizer {
+	options = utils.ClientOptionsWithAppID(appid, options)
 	accessTokenCache := utils.NewAccessTokenCache(
 		newAdapter(componentAppid, appid, accessTokenGetter), cache, locker,
 		utils.CacheClientTokenOptWithExpireBefore(tokenRefreshHandler),
@@ -41,7 +43,7 @@ func New(
 	return &Authorizer{
 		ComponentAppid:   componentAppid,
 		Appid:            appid,
-		Client:           utils.NewClient(WXServerUrl, accessTokenCache),
+		Client:           utils.NewClient(WXServerUrl, accessTokenCache, options...),
 		accessTokenCache: accessTokenCache,
 	}
 }
@@ -51,7 +53,9 @@ func NewLite(
 	cache utils.Cache,
 	locker utils.Lock,
 	componentAppid, appid string,
+	options ...utils.ClientOption,
 ) *Authorizer {
+	options = utils.ClientOptionsWithAppID(appid, options)
 	accessTokenCache := utils.NewAccessTokenCache(
 		newAdapter(componentAppid, appid, func(context.Context) (string, int, error) {
 			return "", 0, fmt.Errorf(
@@ -63,7 +67,7 @@ func NewLite(
 	return &Authorizer{
 		ComponentAppid: componentAppid,
 		Appid:          appid,
-		Client:         utils.NewClient(WXServerUrl, accessTokenCache),
+		Client:         utils.NewClient(WXServerUrl, accessTokenCache, options...),
 	}
 }
 
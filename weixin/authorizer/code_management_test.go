@@ -1,75 +1,113 @@
 package authorizer
 
 import (
-	"bytes"
 	"context"
-	"fmt"
-	"io"
-	"os"
+	"net/http"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxopen"
 	"github.com/stretchr/testify/require"
 )
 
-func initWxOpen() *wxopen.WxOpen {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	wxopen := wxopen.New(redis, redis, &wxopen.Config{
-		Appid:          test.WxOpenAppid,
-		Secret:         test.WxOpenSecret,
-		Token:          test.WxOpenToken,
-		EncodingAESKey: test.WxOpenEncodingAESKey,
-	}, nil)
-	return wxopen
+const (
+	testComponentAppid  = "wxcomponent"
+	testAuthorizerAppid = "wxauthorizer"
+)
+
+func initWxOpen(t *testing.T, server *mockserver.Server) *wxopen.WxOpen {
+	cache := memory.NewMemory()
+	open := wxopen.New(cache, cache, &wxopen.Config{
+		Appid:  testComponentAppid,
+		Secret: "secret",
+	}, nil, server.ClientOption())
+	require.Equal(t, nil, open.UpdateTicket(context.Background(), "ticket"))
+	open.SetRefreshTokenStore(utils.NewCacheRefreshTokenStore(cache))
+	open.SetAuthorizerFactory(WxOpenFactory)
+	return open
 }
 
-func initAuthorizer() *Authorizer {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	wxopenOA := NewLite(
-		redis, redis,
-		test.WxOpenAppid,
-		test.WxOpenOAAppid,
-	)
-	return wxopenOA
+func initAuthorizer(t *testing.T, server *mockserver.Server) *Authorizer {
+	open := initWxOpen(t, server)
+	require.Equal(t, nil, open.SaveAuthorization(context.Background(), &wxopen.AuthorizationInfo{
+		AuthorizerAppid:        testAuthorizerAppid,
+		AuthorizerRefreshToken: "refresh_token",
+	}))
+	api, err := FromWxOpen(open, testAuthorizerAppid)
+	require.Equal(t, nil, err)
+	return api
 }
 
 func TestCommit(t *testing.T) {
-	open := initWxOpen()
-	templates, err := open.GetTemplateList(context.Background())
-	require.Empty(t, err)
-	require.NotEmpty(t, templates)
+	ctx := context.Background()
+	server := mockserver.New(mockserver.OptWithApp(testComponentAppid, "secret"))
+	defer server.Close()
+	server.HandleJSON("/wxa/gettemplatelist", map[string]interface{}{
+		"template_list": []map[string]interface{}{
+			{"template_id": 1, "user_version": "v1.0.0"},
+			{"template_id": 2, "user_version": "v1.0.1"},
+		},
+	})
+	server.HandleJSON(apiCommit, &utils.WeixinError{})
+
+	open := initWxOpen(t, server)
+	templates, err := open.GetTemplateList(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, 2, len(templates))
 
 	templateID := templates[len(templates)-1].TemplateID
-	require.NotEmpty(t, templateID)
+	api := initAuthorizer(t, server)
+	err = api.CodeCommit(ctx, templateID, "{}", "test", "test")
+	require.Equal(t, nil, err)
 
-	api := initAuthorizer()
-	err = api.CodeCommit(context.Background(), templateID, "{}", "test", "test")
-	require.Empty(t, err)
+	payload := map[string]interface{}{}
+	require.Equal(t, nil, server.LastRequest(apiCommit).Unmarshal(&payload))
+	require.Equal(t, float64(2), payload["template_id"])
+	require.Equal(t, "test", payload["user_version"])
 }
 
 func TestGetQrcode(t *testing.T) {
-	api := initAuthorizer()
-	qrcode, err := api.GetTestQrcode(context.Background(), "")
-	require.Empty(t, err)
-	require.NotEmpty(t, qrcode)
-	// save qrcode to temp file
-	file, _ := os.Create("/tmp/qrcode-" + test.WxOpenOAAppid + ".png")
-	defer file.Close()
-
-	_, _ = io.Copy(file, bytes.NewReader(qrcode))
-	fmt.Printf("save qrcode to a temp file: %s\n", file.Name())
+	server := mockserver.New()
+	defer server.Close()
+	png := []byte("\x89PNG\r\n\x1a\n")
+	server.Handle(apiGetQrcode, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+	})
+
+	api := initAuthorizer(t, server)
+	qrcode, err := api.GetTestQrcode(context.Background(), "page/index?action=1")
+	require.Equal(t, nil, err)
+	require.Equal(t, png, qrcode)
+	require.Equal(t, "page/index?action=1", server.LastRequest(apiGetQrcode).Query.Get("path"))
 }
 
 func TestSubmitAudit(t *testing.T) {
-	api := initAuthorizer()
-	_, err := api.CodeSubmitAudit(context.Background(), &AuditParams{})
-	require.Empty(t, err)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiSubmitAudit, map[string]interface{}{"auditid": 1234567})
+	server.HandleJSON(apiGetAuditStatus, map[string]interface{}{"status": 1, "reason": "reason"})
+
+	api := initAuthorizer(t, server)
+	auditID, err := api.CodeSubmitAudit(context.Background(), &AuditParams{VersionDesc: "desc"})
+	require.Equal(t, nil, err)
+	require.Equal(t, int32(1234567), auditID)
+
+	result, err := api.GetAuditStatus(context.Background(), auditID)
+	require.Equal(t, nil, err)
+	require.Equal(t, int32(1), result.Status)
+	require.Equal(t, "reason", result.Reason)
 }
 
 func TestRelease(t *testing.T) {
-	api := initAuthorizer()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiRelease, &utils.WeixinError{})
+
+	api := initAuthorizer(t, server)
 	err := api.CodeRelease(context.Background())
-	require.Empty(t, err)
+	require.Equal(t, nil, err)
+	require.NotEqual(t, nil, server.LastRequest(apiRelease))
 }
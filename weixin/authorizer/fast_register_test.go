@@ -1,12 +1,21 @@
 package authorizer
 
 import (
-	"fmt"
+	"net/url"
 	"testing"
+
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetFastRegisterAuthUrl(t *testing.T) {
-	api := initAuthorizer()
-	url := api.GetFastRegisterAuthUrl("1", "https://test.lixinchuxing.cn/gateway/component/notify")
-	fmt.Print(url)
+	server := mockserver.New()
+	defer server.Close()
+
+	api := initAuthorizer(t, server)
+	uri := api.GetFastRegisterAuthUrl("1", "https://test.lixinchuxing.cn/gateway/component/notify")
+	u, err := url.Parse(uri)
+	require.Equal(t, nil, err)
+	require.Equal(t, testAuthorizerAppid, u.Query().Get("appid"))
+	require.Equal(t, testComponentAppid, u.Query().Get("component_appid"))
 }
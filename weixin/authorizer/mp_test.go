@@ -2,26 +2,40 @@ package authorizer
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetAccountBasicInfo(t *testing.T) {
-	api := initAuthorizer()
-	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiGetAccountBasicInfo, map[string]interface{}{
+		"appid": testAuthorizerAppid, "account_type": 3, "nickname": "测试小程序",
+	})
 
-	info, err := api.GetAccountBasicInfo(ctx)
-	require.Equal(t, err, nil)
-	fmt.Print(info)
+	api := initAuthorizer(t, server)
+	info, err := api.GetAccountBasicInfo(context.Background())
+	require.Equal(t, nil, err)
+	require.Equal(t, testAuthorizerAppid, info.AppID)
+	require.Equal(t, 3, info.AccountType)
 }
 
 func TestGetCategory(t *testing.T) {
-	api := initAuthorizer()
-	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiGetCategory, map[string]interface{}{
+		"categories": []map[string]interface{}{
+			{"first": 8, "first_name": "教育", "second": 30, "second_name": "培训机构", "audit_status": 3},
+		},
+		"limit": 5, "quota": 4, "category_limit": 5,
+	})
 
-	info, err := api.GetCategory(ctx)
-	require.Equal(t, err, nil)
-	fmt.Print(info)
+	api := initAuthorizer(t, server)
+	info, err := api.GetCategory(context.Background())
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, len(info.Categories))
+	require.Equal(t, "培训机构", info.Categories[0].SecondName)
+	require.Equal(t, 4, info.Quota)
 }
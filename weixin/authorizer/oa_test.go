@@ -2,34 +2,59 @@ package authorizer
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
-func TestWxaMpLinkGet(t *testing.T) {
-	api := initAuthorizer()
-	ctx := context.Background()
+const testMpAppid = "wxminiprogram"
 
-	info, err := api.WxaMpLinkGet(ctx)
-	require.Equal(t, err, nil)
-	fmt.Print(info)
+func TestWxaMpLinkGet(t *testing.T) {
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiWxaMpLinkGet, map[string]interface{}{
+		"wxopens": map[string]interface{}{
+			"items": []map[string]interface{}{
+				{"status": 1, "appid": testMpAppid, "nickname": "测试小程序"},
+			},
+		},
+	})
+
+	api := initAuthorizer(t, server)
+	items, err := api.WxaMpLinkGet(context.Background())
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, len(items))
+	require.Equal(t, testMpAppid, items[0].AppID)
 }
 
 func TestWxaMpUnLink(t *testing.T) {
-	api := initAuthorizer()
-	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiWxaMpUnlink, &utils.WeixinError{})
 
-	err := api.WxaMpUnLink(ctx, test.WxOpenOAAppid)
-	require.Equal(t, err, nil)
+	api := initAuthorizer(t, server)
+	err := api.WxaMpUnLink(context.Background(), testMpAppid)
+	require.Equal(t, nil, err)
+
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiWxaMpUnlink).Unmarshal(&payload))
+	require.Equal(t, testMpAppid, payload["appid"])
 }
 
 func TestWxaMpLink(t *testing.T) {
-	api := initAuthorizer()
-	ctx := context.Background()
-
-	err := api.WxaMpLink(ctx, test.WxOpenOAAppid, "0", "0")
-	require.Equal(t, err, nil)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiWxaMpLink, &utils.WeixinError{})
+
+	api := initAuthorizer(t, server)
+	err := api.WxaMpLink(context.Background(), testMpAppid, "0", "1")
+	require.Equal(t, nil, err)
+
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiWxaMpLink).Unmarshal(&payload))
+	require.Equal(t, map[string]string{
+		"appid": testMpAppid, "notify_users": "0", "show_profile": "1",
+	}, payload)
 }
@@ -3,80 +3,63 @@ package authorizer
 import (
 	"context"
 	"errors"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
 func TestOpenCreate(t *testing.T) {
-	api := initAuthorizer()
-	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiWxOpenCreate, &utils.WeixinError{
+		ErrCode: 89000, ErrMsg: "account has bound open",
+	})
 
-	openid, err := api.WxOpenCreate(ctx, test.WxOpenOAAppid)
-	if err == nil {
-		fmt.Print(openid)
-	} else {
-		var wxError *utils.WeixinError
-		if !errors.As(err, &wxError) {
-			require.Equal(t, err, nil)
-		} else if wxError.ErrCode != 89000 { // ErrCode:89000, ErrMsg:"account has bound open
-			require.Equal(t, err, nil)
-		}
-	}
+	api := initAuthorizer(t, server)
+	_, err := api.WxOpenCreate(context.Background(), testAuthorizerAppid)
+	var wxError *utils.WeixinError
+	require.True(t, errors.As(err, &wxError))
+	require.Equal(t, int64(89000), wxError.ErrCode)
 }
 
 func TestOpen(t *testing.T) {
-	api := initAuthorizer()
 	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiWxOpenGet, map[string]interface{}{"open_appid": "wxopenappid"})
+	server.HandleJSON(apiWxOpenHave, map[string]interface{}{"have_open": true})
+	server.HandleJSON(apiWxOpenBind, &utils.WeixinError{})
+	server.HandleJSON(apiWxOpenUnbind, &utils.WeixinError{})
 
-	openid, err := api.WxOpenGet(ctx, test.WxOpenOAAppid)
-	if err != nil {
-		var wxError *utils.WeixinError
-		if !errors.As(err, &wxError) {
-			require.Equal(t, err, nil)
-		} else if wxError.ErrCode != 89002 { // ErrCode:89002, ErrMsg:"open not exists
-			require.Equal(t, err, nil)
-		}
-		return
-	}
-
-	fmt.Printf("openid : %s\n", openid)
+	api := initAuthorizer(t, server)
+	openid, err := api.WxOpenGet(ctx, testAuthorizerAppid)
+	require.Equal(t, nil, err)
+	require.Equal(t, "wxopenappid", openid)
 
 	have, err := api.WxOpenHave(ctx)
-	require.Equal(t, err, nil)
-	fmt.Println(have)
-
-	fbind := func() {
-		err = api.WxOpenBind(ctx, test.WxOpenOAAppid, openid)
-		if err != nil {
-			var wxError *utils.WeixinError
-			if !errors.As(err, &wxError) {
-				require.Equal(t, err, nil)
-			} else if wxError.ErrCode != 89000 { // ErrCode:89000, ErrMsg:"account has bound open rid
-				require.Equal(t, err, nil)
-			}
-		}
-	}
-
-	fbind()
+	require.Equal(t, nil, err)
+	require.True(t, have)
 
-	err = api.WxOpenUnBind(ctx, test.WxOpenOAAppid, openid)
-	require.Equal(t, err, nil)
-
-	have, err = api.WxOpenHave(ctx)
-	require.Equal(t, err, nil)
-	fmt.Println(have)
-
-	fbind()
+	require.Equal(t, nil, api.WxOpenUnBind(ctx, testAuthorizerAppid, openid))
+	require.Equal(t, nil, api.WxOpenBind(ctx, testAuthorizerAppid, openid))
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiWxOpenBind).Unmarshal(&payload))
+	require.Equal(t, map[string]string{"appid": testAuthorizerAppid, "open_appid": "wxopenappid"}, payload)
 }
 
 func TestRid(t *testing.T) {
-	api := initAuthorizer()
-	ctx := context.Background()
-	req, err := api.RidGet(ctx, "622177b1-686aebfa-2d6bb912")
-	require.Equal(t, err, nil)
-	fmt.Print(req)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiRidGet, map[string]interface{}{
+		"request": map[string]interface{}{
+			"invoke_time": 1635156704, "cost_in_ms": 30, "request_url": "access_token=xxx",
+		},
+	})
+
+	api := initAuthorizer(t, server)
+	req, err := api.RidGet(context.Background(), "622177b1-686aebfa-2d6bb912")
+	require.Equal(t, nil, err)
+	require.Equal(t, 30, req.CostInMs)
 }
@@ -0,0 +1,42 @@
+package authorizer
+
+// 由开放平台(wxopen)托管 refresh token 的授权方
+//
+//	open.SetRefreshTokenStore(utils.NewCacheRefreshTokenStore(redis))
+//	open.SetAuthorizerFactory(authorizer.WxOpenFactory)
+//	api, err := authorizer.FromWxOpen(open, appid)
+import (
+	"context"
+	"fmt"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/wxopen"
+)
+
+var _ wxopen.AuthorizerFactory = WxOpenFactory
+
+// WxOpenFactory 实现 wxopen.AuthorizerFactory
+func WxOpenFactory(
+	cache utils.Cache, locker utils.Lock,
+	componentAppid, appid string,
+	refresh func(context.Context) (string, int, error),
+	options ...utils.ClientOption,
+) wxopen.AuthorizerClient {
+	return New(cache, locker, componentAppid, appid, refresh, nil, options...)
+}
+
+// FromWxOpen 获取开放平台托管的授权方, 需要先设置 SetRefreshTokenStore 和 SetAuthorizerFactory(WxOpenFactory)
+func FromWxOpen(open *wxopen.WxOpen, appid string) (*Authorizer, error) {
+	client, err := open.Authorizer(appid)
+	if err != nil {
+		return nil, err
+	}
+	instance, ok := client.(*Authorizer)
+	if !ok {
+		return nil, fmt.Errorf(
+			"authorizer appid : %s,%s, unexpected authorizer client %T, use WxOpenFactory",
+			open.Config.Appid, appid, client,
+		)
+	}
+	return instance, nil
+}
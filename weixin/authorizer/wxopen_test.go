@@ -0,0 +1,45 @@
+package authorizer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/lixinio/weixin/wxopen"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromWxOpen(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON("/cgi-bin/account/getaccountbasicinfo", map[string]interface{}{
+		"appid": "wxauthorizer", "nickname": "测试小程序",
+	})
+
+	cache := memory.NewMemory()
+	open := wxopen.New(cache, cache, &wxopen.Config{Appid: "wxcomponent", Secret: "secret"}, nil, server.ClientOption())
+	require.Equal(t, nil, open.UpdateTicket(ctx, "ticket"))
+	open.SetRefreshTokenStore(utils.NewCacheRefreshTokenStore(cache))
+
+	_, err := FromWxOpen(open, "wxauthorizer")
+	require.True(t, errors.Is(err, wxopen.ErrAuthorizerFactoryMissing))
+	open.SetAuthorizerFactory(WxOpenFactory)
+
+	require.Equal(t, nil, open.SaveAuthorization(ctx, &wxopen.AuthorizationInfo{
+		AuthorizerAppid:        "wxauthorizer",
+		AuthorizerRefreshToken: "refresh_token",
+	}))
+	api, err := FromWxOpen(open, "wxauthorizer")
+	require.Equal(t, nil, err)
+	require.Equal(t, "wxcomponent", api.ComponentAppid)
+
+	// 使用保存的 refresh token 获取 access token
+	info, err := api.GetAccountBasicInfo(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "测试小程序", info.Nickname)
+	require.NotEqual(t, nil, server.LastRequest("/cgi-bin/component/api_authorizer_token"))
+}
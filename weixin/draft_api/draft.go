@@ -0,0 +1,139 @@
+package draft_api
+
+// 草稿箱
+// https://developers.weixin.qq.com/doc/offiaccount/Draft_Box/Add_draft.html
+
+import (
+	"context"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/weixin/material_api"
+)
+
+const (
+	apiAdd      = "/cgi-bin/draft/add"      // 新建草稿
+	apiGet      = "/cgi-bin/draft/get"      // 获取草稿
+	apiDelete   = "/cgi-bin/draft/delete"   // 删除草稿
+	apiUpdate   = "/cgi-bin/draft/update"   // 修改草稿
+	apiCount    = "/cgi-bin/draft/count"    // 获取草稿总数
+	apiBatchGet = "/cgi-bin/draft/batchget" // 获取草稿列表
+)
+
+type DraftApi struct {
+	*utils.Client
+}
+
+func NewApi(client *utils.Client) *DraftApi {
+	return &DraftApi{Client: client}
+}
+
+/*
+新建草稿, 返回草稿的 media_id
+https://developers.weixin.qq.com/doc/offiaccount/Draft_Box/Add_draft.html
+*/
+func (api *DraftApi) Add(ctx context.Context, articles []*material_api.Article) (string, error) {
+	resp := &struct {
+		utils.WeixinError
+		MediaID string `json:"media_id"`
+	}{}
+	if err := api.Client.HTTPPostJson(ctx, apiAdd, map[string]interface{}{
+		"articles": articles,
+	}, resp); err != nil {
+		return "", err
+	}
+	return resp.MediaID, nil
+}
+
+/*
+获取草稿
+https://developers.weixin.qq.com/doc/offiaccount/Draft_Box/Get_draft.html
+*/
+func (api *DraftApi) Get(ctx context.Context, mediaID string) ([]*material_api.Article, error) {
+	resp := &struct {
+		utils.WeixinError
+		NewsItems []*material_api.Article `json:"news_item"`
+	}{}
+	if err := api.Client.HTTPPostJson(ctx, apiGet, map[string]string{
+		"media_id": mediaID,
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp.NewsItems, nil
+}
+
+/*
+删除草稿
+https://developers.weixin.qq.com/doc/offiaccount/Draft_Box/Delete_draft.html
+*/
+func (api *DraftApi) Delete(ctx context.Context, mediaID string) error {
+	return api.Client.HTTPPostJson(ctx, apiDelete, map[string]string{
+		"media_id": mediaID,
+	}, nil)
+}
+
+/*
+修改草稿, index 为要更新的文章在图文消息中的位置(多图文消息时，此字段才有意义)，第一篇为0
+https://developers.weixin.qq.com/doc/offiaccount/Draft_Box/Update_draft.html
+*/
+func (api *DraftApi) Update(
+	ctx context.Context, mediaID string, index int, article *material_api.Article,
+) error {
+	return api.Client.HTTPPostJson(ctx, apiUpdate, map[string]interface{}{
+		"media_id": mediaID,
+		"index":    index,
+		"articles": article,
+	}, nil)
+}
+
+/*
+获取草稿总数
+https://developers.weixin.qq.com/doc/offiaccount/Draft_Box/Count_drafts.html
+*/
+func (api *DraftApi) Count(ctx context.Context) (int, error) {
+	resp := &struct {
+		utils.WeixinError
+		TotalCount int `json:"total_count"`
+	}{}
+	if err := api.Client.HTTPGet(ctx, apiCount, resp); err != nil {
+		return 0, err
+	}
+	return resp.TotalCount, nil
+}
+
+type DraftList struct {
+	utils.WeixinError
+	TotalCount int `json:"total_count"` // 草稿素材的总数
+	ItemCount  int `json:"item_count"`  // 本次调用获取的素材的数量
+	Items      []struct {
+		MediaID    string `json:"media_id"`
+		UpdateTime int64  `json:"update_time"` // 这篇图文消息素材的最后更新时间
+		Content    struct {
+			NewsItems []*material_api.Article `json:"news_item"`
+		} `json:"content"`
+	} `json:"item"`
+}
+
+/*
+获取草稿列表
+https://developers.weixin.qq.com/doc/offiaccount/Draft_Box/Get_draft_list.html
+*/
+func (api *DraftApi) BatchGet(
+	ctx context.Context,
+	offset, count int, // count 取值在1到20之间
+	noContent bool, // 不返回 content 字段
+) (*DraftList, error) {
+	noContentInt := 0
+	if noContent {
+		noContentInt = 1
+	}
+
+	resp := &DraftList{}
+	if err := api.Client.HTTPPostJson(ctx, apiBatchGet, map[string]interface{}{
+		"offset":     offset,
+		"count":      count,
+		"no_content": noContentInt,
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
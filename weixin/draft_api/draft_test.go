@@ -0,0 +1,52 @@
+package draft_api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/lixinio/weixin/weixin/material_api"
+	"github.com/lixinio/weixin/weixin/official_account"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDraft(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New(mockserver.OptWithApp("appid", "secret"))
+	defer server.Close()
+	server.HandleJSON(apiAdd, map[string]string{"media_id": "draft_media_id"})
+	server.HandleJSON(apiCount, map[string]int{"total_count": 1})
+	server.HandleJSON(apiGet, map[string]interface{}{
+		"news_item": []map[string]string{{"title": "标题", "url": "https://mp.weixin.qq.com/s/draft"}},
+	})
+
+	cache := memory.NewMemory()
+	oa := official_account.New(cache, cache, &official_account.Config{
+		Appid: "appid", Secret: "secret",
+	}, server.ClientOption())
+	api := NewApi(oa.Client)
+
+	mediaID, err := api.Add(ctx, []*material_api.Article{{
+		Title: "标题", Content: "内容", ThumbMediaID: "thumb_media_id",
+	}})
+	require.Equal(t, nil, err)
+	require.Equal(t, "draft_media_id", mediaID)
+
+	// 只读的字段不能提交
+	payload := struct {
+		Articles []map[string]interface{} `json:"articles"`
+	}{}
+	require.Equal(t, nil, server.LastRequest(apiAdd).Unmarshal(&payload))
+	require.Equal(t, "thumb_media_id", payload.Articles[0]["thumb_media_id"])
+	_, ok := payload.Articles[0]["url"]
+	require.False(t, ok)
+
+	articles, err := api.Get(ctx, mediaID)
+	require.Equal(t, nil, err)
+	require.Equal(t, "https://mp.weixin.qq.com/s/draft", articles[0].Url)
+
+	count, err := api.Count(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, count)
+}
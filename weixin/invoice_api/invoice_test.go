@@ -1,52 +1,92 @@
 package invoice_api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
-	"os"
 	"testing"
 	"time"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/weixin/official_account"
 	"github.com/stretchr/testify/require"
 )
 
-func newInvoiceApi() (*InvoiceApi, *official_account.OfficialAccount) {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	officialAccount := official_account.New(redis, redis, &official_account.Config{
-		Appid:  test.OfficialAccountAppid,
-		Secret: test.OfficialAccountSecret,
-	})
+const (
+	testAppid   = "wxofficialaccount"
+	testSecret  = "secret"
+	testSPappID = "d3gxMTY5NGJiNDI4YTMyZTg4X0jdlhfLZft3pZEI0pLVYp3CRPzlu2kW_06OUzJGyaZ3"
+)
+
+func newInvoiceApi(server *mockserver.Server) (*InvoiceApi, *official_account.OfficialAccount) {
+	cache := memory.NewMemory()
+	officialAccount := official_account.New(cache, cache, &official_account.Config{
+		Appid:  testAppid,
+		Secret: testSecret,
+	}, server.ClientOption())
+	officialAccount.EnableWxCardTicketCache(cache, cache)
 
 	return NewApi(officialAccount.Client), officialAccount
 }
 
+func newTestServer() *mockserver.Server {
+	return mockserver.New(mockserver.OptWithApp(testAppid, testSecret))
+}
+
 func TestInvoiceUploadPdf(t *testing.T) {
-	api, _ := newInvoiceApi()
+	server := newTestServer()
+	defer server.Close()
+	api, _ := newInvoiceApi(server)
 	ctx := context.Background()
 
-	file, err := os.Open(test.InvoicePdf)
-	require.Empty(t, err)
-	defer file.Close()
-
-	fi, err := file.Stat()
-	require.Empty(t, err)
+	content := []byte("%PDF-1.4\n%%EOF\n")
+	server.Handle(apiPlatformSetpdf, func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("pdf")
+		if err != nil {
+			_ = json.NewEncoder(w).Encode(&utils.WeixinError{ErrCode: 41005, ErrMsg: "media data missing"})
+			return
+		}
+		body, _ := io.ReadAll(file)
+		require.Equal(t, content, body)
+		require.Equal(t, "fapiao.pdf", header.Filename)
+		_ = json.NewEncoder(w).Encode(map[string]string{"s_media_id": "71381497449443328"})
+	})
 
-	mediaID, err := api.PlatformSetPdf(ctx, "fapiao.pdf", fi.Size(), file)
+	mediaID, err := api.PlatformSetPdf(
+		ctx, "fapiao.pdf", int64(len(content)), bytes.NewReader(content),
+	)
 	require.Equal(t, nil, err)
-	fmt.Printf("media id %s\n", mediaID)
+	require.Equal(t, "71381497449443328", mediaID)
 }
 
 func TestSetContact(t *testing.T) {
-	api, _ := newInvoiceApi()
+	server := newTestServer()
+	defer server.Close()
+	api, _ := newInvoiceApi(server)
 	ctx := context.Background()
 
+	// set_contact 保存, get_contact 返回保存的联系方式
+	var contact json.RawMessage
+	server.Handle(apiSetbizattr, func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]json.RawMessage{}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		switch r.URL.Query().Get("action") {
+		case "set_contact":
+			contact = payload["contact"]
+			_ = json.NewEncoder(w).Encode(&utils.WeixinError{})
+		case "get_contact":
+			_ = json.NewEncoder(w).Encode(map[string]json.RawMessage{"contact": contact})
+		}
+	})
+
 	setbizattrObj := &SetbizattrObj{
-		Phone:   test.InvoicePhone,
+		Phone:   "13800138000",
 		TimeOut: 7200,
 	}
 
@@ -55,34 +95,52 @@ func TestSetContact(t *testing.T) {
 
 	result, err := api.GetContact(ctx)
 	require.Equal(t, nil, err)
+	require.Equal(t, "get_contact", server.LastRequest(apiSetbizattr).Query.Get("action"))
 	require.Equal(t, result.Phone, setbizattrObj.Phone)
 	require.Equal(t, result.TimeOut, setbizattrObj.TimeOut)
 }
 
 func TestPlatformCreateCard(t *testing.T) {
-	api, _ := newInvoiceApi()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiPlatformCreateCard, map[string]string{
+		"card_id": "p-mcP1FC6QHZ515goRP3CsXZcXmI",
+	})
+	api, _ := newInvoiceApi(server)
 	ctx := context.Background()
 
-	cardID, err := api.PlatformCreateCard(ctx, &CreateCardObj{
-		Payee: test.InvoicePayee,
-		Type:  test.InvoiceType,
+	param := &CreateCardObj{
+		Payee: "测试-收款方",
+		Type:  "广东省增值税普通发票",
 		BaseInfo: &CreateCardBaseInfo{
-			Title:                test.InvoiceCustomUrlName,
-			CustomUrlName:        test.InvoiceCustomUrlName,
-			CustomURL:            test.InvoiceCustomURL,
-			CustomUrlSubTitle:    test.InvoiceCustomUrlSubTitle,
+			Title:                "测试",
+			CustomUrlName:        "测试",
+			CustomURL:            "https://www.qq.com",
+			CustomUrlSubTitle:    "测试",
 			PromotionUrlName:     "查看其他",
 			PromotionURL:         "https://www.baidu.com",
 			PromotionUrlSubTitle: "详情",
-			LogoUrl:              "https://mmbiz.qpic.cn/mmbiz_png/5tTVBJAGiap2TWlw0pPpbVtE80xH4sUs4u1aPZOlKHgPNS3sKm1CpJM3aLKd36yLreXqAHenD3q8QU3Hovpjv0g/0",
+			LogoUrl:              "https://mmbiz.qpic.cn/mmbiz_png/logo/0",
 		},
-	})
+	}
+	cardID, err := api.PlatformCreateCard(ctx, param)
 	require.Equal(t, nil, err)
-	fmt.Printf("card id %s\n", cardID)
+	require.Equal(t, "p-mcP1FC6QHZ515goRP3CsXZcXmI", cardID)
+
+	payload := struct {
+		InvoiceInfo *CreateCardObj `json:"invoice_info"`
+	}{}
+	require.Equal(t, nil, server.LastRequest(apiPlatformCreateCard).Unmarshal(&payload))
+	require.Equal(t, param, payload.InvoiceInfo)
 }
 
 func TestInvoiceInsert(t *testing.T) {
-	api, _ := newInvoiceApi()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiInsert, map[string]string{
+		"code": "code", "openid": "openid", "unionid": "unionid",
+	})
+	api, _ := newInvoiceApi(server)
 	ctx := context.Background()
 
 	var billingTime int64
@@ -96,7 +154,7 @@ func TestInvoiceInsert(t *testing.T) {
 	param := &InvoiceInsertObj{
 		OrderID: "1624612433713210184",
 		CardID:  "p-mcP1FC6QHZ515goRP3CsXZcXmI",
-		Appid:   test.OfficialAccountAppid,
+		Appid:   testAppid,
 		CardExt: &InvoiceInsertCardExt{
 			NonceStr: fmt.Sprintf("%d", time.Now().UnixNano()),
 			UserCard: struct {
@@ -112,46 +170,47 @@ func TestInvoiceInsert(t *testing.T) {
 					FeeWithoutTax: 9,
 					Tax:           1,
 					SPdfMediaID:   "71381497449443328",
-					// Cashier:               "李四",
-					// Maker:                 "王五",
-					// SellerNumber:          "91440300M0123456789",
-					// SellerBankAccount:     "中国银行华润城支行 123456789",
-					// SellerAddressAndPhone: "深圳市宝安区西乡街道软件产业基地",
-					// Info: []InvoiceInsertCardExtItem{
-					// 	{
-					// 		Name:  "*信息技术服务*平台服务费",
-					// 		Price: 10,
-					// 		Num:   1,
-					// 		Unit:  "次",
-					// 	},
-					// },
 				},
 			},
 		},
 	}
 
-	b, _ := json.Marshal(param)
-	fmt.Println(string(b))
-
 	result, err := api.Insert(ctx, param)
 	require.Equal(t, nil, err)
-	fmt.Printf("code : %s, openid: %s, unionid: %s\n", result.Code, result.OpenID, result.UnionID)
+	require.Equal(t, "code", result.Code)
+	require.Equal(t, "openid", result.OpenID)
+	require.Equal(t, "unionid", result.UnionID)
+
+	payload := &InvoiceInsertObj{}
+	require.Equal(t, nil, server.LastRequest(apiInsert).Unmarshal(payload))
+	require.Equal(t, param, payload)
 }
 
 func TestRejectInsert(t *testing.T) {
-	api, _ := newInvoiceApi()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiRejectInsert, &utils.WeixinError{})
+	api, _ := newInvoiceApi(server)
 	ctx := context.Background()
 
-	err := api.RejectInsert(ctx, &RejectInsertObj{
+	param := &RejectInsertObj{
 		OrderID: "1624605258318629788",
-		SPappID: "d3gxMTY5NGJiNDI4YTMyZTg4X0jdlhfLZft3pZEI0pLVYp3CRPzlu2kW_06OUzJGyaZ3",
+		SPappID: testSPappID,
 		Reason:  "就是不开",
-	})
+	}
+	err := api.RejectInsert(ctx, param)
 	require.Equal(t, nil, err)
+
+	payload := &RejectInsertObj{}
+	require.Equal(t, nil, server.LastRequest(apiRejectInsert).Unmarshal(payload))
+	require.Equal(t, param, payload)
 }
 
 func TestSetAuthField(t *testing.T) {
-	api, _ := newInvoiceApi()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiSetbizattr, &utils.WeixinError{})
+	api, _ := newInvoiceApi(server)
 	ctx := context.Background()
 
 	param := &AuthFieldObj{
@@ -178,27 +237,42 @@ func TestSetAuthField(t *testing.T) {
 	}
 	err := api.SetAuthField(ctx, param)
 	require.Equal(t, nil, err)
+
+	request := server.LastRequest(apiSetbizattr)
+	require.Equal(t, "set_auth_field", request.Query.Get("action"))
+	payload := struct {
+		AuthField *AuthFieldObj `json:"auth_field"`
+	}{}
+	require.Equal(t, nil, request.Unmarshal(&payload))
+	require.Equal(t, param, payload.AuthField)
 }
 
 func TestInvoice(t *testing.T) {
-	api, officialAccount := newInvoiceApi()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiSetUrl, map[string]string{
+		"invoice_url": "https://mp.weixin.qq.com/bizmall/authinvoice?action=list&s_pappid=" + testSPappID,
+	})
+	server.HandleJSON("/cgi-bin/ticket/getticket", map[string]interface{}{
+		"ticket": "wx_card_ticket", "expires_in": 7200,
+	})
+	server.HandleJSON(apiGetAuthUrl, map[string]string{
+		"auth_url": "https://mp.weixin.qq.com/bizmall/authinvoice", "appid": testAppid,
+	})
+	server.HandleJSON(apiGetAuthData, map[string]interface{}{
+		"invoice_status": "auth success",
+		"auth_time":      1480342498,
+		"user_auth_info": map[string]interface{}{
+			"user_field": map[string]string{"title": "张三", "phone": "13800138000"},
+		},
+	})
+	api, officialAccount := newInvoiceApi(server)
 	ctx := context.Background()
 
-	// {
-	// 	result, err := api.GetAuthData(&AuthDataObj{
-	// 		OrderID: "1623930786748654309",
-	// 		SPappID: "d3g1OTg2NGE5ZTU3ODIyOWVhX_oiY7-5OuzNHme3fHyMQQWjstgWqHfPcktQ40c-H73D",
-	// 	})
-	// 	require.Equal(t, nil, err)
-	// 	fmt.Println(result.InvoiceStatus)
-	// }
-	///////////////////////////// debug
-
 	spappID := ""
 	{
 		result, err := api.SetUrl(ctx)
 		require.Equal(t, nil, err)
-		fmt.Println(result)
 
 		u, err := url.Parse(result)
 		require.Equal(t, nil, err)
@@ -208,14 +282,14 @@ func TestInvoice(t *testing.T) {
 		require.Equal(t, true, ok)
 		require.NotEmpty(t, pappid)
 		spappID = pappid[0]
-		fmt.Printf("s_pappid : %s\n", spappID)
+		require.Equal(t, testSPappID, spappID)
 	}
 
 	orderID := fmt.Sprintf("%d", time.Now().UnixNano())
-	fmt.Printf("order id %s\n", orderID)
 	{
 		ticket, err := officialAccount.GetWxCardApiTicket(ctx)
 		require.Equal(t, nil, err)
+		require.Equal(t, "wx_card", server.LastRequest("/cgi-bin/ticket/getticket").Query.Get("type"))
 
 		result, err := api.GetAuthUrl(ctx, &AuthUrlObj{
 			SPappID:   spappID,
@@ -227,7 +301,12 @@ func TestInvoice(t *testing.T) {
 			Ticket:    ticket,
 		})
 		require.Equal(t, nil, err)
-		fmt.Printf("url : %s , appid %s\n", result.AuthURL, result.AppID)
+		require.Equal(t, testAppid, result.AppID)
+
+		payload := &AuthUrlObj{}
+		require.Equal(t, nil, server.LastRequest(apiGetAuthUrl).Unmarshal(payload))
+		require.Equal(t, "wx_card_ticket", payload.Ticket)
+		require.Equal(t, orderID, payload.OrderID)
 	}
 
 	{
@@ -236,6 +315,8 @@ func TestInvoice(t *testing.T) {
 			SPappID: spappID,
 		})
 		require.Equal(t, nil, err)
-		fmt.Println(result.InvoiceStatus)
+		require.Equal(t, "auth success", result.InvoiceStatus)
+		require.Equal(t, "张三", result.UserAuthInfo.UserField.Title)
+		require.Nil(t, result.UserAuthInfo.BizField)
 	}
 }
@@ -0,0 +1,170 @@
+package kf_api
+
+// 客服账号管理
+// 客服账号的格式为 账号前缀@公众号微信号, 需要先绑定客服人员的微信号(邀请绑定)才能接入会话
+// https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Customer_Service_Management.html
+
+import (
+	"context"
+	"io"
+	"net/url"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+const (
+	apiAddKfAccount    = "/customservice/kfaccount/add"           // 添加客服账号
+	apiUpdateKfAccount = "/customservice/kfaccount/update"        // 设置客服信息
+	apiDelKfAccount    = "/customservice/kfaccount/del"           // 删除客服账号
+	apiUploadHeadImg   = "/customservice/kfaccount/uploadheadimg" // 上传客服头像
+	apiInviteWorker    = "/customservice/kfaccount/inviteworker"  // 邀请绑定客服账号
+	apiGetKfList       = "/cgi-bin/customservice/getkflist"       // 获取客服基本信息
+	apiGetOnlineKfList = "/cgi-bin/customservice/getonlinekflist" // 获取在线客服接待信息
+	apiCustomTyping    = "/cgi-bin/message/custom/typing"         // 客服输入状态
+)
+
+// 邀请绑定的状态
+const (
+	InviteStatusWaiting  = "waiting"  // 等待确认
+	InviteStatusRejected = "rejected" // 被拒绝
+	InviteStatusExpired  = "expired"  // 过期
+)
+
+// 客服在线状态
+const (
+	KfStatusOnline = 1 // web 在线
+)
+
+// 客服输入状态
+const (
+	TypingCommandTyping       = "Typing"       // 正在输入
+	TypingCommandCancelTyping = "CancelTyping" // 取消正在输入
+)
+
+type KfApi struct {
+	*utils.Client
+}
+
+func NewApi(client *utils.Client) *KfApi {
+	return &KfApi{Client: client}
+}
+
+/*
+添加客服账号, 每个公众号最多添加100个客服账号
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Customer_Service_Management.html#添加客服账号
+*/
+func (api *KfApi) AddKfAccount(ctx context.Context, kfAccount, nickname string) error {
+	return api.Client.HTTPPostJson(ctx, apiAddKfAccount, map[string]string{
+		"kf_account": kfAccount,
+		"nickname":   nickname,
+	}, nil)
+}
+
+/*
+设置客服信息(昵称)
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Customer_Service_Management.html#设置客服信息
+*/
+func (api *KfApi) UpdateKfAccount(ctx context.Context, kfAccount, nickname string) error {
+	return api.Client.HTTPPostJson(ctx, apiUpdateKfAccount, map[string]string{
+		"kf_account": kfAccount,
+		"nickname":   nickname,
+	}, nil)
+}
+
+/*
+删除客服账号
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Customer_Service_Management.html#删除客服账号
+*/
+func (api *KfApi) DelKfAccount(ctx context.Context, kfAccount string) error {
+	return api.Client.HTTPGetWithParams(ctx, apiDelKfAccount, func(params url.Values) {
+		params.Add("kf_account", kfAccount)
+	}, nil)
+}
+
+/*
+上传客服头像, 头像图片文件必须是jpg格式，推荐使用640*640大小的图片
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Customer_Service_Management.html#上传客服头像
+*/
+func (api *KfApi) UploadHeadImg(
+	ctx context.Context,
+	kfAccount string,
+	filename string,
+	length int64,
+	content io.Reader,
+) error {
+	return api.Client.HTTPUpload(
+		ctx, apiUploadHeadImg, content, "media", filename, length, func(params url.Values) {
+			params.Add("kf_account", kfAccount)
+		}, nil,
+	)
+}
+
+/*
+邀请绑定客服账号, 被邀请的微信号需要在微信中确认
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Customer_Service_Management.html#邀请绑定客服账号
+*/
+func (api *KfApi) InviteWorker(ctx context.Context, kfAccount, inviteWx string) error {
+	return api.Client.HTTPPostJson(ctx, apiInviteWorker, map[string]string{
+		"kf_account": kfAccount,
+		"invite_wx":  inviteWx,
+	}, nil)
+}
+
+type KfInfo struct {
+	KfAccount        string `json:"kf_account"`         // 完整客服账号，格式为：账号前缀@公众号微信号
+	KfNick           string `json:"kf_nick"`            // 客服昵称
+	KfID             string `json:"kf_id"`              // 客服编号
+	KfHeadImgUrl     string `json:"kf_headimgurl"`      // 客服头像
+	KfWx             string `json:"kf_wx"`              // 绑定的客服人员微信号
+	InviteWx         string `json:"invite_wx"`          // 邀请中的微信号
+	InviteExpireTime int64  `json:"invite_expire_time"` // 邀请的过期时间
+	InviteStatus     string `json:"invite_status"`      // 邀请的状态 InviteStatusXXX
+}
+
+/*
+获取所有客服账号
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Customer_Service_Management.html#获取所有客服账号
+*/
+func (api *KfApi) GetKfList(ctx context.Context) ([]*KfInfo, error) {
+	resp := &struct {
+		utils.WeixinError
+		KfList []*KfInfo `json:"kf_list"`
+	}{}
+	if err := api.Client.HTTPGet(ctx, apiGetKfList, resp); err != nil {
+		return nil, err
+	}
+	return resp.KfList, nil
+}
+
+type OnlineKfInfo struct {
+	KfAccount    string `json:"kf_account"`    // 完整客服账号
+	Status       int    `json:"status"`        // 客服在线状态, KfStatusXXX
+	KfID         string `json:"kf_id"`         // 客服编号
+	AcceptedCase int    `json:"accepted_case"` // 客服当前正在接待的会话数
+}
+
+/*
+获取在线客服
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Customer_Service_Management.html#获取在线客服
+*/
+func (api *KfApi) GetOnlineKfList(ctx context.Context) ([]*OnlineKfInfo, error) {
+	resp := &struct {
+		utils.WeixinError
+		KfOnlineList []*OnlineKfInfo `json:"kf_online_list"`
+	}{}
+	if err := api.Client.HTTPGet(ctx, apiGetOnlineKfList, resp); err != nil {
+		return nil, err
+	}
+	return resp.KfOnlineList, nil
+}
+
+/*
+客服输入状态, 下发 Typing 之后 15s 内有效, 或者下发消息之后自动取消
+https://developers.weixin.qq.com/doc/offiaccount/Message_Management/Service_Center_messages.html#客服输入状态
+*/
+func (api *KfApi) Typing(ctx context.Context, openID, command string) error {
+	return api.Client.HTTPPostJson(ctx, apiCustomTyping, map[string]string{
+		"touser":  openID,
+		"command": command,
+	}, nil)
+}
@@ -0,0 +1,116 @@
+package kf_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/lixinio/weixin/weixin/official_account"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApi(server *mockserver.Server) *KfApi {
+	cache := memory.NewMemory()
+	oa := official_account.New(cache, cache, &official_account.Config{
+		Appid: "appid", Secret: "secret",
+	}, server.ClientOption())
+	return NewApi(oa.Client)
+}
+
+func TestKfAccount(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New(mockserver.OptWithApp("appid", "secret"))
+	defer server.Close()
+	server.HandleJSON(apiAddKfAccount, map[string]interface{}{})
+	server.HandleJSON(apiDelKfAccount, map[string]interface{}{})
+	server.HandleJSON(apiGetKfList, map[string]interface{}{
+		"kf_list": []map[string]interface{}{{
+			"kf_account": "test1@test", "kf_nick": "ntest1", "kf_id": "1001",
+			"invite_wx": "kfwx", "invite_expire_time": 123456789, "invite_status": "waiting",
+		}},
+	})
+	server.HandleJSON(apiGetSession, map[string]interface{}{
+		"createtime": 123456789, "kf_account": "test1@test",
+	})
+	api := newTestApi(server)
+
+	require.Equal(t, nil, api.AddKfAccount(ctx, "test1@test", "客服1"))
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiAddKfAccount).Unmarshal(&payload))
+	require.Equal(t, "客服1", payload["nickname"])
+
+	kfList, err := api.GetKfList(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, len(kfList))
+	require.Equal(t, InviteStatusWaiting, kfList[0].InviteStatus)
+
+	session, err := api.GetSession(ctx, "openid")
+	require.Equal(t, nil, err)
+	require.Equal(t, "test1@test", session.KfAccount)
+	require.Equal(t, "openid", server.LastRequest(apiGetSession).Query.Get("openid"))
+
+	require.Equal(t, nil, api.DelKfAccount(ctx, "test1@test"))
+	require.Equal(t, "test1@test", server.LastRequest(apiDelKfAccount).Query.Get("kf_account"))
+}
+
+func TestEachMsgRecord(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New(mockserver.OptWithApp("appid", "secret"))
+	defer server.Close()
+
+	// 第一页返回满页, 第二页返回不足一页
+	server.Handle(apiGetMsgList, func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]int64{}
+		require.Equal(t, nil, json.NewDecoder(r.Body).Decode(&req))
+		resp := &MsgList{MsgID: req["msgid"] + MaxMsgListNumber}
+		if req["msgid"] == 1 {
+			resp.Number = MaxMsgListNumber
+			resp.RecordList = []*MsgRecord{{OpenID: "openid1", OperCode: OperCodeFanMessage}}
+		} else {
+			resp.Number = 1
+			resp.RecordList = []*MsgRecord{{OpenID: "openid2", OperCode: OperCodeKfMessage}}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	api := newTestApi(server)
+
+	openIDs := []string{}
+	err := api.EachMsgRecord(ctx, 1000, 2000, func(record *MsgRecord) error {
+		openIDs = append(openIDs, record.OpenID)
+		return nil
+	})
+	require.Equal(t, nil, err)
+	require.Equal(t, []string{"openid1", "openid2"}, openIDs)
+
+	payload := map[string]int64{}
+	require.Equal(t, nil, server.LastRequest(apiGetMsgList).Unmarshal(&payload))
+	require.Equal(t, int64(1+MaxMsgListNumber), payload["msgid"])
+	require.Equal(t, int64(1000), payload["starttime"])
+
+	// handler 返回错误时停止
+	errStop := errors.New("stop")
+	err = api.EachMsgRecord(ctx, 1000, 2000, func(record *MsgRecord) error {
+		return errStop
+	})
+	require.True(t, errors.Is(err, errStop))
+
+	// 满页但是 msgid 没有前进, 不会一直请求同一页
+	requests := 0
+	server.Handle(apiGetMsgList, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode(&MsgList{
+			MsgID:      1,
+			Number:     MaxMsgListNumber,
+			RecordList: []*MsgRecord{{OpenID: "openid1"}},
+		})
+	})
+	err = api.EachMsgRecord(ctx, 1000, 2000, func(record *MsgRecord) error {
+		return nil
+	})
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, requests)
+}
@@ -0,0 +1,95 @@
+package kf_api
+
+// 获取聊天记录
+// https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Obtain_chat_transcript.html
+
+import (
+	"context"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+const (
+	apiGetMsgList = "/customservice/msgrecord/getmsglist"
+)
+
+const (
+	MaxMsgListNumber = 10000 // 每次获取条数，最多10000条
+)
+
+// 操作码
+const (
+	OperCodeCreateSession = 1000 // 创建未接入会话
+	OperCodeKfAccept      = 1001 // 接入会话
+	OperCodeKfInitiate    = 1002 // 主动发起会话
+	OperCodeKfSwitch      = 1003 // 转接会话
+	OperCodeKfClose       = 1004 // 关闭会话
+	OperCodeKfAcceptQueue = 1005 // 抢接会话
+	OperCodeFanMessage    = 2001 // 公众号收到消息
+	OperCodeKfMessage     = 2002 // 客服发送消息
+	OperCodeKfReceived    = 2003 // 客服收到消息
+)
+
+type MsgRecord struct {
+	OpenID   string `json:"openid"`   // 用户标识
+	OperCode int    `json:"opercode"` // 操作码，OperCodeXXX
+	Text     string `json:"text"`     // 聊天记录
+	Time     int64  `json:"time"`     // 操作时间，unix时间戳
+	Worker   string `json:"worker"`   // 完整客服帐号
+}
+
+type MsgList struct {
+	utils.WeixinError
+	RecordList []*MsgRecord `json:"recordlist"`
+	Number     int          `json:"number"` // 本次返回的条数
+	MsgID      int64        `json:"msgid"`  // 下一次请求的 msgid
+}
+
+/*
+获取聊天记录, 起始时间和结束时间需要在同一天
+msgID 为消息id顺序从小到大，从1开始, 下一页使用返回的 MsgID
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Obtain_chat_transcript.html
+*/
+func (api *KfApi) GetMsgList(
+	ctx context.Context,
+	startTime, endTime int64, // unix时间戳
+	msgID int64,
+	number int, // 每次获取条数，最多 MaxMsgListNumber 条
+) (*MsgList, error) {
+	resp := &MsgList{}
+	if err := api.Client.HTTPPostJson(ctx, apiGetMsgList, map[string]int64{
+		"starttime": startTime,
+		"endtime":   endTime,
+		"msgid":     msgID,
+		"number":    int64(number),
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// EachMsgRecord 遍历时间段内的所有聊天记录, handler 返回错误时停止
+func (api *KfApi) EachMsgRecord(
+	ctx context.Context,
+	startTime, endTime int64,
+	handler func(*MsgRecord) error,
+) error {
+	msgID := int64(1)
+	for {
+		result, err := api.GetMsgList(ctx, startTime, endTime, msgID, MaxMsgListNumber)
+		if err != nil {
+			return err
+		}
+		for _, record := range result.RecordList {
+			if err = handler(record); err != nil {
+				return err
+			}
+		}
+		// 返回的条数小于请求的条数, 已经是最后一页
+		// 返回的 msgid 没有前进时也停止, 否则会一直请求同一页
+		if result.Number < MaxMsgListNumber || len(result.RecordList) == 0 || result.MsgID <= msgID {
+			return nil
+		}
+		msgID = result.MsgID
+	}
+}
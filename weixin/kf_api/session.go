@@ -0,0 +1,105 @@
+package kf_api
+
+// 会话控制
+// 会话状态的变化通过 server_api.EventTypeKfCreateSession/EventTypeKfCloseSession/EventTypeKfSwitchSession 事件推送
+// https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Session_control.html
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+const (
+	apiCreateSession  = "/customservice/kfsession/create"         // 创建会话
+	apiCloseSession   = "/customservice/kfsession/close"          // 关闭会话
+	apiGetSession     = "/customservice/kfsession/getsession"     // 获取客户会话状态
+	apiGetSessionList = "/customservice/kfsession/getsessionlist" // 获取客服会话列表
+	apiGetWaitCase    = "/customservice/kfsession/getwaitcase"    // 获取未接入会话列表
+)
+
+/*
+创建会话, 客服必须在线
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Session_control.html#创建会话
+*/
+func (api *KfApi) CreateSession(ctx context.Context, kfAccount, openID string) error {
+	return api.Client.HTTPPostJson(ctx, apiCreateSession, map[string]string{
+		"kf_account": kfAccount,
+		"openid":     openID,
+	}, nil)
+}
+
+/*
+关闭会话
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Session_control.html#关闭会话
+*/
+func (api *KfApi) CloseSession(ctx context.Context, kfAccount, openID string) error {
+	return api.Client.HTTPPostJson(ctx, apiCloseSession, map[string]string{
+		"kf_account": kfAccount,
+		"openid":     openID,
+	}, nil)
+}
+
+type Session struct {
+	utils.WeixinError
+	KfAccount  string `json:"kf_account"` // 正在接待的客服，为空表示没有人在接待
+	CreateTime int64  `json:"createtime"` // 会话接入的时间
+}
+
+/*
+获取客户会话状态
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Session_control.html#获取客户会话状态
+*/
+func (api *KfApi) GetSession(ctx context.Context, openID string) (*Session, error) {
+	resp := &Session{}
+	if err := api.Client.HTTPGetWithParams(ctx, apiGetSession, func(params url.Values) {
+		params.Add("openid", openID)
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type SessionItem struct {
+	OpenID     string `json:"openid"`     // 粉丝的openid
+	CreateTime int64  `json:"createtime"` // 会话接入的时间
+}
+
+/*
+获取客服会话列表
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Session_control.html#获取客服会话列表
+*/
+func (api *KfApi) GetSessionList(ctx context.Context, kfAccount string) ([]*SessionItem, error) {
+	resp := &struct {
+		utils.WeixinError
+		SessionList []*SessionItem `json:"sessionlist"`
+	}{}
+	if err := api.Client.HTTPGetWithParams(ctx, apiGetSessionList, func(params url.Values) {
+		params.Add("kf_account", kfAccount)
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp.SessionList, nil
+}
+
+type WaitCase struct {
+	utils.WeixinError
+	Count        int `json:"count"` // 未接入会话数量
+	WaitCaseList []struct {
+		LatestTime int64  `json:"latest_time"` // 粉丝的最后一条消息的时间
+		OpenID     string `json:"openid"`      // 粉丝的openid
+	} `json:"waitcaselist"` // 最多返回100条数据，按照来访顺序
+}
+
+/*
+获取未接入会话列表
+https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Session_control.html#获取未接入会话列表
+*/
+func (api *KfApi) GetWaitCase(ctx context.Context) (*WaitCase, error) {
+	resp := &WaitCase{}
+	if err := api.Client.HTTPGet(ctx, apiGetWaitCase, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -13,20 +13,24 @@ const (
 	apiGetArticle       = "/cgi-bin/freepublish/getarticle" // 获取已发布图文信息
 )
 
+// Article 图文, 同时用于草稿箱(draft_api)和发布(publish_api)
 type Article struct {
-	Title  string `json:"title"`  // 标题
-	Author string `json:"author"` // 作者名
-	Digest string `json:"digest"` // 图文消息的摘要，仅有单图文消息才有摘要，多图文此处为空。如果本字段为没有填写，则默认抓取正文前54个字。
+	ArticleType string `json:"article_type,omitempty"` // 文章类型， news 图文消息(默认)， newspic 图片消息
+	Title       string `json:"title"`                  // 标题
+	Author      string `json:"author"`                 // 作者名
+	Digest      string `json:"digest"`                 // 图文消息的摘要，仅有单图文消息才有摘要，多图文此处为空。如果本字段为没有填写，则默认抓取正文前54个字。
 	// 图文消息的具体内容，支持HTML标签，必须少于2万字符，小于1M，且此处会去除JS,
 	// 涉及图片url必须来源 "上传图文消息内的图片获取URL"接口获取。
-	Content            string `json:"content"`               // 外部图片url将被过滤。 图片消息则仅支持纯文本和部分特殊功能标签如商品，商品个数不可超过50个
-	ContentSourceUrl   string `json:"content_source_url"`    // 图文消息的原文地址，即点击“阅读原文”后的URL
-	ThumbMediaID       string `json:"thumb_media_id"`        // 图文消息的封面图片素材id（必须是永久MediaID）
-	ThumbUrl           string `json:"thumb_url"`             // 图文消息的封面图片URL
-	NeedOpenComment    int    `json:"need_open_comment"`     // 是否打开评论，0不打开(默认)，1打开
-	OnlyFansCanComment int    `json:"only_fans_can_comment"` // 是否粉丝才可评论，0所有人可评论(默认)，1粉丝才可评论
-	Url                string `json:"url"`                   // 草稿的临时链接
-	IsDeleted          bool   `json:"is_deleted"`            // 该图文是否被删除
+	Content            string `json:"content"`                  // 外部图片url将被过滤。 图片消息则仅支持纯文本和部分特殊功能标签如商品，商品个数不可超过50个
+	ContentSourceUrl   string `json:"content_source_url"`       // 图文消息的原文地址，即点击“阅读原文”后的URL
+	ThumbMediaID       string `json:"thumb_media_id"`           // 图文消息的封面图片素材id（必须是永久MediaID）
+	ThumbUrl           string `json:"thumb_url,omitempty"`      // 图文消息的封面图片URL
+	NeedOpenComment    int    `json:"need_open_comment"`        // 是否打开评论，0不打开(默认)，1打开
+	OnlyFansCanComment int    `json:"only_fans_can_comment"`    // 是否粉丝才可评论，0所有人可评论(默认)，1粉丝才可评论
+	PicCrop2351        string `json:"pic_crop_235_1,omitempty"` // 封面裁剪为2.35:1规格的坐标字段
+	PicCrop11          string `json:"pic_crop_1_1,omitempty"`   // 封面裁剪为1:1规格的坐标字段
+	Url                string `json:"url,omitempty"`            // 草稿的临时链接
+	IsDeleted          bool   `json:"is_deleted,omitempty"`     // 该图文是否被删除
 }
 
 type ArticleList struct {
@@ -45,6 +49,8 @@ type ArticleList struct {
 /*
 获取已发布的消息列表
 https://developers.weixin.qq.com/doc/service/api/public/api_freepublish_batchget.html
+
+Deprecated: 使用 publish_api.BatchGet
 */
 func (api *MaterialApi) BatchGetArticles(
 	ctx context.Context,
@@ -71,6 +77,8 @@ func (api *MaterialApi) BatchGetArticles(
 /*
 获取已发布图文信息
 https://developers.weixin.qq.com/doc/service/api/public/api_freepublishgetarticle.html
+
+Deprecated: 使用 publish_api.GetArticle
 */
 func (api *MaterialApi) GetArticle(
 	ctx context.Context, articleID string,
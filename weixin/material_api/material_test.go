@@ -1,315 +1,322 @@
 package material_api
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
+	"image/color"
+	"image/png"
 	"io"
 	"net/http"
-	"os"
+	"sync"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/weixin/official_account"
 	"github.com/stretchr/testify/require"
 )
 
-func initOfficialAccount() *utils.Client {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	officialAccount := official_account.New(redis, redis, &official_account.Config{
-		Appid:  test.OfficialAccountAppid,
-		Secret: test.OfficialAccountSecret,
-	})
+func initOfficialAccount(server *mockserver.Server) *utils.Client {
+	cache := memory.NewMemory()
+	officialAccount := official_account.New(cache, cache, &official_account.Config{
+		Appid:  "wxofficialaccount",
+		Secret: "secret",
+	}, server.ClientOption())
 	return officialAccount.Client
 }
 
-func TestMaterialUrl(t *testing.T) {
-	ctx := context.Background()
-	for _, client := range []*utils.Client{
-		initOfficialAccount(),
-	} {
-		materialApi := NewApi(client)
+// fakeMaterials 模拟素材的存储, 上传之后可以下载/删除
+type fakeMaterials struct {
+	server *mockserver.Server
+	mu     sync.Mutex
+	seq    int
+	files  map[string][]byte
+	videos map[string]map[string]string
+}
 
-		file, err := os.Open(test.ImagePath)
-		require.Empty(t, err)
-		defer file.Close()
+func newFakeMaterials(server *mockserver.Server) *fakeMaterials {
+	m := &fakeMaterials{
+		server: server,
+		files:  map[string][]byte{},
+		videos: map[string]map[string]string{},
+	}
+	server.Handle(apiUpload, m.upload)
+	server.Handle(apiUploadMaterial, m.upload)
+	server.Handle(apiUploadImg, m.upload)
+	server.Handle(apiGet, func(w http.ResponseWriter, r *http.Request) {
+		m.download(w, r.URL.Query().Get("media_id"))
+	})
+	server.Handle(apiGetMaterial, func(w http.ResponseWriter, r *http.Request) {
+		m.download(w, m.mediaIDFromBody(r))
+	})
+	server.Handle(apiDeleteMaterial, func(w http.ResponseWriter, r *http.Request) {
+		mediaID := m.mediaIDFromBody(r)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.files[mediaID]; !ok {
+			writeJSON(w, &utils.WeixinError{ErrCode: 40007, ErrMsg: "invalid media_id"})
+			return
+		}
+		delete(m.files, mediaID)
+		writeJSON(w, &utils.WeixinError{})
+	})
+	server.Handle("/mmbiz_png/", func(w http.ResponseWriter, r *http.Request) {
+		m.download(w, r.URL.Query().Get("media_id"))
+	})
+	return m
+}
 
-		fi, err := file.Stat()
-		require.Empty(t, err)
+func (m *fakeMaterials) upload(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("media")
+	if err != nil {
+		writeJSON(w, &utils.WeixinError{ErrCode: 41005, ErrMsg: "media data missing"})
+		return
+	}
+	content, _ := io.ReadAll(file)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	mediaID := fmt.Sprintf("media_%d", m.seq)
+	m.files[mediaID] = content
+	if description := r.FormValue("description"); description != "" {
+		video := map[string]string{}
+		_ = json.Unmarshal([]byte(description), &video)
+		m.videos[mediaID] = video
+	}
 
-		url, err := materialApi.UploadImg(ctx, test.ImagePath, fi.Size(), file)
-		require.Empty(t, err)
-		fmt.Print(url)
+	writeJSON(w, map[string]interface{}{
+		"media_id":   mediaID,
+		"type":       r.URL.Query().Get("type"),
+		"created_at": 1606717010,
+		"url":        m.server.URL + "/mmbiz_png/?media_id=" + mediaID,
+	})
+}
 
-		// 下载
-		response, err := http.Get(url)
-		require.Empty(t, err)
-		defer response.Body.Close()
+func (m *fakeMaterials) download(w http.ResponseWriter, mediaID string) {
+	m.mu.Lock()
+	content, ok := m.files[mediaID]
+	video, isVideo := m.videos[mediaID]
+	m.mu.Unlock()
 
-		_, _, err = image.Decode(response.Body)
-		require.Empty(t, err)
+	if !ok {
+		writeJSON(w, &utils.WeixinError{ErrCode: 40007, ErrMsg: "invalid media_id"})
+		return
 	}
+	if isVideo {
+		// 视频素材返回的是 json
+		writeJSON(w, map[string]string{
+			"title":       video["title"],
+			"description": video["introduction"],
+			"down_url":    "http://example.com/" + mediaID,
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(content)
 }
 
-func TestUploadMedia(t *testing.T) {
-	ctx := context.Background()
-	for _, client := range []*utils.Client{
-		initOfficialAccount(),
-	} {
-		materialApi := NewApi(client)
-
-		file, err := os.Open(test.ImagePath)
-		require.Empty(t, err)
-		defer file.Close()
-
-		fi, err := file.Stat()
-		require.Empty(t, err)
-
-		result, err := materialApi.UploadMedia(ctx, test.ImagePath, fi.Size(), file, MediaTypeImage)
-		require.Empty(t, err)
-		require.Equal(t, result.Type, MediaTypeImage)
-		fmt.Println(result.MediaID, result.CreatedAt, result.Type)
-
-		// 计算源文件hash
-		originHash := ""
-		{
-			file.Seek(0, 0)
-			hasher := sha256.New()
-			_, err = io.Copy(hasher, file)
-			require.Empty(t, err)
-			originHash = hex.EncodeToString(hasher.Sum(nil))
-		}
-
-		{
-			resp, err := materialApi.GetMedia(ctx, result.MediaID)
-			require.Empty(t, err)
-
-			// 计算hash
-			hasher := sha256.New()
-			_, err = hasher.Write(resp)
-			require.Empty(t, err)
+func (m *fakeMaterials) mediaIDFromBody(r *http.Request) string {
+	payload := map[string]string{}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	return payload["media_id"]
+}
 
-			thisHash := hex.EncodeToString(hasher.Sum(nil))
-			require.Equal(t, thisHash, originHash)
-		}
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
 
-		{
-			r, w := io.Pipe()
-			go func(t *testing.T) {
-				defer w.Close()
-				err := materialApi.SaveMedia(ctx, result.MediaID, w)
-				require.Empty(t, err)
-			}(t)
-
-			// 计算hash
-			hasher := sha256.New()
-			_, err = io.Copy(hasher, r)
-			require.Empty(t, err)
-
-			thisHash := hex.EncodeToString(hasher.Sum(nil))
-			require.Equal(t, thisHash, originHash)
-		}
+func isWeixinError(err error, code int64) bool {
+	var wxError *utils.WeixinError
+	return errors.As(err, &wxError) && wxError.ErrCode == code
+}
 
-		{
-			mediaID := fmt.Sprintf("0%s", result.MediaID)
-			_, err := materialApi.GetMedia(ctx, mediaID)
-			require.NotEmpty(t, err)
-		}
-	}
+func testImage(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	buffer := &bytes.Buffer{}
+	require.Equal(t, nil, png.Encode(buffer, img))
+	return buffer.Bytes()
 }
 
-func TestUploadImageMaterial(t *testing.T) {
+func TestMaterialUrl(t *testing.T) {
 	ctx := context.Background()
-	for _, client := range []*utils.Client{
-		initOfficialAccount(),
-	} {
-		materialApi := NewApi(client)
-
-		file, err := os.Open(test.ImagePath)
-		require.Empty(t, err)
-		defer file.Close()
-
-		fi, err := file.Stat()
-		require.Empty(t, err)
-
-		result, err := materialApi.UploadMaterial(
-			ctx,
-			test.ImagePath,
-			fi.Size(),
-			file,
-			MediaTypeImage,
-		)
-		require.Empty(t, err)
-		fmt.Println(result.MediaID, result.URL)
-
-		// 永久素材， 图片素材不一致
-		{
-			_, err := materialApi.GetMaterial(ctx, result.MediaID)
-			require.Empty(t, err)
-		}
+	server := mockserver.New()
+	defer server.Close()
+	newFakeMaterials(server)
+	materialApi := NewApi(initOfficialAccount(server))
+
+	content := testImage(t)
+	url, err := materialApi.UploadImg(ctx, "a.png", int64(len(content)), bytes.NewReader(content))
+	require.Equal(t, nil, err)
+
+	// 下载
+	response, err := http.Get(url)
+	require.Equal(t, nil, err)
+	defer response.Body.Close()
+
+	_, _, err = image.Decode(response.Body)
+	require.Equal(t, nil, err)
+}
 
-		{
-			_, w := io.Pipe()
-			go func(t *testing.T) {
-				defer w.Close()
-				err := materialApi.SaveMaterial(ctx, result.MediaID, w)
-				require.Empty(t, err)
-			}(t)
-		}
+func TestUploadMedia(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	newFakeMaterials(server)
+	materialApi := NewApi(initOfficialAccount(server))
+
+	content := testImage(t)
+	result, err := materialApi.UploadMedia(
+		ctx, "a.png", int64(len(content)), bytes.NewReader(content), MediaTypeImage,
+	)
+	require.Equal(t, nil, err)
+	require.Equal(t, string(MediaTypeImage), result.Type)
+	require.Equal(t, "image", server.LastRequest(apiUpload).Query.Get("type"))
+
+	{
+		resp, err := materialApi.GetMedia(ctx, result.MediaID)
+		require.Equal(t, nil, err)
+		require.Equal(t, content, resp)
+	}
 
-		{
-			mediaID := fmt.Sprintf("0%s", result.MediaID)
-			_, err := materialApi.GetMaterial(ctx, mediaID)
-			require.NotEmpty(t, err)
-		}
+	{
+		buffer := &bytes.Buffer{}
+		err := materialApi.SaveMedia(ctx, result.MediaID, buffer)
+		require.Equal(t, nil, err)
+		require.Equal(t, content, buffer.Bytes())
+	}
 
-		{
-			err = materialApi.DeleteMaterial(ctx, result.MediaID)
-			require.Empty(t, err)
-		}
+	{
+		_, err := materialApi.GetMedia(ctx, "0"+result.MediaID)
+		require.True(t, isWeixinError(err, 40007))
 	}
 }
 
-func TestUploadVoiceMaterial(t *testing.T) {
+func TestUploadImageMaterial(t *testing.T) {
 	ctx := context.Background()
-	for _, client := range []*utils.Client{
-		initOfficialAccount(),
-	} {
-		materialApi := NewApi(client)
-
-		file, err := os.Open(test.AudioPath)
-		require.Empty(t, err)
-		defer file.Close()
-
-		fi, err := file.Stat()
-		require.Empty(t, err)
-
-		result, err := materialApi.UploadMaterial(
-			ctx,
-			test.AudioPath,
-			fi.Size(),
-			file,
-			MediaTypeVoice,
-		)
-		require.Empty(t, err)
-		fmt.Println(result.MediaID, result.URL)
-
-		// 永久素材， Voice素材不一致
-		{
-			_, err := materialApi.GetMaterial(ctx, result.MediaID)
-			require.Empty(t, err)
-		}
+	server := mockserver.New()
+	defer server.Close()
+	newFakeMaterials(server)
+	materialApi := NewApi(initOfficialAccount(server))
+
+	content := testImage(t)
+	result, err := materialApi.UploadMaterial(
+		ctx, "a.png", int64(len(content)), bytes.NewReader(content), MediaTypeImage,
+	)
+	require.Equal(t, nil, err)
+	require.NotEqual(t, "", result.URL)
+
+	{
+		resp, err := materialApi.GetMaterial(ctx, result.MediaID)
+		require.Equal(t, nil, err)
+		require.Equal(t, content, resp)
+	}
 
-		{
-			_, w := io.Pipe()
-			go func(t *testing.T) {
-				defer w.Close()
-				err := materialApi.SaveMaterial(ctx, result.MediaID, w)
-				require.Empty(t, err)
-			}(t)
-		}
+	{
+		buffer := &bytes.Buffer{}
+		err := materialApi.SaveMaterial(ctx, result.MediaID, buffer)
+		require.Equal(t, nil, err)
+		require.Equal(t, content, buffer.Bytes())
+	}
 
-		{
-			mediaID := fmt.Sprintf("0%s", result.MediaID)
-			_, err := materialApi.GetMaterial(ctx, mediaID)
-			require.NotEmpty(t, err)
-		}
+	{
+		_, err := materialApi.GetMaterial(ctx, "0"+result.MediaID)
+		require.True(t, isWeixinError(err, 40007))
+	}
 
-		{
-			err = materialApi.DeleteMaterial(ctx, result.MediaID)
-			require.Empty(t, err)
-		}
+	{
+		err = materialApi.DeleteMaterial(ctx, result.MediaID)
+		require.Equal(t, nil, err)
+		_, err := materialApi.GetMaterial(ctx, result.MediaID)
+		require.True(t, isWeixinError(err, 40007))
 	}
 }
 
 func TestUploadVideoMaterial(t *testing.T) {
 	ctx := context.Background()
-	for _, client := range []*utils.Client{
-		initOfficialAccount(),
-	} {
-		materialApi := NewApi(client)
-
-		file, err := os.Open(test.VideoPath)
-		require.Empty(t, err)
-		defer file.Close()
-
-		fi, err := file.Stat()
-		require.Empty(t, err)
-
-		result, err := materialApi.UploadVideoMaterial(
-			ctx, test.VideoPath, "Title", "fjasdklfjasd", fi.Size(), file,
-		)
-		require.Empty(t, err)
-		fmt.Println(result.MediaID, result.URL)
-
-		{
-			m, err := materialApi.GetVideoMaterial(ctx, result.MediaID)
-			require.Empty(t, err)
-			fmt.Println(m.Description, m.DownloadUrl, m.Title)
-		}
+	server := mockserver.New()
+	defer server.Close()
+	newFakeMaterials(server)
+	materialApi := NewApi(initOfficialAccount(server))
+
+	content := []byte("video content")
+	result, err := materialApi.UploadVideoMaterial(
+		ctx, "b.mp4", "Title", "fjasdklfjasd", int64(len(content)), bytes.NewReader(content),
+	)
+	require.Equal(t, nil, err)
+	require.Equal(t, "video", server.LastRequest(apiUploadMaterial).Query.Get("type"))
+
+	{
+		m, err := materialApi.GetVideoMaterial(ctx, result.MediaID)
+		require.Equal(t, nil, err)
+		require.Equal(t, "Title", m.Title)
+		require.Equal(t, "fjasdklfjasd", m.Description)
+	}
 
-		{
-			err = materialApi.DeleteMaterial(ctx, result.MediaID)
-			require.Empty(t, err)
-		}
+	{
+		err = materialApi.DeleteMaterial(ctx, result.MediaID)
+		require.Equal(t, nil, err)
 	}
 }
 
 func TestCountMaterial(t *testing.T) {
 	ctx := context.Background()
-	for _, client := range []*utils.Client{
-		initOfficialAccount(),
-	} {
-		materialApi := NewApi(client)
-
-		result, err := materialApi.GetMaterialStatistics(ctx)
-		require.Empty(t, err)
-		fmt.Println(result.ImageCount, result.VoiceCount, result.VideoCount, result.NewsCount)
-
-		{
-			materials, err := materialApi.ListMaterial(ctx, MediaTypeVoice, 0, 100)
-			require.Empty(t, err)
-			fmt.Println(materials.ItemCount, materials.TotalCount)
-			for _, material := range materials.Items {
-				fmt.Println(material.MediaID, material.Name, material.URL, material.UpdateTime)
-			}
-		}
-
-		{
-			materials, err := materialApi.ListMaterial(ctx, MediaTypeImage, 0, 100)
-			require.Empty(t, err)
-			fmt.Println(materials.ItemCount, materials.TotalCount)
-			for _, material := range materials.Items {
-				fmt.Println(material.MediaID, material.Name, material.URL, material.UpdateTime)
-			}
-		}
-
-		{
-			materials, err := materialApi.ListMaterial(ctx, MediaTypeVideo, 0, 100)
-			require.Empty(t, err)
-			fmt.Println(materials.ItemCount, materials.TotalCount)
-			for _, material := range materials.Items {
-				fmt.Println(material.MediaID, material.Name, material.URL, material.UpdateTime)
-			}
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiCountMaterial, map[string]int{
+		"voice_count": 1, "video_count": 2, "image_count": 3, "news_count": 4,
+	})
+	server.Handle(apiListMaterial, func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if payload["type"] == string(MediaTypeNews) {
+			writeJSON(w, map[string]interface{}{
+				"total_count": 1, "item_count": 1,
+				"item": []map[string]interface{}{{
+					"media_id": "news_1",
+					"content": map[string]interface{}{
+						"news_item": []map[string]interface{}{{"title": "标题", "content": "内容"}},
+					},
+				}},
+			})
+			return
 		}
+		writeJSON(w, map[string]interface{}{
+			"total_count": 1, "item_count": 1,
+			"item": []map[string]interface{}{
+				{"media_id": fmt.Sprintf("%s_1", payload["type"]), "name": "a.png", "update_time": 1606717010},
+			},
+		})
+	})
+	materialApi := NewApi(initOfficialAccount(server))
+
+	result, err := materialApi.GetMaterialStatistics(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, 3, result.ImageCount)
+	require.Equal(t, 4, result.NewsCount)
+
+	for _, mediaType := range []MediaType{MediaTypeVoice, MediaTypeImage, MediaTypeVideo} {
+		materials, err := materialApi.ListMaterial(ctx, mediaType, 0, 100)
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, materials.ItemCount)
+		require.Equal(t, fmt.Sprintf("%s_1", mediaType), materials.Items[0].MediaID)
+
+		payload := map[string]interface{}{}
+		require.Equal(t, nil, server.LastRequest(apiListMaterial).Unmarshal(&payload))
+		require.Equal(t, float64(100), payload["count"])
+	}
 
-		{
-			materials, err := materialApi.ListMpnewsMaterial(ctx, 0, 100)
-			require.Empty(t, err)
-			fmt.Println(materials.ItemCount, materials.TotalCount)
-			for _, material := range materials.Items {
-				fmt.Println(material.MediaID)
-				for _, item := range materials.Items {
-					fmt.Println(item.Content, item.MediaID)
-				}
-			}
-		}
+	{
+		materials, err := materialApi.ListMpnewsMaterial(ctx, 0, 100)
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, materials.ItemCount)
+		require.Equal(t, "标题", materials.Items[0].Content.NewsItems[0].Title)
 	}
 }
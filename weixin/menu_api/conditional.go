@@ -0,0 +1,139 @@
+package menu_api
+
+// 个性化菜单, 按照用户标签/客户端版本显示不同的菜单, 需要先创建默认菜单
+// https://developers.weixin.qq.com/doc/offiaccount/Custom_Menus/Personalized_menu_interface.html
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+const (
+	apiAddConditional         = "/cgi-bin/menu/addconditional"
+	apiDelConditional         = "/cgi-bin/menu/delconditional"
+	apiTryMatch               = "/cgi-bin/menu/trymatch"
+	apiGetCurrentSelfMenuInfo = "/cgi-bin/get_current_selfmenu_info"
+)
+
+// MatchRule 菜单匹配规则, 至少有一个字段
+type MatchRule struct {
+	TagID              string `json:"tag_id,omitempty"`               // 用户标签的id
+	ClientPlatformType string `json:"client_platform_type,omitempty"` // 客户端版本, IOS(1), Android(2), Others(3)
+}
+
+// ConditionalMenu 个性化菜单
+type ConditionalMenu struct {
+	Buttons   []*MenuItem `json:"button"`
+	MatchRule *MatchRule  `json:"matchrule"`
+	MenuID    string      `json:"menuid,omitempty"` // 创建之后才有
+}
+
+// jsonString 兼容数字和字符串两种格式
+type jsonString string
+
+func (s *jsonString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*s = jsonString(value)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	*s = jsonString(number)
+	return nil
+}
+
+/*
+创建个性化菜单, 返回 menuid
+https://developers.weixin.qq.com/doc/offiaccount/Custom_Menus/Personalized_menu_interface.html
+*/
+func (api *MenuApi) AddConditionalMenu(
+	ctx context.Context, menus []*MenuItem, rule *MatchRule,
+) (string, error) {
+	if err := ValidateMenus(menus); err != nil {
+		return "", err
+	}
+
+	resp := &struct {
+		utils.WeixinError
+		MenuID jsonString `json:"menuid"`
+	}{}
+	if err := api.Client.HTTPPostJson(ctx, apiAddConditional, &ConditionalMenu{
+		Buttons: menus, MatchRule: rule,
+	}, resp); err != nil {
+		return "", err
+	}
+	return string(resp.MenuID), nil
+}
+
+// 删除个性化菜单
+func (api *MenuApi) DeleteConditionalMenu(ctx context.Context, menuID string) error {
+	return api.Client.HTTPPostJson(ctx, apiDelConditional, map[string]string{
+		"menuid": menuID,
+	}, nil)
+}
+
+// 测试个性化菜单匹配结果, userID 可以是粉丝的 openid, 也可以是粉丝的微信号
+func (api *MenuApi) TryMatch(ctx context.Context, userID string) ([]*MenuItem, error) {
+	resp := &struct {
+		utils.WeixinError
+		Buttons []*MenuItem `json:"button"`
+	}{}
+	if err := api.Client.HTTPPostJson(ctx, apiTryMatch, map[string]string{
+		"user_id": userID,
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Buttons, nil
+}
+
+// SelfMenuItem 查询菜单接口返回的菜单项, 包括在公众平台官网设置的菜单
+type SelfMenuItem struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Key       string `json:"key,omitempty"`
+	Url       string `json:"url,omitempty"`
+	Value     string `json:"value,omitempty"` // 官网设置的菜单, 文本/图片/语音/视频 的内容或者 media_id
+	SubButton *struct {
+		List []*SelfMenuItem `json:"list"`
+	} `json:"sub_button,omitempty"`
+	NewsInfo *struct {
+		List []struct {
+			Title      string `json:"title"`
+			Author     string `json:"author"`
+			Digest     string `json:"digest"`
+			ShowCover  int    `json:"show_cover"`
+			CoverUrl   string `json:"cover_url"`
+			ContentUrl string `json:"content_url"`
+			SourceUrl  string `json:"source_url"`
+		} `json:"list"`
+	} `json:"news_info,omitempty"`
+}
+
+type SelfMenuInfo struct {
+	utils.WeixinError
+	IsMenuOpen   int `json:"is_menu_open"` // 菜单是否开启，0代表未开启，1代表开启
+	SelfMenuInfo struct {
+		Buttons []*SelfMenuItem `json:"button"`
+	} `json:"selfmenu_info"`
+}
+
+/*
+查询当前使用的自定义菜单(包括官网设置的菜单)
+https://developers.weixin.qq.com/doc/offiaccount/Custom_Menus/Querying_Custom_Menus.html
+*/
+func (api *MenuApi) GetCurrentSelfMenuInfo(ctx context.Context) (*SelfMenuInfo, error) {
+	resp := &SelfMenuInfo{}
+	if err := api.Client.HTTPGet(ctx, apiGetCurrentSelfMenuInfo, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -40,6 +40,9 @@ type MenuItem struct {
 // 创建自定义菜单
 // https://developers.weixin.qq.com/doc/subscription/api/custommenu/api_createcustommenu.html
 func (api *MenuApi) CreateMenu(ctx context.Context, menus []*MenuItem) error {
+	if err := ValidateMenus(menus); err != nil {
+		return err
+	}
 	req := struct {
 		Buttons []*MenuItem `json:"button"`
 	}{
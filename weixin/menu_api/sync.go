@@ -0,0 +1,287 @@
+package menu_api
+
+// 声明式的菜单同步, 从 YAML/JSON 加载期望的菜单(默认菜单 + 个性化菜单), 和线上的菜单比较, 只提交有变化的部分
+//
+//	default:
+//	  - name: 官网
+//	    type: view
+//	    url: https://www.example.com
+//	conditional:
+//	  - matchrule:
+//	      tag_id: "2"
+//	    button:
+//	      - name: 会员
+//	        type: click
+//	        key: member
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lixinio/weixin/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const errCodeMenuNotExist = 46003
+
+// MenuSet 默认菜单和个性化菜单
+type MenuSet struct {
+	Default     []*MenuItem        `json:"default"`
+	Conditional []*ConditionalMenu `json:"conditional,omitempty"`
+}
+
+// Validate 校验所有的菜单
+func (set *MenuSet) Validate() error {
+	if len(set.Default) == 0 {
+		if len(set.Conditional) > 0 {
+			return fmt.Errorf("%w: conditional menus require default menu", ErrInvalidMenu)
+		}
+		return nil
+	}
+	if err := ValidateMenus(set.Default); err != nil {
+		return fmt.Errorf("default %w", err)
+	}
+	for i, menu := range set.Conditional {
+		if menu.MatchRule == nil || (menu.MatchRule.TagID == "" && menu.MatchRule.ClientPlatformType == "") {
+			return fmt.Errorf("%w: conditional[%d] matchrule is required", ErrInvalidMenu, i)
+		}
+		if err := ValidateMenus(menu.Buttons); err != nil {
+			return fmt.Errorf("conditional[%d] %w", i, err)
+		}
+	}
+	return nil
+}
+
+// LoadMenuSet 解析 YAML/JSON 格式的菜单配置, 字段名称和微信接口的 json 一致
+func LoadMenuSet(data []byte) (*MenuSet, error) {
+	// YAML 是 JSON 的超集, 先转换为 JSON, 复用 json tag
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &MenuSet{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields() // 拼错的字段名会导致菜单不完整
+	if err = decoder.Decode(set); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMenu, err.Error())
+	}
+	return set, nil
+}
+
+// LoadMenuSetFile 从文件加载菜单配置
+func LoadMenuSetFile(filename string) (*MenuSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadMenuSet(data)
+}
+
+/*
+获取默认菜单和个性化菜单
+https://developers.weixin.qq.com/doc/offiaccount/Custom_Menus/Querying_Custom_Menus.html
+*/
+func (api *MenuApi) GetMenus(ctx context.Context) (*MenuSet, error) {
+	// 查询接口返回的 menuid 是数字, 匹配规则使用 group_id, 和创建接口不一致
+	resp := &struct {
+		utils.WeixinError
+		Menu struct {
+			Buttons []*getMenuItem `json:"button"`
+		} `json:"menu"`
+		ConditionalMenus []*struct {
+			Buttons   []*getMenuItem `json:"button"`
+			MatchRule *struct {
+				GroupID            jsonString `json:"group_id"`
+				TagID              jsonString `json:"tag_id"`
+				ClientPlatformType jsonString `json:"client_platform_type"`
+			} `json:"matchrule"`
+			MenuID jsonString `json:"menuid"`
+		} `json:"conditionalmenu"`
+	}{}
+	if err := api.Client.HTTPGet(ctx, apiGetMenu, resp); err != nil {
+		// 不存在的菜单数据
+		var we *utils.WeixinError
+		if errors.As(err, &we) && we.ErrCode == errCodeMenuNotExist {
+			return &MenuSet{}, nil
+		}
+		return nil, err
+	}
+
+	set := &MenuSet{Default: toMenuItems(resp.Menu.Buttons)}
+	for _, menu := range resp.ConditionalMenus {
+		conditional := &ConditionalMenu{Buttons: toMenuItems(menu.Buttons), MenuID: string(menu.MenuID)}
+		if menu.MatchRule != nil {
+			conditional.MatchRule = &MatchRule{
+				TagID:              string(menu.MatchRule.TagID),
+				ClientPlatformType: string(menu.MatchRule.ClientPlatformType),
+			}
+			if conditional.MatchRule.TagID == "" {
+				conditional.MatchRule.TagID = string(menu.MatchRule.GroupID)
+			}
+		}
+		set.Conditional = append(set.Conditional, conditional)
+	}
+	return set, nil
+}
+
+// getMenuItem 查询接口返回的菜单项目, 和创建接口的格式不完全一致
+//   - sub_button 可能是数组, 也可能是 {"list": [...]} (公众平台官网设置的菜单)
+//   - 官网设置的素材菜单使用 value 保存 media_id, 图文消息菜单使用 news_info, 接口无法创建, 忽略
+type getMenuItem struct {
+	MenuItem
+	SubButtons getSubButtons `json:"sub_button"`
+	Value      string        `json:"value"`
+}
+
+type getSubButtons []*getMenuItem
+
+func (b *getSubButtons) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		wrapper := &struct {
+			List []*getMenuItem `json:"list"`
+		}{}
+		if err := json.Unmarshal(data, wrapper); err != nil {
+			return err
+		}
+		*b = wrapper.List
+		return nil
+	}
+	var list []*getMenuItem
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*b = list
+	return nil
+}
+
+// toMenuItems 转换为创建接口的格式
+func toMenuItems(items []*getMenuItem) []*MenuItem {
+	menus := make([]*MenuItem, 0, len(items))
+	for _, item := range items {
+		menu := item.MenuItem
+		menu.SubButtons = toMenuItems(item.SubButtons)
+		switch item.Type {
+		case "img", "photo", "voice", "video":
+			if item.Value != "" {
+				value := item.Value
+				menu.Type, menu.MediaID = MenuTypeMediaID, &value
+			}
+		}
+		menus = append(menus, &menu)
+	}
+	return normalizeMenus(menus)
+}
+
+// normalizeMenus 复制一份菜单, 去掉空的子菜单, 避免 nil 和 [] 被当作不同的菜单
+func normalizeMenus(menus []*MenuItem) []*MenuItem {
+	if len(menus) == 0 {
+		return nil
+	}
+	result := make([]*MenuItem, 0, len(menus))
+	for _, item := range menus {
+		menu := *item
+		menu.SubButtons = normalizeMenus(item.SubButtons)
+		if menu.MediaID != nil && *menu.MediaID == "" {
+			menu.MediaID = nil
+		}
+		if menu.ArticleID != nil && *menu.ArticleID == "" {
+			menu.ArticleID = nil
+		}
+		result = append(result, &menu)
+	}
+	return result
+}
+
+// SyncResult 同步的结果
+type SyncResult struct {
+	DefaultUpdated bool     // 更新了默认菜单
+	DefaultDeleted bool     // 删除了所有菜单
+	Added          []string // 新建的个性化菜单的 menuid
+	Deleted        []string // 删除的个性化菜单的 menuid
+}
+
+// Sync 把线上的菜单同步为 desired, 只提交有变化的部分
+// 个性化菜单不能修改, 内容或者匹配规则变化时删除之后重新创建
+func (api *MenuApi) Sync(ctx context.Context, desired *MenuSet) (*SyncResult, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+	current, err := api.GetMenus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+	switch {
+	case len(desired.Default) == 0:
+		if len(current.Default) > 0 {
+			// 删除默认菜单会同时删除所有的个性化菜单
+			if err = api.DeleteMenu(ctx); err != nil {
+				return result, err
+			}
+			result.DefaultDeleted = true
+		}
+		return result, nil
+	case menuKey(desired.Default) != menuKey(current.Default):
+		if err = api.CreateMenu(ctx, desired.Default); err != nil {
+			return result, err
+		}
+		result.DefaultUpdated = true
+	}
+
+	// 先删除, 个性化菜单有数量限制
+	wanted := map[string]int{}
+	for _, menu := range desired.Conditional {
+		wanted[conditionalMenuKey(menu)]++
+	}
+	for _, menu := range current.Conditional {
+		key := conditionalMenuKey(menu)
+		if wanted[key] > 0 {
+			wanted[key]--
+			continue
+		}
+		if err = api.DeleteConditionalMenu(ctx, menu.MenuID); err != nil {
+			return result, err
+		}
+		result.Deleted = append(result.Deleted, menu.MenuID)
+	}
+
+	for _, menu := range desired.Conditional {
+		key := conditionalMenuKey(menu)
+		if wanted[key] == 0 {
+			continue
+		}
+		wanted[key]--
+		menuID, err := api.AddConditionalMenu(ctx, menu.Buttons, menu.MatchRule)
+		if err != nil {
+			return result, err
+		}
+		result.Added = append(result.Added, menuID)
+	}
+	return result, nil
+}
+
+// menuKey 规范化并序列化之后比较, 本地配置和接口返回的菜单都需要规范化
+func menuKey(menus []*MenuItem) string {
+	data, _ := json.Marshal(normalizeMenus(menus))
+	return string(data)
+}
+
+func conditionalMenuKey(menu *ConditionalMenu) string {
+	rule := MatchRule{}
+	if menu.MatchRule != nil {
+		rule = *menu.MatchRule
+	}
+	data, _ := json.Marshal(&rule)
+	return string(data) + menuKey(menu.Buttons)
+}
@@ -0,0 +1,205 @@
+package menu_api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/lixinio/weixin/weixin/official_account"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMenus(t *testing.T) {
+	mediaID := ""
+	for _, menus := range [][]*MenuItem{
+		{},
+		{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}},
+		{{Name: strings.Repeat("菜单", 3), Type: MenuTypeClick, Key: "key"}}, // 18 bytes
+		{{Name: "菜单", Type: MenuTypeClick}},
+		{{Name: "菜单", Type: MenuTypeView, Uri: strings.Repeat("a", 1025)}},
+		{{Name: "菜单", Type: MenuTypeMediaID, MediaID: &mediaID}},
+		{{Name: "菜单", Type: MenuTypeMiniprogram, Uri: "https://example.com", AppID: "appid"}},
+		{{Name: "菜单", Type: "unknown"}},
+		{{Name: "菜单", Type: MenuTypeClick, SubButtons: []*MenuItem{{Name: "子菜单", Type: MenuTypeClick, Key: "key"}}}},
+		{{Name: "菜单", SubButtons: []*MenuItem{{Name: "子菜单", SubButtons: []*MenuItem{{Name: "a"}}}}}},
+	} {
+		require.True(t, errors.Is(ValidateMenus(menus), ErrInvalidMenu), menus)
+	}
+
+	require.Equal(t, nil, ValidateMenus([]*MenuItem{
+		{Name: "官网", Type: MenuTypeView, Uri: "https://example.com"},
+		{Name: "更多", SubButtons: []*MenuItem{
+			{Name: "扫码", Type: MenuTypeScancodePush, Key: "scan"},
+			{Name: "小程序", Type: MenuTypeMiniprogram, Uri: "https://example.com", AppID: "appid", PagePath: "pages/index"},
+		}},
+	}))
+}
+
+const syncMenuYAML = `
+default:
+  - name: 官网
+    type: view
+    url: https://example.com
+conditional:
+  - matchrule:
+      tag_id: "1"
+    button:
+      - name: 会员
+        type: click
+        key: member
+  - matchrule:
+      tag_id: "3"
+    button:
+      - name: 新用户
+        type: click
+        key: new
+`
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiGetMenu, map[string]interface{}{
+		"menu": map[string]interface{}{
+			"button": []map[string]interface{}{
+				{"name": "官网", "type": "view", "url": "https://example.com", "sub_button": []string{}},
+			},
+		},
+		"conditionalmenu": []map[string]interface{}{{
+			"button":    []map[string]interface{}{{"name": "会员", "type": "click", "key": "member", "sub_button": []string{}}},
+			"matchrule": map[string]interface{}{"group_id": 1, "sex": "", "client_platform_type": ""},
+			"menuid":    208396938,
+		}, {
+			"button":    []map[string]interface{}{{"name": "老用户", "type": "click", "key": "old", "sub_button": []string{}}},
+			"matchrule": map[string]interface{}{"group_id": "2", "sex": "", "client_platform_type": ""},
+			"menuid":    208396993,
+		}},
+	})
+	server.HandleJSON(apiDelConditional, map[string]interface{}{})
+	server.HandleJSON(apiAddConditional, map[string]string{"menuid": "208397025"})
+
+	cache := memory.NewMemory()
+	oa := official_account.New(cache, cache, &official_account.Config{Appid: "appid", Secret: "secret"}, server.ClientOption())
+	api := NewApi(oa.Client)
+
+	desired, err := LoadMenuSet([]byte(syncMenuYAML))
+	require.Equal(t, nil, err)
+	result, err := api.Sync(ctx, desired)
+	require.Equal(t, nil, err)
+
+	// 默认菜单和 tag 1 没有变化
+	require.False(t, result.DefaultUpdated)
+	require.Equal(t, []string{"208396993"}, result.Deleted)
+	require.Equal(t, []string{"208397025"}, result.Added)
+	require.True(t, server.LastRequest(apiCreateMenu) == nil)
+
+	deleted := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiDelConditional).Unmarshal(&deleted))
+	require.Equal(t, "208396993", deleted["menuid"])
+
+	added := &ConditionalMenu{}
+	require.Equal(t, nil, server.LastRequest(apiAddConditional).Unmarshal(added))
+	require.Equal(t, "3", added.MatchRule.TagID)
+	require.Equal(t, "new", added.Buttons[0].Key)
+
+	// 拼错的字段
+	_, err = LoadMenuSet([]byte(`{"default": [{"name": "官网", "typ": "view"}]}`))
+	require.True(t, errors.Is(err, ErrInvalidMenu))
+}
+
+// 接口文档中的查询结果, 子菜单有空数组和 {"list": [...]} 两种格式
+const getMenuResponse = `{
+  "menu": {
+    "button": [
+      {"type": "click", "name": "今日歌曲", "key": "V1001_TODAY_MUSIC", "sub_button": []},
+      {"name": "菜单", "sub_button": [
+        {"type": "view", "name": "搜索", "url": "http://www.soso.com/", "sub_button": []},
+        {"type": "view", "name": "视频", "url": "http://v.qq.com/", "sub_button": []},
+        {"type": "click", "name": "赞一下我们", "key": "V1001_GOOD", "sub_button": []}
+      ]}
+    ],
+    "menuid": 208396938
+  },
+  "conditionalmenu": [{
+    "button": [
+      {"name": "素材", "sub_button": {"list": [
+        {"type": "img", "name": "图片", "value": "media_id_1"},
+        {"type": "news", "name": "图文", "value": "media_id_2", "news_info": {"list": [{"title": "标题"}]}}
+      ]}}
+    ],
+    "matchrule": {"group_id": 2, "sex": 1, "country": "中国", "province": "广东", "city": "广州", "client_platform_type": 2},
+    "menuid": 208396993
+  }]
+}`
+
+func TestSyncNormalize(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.Handle(apiGetMenu, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(getMenuResponse))
+	})
+	server.HandleJSON(apiCreateMenu, map[string]interface{}{})
+	server.HandleJSON(apiDelConditional, map[string]interface{}{})
+	server.HandleJSON(apiAddConditional, map[string]string{"menuid": "208397025"})
+
+	cache := memory.NewMemory()
+	oa := official_account.New(cache, cache, &official_account.Config{Appid: "appid", Secret: "secret"}, server.ClientOption())
+	api := NewApi(oa.Client)
+
+	current, err := api.GetMenus(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, 2, len(current.Default))
+	require.Equal(t, 0, len(current.Default[0].SubButtons))
+	require.Equal(t, 3, len(current.Default[1].SubButtons))
+	require.Equal(t, "2", current.Conditional[0].MatchRule.TagID)
+	require.Equal(t, "2", current.Conditional[0].MatchRule.ClientPlatformType)
+	buttons := current.Conditional[0].Buttons[0].SubButtons
+	require.Equal(t, 2, len(buttons))
+	require.Equal(t, MenuTypeMediaID, buttons[0].Type)
+	require.Equal(t, "media_id_1", *buttons[0].MediaID)
+	require.Equal(t, "news", buttons[1].Type) // 图文消息菜单接口无法创建
+
+	desired, err := LoadMenuSet([]byte(`
+default:
+  - name: 今日歌曲
+    type: click
+    key: V1001_TODAY_MUSIC
+    sub_button: []
+  - name: 菜单
+    sub_button:
+      - name: 搜索
+        type: view
+        url: http://www.soso.com/
+      - name: 视频
+        type: view
+        url: http://v.qq.com/
+      - name: 赞一下我们
+        type: click
+        key: V1001_GOOD
+conditional:
+  - matchrule:
+      tag_id: "2"
+      client_platform_type: "2"
+    button:
+      - name: 素材
+        sub_button:
+          - name: 图片
+            type: media_id
+            media_id: media_id_1
+`))
+	require.Equal(t, nil, err)
+	result, err := api.Sync(ctx, desired)
+	require.Equal(t, nil, err)
+
+	// 默认菜单内容一致, 个性化菜单包含图文消息菜单, 需要重新创建
+	require.False(t, result.DefaultUpdated)
+	require.True(t, server.LastRequest(apiCreateMenu) == nil)
+	require.Equal(t, []string{"208396993"}, result.Deleted)
+	require.Equal(t, []string{"208397025"}, result.Added)
+}
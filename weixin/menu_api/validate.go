@@ -0,0 +1,124 @@
+package menu_api
+
+// 提交之前在本地校验菜单, 避免一个菜单项的错误导致整个菜单创建失败(微信只返回 40xxx, 不指明是哪一项)
+// https://developers.weixin.qq.com/doc/offiaccount/Custom_Menus/Creating_Custom-Defined_Menu.html
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidMenu = errors.New("invalid menu")
+
+// 菜单类型
+const (
+	MenuTypeClick              = "click"
+	MenuTypeView               = "view"
+	MenuTypeScancodePush       = "scancode_push"
+	MenuTypeScancodeWaitmsg    = "scancode_waitmsg"
+	MenuTypePicSysphoto        = "pic_sysphoto"
+	MenuTypePicPhotoOrAlbum    = "pic_photo_or_album"
+	MenuTypePicWeixin          = "pic_weixin"
+	MenuTypeLocationSelect     = "location_select"
+	MenuTypeMediaID            = "media_id"
+	MenuTypeViewLimited        = "view_limited"
+	MenuTypeArticleID          = "article_id"
+	MenuTypeArticleViewLimited = "article_view_limited"
+	MenuTypeMiniprogram        = "miniprogram"
+)
+
+const (
+	maxButtons         = 3    // 一级菜单最多3个
+	maxSubButtons      = 5    // 二级菜单最多5个
+	maxButtonNameBytes = 16   // 一级菜单标题
+	maxSubNameBytes    = 60   // 二级菜单标题
+	maxKeyBytes        = 128  // key
+	maxUrlBytes        = 1024 // url
+)
+
+// ValidateMenus 校验一组菜单(默认菜单或者个性化菜单)
+func ValidateMenus(menus []*MenuItem) error {
+	if len(menus) == 0 || len(menus) > maxButtons {
+		return fmt.Errorf("%w: %d buttons, must be 1-%d", ErrInvalidMenu, len(menus), maxButtons)
+	}
+	for i, item := range menus {
+		if err := item.validate(maxButtonNameBytes, true); err != nil {
+			return fmt.Errorf("button[%d] %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (item *MenuItem) validate(maxNameBytes int, allowSub bool) error {
+	if item == nil {
+		return fmt.Errorf("%w: nil", ErrInvalidMenu)
+	}
+	if item.Name == "" || len(item.Name) > maxNameBytes {
+		return fmt.Errorf(
+			"%w: name(%s) must be 1-%d bytes", ErrInvalidMenu, item.Name, maxNameBytes,
+		)
+	}
+
+	// 有子菜单时, 只需要 name
+	if len(item.SubButtons) > 0 {
+		if !allowSub {
+			return fmt.Errorf("%w: %s, sub button can NOT have sub buttons", ErrInvalidMenu, item.Name)
+		}
+		if item.Type != "" {
+			return fmt.Errorf("%w: %s, button with sub buttons can NOT have type", ErrInvalidMenu, item.Name)
+		}
+		if len(item.SubButtons) > maxSubButtons {
+			return fmt.Errorf(
+				"%w: %s, %d sub buttons, at most %d",
+				ErrInvalidMenu, item.Name, len(item.SubButtons), maxSubButtons,
+			)
+		}
+		for i, sub := range item.SubButtons {
+			if err := sub.validate(maxSubNameBytes, false); err != nil {
+				return fmt.Errorf("sub_button[%d] %w", i, err)
+			}
+		}
+		return nil
+	}
+
+	switch item.Type {
+	case MenuTypeClick, MenuTypeScancodePush, MenuTypeScancodeWaitmsg,
+		MenuTypePicSysphoto, MenuTypePicPhotoOrAlbum, MenuTypePicWeixin, MenuTypeLocationSelect:
+		return checkField(item, "key", item.Key, maxKeyBytes)
+	case MenuTypeView:
+		return checkField(item, "url", item.Uri, maxUrlBytes)
+	case MenuTypeMediaID, MenuTypeViewLimited:
+		if item.MediaID == nil {
+			return checkField(item, "media_id", "", 0)
+		}
+		return checkField(item, "media_id", *item.MediaID, 0)
+	case MenuTypeArticleID, MenuTypeArticleViewLimited:
+		if item.ArticleID == nil {
+			return checkField(item, "article_id", "", 0)
+		}
+		return checkField(item, "article_id", *item.ArticleID, 0)
+	case MenuTypeMiniprogram:
+		// 不支持小程序的老版本客户端将打开 url
+		if err := checkField(item, "url", item.Uri, maxUrlBytes); err != nil {
+			return err
+		}
+		if err := checkField(item, "appid", item.AppID, 0); err != nil {
+			return err
+		}
+		return checkField(item, "pagepath", item.PagePath, 0)
+	case "":
+		return fmt.Errorf("%w: %s, type is required", ErrInvalidMenu, item.Name)
+	}
+	return fmt.Errorf("%w: %s, unknown type %s", ErrInvalidMenu, item.Name, item.Type)
+}
+
+// checkField 必填, maxBytes 为 0 时不限制长度
+func checkField(item *MenuItem, field, value string, maxBytes int) error {
+	if value == "" {
+		return fmt.Errorf("%w: %s, %s is required for %s", ErrInvalidMenu, item.Name, field, item.Type)
+	}
+	if maxBytes > 0 && len(value) > maxBytes {
+		return fmt.Errorf("%w: %s, %s must be at most %d bytes", ErrInvalidMenu, item.Name, field, maxBytes)
+	}
+	return nil
+}
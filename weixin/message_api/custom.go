@@ -1,8 +1,13 @@
 package message_api
 
 // 客服消息
+// 客服消息没有防重入id, 发送失败不重试
 
-import "context"
+import (
+	"context"
+
+	"github.com/lixinio/weixin/utils"
+)
 
 const (
 	apiCustomSend = "/cgi-bin/message/custom/send"
@@ -27,7 +32,7 @@ https://developers.weixin.qq.com/doc/offiaccount/Message_Management/Service_Cent
 func (api *MessageApi) SendCustomTextMessage(
 	ctx context.Context, openID, content string,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &TextMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &TextMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "text",
@@ -54,7 +59,7 @@ type ImageMessage struct {
 func (api *MessageApi) SendCustomImageMessage(
 	ctx context.Context, openID, mediaID string,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &ImageMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &ImageMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "image",
@@ -81,7 +86,7 @@ type VoiceMessage struct {
 func (api *MessageApi) SendCustomVoiceMessage(
 	ctx context.Context, openID, mediaID string,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &VoiceMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &VoiceMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "voice",
@@ -113,7 +118,7 @@ type VideoMessage struct {
 func (api *MessageApi) SendCustomVideoMessage(
 	ctx context.Context, openID string, video *Video,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &VideoMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &VideoMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "video",
@@ -142,7 +147,7 @@ type MusicMessage struct {
 func (api *MessageApi) SendCustomMusicMessage(
 	ctx context.Context, openID string, music *Music,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &MusicMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &MusicMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "music",
@@ -172,7 +177,7 @@ type NewsMessage struct {
 func (api *MessageApi) SendCustomNewsMessage(
 	ctx context.Context, openID string, articles []*Article,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &NewsMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &NewsMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "news",
@@ -199,7 +204,7 @@ type MpnewsMessage struct {
 func (api *MessageApi) SendCustomMpnewsMessage(
 	ctx context.Context, openID, mediaID string,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &MpnewsMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &MpnewsMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "mpnews",
@@ -226,7 +231,7 @@ type MpnewsArticleMessage struct {
 func (api *MessageApi) SendCustomMpnewsArticleMessage(
 	ctx context.Context, openID, articleID string,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &MpnewsArticleMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &MpnewsArticleMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "mpnewsarticle",
@@ -260,7 +265,7 @@ type MenuMessage struct {
 func (api *MessageApi) SendCustomMenuMessage(
 	ctx context.Context, openID, head, tail string, menus []*Menu,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &MenuMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &MenuMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "msgmenu",
@@ -291,7 +296,7 @@ type WxcardMessage struct {
 func (api *MessageApi) SendCustomWxcardMessage(
 	ctx context.Context, openID, cardID string,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &WxcardMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &WxcardMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "wxcard",
@@ -324,7 +329,7 @@ type MiniProgrampageMessage struct {
 func (api *MessageApi) SendCustomMiniProgramMessage(
 	ctx context.Context, openID string, mp *MiniProgram,
 ) error {
-	return api.Client.HTTPPostJson(ctx, apiCustomSend, &MiniProgrampageMessage{
+	return api.Client.HTTPPostJson(utils.NewNoRetryContext(ctx), apiCustomSend, &MiniProgrampageMessage{
 		MessageHeader: &MessageHeader{
 			ToUser:  openID,
 			MsgType: "miniprogrampage",
@@ -3,15 +3,29 @@ package message_api
 import (
 	"context"
 	"testing"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCustomerMessage(t *testing.T) {
 	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiCustomSend, &utils.WeixinError{})
+
 	for _, client := range []*messageItem{
-		initOfficialAccount(),
-		initAuthorizer(),
+		initOfficialAccount(t, server),
 	} {
 		messageApi := NewApi(client.Client)
-		messageApi.SendCustomTextMessage(ctx, client.OpenID, "发多了开发")
+		err := messageApi.SendCustomTextMessage(ctx, client.OpenID, "发多了开发")
+		require.Equal(t, nil, err)
+
+		msg := &TextMessage{}
+		require.Equal(t, nil, server.LastRequest(apiCustomSend).Unmarshal(msg))
+		require.Equal(t, client.OpenID, msg.ToUser)
+		require.Equal(t, "text", msg.MsgType)
+		require.Equal(t, "发多了开发", msg.Text.Content)
 	}
 }
@@ -61,7 +61,7 @@ func (api *MessageApi) SendMassTextMessage(
 ) (int64, int64, error) {
 	resp := &SendMassMessageResult{}
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewDedupeContext(ctx, clientmsgid), // clientmsgid 防重入
 		apiSendMassMessageByTag,
 		buildSendMassMessageReq(sendAll, tagID, clientmsgid, "text", map[string]any{
 			"content": content,
@@ -81,7 +81,7 @@ func (api *MessageApi) SendMassVoiceMessage(
 ) (int64, int64, error) {
 	resp := &SendMassMessageResult{}
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewDedupeContext(ctx, clientmsgid), // clientmsgid 防重入
 		apiSendMassMessageByTag,
 		buildSendMassMessageReq(sendAll, tagID, clientmsgid, "voice", map[string]any{
 			"media_id": mediaID,
@@ -109,7 +109,7 @@ func (api *MessageApi) SendMassImageMessage(
 ) (int64, int64, error) {
 	resp := &SendMassMessageResult{}
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewDedupeContext(ctx, clientmsgid), // clientmsgid 防重入
 		apiSendMassMessageByTag,
 		buildSendMassMessageReq(sendAll, tagID, clientmsgid, "image", req, "images"),
 		resp,
@@ -127,7 +127,7 @@ func (api *MessageApi) SendMassVideoMessage(
 ) (int64, int64, error) {
 	resp := &SendMassMessageResult{}
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewDedupeContext(ctx, clientmsgid), // clientmsgid 防重入
 		apiSendMassMessageByTag,
 		buildSendMassMessageReq(sendAll, tagID, clientmsgid, "mpvideo", map[string]any{
 			"media_id": mediaID,
@@ -147,7 +147,7 @@ func (api *MessageApi) SendMassCardMessage(
 ) (int64, int64, error) {
 	resp := &SendMassMessageResult{}
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewDedupeContext(ctx, clientmsgid), // clientmsgid 防重入
 		apiSendMassMessageByTag,
 		buildSendMassMessageReq(sendAll, tagID, clientmsgid, "wxcard", map[string]any{
 			"card_id": cardID,
@@ -167,7 +167,7 @@ func (api *MessageApi) SendMassNewsMessage(
 ) (int64, int64, error) {
 	resp := &SendMassMessageResult{}
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewDedupeContext(ctx, clientmsgid), // clientmsgid 防重入
 		apiSendMassMessageByTag,
 
 		buildSendMassMessageReq(sendAll, tagID, clientmsgid, "mpnews", map[string]any{
@@ -207,7 +207,7 @@ func (api *MessageApi) PreviewMassTextMessage(
 	content string,
 ) error {
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewNoRetryContext(ctx),
 		apiPreviewMassMessage,
 		buildPreviewMassMessageReq(touser, towxname, "text", map[string]any{
 			"content": content,
@@ -226,7 +226,7 @@ func (api *MessageApi) PreviewMassVoiceMessage(
 	mediaID string,
 ) error {
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewNoRetryContext(ctx),
 		apiPreviewMassMessage,
 		buildPreviewMassMessageReq(touser, towxname, "voice", map[string]any{
 			"media_id": mediaID,
@@ -245,7 +245,7 @@ func (api *MessageApi) PreviewMassImageMessage(
 	mediaID string,
 ) error {
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewNoRetryContext(ctx),
 		apiPreviewMassMessage,
 		buildPreviewMassMessageReq(touser, towxname, "image", map[string]any{
 			"media_id": mediaID,
@@ -264,7 +264,7 @@ func (api *MessageApi) PreviewMassVideoMessage(
 	mediaID string,
 ) error {
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewNoRetryContext(ctx),
 		apiPreviewMassMessage,
 		buildPreviewMassMessageReq(touser, towxname, "mpvideo", map[string]any{
 			"media_id": mediaID,
@@ -283,7 +283,7 @@ func (api *MessageApi) PreviewMassCardMessage(
 	cardID string,
 ) error {
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewNoRetryContext(ctx),
 		apiPreviewMassMessage,
 		buildPreviewMassMessageReq(touser, towxname, "wxcard", map[string]any{
 			"card_id": cardID,
@@ -302,7 +302,7 @@ func (api *MessageApi) PreviewMassNewsMessage(
 	mediaID string,
 ) error {
 	if err := api.Client.HTTPPostJson(
-		ctx,
+		utils.NewNoRetryContext(ctx),
 		apiPreviewMassMessage,
 
 		buildPreviewMassMessageReq(touser, towxname, "mpnews", map[string]any{
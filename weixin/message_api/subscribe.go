@@ -57,7 +57,7 @@ func (api *MessageApi) SendSubscribeMpMessage(
 	}
 
 	if err := api.Client.HTTPPostJson(
-		ctx, apiSubscribeMsgSend, req, nil,
+		utils.NewNoRetryContext(ctx), apiSubscribeMsgSend, req, nil,
 	); err != nil {
 		return err
 	}
@@ -86,7 +86,7 @@ func (api *MessageApi) SendSubscribeOaMessage(
 	}
 
 	if err := api.Client.HTTPPostJson(
-		ctx, apiSubscribeMsgBizSend, req, nil,
+		utils.NewNoRetryContext(ctx), apiSubscribeMsgBizSend, req, nil,
 	); err != nil {
 		return err
 	}
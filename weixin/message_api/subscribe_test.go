@@ -2,108 +2,109 @@ package message_api
 
 import (
 	"context"
-	"fmt"
 	"strconv"
 	"strings"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
-	"github.com/lixinio/weixin/weixin/official_account"
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
-func initWxa() *messageItem {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	wxa := official_account.New(redis, redis, &official_account.Config{
-		Appid:  test.WxaAppid,
-		Secret: test.WxaSecret,
-	})
-
-	return &messageItem{
-		OpenID: test.OfficialAccountOpenid,
-		Client: wxa.Client,
-	}
+func initWxa(t *testing.T, server *mockserver.Server) *messageItem {
+	return initOfficialAccount(t, server)
 }
 
 /*
 125 停车服务
 884 维修保养
-892 汽车经销商/4S店
-894 汽车厂商
-1129
 131 车辆出场通知 3 125
-0
 1 车牌号 粤A12345
 2 停车场 广州白云万达
-4 停车费用 20元
-5 提示说明 您的车已驶出停车场
-6 停车时间 2015/1/1 12:00:00~2015/1/1 15:00:00
 */
 func TestSubscribe(t *testing.T) {
 	ctx := context.Background()
-	for _, client := range []*messageItem{
-		initWxa(),
-	} {
-		messageApi := NewApi(client.Client)
-		categories, err := messageApi.SubscribeGetCategory(ctx)
-		require.Equal(t, nil, err)
-
-		categoryIDs := []string{}
-		for _, category := range categories {
-			fmt.Println(category.ID, category.Name)
-			categoryIDs = append(categoryIDs, strconv.Itoa(category.ID))
-		}
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiSubscribeGetCategory, map[string]interface{}{
+		"data": []map[string]interface{}{
+			{"id": 125, "name": "停车服务"},
+			{"id": 884, "name": "维修保养"},
+		},
+	})
+	server.HandleJSON(apiSubscribeGetPubTemplateTitles, map[string]interface{}{
+		"count": 1,
+		"data": []map[string]interface{}{
+			{"tid": 131, "title": "车辆出场通知", "type": 3, "categoryId": "125"},
+		},
+	})
+	server.HandleJSON(apiSubscribeGetPubTemplateKeywords, map[string]interface{}{
+		"count": 2,
+		"data": []map[string]interface{}{
+			{"kid": 1, "name": "车牌号", "example": "粤A12345", "rule": "car_number"},
+			{"kid": 2, "name": "停车场", "example": "广州白云万达", "rule": "thing"},
+		},
+	})
 
-		subscribePubTemplate, total, err := messageApi.SubscribeGetPubTemplateTitles(
-			ctx, strings.Join(categoryIDs, ","), 1, 30)
-		require.Equal(t, nil, err)
-		fmt.Println(total)
-		for _, tmpl := range subscribePubTemplate {
-			fmt.Println(tmpl.Tid, tmpl.Title, tmpl.Type, tmpl.CategoryID)
-		}
+	messageApi := NewApi(initWxa(t, server).Client)
+	categories, err := messageApi.SubscribeGetCategory(ctx)
+	require.Equal(t, nil, err)
 
-		keywords, total, err := messageApi.SubscribeGetPubTemplateKeywords(
-			ctx, subscribePubTemplate[0].Tid,
-		)
-		require.Equal(t, nil, err)
-		fmt.Println(total)
-		for _, keyword := range keywords {
-			fmt.Println(keyword.Kid, keyword.Name, keyword.Example)
-		}
+	categoryIDs := []string{}
+	for _, category := range categories {
+		categoryIDs = append(categoryIDs, strconv.Itoa(category.ID))
 	}
+
+	subscribePubTemplate, total, err := messageApi.SubscribeGetPubTemplateTitles(
+		ctx, strings.Join(categoryIDs, ","), 1, 30)
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, total)
+	query := server.LastRequest(apiSubscribeGetPubTemplateTitles).Query
+	require.Equal(t, "125,884", query.Get("ids"))
+	require.Equal(t, "30", query.Get("limit"))
+
+	keywords, total, err := messageApi.SubscribeGetPubTemplateKeywords(
+		ctx, subscribePubTemplate[0].Tid,
+	)
+	require.Equal(t, nil, err)
+	require.Equal(t, 2, total)
+	require.Equal(t, "车牌号", keywords[0].Name)
+	require.Equal(t, "131", server.LastRequest(apiSubscribeGetPubTemplateKeywords).Query.Get("tid"))
 }
 
 /*
 690 洗车提醒 2 884
 
 1 车牌号 京TIN68
-2 洗车指数 较适宜洗车
 3 服务项目 蜡洗
-4 客服热线 4008822339
-5 洗车地址 软件产业基地5D2栋
 6 温馨提示 设备即将为您进行洗车，请注意安全
-7 洗车时间 2020-07-21 15:20
 */
 func TestSubscribeTemplate(t *testing.T) {
 	ctx := context.Background()
-	for _, client := range []*messageItem{
-		initWxa(),
-	} {
-		messageApi := NewApi(client.Client)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiSubscribeGetTemplate, map[string]interface{}{
+		"data": []map[string]interface{}{
+			{"priTmplId": "pri_tmpl_1", "title": "洗车提醒", "type": 2},
+		},
+	})
+	server.HandleJSON(apiSubscribeAddTemplate, map[string]string{"priTmplId": "pri_tmpl_2"})
+	server.HandleJSON(apiSubscribeDelTemplate, &utils.WeixinError{})
 
-		tmplates, err := messageApi.SubscribeGetTemplate(ctx)
-		require.Equal(t, nil, err)
+	messageApi := NewApi(initWxa(t, server).Client)
+	tmplates, err := messageApi.SubscribeGetTemplate(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, len(tmplates))
+	require.Equal(t, "洗车提醒", tmplates[0].Title)
 
-		for _, tmpl := range tmplates {
-			fmt.Println(tmpl.PriTmplID, tmpl.Type, tmpl.Title, tmpl.Content, tmpl.Example)
-		}
+	priTmplID, err := messageApi.SubscribeAddTemplate(ctx, 690, []int{1, 3, 6}, "提醒车主洗车")
+	require.Equal(t, nil, err)
+	require.Equal(t, "pri_tmpl_2", priTmplID)
 
-		priTmplID, err := messageApi.SubscribeAddTemplate(ctx, 690, []int{1, 3, 6}, "提醒车主洗车")
-		require.Equal(t, nil, err)
-		fmt.Println(priTmplID)
+	payload := map[string]interface{}{}
+	require.Equal(t, nil, server.LastRequest(apiSubscribeAddTemplate).Unmarshal(&payload))
+	require.Equal(t, []interface{}{float64(1), float64(3), float64(6)}, payload["kidList"])
 
-		err = messageApi.SubscribeDelTemplate(ctx, priTmplID)
-		require.Equal(t, nil, err)
-	}
+	err = messageApi.SubscribeDelTemplate(ctx, priTmplID)
+	require.Equal(t, nil, err)
 }
@@ -53,7 +53,9 @@ func (api *MessageApi) SendTemplateMessage(
 		utils.WeixinError
 		MsgID int64 `json:"msgid"`
 	}{}
-	if err := api.Client.HTTPPostJson(ctx, apiTemplateSend, msg, resp); err != nil {
+	if err := api.Client.HTTPPostJson(
+		utils.NewDedupeContext(ctx, msg.ClientMsgID), apiTemplateSend, msg, resp,
+	); err != nil {
 		return 0, err
 	}
 	return resp.MsgID, nil
@@ -2,13 +2,11 @@ package message_api
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
-	"github.com/lixinio/weixin/weixin/authorizer"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/weixin/official_account"
 	"github.com/stretchr/testify/require"
 )
@@ -18,31 +16,18 @@ type messageItem struct {
 	Client *utils.Client
 }
 
-func initOfficialAccount() *messageItem {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	officialAccount := official_account.New(redis, redis, &official_account.Config{
-		Appid:  test.OfficialAccountAppid,
-		Secret: test.OfficialAccountSecret,
-	})
+func initOfficialAccount(t *testing.T, server *mockserver.Server) *messageItem {
+	cache := memory.NewMemory()
+	officialAccount := official_account.New(cache, cache, &official_account.Config{
+		Appid:  "wxofficialaccount",
+		Secret: "secret",
+	}, server.ClientOption())
 	return &messageItem{
-		OpenID: test.OfficialAccountOpenid,
+		OpenID: "oa_openid",
 		Client: officialAccount.Client,
 	}
 }
 
-func initAuthorizer() *messageItem {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	wxopenOA := authorizer.NewLite(
-		redis, redis,
-		test.WxOpenAppid,
-		test.WxOpenOAAppid,
-	)
-	return &messageItem{
-		OpenID: test.WxOpenOAOpenID,
-		Client: wxopenOA.Client,
-	}
-}
-
 /*
 测试号模板
 {{title.DATA}}
@@ -54,9 +39,13 @@ func initAuthorizer() *messageItem {
 */
 func TestTemplateMessage(t *testing.T) {
 	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiTemplateSend, map[string]interface{}{"msgid": 200228332})
+
+	// 授权方(weixin/authorizer)依赖 wxopen, wxopen 依赖 message_api, 测试只使用公众号
 	for _, client := range []*messageItem{
-		initOfficialAccount(),
-		initAuthorizer(),
+		initOfficialAccount(t, server),
 	} {
 		messageApi := NewApi(client.Client)
 		id, err := messageApi.SendTemplateMessage(ctx, &TemplateMessage{
@@ -69,59 +58,68 @@ func TestTemplateMessage(t *testing.T) {
 				"msg1": {
 					Value: "标题msg1",
 				},
-				"msg2": {
-					Value: "标题msg2",
-				},
-				"msg3": {
-					Value: "标题msg3",
-				},
-				"msg4": {
-					Value: "标题msg4",
-				},
-				"msg5": {
-					Value: "标题msg5",
-				},
 			},
 		})
 		require.Equal(t, nil, err)
-		fmt.Println(id)
+		require.Equal(t, int64(200228332), id)
+
+		msg := &TemplateMessage{}
+		require.Equal(t, nil, server.LastRequest(apiTemplateSend).Unmarshal(msg))
+		require.Equal(t, client.OpenID, msg.ToUser)
+		require.Equal(t, "标题msg1", msg.Datas["msg1"].Value)
 	}
 }
 
-// 支持 测试号 https://mp.weixin.qq.com/debug/cgi-bin/sandboxinfo
 func TestTemplateIndustry(t *testing.T) {
 	ctx := context.Background()
-	for _, client := range []*messageItem{
-		initOfficialAccount(),
-	} {
-		messageApi := NewApi(client.Client)
-		err := messageApi.SetIndustry(ctx, "1", "2")
-		require.Equal(t, nil, err)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiSetIndustry, &utils.WeixinError{})
+	server.HandleJSON(apiGetIndustry, map[string]interface{}{
+		"primary_industry":   map[string]string{"first_class": "IT科技", "second_class": "互联网/电子商务"},
+		"secondary_industry": map[string]string{"first_class": "IT科技", "second_class": "IT软件与服务"},
+	})
 
-		industryInfo, err := messageApi.GetIndustry(ctx)
-		require.Equal(t, nil, err)
-		fmt.Println(industryInfo)
-	}
+	messageApi := NewApi(initOfficialAccount(t, server).Client)
+	err := messageApi.SetIndustry(ctx, "1", "2")
+	require.Equal(t, nil, err)
+
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiSetIndustry).Unmarshal(&payload))
+	require.Equal(t, map[string]string{"industry_id1": "1", "industry_id2": "2"}, payload)
+
+	industryInfo, err := messageApi.GetIndustry(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "互联网/电子商务", industryInfo.PrimaryIndustry.SecondClass)
+	require.Equal(t, "IT软件与服务", industryInfo.SecondaryIndustry.SecondClass)
 }
 
-// 支持 测试号 https://mp.weixin.qq.com/debug/cgi-bin/sandboxinfo
 func TestPrivateTemplate(t *testing.T) {
 	ctx := context.Background()
-	for _, client := range []*messageItem{
-		initOfficialAccount(),
-	} {
-		messageApi := NewApi(client.Client)
-		templateID, err := messageApi.AddTemplate(ctx, "TM00210")
-		require.Equal(t, nil, err)
-		fmt.Println(templateID)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiAddTemplate, map[string]string{"template_id": "template_1"})
+	server.HandleJSON(apiGetAllPrivateTemplate, map[string]interface{}{
+		"template_list": []map[string]string{
+			{"template_id": "template_1", "title": "领取奖金提醒"},
+		},
+	})
+	server.HandleJSON(apiDelPrivateTemplate, &utils.WeixinError{})
 
-		privateTemplates, err := messageApi.GetAllPrivateTemplate(ctx)
-		require.Equal(t, nil, err)
-		fmt.Println(privateTemplates)
+	messageApi := NewApi(initOfficialAccount(t, server).Client)
+	templateID, err := messageApi.AddTemplate(ctx, "TM00210")
+	require.Equal(t, nil, err)
+	require.Equal(t, "template_1", templateID)
+
+	privateTemplates, err := messageApi.GetAllPrivateTemplate(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, len(privateTemplates))
 
-		for _, privateTemplate := range privateTemplates {
-			err = messageApi.DelPrivateTemplate(ctx, privateTemplate.TemplateID)
-			require.Equal(t, nil, err)
-		}
+	for _, privateTemplate := range privateTemplates {
+		err = messageApi.DelPrivateTemplate(ctx, privateTemplate.TemplateID)
+		require.Equal(t, nil, err)
 	}
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiDelPrivateTemplate).Unmarshal(&payload))
+	require.Equal(t, "template_1", payload["template_id"])
 }
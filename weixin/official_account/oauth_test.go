@@ -2,29 +2,45 @@ package official_account
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"net/http"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTicket(t *testing.T) {
 	ctx := context.TODO()
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	officialAccount := New(redis, redis, &Config{
-		Appid:  test.OfficialAccountAppid,
-		Secret: test.OfficialAccountSecret,
+	server := mockserver.New(mockserver.OptWithApp("appid", "secret"))
+	defer server.Close()
+	server.Handle(apiGetJSApiTicket, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"ticket": "ticket_" + r.URL.Query().Get("type"), "expires_in": 7200,
+		})
 	})
-	officialAccount.EnableJSApiTicketCache(redis, redis)
-	officialAccount.EnableWxCardTicketCache(redis, redis)
+
+	mem := memory.NewMemory()
+	officialAccount := New(mem, mem, &Config{
+		Appid:  "appid",
+		Secret: "secret",
+	}, server.ClientOption())
+	officialAccount.EnableJSApiTicketCache(mem, mem)
+	officialAccount.EnableWxCardTicketCache(mem, mem)
 
 	ticket, err := officialAccount.GetJSApiTicket(ctx)
 	require.Equal(t, nil, err)
-	fmt.Println(ticket)
+	require.Equal(t, "ticket_jsapi", ticket)
 
 	ticket, err = officialAccount.GetWxCardApiTicket(ctx)
 	require.Equal(t, nil, err)
-	fmt.Println(ticket)
+	require.Equal(t, "ticket_wx_card", ticket)
+
+	// 已缓存, 不会再请求
+	count := len(server.Requests())
+	ticket, err = officialAccount.GetJSApiTicket(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "ticket_jsapi", ticket)
+	require.Equal(t, count, len(server.Requests()))
 }
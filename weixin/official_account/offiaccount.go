@@ -24,29 +24,37 @@ var (
 type Config struct {
 	Appid  string
 	Secret string
+	// 使用稳定版接口(/cgi-bin/stable_token)获取token, 多个独立的服务共用一个appid时不会互相刷掉token
+	StableToken bool
 }
 
 type OfficialAccount struct {
 	Config            *Config
 	Client            *utils.Client
+	accessTokenCache  *utils.AccessTokenCache // lite 模式为nil
 	jsApiTicketCache  *utils.AccessTokenCache
 	wxCardTicketCache *utils.AccessTokenCache
 }
 
-func New(cache utils.Cache, locker utils.Lock, config *Config) *OfficialAccount {
+func New(
+	cache utils.Cache, locker utils.Lock, config *Config, options ...utils.ClientOption,
+) *OfficialAccount {
+	options = utils.ClientOptionsWithAppID(config.Appid, options)
 	instance := &OfficialAccount{
 		Config: config,
 	}
-	instance.Client = utils.NewClient(
-		WXServerUrl, utils.NewAccessTokenCache(
-			newAdapter(config.Appid, instance.refreshAccessTokenFromWXServer),
-			cache, locker,
-		),
+	instance.accessTokenCache = utils.NewAccessTokenCache(
+		newAdapter(config.Appid, instance.refreshAccessTokenFromWXServer),
+		cache, locker,
 	)
+	instance.Client = utils.NewClient(WXServerUrl, instance.accessTokenCache, options...)
 	return instance
 }
 
-func NewLite(cache utils.Cache, locker utils.Lock, appid string) *OfficialAccount {
+func NewLite(
+	cache utils.Cache, locker utils.Lock, appid string, options ...utils.ClientOption,
+) *OfficialAccount {
+	options = utils.ClientOptionsWithAppID(appid, options)
 	client := utils.NewClient(
 		WXServerUrl, utils.NewAccessTokenCache(
 			newAdapter(appid, func(ctx context.Context) (string, int, error) {
@@ -57,6 +65,7 @@ func NewLite(cache utils.Cache, locker utils.Lock, appid string) *OfficialAccoun
 			}),
 			cache, locker,
 		),
+		options...,
 	)
 	return &OfficialAccount{
 		Client: client,
@@ -2,6 +2,7 @@ package official_account
 
 import (
 	"context"
+	"fmt"
 	"net/url"
 
 	"github.com/lixinio/weixin/utils"
@@ -15,6 +16,10 @@ See: https://github.com/fastwego/offiaccount/blob/master/client.go#L268
 func (officialAccount *OfficialAccount) refreshAccessTokenFromWXServer(
 	ctx context.Context,
 ) (accessToken string, expiresIn int, err error) {
+	if officialAccount.Config.StableToken {
+		return officialAccount.getStableAccessToken(ctx, false)
+	}
+
 	var result utils.TokenResponse
 	if err := officialAccount.Client.HTTPGetToken(
 		utils.NewStripContext(ctx, "secret"),
@@ -30,3 +35,51 @@ func (officialAccount *OfficialAccount) refreshAccessTokenFromWXServer(
 	}
 	return result.AccessToken, result.ExpiresIn, nil
 }
+
+/*
+获取稳定版接口调用凭据
+普通模式下, 有效期内重复调用返回相同的token, 不会让其他服务正在使用的token失效
+强制刷新模式(force_refresh)会让之前的token失效, 每天限用20次
+See: https://developers.weixin.qq.com/doc/offiaccount/Basic_Information/getStableAccessToken.html
+*/
+func (officialAccount *OfficialAccount) getStableAccessToken(
+	ctx context.Context, forceRefresh bool,
+) (accessToken string, expiresIn int, err error) {
+	var result utils.TokenResponse
+	if err := officialAccount.Client.HTTPPostToken(
+		ctx,
+		"/cgi-bin/stable_token",
+		map[string]interface{}{
+			"grant_type":    "client_credential",
+			"appid":         officialAccount.Config.Appid,
+			"secret":        officialAccount.Config.Secret,
+			"force_refresh": forceRefresh,
+		},
+		&result,
+	); err != nil {
+		return "", 0, err
+	}
+	return result.AccessToken, result.ExpiresIn, nil
+}
+
+// ForceRefresh 立即从微信服务器获取新的token并更新缓存, 之前的token会失效
+// 稳定版模式使用 force_refresh, 普通模式调用 /cgi-bin/token
+func (officialAccount *OfficialAccount) ForceRefresh(ctx context.Context) (string, error) {
+	if officialAccount.accessTokenCache == nil {
+		return "", fmt.Errorf(
+			"can NOT force refresh token in lite mod, appid(%s), %w",
+			officialAccount.Config.Appid, ErrTokenUpdateForbidden,
+		)
+	}
+
+	// 和定时刷新/并发获取一样在锁内从服务器获取, 避免新token被别人刷新的token覆盖
+	return officialAccount.accessTokenCache.ForceRefreshAccessToken(
+		ctx,
+		func(ctx context.Context) (string, int, error) {
+			if officialAccount.Config.StableToken {
+				return officialAccount.getStableAccessToken(ctx, true)
+			}
+			return officialAccount.refreshAccessTokenFromWXServer(ctx)
+		},
+	)
+}
@@ -0,0 +1,69 @@
+package official_account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestStableToken(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.NewMemory()
+
+	requests := []map[string]interface{}{}
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/cgi-bin/stable_token" || req.Method != http.MethodPost {
+			return nil, fmt.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		body := map[string]interface{}{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		requests = append(requests, body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body: io.NopCloser(strings.NewReader(fmt.Sprintf(
+				`{"access_token":"token-%d","expires_in":7200}`, len(requests),
+			))),
+		}, nil
+	})
+
+	oa := New(mem, mem, &Config{
+		Appid: "appid", Secret: "secret", StableToken: true,
+	}, utils.ClientOptWithTransport(transport))
+
+	token, err := oa.accessTokenCache.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-1", token)
+	require.Equal(t, "appid", requests[0]["appid"])
+	require.Equal(t, "secret", requests[0]["secret"])
+	require.Equal(t, false, requests[0]["force_refresh"])
+
+	token, err = oa.ForceRefresh(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-2", token)
+	require.Equal(t, true, requests[1]["force_refresh"])
+
+	token, err = oa.accessTokenCache.GetAccessToken(ctx)
+	require.Equal(t, nil, err)
+	require.Equal(t, "token-2", token)
+
+	_, err = NewLite(mem, mem, "appid").ForceRefresh(ctx)
+	require.True(t, errors.Is(err, ErrTokenUpdateForbidden))
+}
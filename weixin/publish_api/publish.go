@@ -0,0 +1,143 @@
+package publish_api
+
+// 发布能力, 发布草稿箱中的图文
+// 发布结果通过 server_api.EventTypePublishJobFinish 事件推送, 通过 publish_id 关联
+// https://developers.weixin.qq.com/doc/offiaccount/Publish/Publish.html
+
+import (
+	"context"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/weixin/material_api"
+)
+
+const (
+	apiSubmit     = "/cgi-bin/freepublish/submit"     // 发布接口
+	apiGet        = "/cgi-bin/freepublish/get"        // 发布状态轮询接口
+	apiDelete     = "/cgi-bin/freepublish/delete"     // 删除发布
+	apiGetArticle = "/cgi-bin/freepublish/getarticle" // 通过 article_id 获取已发布文章
+	apiBatchGet   = "/cgi-bin/freepublish/batchget"   // 获取成功发布列表
+)
+
+// 发布状态, 发布完成事件(server_api.EventTypePublishJobFinish)也使用这里的定义
+const (
+	PublishStatusSuccess     = 0 // 成功
+	PublishStatusPublishing  = 1 // 发布中
+	PublishStatusOriginalErr = 2 // 原创失败
+	PublishStatusFailed      = 3 // 常规失败
+	PublishStatusAuditFailed = 4 // 平台审核不通过
+	PublishStatusDeleted     = 5 // 成功后用户删除所有文章
+	PublishStatusBanned      = 6 // 成功后系统封禁所有文章
+)
+
+type PublishApi struct {
+	*utils.Client
+}
+
+func NewApi(client *utils.Client) *PublishApi {
+	return &PublishApi{Client: client}
+}
+
+type SubmitResult struct {
+	utils.WeixinError
+	PublishID string `json:"publish_id"`  // 发布任务的id
+	MsgDataID int64  `json:"msg_data_id"` // 消息的数据ID
+}
+
+/*
+发布草稿, 提交成功只表示发布任务提交成功, 结果通过事件推送或者 Get 轮询
+https://developers.weixin.qq.com/doc/offiaccount/Publish/Publish.html
+*/
+func (api *PublishApi) Submit(ctx context.Context, mediaID string) (*SubmitResult, error) {
+	resp := &SubmitResult{}
+	if err := api.Client.HTTPPostJson(ctx, apiSubmit, map[string]string{
+		"media_id": mediaID,
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type PublishStatus struct {
+	utils.WeixinError
+	PublishID     string `json:"publish_id"`
+	PublishStatus int    `json:"publish_status"` // PublishStatusXXX
+	ArticleID     string `json:"article_id"`     // 成功时, 图文的 article_id
+	ArticleDetail struct {
+		Count int `json:"count"`
+		Items []struct {
+			Idx        int    `json:"idx"`
+			ArticleUrl string `json:"article_url"`
+		} `json:"item"`
+	} `json:"article_detail"`
+	FailIdx []int `json:"fail_idx"` // 原创失败和审核不通过的文章序号
+}
+
+/*
+发布状态轮询
+https://developers.weixin.qq.com/doc/offiaccount/Publish/Get_status.html
+*/
+func (api *PublishApi) Get(ctx context.Context, publishID string) (*PublishStatus, error) {
+	resp := &PublishStatus{}
+	if err := api.Client.HTTPPostJson(ctx, apiGet, map[string]string{
+		"publish_id": publishID,
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+/*
+删除发布, index 为要删除的文章在图文消息中的位置，第一篇编号为1，0 表示删除全部文章
+https://developers.weixin.qq.com/doc/offiaccount/Publish/Delete_posts.html
+*/
+func (api *PublishApi) Delete(ctx context.Context, articleID string, index int) error {
+	return api.Client.HTTPPostJson(ctx, apiDelete, map[string]interface{}{
+		"article_id": articleID,
+		"index":      index,
+	}, nil)
+}
+
+/*
+通过 article_id 获取已发布文章
+https://developers.weixin.qq.com/doc/offiaccount/Publish/Get_article_from_id.html
+*/
+func (api *PublishApi) GetArticle(
+	ctx context.Context, articleID string,
+) ([]*material_api.Article, error) {
+	resp := &struct {
+		utils.WeixinError
+		NewsItems []*material_api.Article `json:"news_item"`
+	}{}
+	if err := api.Client.HTTPPostJson(ctx, apiGetArticle, map[string]string{
+		"article_id": articleID,
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp.NewsItems, nil
+}
+
+/*
+获取成功发布列表
+https://developers.weixin.qq.com/doc/offiaccount/Publish/Get_publication_records.html
+*/
+func (api *PublishApi) BatchGet(
+	ctx context.Context,
+	offset, count int, // count 取值在1到20之间
+	noContent bool, // 不返回 content 字段
+) (*material_api.ArticleList, error) {
+	noContentInt := 0
+	if noContent {
+		noContentInt = 1
+	}
+
+	resp := &material_api.ArticleList{}
+	if err := api.Client.HTTPPostJson(ctx, apiBatchGet, map[string]interface{}{
+		"offset":     offset,
+		"count":      count,
+		"no_content": noContentInt,
+	}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,51 @@
+package publish_api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/lixinio/weixin/weixin/official_account"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New(mockserver.OptWithApp("appid", "secret"))
+	defer server.Close()
+	server.HandleJSON(apiSubmit, map[string]interface{}{
+		"publish_id": "100000001", "msg_data_id": 2247483817,
+	})
+	server.HandleJSON(apiGet, map[string]interface{}{
+		"publish_id":     "100000001",
+		"publish_status": PublishStatusSuccess,
+		"article_id":     "article_id",
+		"article_detail": map[string]interface{}{
+			"count": 1,
+			"item":  []map[string]interface{}{{"idx": 1, "article_url": "https://mp.weixin.qq.com/s/article"}},
+		},
+		"fail_idx": []int{},
+	})
+
+	cache := memory.NewMemory()
+	oa := official_account.New(cache, cache, &official_account.Config{
+		Appid: "appid", Secret: "secret",
+	}, server.ClientOption())
+	api := NewApi(oa.Client)
+
+	result, err := api.Submit(ctx, "draft_media_id")
+	require.Equal(t, nil, err)
+	require.Equal(t, "100000001", result.PublishID)
+	require.Equal(t, int64(2247483817), result.MsgDataID)
+
+	status, err := api.Get(ctx, result.PublishID)
+	require.Equal(t, nil, err)
+	require.Equal(t, PublishStatusSuccess, status.PublishStatus)
+	require.Equal(t, "article_id", status.ArticleID)
+	require.Equal(t, "https://mp.weixin.qq.com/s/article", status.ArticleDetail.Items[0].ArticleUrl)
+
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiGet).Unmarshal(&payload))
+	require.Equal(t, "100000001", payload["publish_id"])
+}
@@ -0,0 +1,116 @@
+package server_api
+
+// 小程序的消息推送可以配置为 JSON 格式
+// https://developers.weixin.qq.com/miniprogram/dev/framework/server-ability/message-push.html
+// JSON 消息转换为等价的 XML 之后处理, 复用 ParseXML / Mux 以及所有的消息/事件类型, 回复也使用 JSON 格式
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+const (
+	DataFormatXML  = "xml"
+	DataFormatJSON = "json"
+)
+
+type dataFormatContext int
+
+var dataFormatContextKey = dataFormatContext(0)
+
+// dataFormat 配置的格式, 未配置时根据内容判断
+func (s *ServerApi) dataFormat(body []byte) string {
+	if s.DataFormat != "" {
+		return s.DataFormat
+	}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		return DataFormatJSON
+	}
+	return DataFormatXML
+}
+
+func withDataFormat(r *http.Request, format string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), dataFormatContextKey, format))
+}
+
+// requestDataFormat ServeData 时记录的格式, 用于回复
+func requestDataFormat(r *http.Request) string {
+	if format, ok := r.Context().Value(dataFormatContextKey).(string); ok {
+		return format
+	}
+	return DataFormatXML
+}
+
+func marshalReply(format string, v interface{}) ([]byte, error) {
+	if format == DataFormatJSON {
+		return json.Marshal(v)
+	}
+	return xml.Marshal(v)
+}
+
+// jsonToXML 把 JSON 对象转换为 <xml>...</xml>, 嵌套的对象转换为子元素, 数组转换为重复的元素
+func jsonToXML(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := xml.NewEncoder(buf)
+	if err := encodeJSONValue(encoder, "xml", object); err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeJSONValue(encoder *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := encodeJSONValue(encoder, key, v[key]); err != nil {
+				return err
+			}
+		}
+		return encoder.EncodeToken(start.End())
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeJSONValue(encoder, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		return encoder.EncodeElement("", start)
+	case string, json.Number, bool:
+		return encoder.EncodeElement(fmt.Sprint(v), start)
+	default:
+		return fmt.Errorf("unsupported json value %T", value)
+	}
+}
+
+// ParseJSON 解析 JSON 格式的消息/事件, 返回的类型和 ParseXML 一致
+func (s *ServerApi) ParseJSON(body []byte) (message *Message, m any, err error) {
+	xmlBody, err := jsonToXML(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.ParseXML(xmlBody)
+}
@@ -0,0 +1,85 @@
+package server_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/stretchr/testify/require"
+)
+
+const testEncodingAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+
+func TestParseJSON(t *testing.T) {
+	s := NewApi("appid", "token", "", nil)
+
+	message, m, err := s.ParseJSON([]byte(`{
+		"ToUserName": "toUser", "FromUserName": "fromUser", "CreateTime": 1482048670,
+		"MsgType": "text", "Content": "this is a test <&>", "MsgId": 1234567890123456
+	}`))
+	require.Equal(t, nil, err)
+	require.Equal(t, MsgTypeText, message.MsgType)
+	text := m.(*MessageText)
+	require.Equal(t, "this is a test <&>", text.Content)
+	require.Equal(t, "1234567890123456", text.MsgId)
+	require.Equal(t, "1482048670", text.CreateTime)
+
+	_, m, err = s.ParseJSON([]byte(`{
+		"ToUserName": "toUser", "FromUserName": "fromUser", "CreateTime": 1482048670,
+		"MsgType": "event", "Event": "weapp_audit_success", "SuccTime": 1535442963
+	}`))
+	require.Equal(t, nil, err)
+	require.Equal(t, int64(1535442963), m.(*EventWeappAuditSuccess).SuccTime)
+}
+
+func TestServeDataJSON(t *testing.T) {
+	s := NewApi("appid", "token", testEncodingAESKey, nil)
+	mux := NewMux(s)
+	mux.OnText(func(_ context.Context, msg *MessageText) (Reply, error) {
+		reply := &ReplyMessageTransferCustomerService{ReplyMessage: *msg.Reply()}
+		reply.MsgType = ReplyMsgTypeTransferCustomerService
+		return reply, nil
+	})
+
+	plain := []byte(`{"ToUserName":"toUser","FromUserName":"fromUser","CreateTime":1482048670,` +
+		`"MsgType":"text","Content":"hello","MsgId":1}`)
+	cipherText, err := utils.AESEncryptMsg([]byte(utils.GetRandString(16)), plain, "appid", testEncodingAESKey)
+	require.Equal(t, nil, err)
+	body, _ := json.Marshal(map[string]string{"ToUserName": "toUser", "Encrypt": cipherText})
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "nonce"
+	query := url.Values{}
+	query.Set("timestamp", timestamp)
+	query.Set("nonce", nonce)
+	query.Set("signature", utils.CalcSignature(timestamp, nonce, "token"))
+	query.Set("encrypt_type", "aes")
+	query.Set("msg_signature", utils.CalcSignature("token", timestamp, nonce, cipherText))
+
+	r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/?%s", query.Encode()), bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	require.Equal(t, nil, s.ServeData(w, r, mux.ServeXML))
+
+	// 加密的JSON回复
+	reply := map[string]string{}
+	require.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &reply))
+	require.Equal(
+		t, reply["MsgSignature"],
+		utils.CalcSignature("token", reply["TimeStamp"], reply["Nonce"], reply["Encrypt"]),
+	)
+	_, replyBody, _, err := utils.AESDecryptMsg(reply["Encrypt"], testEncodingAESKey)
+	require.Equal(t, nil, err)
+
+	replyMsg := map[string]interface{}{}
+	require.Equal(t, nil, json.Unmarshal(replyBody, &replyMsg))
+	require.Equal(t, "fromUser", replyMsg["ToUserName"])
+	require.Equal(t, "transfer_customer_service", replyMsg["MsgType"])
+}
@@ -0,0 +1,133 @@
+package server_api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	muxTextBody = `<xml>
+<ToUserName><![CDATA[toUser]]></ToUserName>
+<FromUserName><![CDATA[fromUser]]></FromUserName>
+<CreateTime>1348831860</CreateTime>
+<MsgType><![CDATA[text]]></MsgType>
+<Content><![CDATA[hello]]></Content>
+<MsgId>1234567890123456</MsgId>
+</xml>`
+	muxSubscribeBody = `<xml>
+<ToUserName><![CDATA[toUser]]></ToUserName>
+<FromUserName><![CDATA[fromUser]]></FromUserName>
+<CreateTime>123456789</CreateTime>
+<MsgType><![CDATA[event]]></MsgType>
+<Event><![CDATA[subscribe]]></Event>
+<EventKey><![CDATA[qrscene_123123]]></EventKey>
+</xml>`
+	muxImageBody = `<xml>
+<ToUserName><![CDATA[toUser]]></ToUserName>
+<FromUserName><![CDATA[fromUser]]></FromUserName>
+<CreateTime>1348831860</CreateTime>
+<MsgType><![CDATA[image]]></MsgType>
+<MediaId><![CDATA[media_id]]></MediaId>
+</xml>`
+)
+
+func TestMux(t *testing.T) {
+	mux := NewMux(NewApi("appid", "token", "", nil))
+
+	trace := []string{}
+	mux.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, message *Message, m any) (Reply, error) {
+			trace = append(trace, message.MsgType)
+			return next(ctx, message, m)
+		}
+	})
+	mux.OnText(func(_ context.Context, msg *MessageText) (Reply, error) {
+		return &ReplyMessageText{ReplyMessage: *msg.Reply(), Content: CDATA(msg.Content)}, nil
+	})
+	OnEvent(mux, EventTypeSubscribe, func(_ context.Context, event *EventSubscribe) (Reply, error) {
+		trace = append(trace, event.EventKey)
+		return nil, nil
+	})
+	mux.Fallback(func(_ context.Context, _ *Message, m any) (Reply, error) {
+		trace = append(trace, "fallback")
+		return nil, nil
+	})
+
+	serve := func(body string) string {
+		r := httptest.NewRequest(http.MethodPost, "/callback", nil)
+		w := httptest.NewRecorder()
+		require.Equal(t, nil, mux.ServeXML(w, r, []byte(body)))
+		return w.Body.String()
+	}
+
+	output := serve(muxTextBody)
+	require.True(t, strings.Contains(output, "<Content><![CDATA[hello]]></Content>"), output)
+	require.True(t, strings.Contains(output, "<ToUserName><![CDATA[fromUser]]></ToUserName>"), output)
+
+	require.Equal(t, "success", serve(muxSubscribeBody))
+	require.Equal(t, "success", serve(muxImageBody))
+	require.Equal(t, []string{"text", "event", "qrscene_123123", "image", "fallback"}, trace)
+
+	// 类型不匹配
+	OnMessage(mux, MsgTypeImage, func(context.Context, *MessageText) (Reply, error) {
+		return nil, nil
+	})
+	_, err := mux.Dispatch(context.Background(), []byte(muxImageBody))
+	require.NotEqual(t, nil, err)
+}
+
+func TestPublishJobFinish(t *testing.T) {
+	body := `<xml>
+<ToUserName><![CDATA[gh_4d00ed8d6399]]></ToUserName>
+<FromUserName><![CDATA[oV5CrjpxgaGXNHIQigzNlgLTnwic]]></FromUserName>
+<CreateTime>1481013459</CreateTime>
+<MsgType><![CDATA[event]]></MsgType>
+<Event><![CDATA[PUBLISHJOBFINISH]]></Event>
+<PublishEventInfo>
+<publish_id>2247503051</publish_id>
+<publish_status>2</publish_status>
+<fail_idx>1</fail_idx>
+<fail_idx>2</fail_idx>
+</PublishEventInfo>
+</xml>`
+
+	mux := NewMux(NewApi("appid", "token", "", nil))
+	publishID := ""
+	OnEvent(mux, EventTypePublishJobFinish, func(_ context.Context, event *EventPublishJobFinish) (Reply, error) {
+		publishID = event.PublishEventInfo.PublishID
+		require.Equal(t, PublishStatusOriginalErr, event.PublishEventInfo.PublishStatus)
+		require.Equal(t, []int{1, 2}, event.PublishEventInfo.FailIdx)
+		return nil, nil
+	})
+	_, err := mux.Dispatch(context.Background(), []byte(body))
+	require.Equal(t, nil, err)
+	require.Equal(t, "2247503051", publishID)
+}
+
+func TestKfSwitchSession(t *testing.T) {
+	body := `<xml>
+<ToUserName><![CDATA[touser]]></ToUserName>
+<FromUserName><![CDATA[fromuser]]></FromUserName>
+<CreateTime>1399197672</CreateTime>
+<MsgType><![CDATA[event]]></MsgType>
+<Event><![CDATA[kf_switch_session]]></Event>
+<FromKfAccount><![CDATA[test1@test]]></FromKfAccount>
+<ToKfAccount><![CDATA[test2@test]]></ToKfAccount>
+</xml>`
+
+	mux := NewMux(NewApi("appid", "token", "", nil))
+	toKfAccount := ""
+	OnEvent(mux, EventTypeKfSwitchSession, func(_ context.Context, event *EventKfSwitchSession) (Reply, error) {
+		require.Equal(t, "test1@test", event.FromKfAccount)
+		toKfAccount = event.ToKfAccount
+		return nil, nil
+	})
+	_, err := mux.Dispatch(context.Background(), []byte(body))
+	require.Equal(t, nil, err)
+	require.Equal(t, "test2@test", toKfAccount)
+}
@@ -17,13 +17,11 @@ package server_api
 // limitations under the License.
 
 import (
+	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
-	"time"
 
 	"github.com/lixinio/weixin/utils"
 )
@@ -33,6 +31,9 @@ type ServerApi struct {
 	AppID          string
 	Token          string
 	EncodingAESKey string
+	// 消息格式 DataFormatXML / DataFormatJSON(小程序), 为空时根据内容判断
+	DataFormat  string
+	replayGuard *utils.ReplayGuard
 }
 
 func NewApi(
@@ -47,24 +48,28 @@ func NewApi(
 	}
 }
 
-func calcSignatureFromHttp(r *http.Request, token string) string {
-	return utils.CalcSignature(
-		r.URL.Query().Get("timestamp"),
-		r.URL.Query().Get("nonce"),
-		token,
-	)
+// SetReplayGuard 开启回调的防重放检查
+func (s *ServerApi) SetReplayGuard(guard *utils.ReplayGuard) {
+	s.replayGuard = guard
+}
+
+// crypto 签名和加解密, 第三方平台代公众号接收消息时, appid 为第三方平台的appid
+func (s *ServerApi) crypto() *utils.CallbackCrypto {
+	receiverIDs := []string{}
+	if s.AppID != "" {
+		receiverIDs = append(receiverIDs, s.AppID)
+	}
+	return utils.NewCallbackCrypto(s.Token, s.EncodingAESKey, s.AppID, receiverIDs...)
 }
 
 func (s *ServerApi) ServeEcho(w http.ResponseWriter, r *http.Request) error {
-	signature := calcSignatureFromHttp(r, s.Token)
-	echoStr := r.URL.Query().Get("echostr")
-	if echoStr != "" && signature == r.URL.Query().Get("signature") {
-		_, err := io.WriteString(w, echoStr)
-		return err
-	} else {
+	echo, err := s.crypto().VerifyEcho(r.URL.Query())
+	if err != nil {
 		utils.HttpAbortBadRequest(w)
-		return errors.New("signature dismatch")
+		return err
 	}
+	_, err = w.Write(echo)
+	return err
 }
 
 func (s *ServerApi) ServeData(
@@ -72,13 +77,14 @@ func (s *ServerApi) ServeData(
 	r *http.Request,
 	processor utils.XmlHandlerFunc,
 ) error {
-	signature := calcSignatureFromHttp(r, s.Token)
-	if signature != r.URL.Query().Get("signature") {
+	crypto := s.crypto()
+	query := r.URL.Query()
+	timestamp := query.Get("timestamp")
+	nonce := query.Get("nonce")
+	signature := query.Get("signature")
+	if err := crypto.VerifySignature(signature, timestamp, nonce); err != nil {
 		utils.HttpAbortBadRequest(w)
-		return fmt.Errorf(
-			"signature dismatch %s != %s",
-			signature, r.URL.Query().Get("signature"),
-		)
+		return err
 	}
 
 	body, err := io.ReadAll(r.Body)
@@ -88,32 +94,45 @@ func (s *ServerApi) ServeData(
 	}
 
 	// 是否加密消息
+	format := s.dataFormat(body)
 	encryptMsg := &EncryptMessage{}
-	if err = xml.Unmarshal(body, encryptMsg); err != nil {
+	if format == DataFormatJSON {
+		err = json.Unmarshal(body, encryptMsg)
+	} else {
+		err = xml.Unmarshal(body, encryptMsg)
+	}
+	if err != nil {
 		return err
 	}
 
 	// 需要解密
 	if encryptMsg.Encrypt != "" {
-		msgSignature := r.URL.Query().Get("msg_signature")
-		timestamp := r.URL.Query().Get("timestamp")
-		nonce := r.URL.Query().Get("nonce")
-
-		if utils.CalcSignature(
-			s.Token, timestamp, nonce, encryptMsg.Encrypt,
-		) != msgSignature {
-			return errors.New("invalid msg signature")
+		signature = query.Get("msg_signature")
+		if body, _, err = crypto.OpenEnvelope(r.Context(), query, encryptMsg); err != nil {
+			return err
 		}
+	}
 
-		var xmlMsg []byte
-		_, xmlMsg, _, err = utils.AESDecryptMsg(encryptMsg.Encrypt, s.EncodingAESKey)
-		if err != nil {
+	if format == DataFormatJSON {
+		// 转换为XML, 处理函数可以使用 ParseXML, 回复时使用JSON
+		if body, err = jsonToXML(body); err != nil {
 			return err
 		}
-		body = xmlMsg
+		r = withDataFormat(r, format)
 	}
 
-	return processor(w, r, body)
+	return s.replayGuard.Guard(r.Context(), timestamp, nonce, signature, func() error {
+		return processor(w, r, body)
+	})
+}
+
+// ServeDataAsync 校验/解密之后交给 queue 异步处理, 立即回复 success
+func (s *ServerApi) ServeDataAsync(
+	w http.ResponseWriter,
+	r *http.Request,
+	queue utils.CallbackQueue,
+) error {
+	return s.ServeData(w, r, utils.NewAsyncXmlHandler(queue, "success"))
 }
 
 // ParseXML 解析微信推送过来的消息/事件
@@ -409,6 +428,34 @@ func parseEvent(body []byte) (_ any, err error) {
 		}
 		return msg, nil
 
+		// 发布任务完成
+	case EventTypePublishJobFinish:
+		msg := &EventPublishJobFinish{}
+		if err = xml.Unmarshal(body, msg); err != nil {
+			return
+		}
+		return msg, nil
+
+		// 客服会话
+	case EventTypeKfCreateSession:
+		msg := &EventKfCreateSession{}
+		if err = xml.Unmarshal(body, msg); err != nil {
+			return
+		}
+		return msg, nil
+	case EventTypeKfCloseSession:
+		msg := &EventKfCloseSession{}
+		if err = xml.Unmarshal(body, msg); err != nil {
+			return
+		}
+		return msg, nil
+	case EventTypeKfSwitchSession:
+		msg := &EventKfSwitchSession{}
+		if err = xml.Unmarshal(body, msg); err != nil {
+			return
+		}
+		return msg, nil
+
 	case EventTypeAuthorizeInvoice:
 		msg := &EventAuthorizeInvoice{}
 		if err = xml.Unmarshal(body, msg); err != nil {
@@ -466,8 +513,9 @@ func (s *ServerApi) response(
 	//&encrypt_type=aes
 	//&msg_signature=cc24cc38467417603fc3689170e8b0fd3c9bf4a2
 	output := []byte("success") // 默认回复
+	format := requestDataFormat(r)
 	if reply != nil {
-		output, err = xml.Marshal(reply)
+		output, err = marshalReply(format, reply)
 		if err != nil {
 			return
 		}
@@ -475,47 +523,27 @@ func (s *ServerApi) response(
 		// 加密
 		if r.URL.Query().Get("encrypt_type") == "aes" {
 			var message *ReplyEncryptMessage
-			message, err = s.encryptReplyMessage(output)
+			message, err = s.crypto().EncryptReply(output, "")
 			if err != nil {
-				fmt.Println("encryptReplyMessage", err)
+				fmt.Println("EncryptReply", err)
 				return
 			}
-			output, err = xml.Marshal(message)
+			output, err = marshalReply(format, message)
 			if err != nil {
-				fmt.Println("marshal encryptReplyMessage", err)
+				fmt.Println("marshal EncryptReply", err)
 				return
 			}
 		}
 	}
 
+	if format == DataFormatJSON && reply != nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
 	_, err = w.Write(output)
 
 	return
 }
 
-// encryptReplyMessage 加密回复消息
-func (s *ServerApi) encryptReplyMessage(rawXmlMsg []byte) (*ReplyEncryptMessage, error) {
-	cipherText, err := utils.AESEncryptMsg(
-		[]byte(utils.GetRandString(16)),
-		rawXmlMsg,
-		s.AppID,
-		s.EncodingAESKey,
-	)
-	if err != nil {
-		return nil, err
-	}
-	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
-	nonce := utils.GetRandString(6)
-	signature := utils.CalcSignature(timestamp, nonce, s.Token, cipherText)
-
-	return &ReplyEncryptMessage{
-		Encrypt:      CDATA(cipherText),
-		MsgSignature: CDATA(signature),
-		TimeStamp:    timestamp,
-		Nonce:        CDATA(nonce),
-	}, nil
-}
-
 func (s *ServerApi) ResponseText(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -0,0 +1,63 @@
+package server_api
+
+// 客服会话状态通知, 客服接入/关闭/转接会话时推送
+// https://developers.weixin.qq.com/doc/offiaccount/Customer_Service/Session_control.html
+
+const (
+	EventTypeKfCreateSession = "kf_create_session" // 接入会话
+	EventTypeKfCloseSession  = "kf_close_session"  // 关闭会话
+	EventTypeKfSwitchSession = "kf_switch_session" // 转接会话
+)
+
+/*
+<xml>
+
+	<ToUserName><![CDATA[touser]]></ToUserName>
+	<FromUserName><![CDATA[fromuser]]></FromUserName>
+	<CreateTime>1399197672</CreateTime>
+	<MsgType><![CDATA[event]]></MsgType>
+	<Event><![CDATA[kf_create_session]]></Event>
+	<KfAccount><![CDATA[test1@test]]></KfAccount>
+
+</xml>
+*/
+type EventKfCreateSession struct {
+	Event
+	KfAccount string `xml:"KfAccount"` // 完整客服账号
+}
+
+/*
+<xml>
+
+	<ToUserName><![CDATA[touser]]></ToUserName>
+	<FromUserName><![CDATA[fromuser]]></FromUserName>
+	<CreateTime>1399197672</CreateTime>
+	<MsgType><![CDATA[event]]></MsgType>
+	<Event><![CDATA[kf_close_session]]></Event>
+	<KfAccount><![CDATA[test1@test]]></KfAccount>
+
+</xml>
+*/
+type EventKfCloseSession struct {
+	Event
+	KfAccount string `xml:"KfAccount"` // 完整客服账号
+}
+
+/*
+<xml>
+
+	<ToUserName><![CDATA[touser]]></ToUserName>
+	<FromUserName><![CDATA[fromuser]]></FromUserName>
+	<CreateTime>1399197672</CreateTime>
+	<MsgType><![CDATA[event]]></MsgType>
+	<Event><![CDATA[kf_switch_session]]></Event>
+	<FromKfAccount><![CDATA[test1@test]]></FromKfAccount>
+	<ToKfAccount><![CDATA[test2@test]]></ToKfAccount>
+
+</xml>
+*/
+type EventKfSwitchSession struct {
+	Event
+	FromKfAccount string `xml:"FromKfAccount"` // 来自的客服账号
+	ToKfAccount   string `xml:"ToKfAccount"`   // 转移给的客服账号
+}
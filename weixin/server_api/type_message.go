@@ -20,6 +20,8 @@ import (
 	"encoding/xml"
 	"strconv"
 	"time"
+
+	"github.com/lixinio/weixin/utils"
 )
 
 const (
@@ -61,11 +63,7 @@ func (message *Message) Reply() *ReplyMessage {
 
 </xml>
 */
-type EncryptMessage struct {
-	XMLName    xml.Name `xml:"xml"`
-	ToUserName string
-	Encrypt    string
-}
+type EncryptMessage = utils.EncryptMessage
 
 /*
 <xml>
@@ -0,0 +1,62 @@
+package server_api
+
+// https://developers.weixin.qq.com/doc/offiaccount/Publish/Callback_on_finish.html
+
+import "github.com/lixinio/weixin/weixin/publish_api"
+
+const (
+	EventTypePublishJobFinish = "PUBLISHJOBFINISH" // 发布任务完成
+)
+
+// 发布状态, 定义在 publish_api, 和轮询的结果一致
+const (
+	PublishStatusSuccess     = publish_api.PublishStatusSuccess
+	PublishStatusPublishing  = publish_api.PublishStatusPublishing
+	PublishStatusOriginalErr = publish_api.PublishStatusOriginalErr
+	PublishStatusFailed      = publish_api.PublishStatusFailed
+	PublishStatusAuditFailed = publish_api.PublishStatusAuditFailed
+	PublishStatusDeleted     = publish_api.PublishStatusDeleted
+	PublishStatusBanned      = publish_api.PublishStatusBanned
+)
+
+/*
+<xml>
+
+	<ToUserName><![CDATA[gh_4d00ed8d6399]]></ToUserName>
+	<FromUserName><![CDATA[oV5CrjpxgaGXNHIQigzNlgLTnwic]]></FromUserName>
+	<CreateTime>1481013459</CreateTime>
+	<MsgType><![CDATA[event]]></MsgType>
+	<Event><![CDATA[PUBLISHJOBFINISH]]></Event>
+	<PublishEventInfo>
+		<publish_id>2247503051</publish_id>
+		<publish_status>0</publish_status>
+		<article_id><![CDATA[b5O2OUs25HBxRceL7hfReg-U9QGeq9zQjiDvyWP4Hq4]]></article_id>
+		<article_detail>
+			<count>1</count>
+			<item>
+				<idx>1</idx>
+				<article_url><![CDATA[ARTICLE_URL]]></article_url>
+			</item>
+		</article_detail>
+	</PublishEventInfo>
+
+</xml>
+
+失败时没有 article_id/article_detail, 有 fail_idx (原创失败和审核不通过的文章序号, 可能有多个)
+*/
+type EventPublishJobFinish struct {
+	Event
+	PublishEventInfo struct {
+		PublishID     string `xml:"publish_id"`     // 发布任务id, 和 publish_api.Submit 返回的一致
+		PublishStatus int    `xml:"publish_status"` // PublishStatusXXX
+		ArticleID     string `xml:"article_id"`     // 成功时, 图文的 article_id
+		ArticleDetail struct {
+			Count int `xml:"count"`
+			Items []struct {
+				Idx        int    `xml:"idx"`
+				ArticleUrl string `xml:"article_url"`
+			} `xml:"item"`
+		} `xml:"article_detail"`
+		FailIdx []int `xml:"fail_idx"`
+	}
+}
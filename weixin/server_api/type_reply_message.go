@@ -16,15 +16,14 @@ package server_api
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-import "encoding/xml"
+import (
+	"encoding/xml"
 
-type CDATA string
+	"github.com/lixinio/weixin/utils"
+)
 
-func (c CDATA) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-	return e.EncodeElement(struct {
-		string `xml:",cdata"`
-	}{string(c)}, start)
-}
+// CDATA 和 加密回复 使用 utils 中的定义, 所有回调共用
+type CDATA = utils.CDATA
 
 const (
 	ReplyMsgTypeText                    = "text"
@@ -37,29 +36,14 @@ const (
 )
 
 type ReplyMessage struct {
-	XMLName      xml.Name `xml:"xml"`
+	XMLName      xml.Name `xml:"xml" json:"-"`
 	ToUserName   CDATA
 	FromUserName CDATA
 	CreateTime   string
 	MsgType      CDATA
 }
 
-/*
-加密处理后 的 回复 消息体
-<xml>
-<Encrypt></Encrypt>
-<MsgSignature></MsgSignature>
-<TimeStamp></TimeStamp>
-<Nonce></Nonce>
-</xml>
-*/
-type ReplyEncryptMessage struct {
-	XMLName      xml.Name `xml:"xml"`
-	Encrypt      CDATA
-	MsgSignature CDATA
-	TimeStamp    string
-	Nonce        CDATA
-}
+type ReplyEncryptMessage = utils.ReplyEncryptMessage
 
 /*
 <xml>
@@ -12,9 +12,12 @@ import (
 
 func TestUserTag(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+
 	for _, client := range []*utils.Client{
-		initOfficialAccount(),
-		initAuthorizer(),
+		initOfficialAccount(server),
+		initAuthorizer(t, server),
 	} {
 		userApi := NewApi(client)
 		// 获得第一个用户
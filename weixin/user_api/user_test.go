@@ -2,41 +2,257 @@ package user_api
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/weixin/authorizer"
 	"github.com/lixinio/weixin/weixin/official_account"
+	"github.com/lixinio/weixin/wxopen"
 	"github.com/stretchr/testify/require"
 )
 
-func initOfficialAccount() *utils.Client {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	officialAccount := official_account.New(redis, redis, &official_account.Config{
-		Appid:  test.OfficialAccountAppid,
-		Secret: test.OfficialAccountSecret,
-	})
+const (
+	testAppid           = "wxofficialaccount"
+	testSecret          = "secret"
+	testComponentAppid  = "wxcomponent"
+	testComponentSecret = "component_secret"
+	testAuthorizerAppid = "wxauthorizer"
+	testOpenID          = "oDjGHs-1yCnGrRovBj2yHij5JAAA"
+)
+
+func initOfficialAccount(server *mockserver.Server) *utils.Client {
+	cache := memory.NewMemory()
+	officialAccount := official_account.New(cache, cache, &official_account.Config{
+		Appid:  testAppid,
+		Secret: testSecret,
+	}, server.ClientOption())
 	return officialAccount.Client
 }
 
-func initAuthorizer() *utils.Client {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	wxopenOA := authorizer.NewLite(
-		redis, redis,
-		test.WxOpenAppid,
-		test.WxOpenOAAppid,
-	)
+func initAuthorizer(t *testing.T, server *mockserver.Server) *utils.Client {
+	ctx := context.Background()
+	cache := memory.NewMemory()
+	open := wxopen.New(cache, cache, &wxopen.Config{
+		Appid:  testComponentAppid,
+		Secret: testComponentSecret,
+	}, nil, server.ClientOption())
+	require.Equal(t, nil, open.UpdateTicket(ctx, "ticket"))
+	open.SetRefreshTokenStore(utils.NewCacheRefreshTokenStore(cache))
+	open.SetAuthorizerFactory(authorizer.WxOpenFactory)
+	require.Equal(t, nil, open.SaveAuthorization(ctx, &wxopen.AuthorizationInfo{
+		AuthorizerAppid:        testAuthorizerAppid,
+		AuthorizerRefreshToken: "refresh_token",
+	}))
+	wxopenOA, err := authorizer.FromWxOpen(open, testAuthorizerAppid)
+	require.Equal(t, nil, err)
 	return wxopenOA.Client
 }
 
+// fakeUsers 模拟公众号的粉丝, 备注, 黑名单和标签
+type fakeUsers struct {
+	mu        sync.Mutex
+	remarks   map[string]string
+	blacklist map[string]bool
+	tagSeq    int
+	tags      map[int]string
+	members   map[int]map[string]bool
+}
+
+func newTestServer() *mockserver.Server {
+	server := mockserver.New(
+		mockserver.OptWithApp(testAppid, testSecret),
+		mockserver.OptWithApp(testComponentAppid, testComponentSecret),
+	)
+	users := &fakeUsers{
+		remarks:   map[string]string{testOpenID: ""},
+		blacklist: map[string]bool{},
+		tagSeq:    100,
+		tags:      map[int]string{},
+		members:   map[int]map[string]bool{},
+	}
+	users.register(server)
+	return server
+}
+
+func (f *fakeUsers) register(server *mockserver.Server) {
+	server.Handle(apiGet, f.handle(func(r *http.Request) interface{} {
+		return openIDResponse(keys(f.remarks))
+	}))
+	server.Handle(apiUpdateRemark, f.handle(func(r *http.Request) interface{} {
+		param := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&param)
+		f.remarks[param["openid"]] = param["remark"]
+		return &utils.WeixinError{}
+	}))
+	server.Handle(apiGetUserInfo, f.handle(func(r *http.Request) interface{} {
+		return f.user(r.URL.Query().Get("openid"))
+	}))
+	server.Handle(apiBatchGetUserInfo, f.handle(func(r *http.Request) interface{} {
+		param := &BatchGetUserParams{}
+		_ = json.NewDecoder(r.Body).Decode(param)
+		result := &UserInfoList{}
+		for _, item := range param.UserList {
+			result.UserInfoList = append(result.UserInfoList, *f.user(item.OpenID))
+		}
+		return result
+	}))
+	server.Handle(apiGetBlackList, f.handle(func(r *http.Request) interface{} {
+		return openIDResponse(keys(f.blacklist))
+	}))
+	server.Handle(apiBatchBlackList, f.handle(func(r *http.Request) interface{} {
+		for _, openid := range decodeOpenIDList(r) {
+			f.blacklist[openid] = true
+		}
+		return &utils.WeixinError{}
+	}))
+	server.Handle(apiBatchUnBlackList, f.handle(func(r *http.Request) interface{} {
+		for _, openid := range decodeOpenIDList(r) {
+			delete(f.blacklist, openid)
+		}
+		return &utils.WeixinError{}
+	}))
+
+	server.Handle(apiTagCreate, f.handle(func(r *http.Request) interface{} {
+		param := map[string]TagItem{}
+		_ = json.NewDecoder(r.Body).Decode(&param)
+		f.tagSeq++
+		f.tags[f.tagSeq] = param["tag"].Name
+		f.members[f.tagSeq] = map[string]bool{}
+		return &TagInfo{Tag: TagItem{ID: f.tagSeq, Name: param["tag"].Name}}
+	}))
+	server.Handle(apiTagGet, f.handle(func(r *http.Request) interface{} {
+		tags := []TagItem{}
+		for id, name := range f.tags {
+			tags = append(tags, TagItem{ID: id, Name: name, Count: int64(len(f.members[id]))})
+		}
+		return map[string]interface{}{"tags": tags}
+	}))
+	server.Handle(apiTagUpdate, f.handle(func(r *http.Request) interface{} {
+		param := map[string]TagItem{}
+		_ = json.NewDecoder(r.Body).Decode(&param)
+		if _, ok := f.tags[param["tag"].ID]; !ok {
+			return &utils.WeixinError{ErrCode: 45058, ErrMsg: "tag not exist"}
+		}
+		f.tags[param["tag"].ID] = param["tag"].Name
+		return &utils.WeixinError{}
+	}))
+	server.Handle(apiTagDelete, f.handle(func(r *http.Request) interface{} {
+		param := map[string]TagItem{}
+		_ = json.NewDecoder(r.Body).Decode(&param)
+		delete(f.tags, param["tag"].ID)
+		delete(f.members, param["tag"].ID)
+		return &utils.WeixinError{}
+	}))
+	server.Handle(apiTagGetUsersByTag, f.handle(func(r *http.Request) interface{} {
+		param := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&param)
+		tagID, _ := param["tagid"].(float64)
+		return openIDResponse(keys(f.members[int(tagID)]))
+	}))
+	server.Handle(apiTagBatchTagging, f.handle(func(r *http.Request) interface{} {
+		tagID, openids := decodeTagging(r)
+		for _, openid := range openids {
+			f.members[tagID][openid] = true
+		}
+		return &utils.WeixinError{}
+	}))
+	server.Handle(apiTagBatchUnTagging, f.handle(func(r *http.Request) interface{} {
+		tagID, openids := decodeTagging(r)
+		for _, openid := range openids {
+			delete(f.members[tagID], openid)
+		}
+		return &utils.WeixinError{}
+	}))
+	server.Handle(apiTagGetTagIdList, f.handle(func(r *http.Request) interface{} {
+		param := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&param)
+		return &UserTagList{TagIDList: f.tagIDs(param["openid"])}
+	}))
+}
+
+func (f *fakeUsers) handle(fn func(r *http.Request) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		result := fn(r)
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
+
+func (f *fakeUsers) tagIDs(openid string) []int {
+	ids := []int{}
+	for id, members := range f.members {
+		if members[openid] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (f *fakeUsers) user(openid string) *User {
+	user := &User{Subscribe: 1, OpenID: openid, Remark: f.remarks[openid]}
+	for _, id := range f.tagIDs(openid) {
+		user.TagIDList = append(user.TagIDList, int32(id))
+	}
+	return user
+}
+
+func openIDResponse(openids []string) map[string]interface{} {
+	return map[string]interface{}{
+		"total": len(openids),
+		"count": len(openids),
+		"data":  map[string][]string{"openid": openids},
+	}
+}
+
+func decodeOpenIDList(r *http.Request) []string {
+	param := map[string][]string{}
+	_ = json.NewDecoder(r.Body).Decode(&param)
+	return param["openid_list"]
+}
+
+func decodeTagging(r *http.Request) (int, []string) {
+	param := &struct {
+		TagID      int      `json:"tagid"`
+		OpenIDList []string `json:"openid_list"`
+	}{}
+	_ = json.NewDecoder(r.Body).Decode(param)
+	return param.TagID, param.OpenIDList
+}
+
+func keys(m interface{}) []string {
+	result := []string{}
+	switch v := m.(type) {
+	case map[string]string:
+		for key := range v {
+			result = append(result, key)
+		}
+	case map[string]bool:
+		for key := range v {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
 func TestUser(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+
 	for _, client := range []*utils.Client{
-		initOfficialAccount(),
-		initAuthorizer(),
+		initOfficialAccount(server),
+		initAuthorizer(t, server),
 	} {
 		userApi := NewApi(client)
 		// 用户列表
@@ -61,6 +277,7 @@ func TestUser(t *testing.T) {
 			require.Equal(t, openid, resp.OpenID)
 			// 备注一样
 			require.Equal(t, remark, resp.Remark)
+			require.Equal(t, openid, server.LastRequest(apiGetUserInfo).Query.Get("openid"))
 		}
 
 		{
@@ -116,4 +333,5 @@ func TestUser(t *testing.T) {
 			require.NotContains(t, resp.Data.OpenIDs, openid)
 		}
 	}
+	require.NotEqual(t, nil, server.LastRequest("/cgi-bin/component/api_authorizer_token"))
 }
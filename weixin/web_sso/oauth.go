@@ -38,13 +38,14 @@ type WebSSO struct {
 	Client *utils.Client
 }
 
-func New(config *Config) *WebSSO {
+func New(config *Config, options ...utils.ClientOption) *WebSSO {
 	instance := &WebSSO{
 		Config: config,
 	}
 	instance.Client = utils.NewClient(
 		WXServerUrl,
 		utils.EmptyClientAccessTokenGetter(0),
+		options...,
 	)
 	return instance
 }
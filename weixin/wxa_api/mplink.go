@@ -59,9 +59,9 @@ func (api *WxaApi) GetWxaMplinkForShow(
 ) (*ShowWxaMpList, error) {
 	result := &ShowWxaMpList{}
 	if err := api.Client.HTTPGetWithParams(
-		ctx, apiGetShowWxaItem, func(query url.Values) {
+		ctx, apiGetWxaMplinkForShow, func(query url.Values) {
 			query.Add("page", strconv.Itoa(page))
-			if count > 0 {
+			if count <= 0 {
 				count = 20
 			}
 			query.Add("num", strconv.Itoa(count))
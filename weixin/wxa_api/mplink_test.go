@@ -2,31 +2,51 @@ package wxa_api
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetShowWxaItem(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+	mpInfo := map[string]string{
+		"appid": "wxofficialaccount", "nickname": "公众号", "headimg": "https://wx.qlogo.cn/headimg",
+	}
+	server.HandleJSON(apiGetWxaMplinkForShow, map[string]interface{}{
+		"total_num": 1, "biz_info_list": []map[string]string{mpInfo},
+	})
+	server.HandleJSON(apiGetShowWxaItem, map[string]interface{}{
+		"can_open": 1, "is_open": 0,
+		"appid": mpInfo["appid"], "nickname": mpInfo["nickname"], "headimg": mpInfo["headimg"],
+	})
+	server.HandleJSON(apiUpdateShowWxaItem, &utils.WeixinError{})
+
 	for _, client := range []*utils.Client{
-		initWxa(),
-		// initAuthorizer(),
+		initWxa(server),
 	} {
 		wxaApi := NewApi(client)
 
 		list, err := wxaApi.GetWxaMplinkForShow(ctx, 0, 0)
 		require.Equal(t, nil, err)
-		fmt.Println(list)
+		require.Equal(t, 1, list.TotalNum)
+		require.Equal(t, mpInfo["appid"], list.BizInfoList[0].AppID)
+		query := server.LastRequest(apiGetWxaMplinkForShow).Query
+		require.Equal(t, "0", query.Get("page"))
+		require.Equal(t, "20", query.Get("num"))
 
 		b, err := wxaApi.GetShowWxaItem(ctx)
 		require.Equal(t, nil, err)
-		fmt.Println(b)
+		require.Equal(t, 1, b.CanOpen)
+		require.Equal(t, mpInfo["appid"], b.AppID)
 
-		err = wxaApi.UpdateShowWxaItem(ctx, 1, test.OfficialAccountAppid)
+		err = wxaApi.UpdateShowWxaItem(ctx, 1, mpInfo["appid"])
 		require.Equal(t, nil, err)
+		payload := map[string]interface{}{}
+		require.Equal(t, nil, server.LastRequest(apiUpdateShowWxaItem).Unmarshal(&payload))
+		require.Equal(t, float64(1), payload["wxa_subscribe_biz_flag"])
+		require.Equal(t, mpInfo["appid"], payload["appid"])
 	}
 }
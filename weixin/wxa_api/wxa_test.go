@@ -2,78 +2,119 @@ package wxa_api
 
 import (
 	"context"
-	"fmt"
 	"testing"
 	"time"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/weixin/official_account"
 	"github.com/stretchr/testify/require"
 )
 
-func initWxa() *utils.Client {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	wxa := official_account.New(redis, redis, &official_account.Config{
-		Appid:  test.WxaAppid,
-		Secret: test.WxaSecret,
-	})
+const (
+	testWxaAppid  = "wxminiprogram"
+	testWxaSecret = "secret"
+	testWxaPath   = "/modules/usedcar/Showroom/index"
+)
+
+func initWxa(server *mockserver.Server) *utils.Client {
+	cache := memory.NewMemory()
+	wxa := official_account.New(cache, cache, &official_account.Config{
+		Appid:  testWxaAppid,
+		Secret: testWxaSecret,
+	}, server.ClientOption())
 	return wxa.Client
 }
 
-// func initAuthorizer() *utils.Client {
-// 	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-// 	wxopenOA := authorizer.NewLite(
-// 		redis, redis,
-// 		test.WxOpenAppid,
-// 		test.WxOpenOAAppid,
-// 	)
-// 	return wxopenOA.Client
-// }
+func newTestServer() *mockserver.Server {
+	return mockserver.New(mockserver.OptWithApp(testWxaAppid, testWxaSecret))
+}
 
 func TestUrlLink(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiGenerateUrlLink, map[string]string{
+		"url_link": "https://wxaurl.cn/74306i0l9ug",
+	})
+	server.HandleJSON(apiQueryUrlLink, map[string]interface{}{
+		"url_link_info": map[string]interface{}{
+			"appid": testWxaAppid, "path": testWxaPath, "create_time": 1611303002,
+		},
+		"url_link_quota": map[string]int{"long_time_used": 100, "long_time_limit": 100000},
+	})
+
 	for _, client := range []*utils.Client{
-		initWxa(),
-		// initAuthorizer(),
+		initWxa(server),
 	} {
 		wxaApi := NewApi(client)
+		expireTime := time.Now().Add(time.Hour).Unix()
 		url, err := wxaApi.GenerateUrlLink(ctx, &GenerateUrlLinkRequest{
-			Path:       "/modules/usedcar/Showroom/index",
+			Path:       testWxaPath,
 			IsExpire:   true,
-			ExpireTime: time.Now().Add(time.Hour).Unix(),
+			ExpireTime: expireTime,
 		})
 		require.Equal(t, nil, err)
-		fmt.Println(url)
-		// url := "https://wxaurl.cn/74306i0l9ug"
+		require.Equal(t, "https://wxaurl.cn/74306i0l9ug", url)
+
+		param := &GenerateUrlLinkRequest{}
+		require.Equal(t, nil, server.LastRequest(apiGenerateUrlLink).Unmarshal(param))
+		require.Equal(t, testWxaPath, param.Path)
+		require.True(t, param.IsExpire)
+		require.Equal(t, expireTime, param.ExpireTime)
 
 		body, err := wxaApi.GetUrlLink(ctx, url, 0)
 		require.Equal(t, nil, err)
-		fmt.Println(body)
+		require.Equal(t, testWxaAppid, body.UrlLinkInfo.AppID)
+		require.Equal(t, testWxaPath, body.UrlLinkInfo.Path)
+		require.Equal(t, 100000, body.UrlLinkQuota.LongTimeLimit)
+
+		payload := map[string]interface{}{}
+		require.Equal(t, nil, server.LastRequest(apiQueryUrlLink).Unmarshal(&payload))
+		require.Equal(t, url, payload["url_link"])
 	}
 }
 
 func TestSchema(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiGenerateScheme, map[string]string{
+		"openlink": "weixin://dl/business/?t=hTMDg0hg3hu",
+	})
+	server.HandleJSON(apiQueryScheme, map[string]interface{}{
+		"scheme_info": map[string]interface{}{
+			"appid": testWxaAppid, "path": testWxaPath, "create_time": 1611303002,
+		},
+		"scheme_quota": map[string]int{"long_time_used": 100, "long_time_limit": 100000},
+	})
+
 	for _, client := range []*utils.Client{
-		initWxa(),
-		// initAuthorizer(),
+		initWxa(server),
 	} {
 		wxaApi := NewApi(client)
 		url, err := wxaApi.GenerateScheme(ctx, &GenerateSchemeRequest{
 			JumpWxa: &JumpWxa{
-				Path: "/modules/usedcar/Showroom/index",
+				Path: testWxaPath,
 			},
 			ExpireType: 0,
 			ExpireTime: time.Now().Add(time.Hour).Unix(),
 		})
 		require.Equal(t, nil, err)
-		fmt.Println(url)
-		// url := "weixin://dl/business/?t=hTMDg0hg3hu"
+		require.Equal(t, "weixin://dl/business/?t=hTMDg0hg3hu", url)
+
+		param := &GenerateSchemeRequest{}
+		require.Equal(t, nil, server.LastRequest(apiGenerateScheme).Unmarshal(param))
+		require.Equal(t, testWxaPath, param.JumpWxa.Path)
 
 		body, err := wxaApi.GetSchema(ctx, url, 0)
 		require.Equal(t, nil, err)
-		fmt.Println(body)
+		require.Equal(t, testWxaAppid, body.SchemeInfo.AppID)
+		require.Equal(t, testWxaPath, body.SchemeInfo.Path)
+
+		payload := map[string]interface{}{}
+		require.Equal(t, nil, server.LastRequest(apiQueryScheme).Unmarshal(&payload))
+		require.Equal(t, url, payload["scheme"])
 	}
 }
@@ -2,49 +2,75 @@ package wxopen
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
 func TestCreatePreAuthCode(t *testing.T) {
-	open := initWxOpen()
+	server := mockserver.New(mockserver.OptWithApp(testComponentAppid, testComponentSecret))
+	defer server.Close()
+	server.HandleJSON(apiCreatePreAuthCode, map[string]interface{}{
+		"pre_auth_code": "preauthcode@@@xxx", "expires_in": 1800,
+	})
+
+	open := initWxOpen(t, server)
 	code, expiresIn, err := open.CreatePreAuthCode(context.Background())
-	fmt.Println(code, expiresIn)
-	require.Empty(t, err)
+	require.Equal(t, nil, err)
+	require.Equal(t, "preauthcode@@@xxx", code)
+	require.Equal(t, 1800, expiresIn)
+
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiCreatePreAuthCode).Unmarshal(&payload))
+	require.Equal(t, testComponentAppid, payload["component_appid"])
 }
 
 func TestGetAuthorizerList(t *testing.T) {
-	open := initWxOpen()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiApiGetAuthorizerList, map[string]interface{}{
+		"total_count": 1,
+		"list": []map[string]interface{}{
+			{"authorizer_appid": "wxauthorizer", "refresh_token": "refresh_token", "auth_time": 1558000607},
+		},
+	})
+
+	open := initWxOpen(t, server)
 	details, err := open.GetAuthorizerList(context.Background(), 0, 10)
-	require.Empty(t, err)
-	for _, detail := range details {
-		fmt.Printf(
-			"%s %d %s\n",
-			detail.AuthorizerAppid,
-			detail.AuthTime,
-			detail.AuthorizerRefreshToken,
-		)
-	}
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, len(details))
+	require.Equal(t, "wxauthorizer", details[0].AuthorizerAppid)
+	require.Equal(t, "refresh_token", details[0].AuthorizerRefreshToken)
+
+	payload := map[string]interface{}{}
+	require.Equal(t, nil, server.LastRequest(apiApiGetAuthorizerList).Unmarshal(&payload))
+	require.Equal(t, float64(10), payload["count"])
 }
 
 func TestGetAuthorizerInfo(t *testing.T) {
-	open := initWxOpen()
-	detail, err := open.GetAuthorizerInfo(context.Background(), test.WxOpenOAAppid)
-	require.Empty(t, err)
-	fmt.Printf(
-		"%v\n%v\n",
-		detail.AuthorizationInfo,
-		detail.AuthorizerInfo,
-	)
-
-	detail2, err := open.GetAuthorizerMpInfo(context.Background(), test.WxOpenMpAppid)
-	require.Empty(t, err)
-	fmt.Printf(
-		"%v\n%v\n",
-		detail2.AuthorizationInfo,
-		detail2.AuthorizerInfo,
-	)
+	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiApiGetAuthorizerInfo, map[string]interface{}{
+		"authorization_info": map[string]interface{}{"authorizer_appid": "wxauthorizer"},
+		"authorizer_info": map[string]interface{}{
+			"nick_name":         "测试小程序",
+			"service_type_info": map[string]interface{}{"id": 0},
+			"MiniProgramInfo": map[string]interface{}{
+				"categories": []map[string]interface{}{{"first": "教育", "second": "培训机构"}},
+			},
+		},
+	})
+
+	open := initWxOpen(t, server)
+	detail, err := open.GetAuthorizerInfo(ctx, "wxauthorizer")
+	require.Equal(t, nil, err)
+	require.Equal(t, "wxauthorizer", detail.AuthorizationInfo.AuthorizerAppid)
+	require.Equal(t, "测试小程序", detail.AuthorizerInfo.NickName)
+	require.NotEqual(t, nil, detail.AuthorizerInfo.MiniProgramInfo)
+
+	detail2, err := open.GetAuthorizerMpInfo(ctx, "wxauthorizer")
+	require.Equal(t, nil, err)
+	require.Equal(t, "培训机构", detail2.AuthorizerInfo.MiniProgramInfo.Categories[0].Second)
 }
@@ -0,0 +1,147 @@
+package wxopen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+var (
+	ErrRefreshTokenStoreMissing = errors.New("refresh token store NOT set, call SetRefreshTokenStore first")
+	ErrAuthorizerFactoryMissing = errors.New("authorizer factory NOT set, call SetAuthorizerFactory first")
+)
+
+// AuthorizerClient 授权方(公众号/小程序)的客户端, weixin/authorizer.Authorizer 实现了该接口
+type AuthorizerClient interface {
+	UpdateAccessToken(ctx context.Context, token string, expiresIn int) error
+	ClearAccessToken(ctx context.Context) error
+}
+
+// AuthorizerFactory 创建授权方的客户端, refresh 使用保存的 authorizer_refresh_token 获取 access token
+// 由 weixin/authorizer.WxOpenFactory 实现, wxopen 不依赖 weixin/authorizer
+type AuthorizerFactory func(
+	cache utils.Cache, locker utils.Lock,
+	componentAppid, appid string,
+	refresh func(context.Context) (string, int, error),
+	options ...utils.ClientOption,
+) AuthorizerClient
+
+// SetRefreshTokenStore 保存 authorizer_refresh_token, 开启 Authorizer 工厂
+func (wxopen *WxOpen) SetRefreshTokenStore(store utils.RefreshTokenStore) {
+	wxopen.refreshTokenStore = store
+}
+
+// SetAuthorizerFactory 设置创建授权方客户端的工厂, 一般为 authorizer.WxOpenFactory
+func (wxopen *WxOpen) SetAuthorizerFactory(factory AuthorizerFactory) {
+	wxopen.authorizerFactory = factory
+}
+
+func (wxopen *WxOpen) authorizerRefreshTokenKey(appid string) string {
+	return fmt.Sprintf("refresh-token.authorizer.%s.%s", wxopen.Config.Appid, appid)
+}
+
+// Authorizer 授权方(公众号/小程序)的客户端, 第一次调用时创建
+// access token 过期后用保存的 authorizer_refresh_token 刷新, 刷新时返回新的 refresh token 会同步更新
+// 参考 authorizer.FromWxOpen
+func (wxopen *WxOpen) Authorizer(appid string) (AuthorizerClient, error) {
+	if err := wxopen.checkAuthorizerFactory(); err != nil {
+		return nil, err
+	}
+
+	wxopen.authorizersLock.Lock()
+	defer wxopen.authorizersLock.Unlock()
+	if instance, ok := wxopen.authorizers[appid]; ok {
+		return instance, nil
+	}
+
+	instance := wxopen.newAuthorizer(appid)
+	wxopen.authorizers[appid] = instance
+	return instance, nil
+}
+
+func (wxopen *WxOpen) checkAuthorizerFactory() error {
+	if wxopen.refreshTokenStore == nil {
+		return fmt.Errorf(
+			"wxopen appid : %s, error: %w", wxopen.Config.Appid, ErrRefreshTokenStoreMissing,
+		)
+	}
+	if wxopen.authorizerFactory == nil {
+		return fmt.Errorf(
+			"wxopen appid : %s, error: %w", wxopen.Config.Appid, ErrAuthorizerFactoryMissing,
+		)
+	}
+	return nil
+}
+
+func (wxopen *WxOpen) newAuthorizer(appid string) AuthorizerClient {
+	return wxopen.authorizerFactory(
+		wxopen.cache, wxopen.locker, wxopen.Config.Appid, appid,
+		wxopen.refreshAuthorizerToken(appid), wxopen.options...,
+	)
+}
+
+func (wxopen *WxOpen) refreshAuthorizerToken(appid string) func(context.Context) (string, int, error) {
+	key := wxopen.authorizerRefreshTokenKey(appid)
+	return func(ctx context.Context) (string, int, error) {
+		refreshToken, err := wxopen.refreshTokenStore.GetRefreshToken(ctx, key)
+		if err != nil {
+			return "", 0, fmt.Errorf("authorizer appid : %s, error: %w", appid, err)
+		}
+
+		resp, err := wxopen.GetAuthorizerToken(ctx, appid, refreshToken)
+		if err != nil {
+			return "", 0, err
+		}
+
+		// refresh token 可能轮换, 需要保存最新的
+		if resp.RefreshToken != "" && resp.RefreshToken != refreshToken {
+			if err = wxopen.refreshTokenStore.SetRefreshToken(ctx, key, resp.RefreshToken); err != nil {
+				return "", 0, err
+			}
+		}
+		return resp.AccessToken, resp.ExpiresIn, nil
+	}
+}
+
+// SaveAuthorization 保存 QueryAuth 返回的 refresh token 和 access token
+func (wxopen *WxOpen) SaveAuthorization(ctx context.Context, info *AuthorizationInfo) error {
+	instance, err := wxopen.Authorizer(info.AuthorizerAppid)
+	if err != nil {
+		return err
+	}
+
+	err = wxopen.refreshTokenStore.SetRefreshToken(
+		ctx, wxopen.authorizerRefreshTokenKey(info.AuthorizerAppid), info.AuthorizerRefreshToken,
+	)
+	if err != nil {
+		return err
+	}
+	if info.AuthorizerAccessToken == "" {
+		return nil
+	}
+	return instance.UpdateAccessToken(ctx, info.AuthorizerAccessToken, info.ExpiresIn)
+}
+
+// RemoveAuthorizer 取消授权(EventTypeUnauthorized)时, 清除 refresh token 和 access token
+func (wxopen *WxOpen) RemoveAuthorizer(ctx context.Context, appid string) error {
+	if err := wxopen.checkAuthorizerFactory(); err != nil {
+		return err
+	}
+
+	wxopen.authorizersLock.Lock()
+	instance, ok := wxopen.authorizers[appid]
+	delete(wxopen.authorizers, appid)
+	wxopen.authorizersLock.Unlock()
+	if !ok {
+		// 本实例没有使用过, access token 可能是其他实例缓存的, 只用来清除, 不保存
+		instance = wxopen.newAuthorizer(appid)
+	}
+
+	err := wxopen.refreshTokenStore.DeleteRefreshToken(ctx, wxopen.authorizerRefreshTokenKey(appid))
+	if err != nil {
+		return err
+	}
+	return instance.ClearAccessToken(ctx)
+}
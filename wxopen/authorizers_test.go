@@ -0,0 +1,115 @@
+package wxopen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuthorizer 测试用的授权方客户端, wxopen 的测试不能依赖 weixin/authorizer(weixin/authorizer 依赖 wxopen)
+type testAuthorizer struct {
+	*utils.Client
+	accessTokenCache *utils.AccessTokenCache
+}
+
+type testAuthorizerTokenGetter struct {
+	appid   string
+	refresh func(context.Context) (string, int, error)
+}
+
+func (g *testAuthorizerTokenGetter) GetAccessToken(ctx context.Context) (string, int, error) {
+	return g.refresh(ctx)
+}
+
+func (g *testAuthorizerTokenGetter) GetAccessTokenKey() string {
+	return fmt.Sprintf("access-token.test-authorizer.%s", g.appid)
+}
+
+func (g *testAuthorizerTokenGetter) GetAccessTokenLockKey() string {
+	return g.GetAccessTokenKey() + ".lock"
+}
+
+func testAuthorizerFactory(
+	cache utils.Cache, locker utils.Lock,
+	componentAppid, appid string,
+	refresh func(context.Context) (string, int, error),
+	options ...utils.ClientOption,
+) AuthorizerClient {
+	accessTokenCache := utils.NewAccessTokenCache(
+		&testAuthorizerTokenGetter{appid: appid, refresh: refresh}, cache, locker,
+	)
+	return &testAuthorizer{
+		Client:           utils.NewClient(WXServerUrl, accessTokenCache, options...),
+		accessTokenCache: accessTokenCache,
+	}
+}
+
+func (a *testAuthorizer) UpdateAccessToken(ctx context.Context, token string, expiresIn int) error {
+	_, err := a.accessTokenCache.UpdateAccessToken(ctx, token, expiresIn)
+	return err
+}
+
+func (a *testAuthorizer) ClearAccessToken(ctx context.Context) error {
+	return a.accessTokenCache.ClearAccessToken(ctx)
+}
+
+func TestAuthorizerFactory(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON("/cgi-bin/test", &utils.WeixinError{})
+
+	cache := memory.NewMemory()
+	open := New(cache, cache, &Config{Appid: "wxcomponent", Secret: "secret"}, nil, server.ClientOption())
+	require.Equal(t, nil, open.UpdateTicket(ctx, "ticket"))
+
+	_, err := open.Authorizer("wxauthorizer")
+	require.True(t, errors.Is(err, ErrRefreshTokenStoreMissing))
+	store := utils.NewCacheRefreshTokenStore(cache)
+	open.SetRefreshTokenStore(store)
+	_, err = open.Authorizer("wxauthorizer")
+	require.True(t, errors.Is(err, ErrAuthorizerFactoryMissing))
+	open.SetAuthorizerFactory(testAuthorizerFactory)
+
+	require.Equal(t, nil, open.SaveAuthorization(ctx, &AuthorizationInfo{
+		AuthorizerAppid:        "wxauthorizer",
+		AuthorizerRefreshToken: "refresh_token",
+	}))
+
+	client, err := open.Authorizer("wxauthorizer")
+	require.Equal(t, nil, err)
+	cached, _ := open.Authorizer("wxauthorizer")
+	require.True(t, client == cached)
+	api := client.(*testAuthorizer)
+
+	// 使用保存的 refresh token 获取 access token, 并保存轮换之后的 refresh token
+	require.Equal(t, nil, api.Client.HTTPGet(ctx, "/cgi-bin/test", nil))
+	payload := map[string]string{}
+	request := server.LastRequest("/cgi-bin/component/api_authorizer_token")
+	require.Equal(t, nil, request.Unmarshal(&payload))
+	require.Equal(t, "refresh_token", payload["authorizer_refresh_token"])
+	refreshToken, err := store.GetRefreshToken(ctx, "refresh-token.authorizer.wxcomponent.wxauthorizer")
+	require.Equal(t, nil, err)
+	require.Equal(t, "mock_refresh_token", refreshToken)
+
+	// 取消授权之后, 不能再获取 access token
+	require.Equal(t, nil, open.RemoveAuthorizer(ctx, "wxauthorizer"))
+	client, err = open.Authorizer("wxauthorizer")
+	require.Equal(t, nil, err)
+	require.False(t, client == cached)
+	err = client.(*testAuthorizer).Client.HTTPGet(ctx, "/cgi-bin/test", nil)
+	require.True(t, errors.Is(err, utils.ErrRefreshTokenNotFound))
+
+	// 没有使用过的授权方, 清除 access token 时不会缓存实例
+	require.Equal(t, nil, open.RemoveAuthorizer(ctx, "wxother"))
+	open.authorizersLock.Lock()
+	_, ok := open.authorizers["wxother"]
+	open.authorizersLock.Unlock()
+	require.False(t, ok)
+}
@@ -2,17 +2,25 @@ package wxopen
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
 func TestFastRegisterBetaWeapp(t *testing.T) {
-	open := initWxOpen()
-	ctx := context.Background()
-	result, err := open.FastRegisterBetaWeapp(ctx, "叮当当", test.WxOpenOAOpenID)
-	require.Empty(t, err)
-	fmt.Println(result)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiFastRegisterBetaWeapp, map[string]interface{}{
+		"unique_id": "unique_id", "authorize_url": "https://mp.weixin.qq.com/authorize",
+	})
+
+	open := initWxOpen(t, server)
+	result, err := open.FastRegisterBetaWeapp(context.Background(), "叮当当", "openid")
+	require.Equal(t, nil, err)
+	require.Equal(t, "unique_id", result.UniqueID)
+
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiFastRegisterBetaWeapp).Unmarshal(&payload))
+	require.Equal(t, map[string]string{"name": "叮当当", "openid": "openid"}, payload)
 }
@@ -2,41 +2,69 @@ package wxopen
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"testing"
 
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetTemplateDraftList(t *testing.T) {
-	open := initWxOpen()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiGetTemplateDraftList, map[string]interface{}{
+		"draft_list": []map[string]interface{}{
+			{"draft_id": 0, "user_version": "v1.0.0"},
+			{"draft_id": 1, "user_version": "v1.0.1"},
+		},
+	})
+
+	open := initWxOpen(t, server)
 	drafts, err := open.GetTemplateDraftList(context.Background())
-	require.Empty(t, err)
-	fmt.Printf("%v\n", drafts)
+	require.Equal(t, nil, err)
+	require.Equal(t, 2, len(drafts))
+	require.Equal(t, "v1.0.1", drafts[1].UserVersion)
 }
 
 func TestAddToTemplate(t *testing.T) {
-	open := initWxOpen()
-	drafts, err := open.GetTemplateDraftList(context.Background())
-	require.Empty(t, err)
-	require.NotEmpty(t, drafts)
-
-	// get last DraftID from drafts
-	draft_id := drafts[len(drafts)-1].DraftID
-	fmt.Println(draft_id)
-	err = open.AddToTemplate(context.Background(), draft_id)
-	require.Empty(t, err)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiAddToTemplate, &utils.WeixinError{})
+
+	open := initWxOpen(t, server)
+	err := open.AddToTemplate(context.Background(), 1)
+	require.Equal(t, nil, err)
+
+	payload := map[string]int32{}
+	require.Equal(t, nil, server.LastRequest(apiAddToTemplate).Unmarshal(&payload))
+	require.Equal(t, int32(1), payload["draft_id"])
 }
 
 func TestGetTemplateList(t *testing.T) {
-	open := initWxOpen()
-	drafts, err := open.GetTemplateList(context.Background())
-	require.Empty(t, err)
-	fmt.Printf("%v\n", drafts)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiGetTemplateList, map[string]interface{}{
+		"template_list": []map[string]interface{}{
+			{"template_id": 3, "template_type": 0, "user_version": "v1.0.0"},
+		},
+	})
+
+	open := initWxOpen(t, server)
+	templates, err := open.GetTemplateList(context.Background())
+	require.Equal(t, nil, err)
+	require.Equal(t, 1, len(templates))
+	require.Equal(t, int32(3), templates[0].TemplateID)
 }
 
 func TestDeleteTemplate(t *testing.T) {
-	open := initWxOpen()
-	// 实际测试时，请把 -1 替换成自己的模板ID
-	open.DeleteTemplate(context.Background(), -1)
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiDeleteTemplate, &utils.WeixinError{ErrCode: 85064, ErrMsg: "template not found"})
+
+	open := initWxOpen(t, server)
+	err := open.DeleteTemplate(context.Background(), -1)
+	var wxError *utils.WeixinError
+	require.True(t, errors.As(err, &wxError))
+	require.Equal(t, int64(85064), wxError.ErrCode)
 }
@@ -0,0 +1,305 @@
+package wxopen
+
+// 授权生命周期, 处理第三方平台的授权事件
+//
+//	component_verify_ticket: 保存 ticket
+//	authorized / updateauthorized: QueryAuth 之后保存 refresh token, access token, 授权方信息和权限集
+//	unauthorized: 清除 token 和授权信息
+//
+// 需要先调用 SetRefreshTokenStore 和 SetAuthorizerFactory, 业务逻辑通过 OnAuthorized / OnRevoked / OnPermissionsChanged 处理
+// 授权码换取成功之后的错误不返回给微信(授权码只能使用一次), 通过 LifecycleOptWithErrorHandler 获取
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lixinio/weixin/utils"
+)
+
+// 和 refresh token 一样长期保存, 每次授权事件都会重新设置
+const authorizationExpiresIn = 365 * 24 * time.Hour
+
+// Authorization 授权方的信息和权限集
+type Authorization struct {
+	AuthorizerAppid string          `json:"authorizer_appid"`
+	FuncInfo        []int           `json:"func_info"`       // 权限集 id
+	AuthorizerInfo  *AuthorizerInfo `json:"authorizer_info"` // 基本信息, 公众号和小程序通用的部分
+	UpdateTime      int64           `json:"update_time"`     // 最后一次授权事件的时间
+}
+
+// HasFunc 是否授权了权限集
+func (a *Authorization) HasFunc(id int) bool {
+	for _, funcID := range a.FuncInfo {
+		if funcID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationStore 保存授权方的信息
+type AuthorizationStore interface {
+	GetAuthorization(ctx context.Context, appid string) (*Authorization, error) // 不存在时返回 (nil, nil)
+	SaveAuthorization(ctx context.Context, authorization *Authorization) error
+	DeleteAuthorization(ctx context.Context, appid string) error
+}
+
+// CacheAuthorizationStore 以 json 格式保存在 Cache 中
+type CacheAuthorizationStore struct {
+	cache          utils.Cache
+	componentAppid string
+}
+
+func NewCacheAuthorizationStore(cache utils.Cache, componentAppid string) *CacheAuthorizationStore {
+	return &CacheAuthorizationStore{cache: cache, componentAppid: componentAppid}
+}
+
+func (s *CacheAuthorizationStore) key(appid string) string {
+	return fmt.Sprintf("authorization.%s.%s", s.componentAppid, appid)
+}
+
+func (s *CacheAuthorizationStore) GetAuthorization(
+	ctx context.Context, appid string,
+) (*Authorization, error) {
+	data := ""
+	ok, err := s.cache.Get(ctx, s.key(appid), &data)
+	if err != nil || !ok {
+		return nil, err
+	}
+	authorization := &Authorization{}
+	if err = json.Unmarshal([]byte(data), authorization); err != nil {
+		return nil, err
+	}
+	return authorization, nil
+}
+
+func (s *CacheAuthorizationStore) SaveAuthorization(
+	ctx context.Context, authorization *Authorization,
+) error {
+	data, err := json.Marshal(authorization)
+	if err != nil {
+		return err
+	}
+	return s.cache.Set(
+		ctx, s.key(authorization.AuthorizerAppid), string(data), authorizationExpiresIn,
+	)
+}
+
+func (s *CacheAuthorizationStore) DeleteAuthorization(ctx context.Context, appid string) error {
+	return s.cache.Delete(ctx, s.key(appid))
+}
+
+type (
+	LifecycleOption  func(*lifecycleOptions)
+	lifecycleOptions struct {
+		onAuthorized         func(ctx context.Context, authorization *Authorization) error
+		onRevoked            func(ctx context.Context, authorization *Authorization) error
+		onPermissionsChanged func(ctx context.Context, authorization *Authorization, added, removed []int) error
+		errorHandler         func(ctx context.Context, appid string, err error)
+	}
+)
+
+// LifecycleOptWithOnAuthorized 新授权(authorized)
+func LifecycleOptWithOnAuthorized(
+	hook func(ctx context.Context, authorization *Authorization) error,
+) LifecycleOption {
+	return func(o *lifecycleOptions) {
+		o.onAuthorized = hook
+	}
+}
+
+// LifecycleOptWithOnRevoked 取消授权(unauthorized), authorization 为之前保存的信息, 没有时只有 appid
+func LifecycleOptWithOnRevoked(
+	hook func(ctx context.Context, authorization *Authorization) error,
+) LifecycleOption {
+	return func(o *lifecycleOptions) {
+		o.onRevoked = hook
+	}
+}
+
+// LifecycleOptWithOnPermissionsChanged 授权更新(updateauthorized)之后权限集有变化
+func LifecycleOptWithOnPermissionsChanged(
+	hook func(ctx context.Context, authorization *Authorization, added, removed []int) error,
+) LifecycleOption {
+	return func(o *lifecycleOptions) {
+		o.onPermissionsChanged = hook
+	}
+}
+
+// LifecycleOptWithErrorHandler 授权码已经换取(QueryAuth)之后的失败, 包括保存授权信息和 hook 的错误
+// 授权码只能使用一次, 这些错误不会返回给微信重试, 只通过 errorHandler 通知, 未设置时忽略
+func LifecycleOptWithErrorHandler(
+	errorHandler func(ctx context.Context, appid string, err error),
+) LifecycleOption {
+	return func(o *lifecycleOptions) {
+		o.errorHandler = errorHandler
+	}
+}
+
+// LifecycleHandler 授权生命周期
+type LifecycleHandler struct {
+	wxopen  *WxOpen
+	store   AuthorizationStore
+	options lifecycleOptions
+}
+
+func NewLifecycleHandler(
+	wxopen *WxOpen, store AuthorizationStore, opts ...LifecycleOption,
+) *LifecycleHandler {
+	handler := &LifecycleHandler{wxopen: wxopen, store: store}
+	for _, o := range opts {
+		o(&handler.options)
+	}
+	return handler
+}
+
+// Handle 处理(解密之后的)回调, 不是授权相关的事件返回 false, 由调用方继续处理
+func (h *LifecycleHandler) Handle(ctx context.Context, body []byte) (bool, error) {
+	event, content, err := h.wxopen.ParseXML(body)
+	if err != nil {
+		return false, err
+	}
+
+	switch v := content.(type) {
+	case *EventComponentVerifyTicket:
+		return true, h.wxopen.UpdateTicket(ctx, v.ComponentVerifyTicket)
+	case *EventAuthorized:
+		return true, h.authorize(ctx, event, v.AuthorizerAppid, v.AuthorizationCode, false)
+	case *EventUpdateAuthorized:
+		return true, h.authorize(ctx, event, v.AuthorizerAppid, v.AuthorizationCode, true)
+	case *EventUnauthorized:
+		return true, h.revoke(ctx, v.AuthorizerAppid)
+	}
+	return false, nil
+}
+
+// XmlHandler 用于 ServeData, 处理之后回复 success, 其他的事件交给 next (为 nil 时直接回复 success)
+func (h *LifecycleHandler) XmlHandler(next utils.XmlHandlerFunc) utils.XmlHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) error {
+		handled, err := h.Handle(r.Context(), body)
+		if err != nil {
+			return err
+		}
+		if !handled && next != nil {
+			return next(w, r, body)
+		}
+		_, err = io.WriteString(w, "success")
+		return err
+	}
+}
+
+// 只有换取授权码失败时返回错误(微信重试), 之后授权码已经失效, 重试也无法恢复,
+// 保存授权信息和 hook 的错误交给 errorHandler, 回复 success
+func (h *LifecycleHandler) authorize(
+	ctx context.Context, event *Event, appid, code string, update bool,
+) error {
+	info, err := h.wxopen.QueryAuth(ctx, code)
+	if err != nil {
+		return err
+	}
+	if err = h.afterAuthorize(ctx, event, appid, info, update); err != nil && h.options.errorHandler != nil {
+		h.options.errorHandler(ctx, appid, err)
+	}
+	return nil
+}
+
+func (h *LifecycleHandler) afterAuthorize(
+	ctx context.Context, event *Event, appid string, info *AuthorizationInfo, update bool,
+) error {
+	// 先保存 refresh token, 后面的步骤失败也不影响调用授权方的接口
+	if err := h.wxopen.SaveAuthorization(ctx, info); err != nil {
+		return err
+	}
+	detail, err := h.wxopen.GetAuthorizerInfo(ctx, appid)
+	if err != nil {
+		return err
+	}
+
+	previous, err := h.store.GetAuthorization(ctx, appid)
+	if err != nil {
+		return err
+	}
+	updateTime, _ := strconv.ParseInt(event.CreateTime, 10, 64)
+	authorization := &Authorization{
+		AuthorizerAppid: appid,
+		FuncInfo:        funcInfoIDs(info.FuncInfo),
+		AuthorizerInfo:  detail.AuthorizerInfo,
+		UpdateTime:      updateTime,
+	}
+	if err = h.store.SaveAuthorization(ctx, authorization); err != nil {
+		return err
+	}
+
+	if !update {
+		if h.options.onAuthorized != nil {
+			return h.options.onAuthorized(ctx, authorization)
+		}
+		return nil
+	}
+
+	var before []int
+	if previous != nil {
+		before = previous.FuncInfo
+	}
+	added, removed := diffFuncInfo(before, authorization.FuncInfo)
+	if (len(added) > 0 || len(removed) > 0) && h.options.onPermissionsChanged != nil {
+		return h.options.onPermissionsChanged(ctx, authorization, added, removed)
+	}
+	return nil
+}
+
+func (h *LifecycleHandler) revoke(ctx context.Context, appid string) error {
+	if err := h.wxopen.RemoveAuthorizer(ctx, appid); err != nil {
+		return err
+	}
+
+	authorization, err := h.store.GetAuthorization(ctx, appid)
+	if err != nil {
+		return err
+	}
+	if authorization == nil {
+		authorization = &Authorization{AuthorizerAppid: appid}
+	}
+	if err = h.store.DeleteAuthorization(ctx, appid); err != nil {
+		return err
+	}
+
+	if h.options.onRevoked != nil {
+		return h.options.onRevoked(ctx, authorization)
+	}
+	return nil
+}
+
+func funcInfoIDs(funcInfo []AuthorizationFuncInfo) []int {
+	ids := make([]int, 0, len(funcInfo))
+	for _, f := range funcInfo {
+		ids = append(ids, f.FuncscopeCategory.ID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// diffFuncInfo 新增和取消的权限集
+func diffFuncInfo(before, after []int) (added, removed []int) {
+	exists := map[int]bool{}
+	for _, id := range before {
+		exists[id] = true
+	}
+	for _, id := range after {
+		if !exists[id] {
+			added = append(added, id)
+		}
+		delete(exists, id)
+	}
+	for _, id := range before {
+		if exists[id] {
+			removed = append(removed, id)
+		}
+	}
+	return
+}
@@ -0,0 +1,132 @@
+package wxopen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleHandler(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New()
+	defer server.Close()
+
+	funcInfo := []int{1, 2}
+	server.Handle("/cgi-bin/component/api_query_auth", func(w http.ResponseWriter, r *http.Request) {
+		info := &AuthorizationInfo{
+			AuthorizerAppid:        "wxauthorizer",
+			AuthorizerRefreshToken: "refresh_token",
+			AuthorizerAccessToken:  "access_token",
+			ExpiresIn:              7200,
+		}
+		for _, id := range funcInfo {
+			f := AuthorizationFuncInfo{}
+			f.FuncscopeCategory.ID = id
+			info.FuncInfo = append(info.FuncInfo, f)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"authorization_info": info})
+	})
+	server.HandleJSON("/cgi-bin/component/api_get_authorizer_info", map[string]interface{}{
+		"authorizer_info": map[string]interface{}{"nick_name": "测试公众号"},
+	})
+
+	cache := memory.NewMemory()
+	open := New(cache, cache, &Config{Appid: "wxcomponent", Secret: "secret"}, nil, server.ClientOption())
+	open.SetRefreshTokenStore(utils.NewCacheRefreshTokenStore(cache))
+	open.SetAuthorizerFactory(testAuthorizerFactory)
+	store := NewCacheAuthorizationStore(cache, "wxcomponent")
+
+	events := []string{}
+	handler := NewLifecycleHandler(
+		open, store,
+		LifecycleOptWithOnAuthorized(func(ctx context.Context, a *Authorization) error {
+			events = append(events, "authorized")
+			require.Equal(t, []int{1, 2}, a.FuncInfo)
+			require.Equal(t, "测试公众号", a.AuthorizerInfo.NickName)
+			return nil
+		}),
+		LifecycleOptWithOnPermissionsChanged(func(ctx context.Context, a *Authorization, added, removed []int) error {
+			events = append(events, "changed")
+			require.Equal(t, []int{3}, added)
+			require.Equal(t, []int{1}, removed)
+			return nil
+		}),
+		LifecycleOptWithOnRevoked(func(ctx context.Context, a *Authorization) error {
+			events = append(events, "revoked")
+			require.True(t, a.HasFunc(3))
+			return nil
+		}),
+	)
+
+	// ticket
+	handled, err := handler.Handle(ctx, []byte(`<xml><AppId>wxcomponent</AppId><CreateTime>1413192605</CreateTime>`+
+		`<InfoType>component_verify_ticket</InfoType><ComponentVerifyTicket>ticket</ComponentVerifyTicket></xml>`))
+	require.Equal(t, nil, err)
+	require.True(t, handled)
+
+	// 授权
+	handled, err = handler.Handle(ctx, []byte(`<xml><AppId>wxcomponent</AppId><CreateTime>1413192760</CreateTime>`+
+		`<InfoType>authorized</InfoType><AuthorizerAppid>wxauthorizer</AuthorizerAppid>`+
+		`<AuthorizationCode>code</AuthorizationCode></xml>`))
+	require.Equal(t, nil, err)
+	require.True(t, handled)
+	authorization, err := store.GetAuthorization(ctx, "wxauthorizer")
+	require.Equal(t, nil, err)
+	require.Equal(t, int64(1413192760), authorization.UpdateTime)
+
+	// 更新授权
+	funcInfo = []int{2, 3}
+	_, err = handler.Handle(ctx, []byte(`<xml><AppId>wxcomponent</AppId><CreateTime>1413192800</CreateTime>`+
+		`<InfoType>updateauthorized</InfoType><AuthorizerAppid>wxauthorizer</AuthorizerAppid>`+
+		`<AuthorizationCode>code</AuthorizationCode></xml>`))
+	require.Equal(t, nil, err)
+
+	// 取消授权
+	_, err = handler.Handle(ctx, []byte(`<xml><AppId>wxcomponent</AppId><CreateTime>1413192900</CreateTime>`+
+		`<InfoType>unauthorized</InfoType><AuthorizerAppid>wxauthorizer</AuthorizerAppid></xml>`))
+	require.Equal(t, nil, err)
+	authorization, err = store.GetAuthorization(ctx, "wxauthorizer")
+	require.Equal(t, nil, err)
+	require.True(t, authorization == nil)
+	_, err = open.refreshTokenStore.GetRefreshToken(ctx, open.authorizerRefreshTokenKey("wxauthorizer"))
+	require.True(t, errors.Is(err, utils.ErrRefreshTokenNotFound))
+
+	require.Equal(t, []string{"authorized", "changed", "revoked"}, events)
+
+	// 授权码换取之后 hook 失败, 不返回给微信重试, refresh token 已保存
+	hookErr := errors.New("hook failed")
+	failures := []error{}
+	handler = NewLifecycleHandler(
+		open, store,
+		LifecycleOptWithOnAuthorized(func(ctx context.Context, a *Authorization) error {
+			return hookErr
+		}),
+		LifecycleOptWithErrorHandler(func(ctx context.Context, appid string, err error) {
+			require.Equal(t, "wxauthorizer", appid)
+			failures = append(failures, err)
+		}),
+	)
+	handled, err = handler.Handle(ctx, []byte(`<xml><AppId>wxcomponent</AppId><CreateTime>1413193000</CreateTime>`+
+		`<InfoType>authorized</InfoType><AuthorizerAppid>wxauthorizer</AuthorizerAppid>`+
+		`<AuthorizationCode>code</AuthorizationCode></xml>`))
+	require.Equal(t, nil, err)
+	require.True(t, handled)
+	require.Equal(t, 1, len(failures))
+	require.True(t, errors.Is(failures[0], hookErr))
+	refreshToken, err := open.refreshTokenStore.GetRefreshToken(ctx, open.authorizerRefreshTokenKey("wxauthorizer"))
+	require.Equal(t, nil, err)
+	require.Equal(t, "refresh_token", refreshToken)
+
+	// 其他事件由调用方处理
+	handled, err = handler.Handle(ctx, []byte(`<xml><AppId>wxcomponent</AppId><CreateTime>1413192900</CreateTime>`+
+		`<InfoType>notify_third_fasteregister</InfoType></xml>`))
+	require.Equal(t, nil, err)
+	require.False(t, handled)
+}
@@ -2,7 +2,6 @@ package wxopen
 
 import (
 	"encoding/xml"
-	"errors"
 	"io"
 	"net/http"
 
@@ -11,17 +10,19 @@ import (
 
 // https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/api/ThirdParty/token/authorize_event.html
 // https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/api/ThirdParty/token/component_verify_ticket.html
-/*
-启用 加密模式 后 收到的 消息格式
-<xml>
-    <ToUserName><![CDATA[]]></ToUserName>
-    <Encrypt><![CDATA[]]></Encrypt>
-</xml>
-*/
-type EncryptMessage struct {
-	XMLName    xml.Name `xml:"xml"`
-	ToUserName string
-	Encrypt    string
+type EncryptMessage = utils.EncryptMessage
+
+// SetReplayGuard 开启回调的防重放检查
+func (wxopen *WxOpen) SetReplayGuard(guard *utils.ReplayGuard) {
+	wxopen.replayGuard = guard
+}
+
+// crypto 签名和加解密, receiveid 为第三方平台的 appid
+func (wxopen *WxOpen) crypto() *utils.CallbackCrypto {
+	return utils.NewCallbackCrypto(
+		wxopen.Config.Token, wxopen.Config.EncodingAESKey,
+		wxopen.Config.Appid, wxopen.Config.Appid,
+	)
 }
 
 func (wxopen *WxOpen) ServeData(
@@ -40,27 +41,36 @@ func (wxopen *WxOpen) ServeData(
 		return err
 	}
 
-	msgSignature := r.URL.Query().Get("msg_signature")
-	timestamp := r.URL.Query().Get("timestamp")
-	nonce := r.URL.Query().Get("nonce")
-	if utils.CalcSignature(
-		wxopen.Config.Token,
-		timestamp,
-		nonce,
-		encryptMsg.Encrypt,
-	) != msgSignature {
-		return errors.New("invalid msg signature")
-	}
-
-	// 解密
-	var xmlMsg []byte
-	_, xmlMsg, _, err = utils.AESDecryptMsg(
-		encryptMsg.Encrypt, wxopen.Config.EncodingAESKey,
-	)
+	// 验证签名, 解密
+	query := r.URL.Query()
+	xmlMsg, _, err := wxopen.crypto().OpenEnvelope(r.Context(), query, encryptMsg)
 	if err != nil {
 		return err
 	}
-	return processor(w, r, xmlMsg)
+	return wxopen.replayGuard.Guard(
+		r.Context(), query.Get("timestamp"), query.Get("nonce"), query.Get("msg_signature"),
+		func() error {
+			return processor(w, r, xmlMsg)
+		},
+	)
+}
+
+// ServeDataAsync 校验/解密之后交给 queue 异步处理, 立即回复 success
+func (wxopen *WxOpen) ServeDataAsync(
+	w http.ResponseWriter,
+	r *http.Request,
+	queue utils.CallbackQueue,
+) error {
+	return wxopen.ServeData(w, r, utils.NewAsyncXmlHandler(queue, "success"))
+}
+
+// Response 加密回复, reply 为 nil 时回复 success
+func (wxopen *WxOpen) Response(
+	w http.ResponseWriter,
+	r *http.Request,
+	reply interface{},
+) error {
+	return wxopen.crypto().WriteReply(w, reply, "", "success")
 }
 
 // ParseXML 解析微信推送过来的消息/事件
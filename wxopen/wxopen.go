@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/lixinio/weixin/utils"
 )
@@ -40,22 +41,38 @@ type WxOpen struct {
 	Client           *utils.Client
 	ticketCache      *utils.AccessTokenCache
 	accessTokenCache *utils.AccessTokenCache
+	replayGuard      *utils.ReplayGuard
+
+	// 授权方, 参考 Authorizer
+	cache             utils.Cache
+	locker            utils.Lock
+	options           []utils.ClientOption
+	refreshTokenStore utils.RefreshTokenStore
+	authorizerFactory AuthorizerFactory
+	authorizersLock   sync.Mutex
+	authorizers       map[string]AuthorizerClient
 }
 
 func New(
 	cache utils.Cache, locker utils.Lock, config *Config,
 	tokenRefreshHandler utils.TokenRefreshHandler, // 刷新callback
+	options ...utils.ClientOption,
 ) *WxOpen {
+	clientOptions := utils.ClientOptionsWithAppID(config.Appid, options)
 	ticketCache := utils.NewAccessTokenCache(newTicketAdapter(config.Appid), cache, locker)
 	accessTokenCache := utils.NewAccessTokenCache(
-		newAccessTokenAdaptor(config, ticketCache), cache, locker,
+		newAccessTokenAdaptor(config, ticketCache, clientOptions...), cache, locker,
 		utils.CacheClientTokenOptWithExpireBefore(tokenRefreshHandler),
 	)
 	instance := &WxOpen{
 		Config:           config,
-		Client:           utils.NewClient(WXServerUrl, accessTokenCache),
+		Client:           utils.NewClient(WXServerUrl, accessTokenCache, clientOptions...),
 		ticketCache:      ticketCache,
 		accessTokenCache: accessTokenCache,
+		cache:            cache,
+		locker:           locker,
+		options:          options,
+		authorizers:      map[string]AuthorizerClient{},
 	}
 	instance.Client.UpdateAccessTokenKey(accessTokenKey) // token的名称不一样
 	return instance
@@ -65,13 +82,18 @@ func NewLite(
 	cache utils.Cache,
 	locker utils.Lock,
 	appID string,
+	options ...utils.ClientOption,
 ) *WxOpen {
 	config := &Config{Appid: appID}
 	instance := &WxOpen{
 		Config: config,
 		Client: utils.NewClient(WXServerUrl, utils.NewAccessTokenCache(
 			newAccessTokenAdaptor(config, nil), cache, locker,
-		)),
+		), utils.ClientOptionsWithAppID(appID, options)...),
+		cache:       cache,
+		locker:      locker,
+		options:     options,
+		authorizers: map[string]AuthorizerClient{},
 	}
 	instance.Client.UpdateAccessTokenKey(accessTokenKey) // token的名称不一样
 	return instance
@@ -94,6 +94,7 @@ func (ta *accessTokenAdaptor) GetAccessToken(
 func newAccessTokenAdaptor(
 	config *Config,
 	ticketCache *utils.AccessTokenCache,
+	options ...utils.ClientOption,
 ) *accessTokenAdaptor {
 	adaptor := &accessTokenAdaptor{
 		config:      config,
@@ -102,7 +103,9 @@ func newAccessTokenAdaptor(
 		lockerKey:   fmt.Sprintf("weixin.component.access_token.%s.lock", config.Appid),
 	}
 	if ticketCache != nil {
-		adaptor.client = utils.NewClient(WXServerUrl, utils.EmptyClientAccessTokenGetter(0))
+		adaptor.client = utils.NewClient(
+			WXServerUrl, utils.EmptyClientAccessTokenGetter(0), options...,
+		)
 	}
 	return adaptor
 }
@@ -4,31 +4,41 @@ import (
 	"context"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/stretchr/testify/require"
 )
 
-func initWxOpen() *WxOpen {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	wxopen := New(redis, redis, &Config{
-		Appid:          test.WxOpenAppid,
-		Secret:         test.WxOpenSecret,
-		Token:          test.WxOpenToken,
-		EncodingAESKey: test.WxOpenEncodingAESKey,
-	}, nil)
+const (
+	testComponentAppid  = "wxcomponent"
+	testComponentSecret = "secret"
+)
+
+func initWxOpen(t *testing.T, server *mockserver.Server) *WxOpen {
+	cache := memory.NewMemory()
+	wxopen := New(cache, cache, &Config{
+		Appid:  testComponentAppid,
+		Secret: testComponentSecret,
+	}, nil, server.ClientOption())
+	require.Equal(t, nil, wxopen.UpdateTicket(context.Background(), "ticket"))
+	wxopen.SetRefreshTokenStore(utils.NewCacheRefreshTokenStore(cache))
 	return wxopen
 }
 
 func TestStartPushTicket(t *testing.T) {
-	open := initWxOpen()
+	server := mockserver.New()
+	defer server.Close()
+	server.HandleJSON(apiStartPushTicket, &utils.WeixinError{})
+
+	open := initWxOpen(t, server)
 	err := open.StartPushTicket(context.Background())
-	require.Empty(t, err)
-}
+	require.Equal(t, nil, err)
 
-// func TestRefreshToken(t *testing.T) {
-// 	open := initWxOpen()
-// 	token, _, err := open.refreshAccessTokenFromWXServer()
-// 	fmt.Println(token)
-// 	require.Empty(t, err)
-// }
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiStartPushTicket).Unmarshal(&payload))
+	require.Equal(t, map[string]string{
+		"component_appid":  testComponentAppid,
+		"component_secret": testComponentSecret,
+	}, payload)
+}
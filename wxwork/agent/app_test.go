@@ -2,23 +2,33 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	"github.com/stretchr/testify/require"
 )
 
-func TestUser(t *testing.T) {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := New(corp, redis, redis, &Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
-	})
+const (
+	testCorpID      = "wxcorp"
+	testAgentID     = 1000002
+	testAgentSecret = "agent_secret"
+)
+
+func newTestAgent(server *mockserver.Server) *Agent {
+	cache := memory.NewMemory()
+	corp := wxwork.New(&wxwork.Config{Corpid: testCorpID}, server.ClientOption())
+	return New(corp, cache, cache, &Config{AgentID: testAgentID, Secret: testAgentSecret})
+}
+
+func TestMenu(t *testing.T) {
+	server := mockserver.New(mockserver.OptWithApp(testCorpID, testAgentSecret))
+	defer server.Close()
+	server.HandleJSON(apiMenuDelete, map[string]interface{}{})
+	server.HandleJSON(apiMenuCreate, map[string]interface{}{})
+	agent := newTestAgent(server)
 	ctx := context.Background()
 
 	menu := []MenuEntryObj{
@@ -80,5 +90,14 @@ func TestUser(t *testing.T) {
 	}
 
 	require.Equal(t, nil, agent.MenuDelete(ctx, agent.Config.AgentID))
+	require.Equal(t, "1000002", server.LastRequest(apiMenuDelete).Query.Get("agentid"))
+
 	require.Equal(t, nil, agent.MenuCreate(ctx, agent.Config.AgentID, menu))
+	request := server.LastRequest(apiMenuCreate)
+	require.Equal(t, "1000002", request.Query.Get("agentid"))
+	payload := struct {
+		Buttons []MenuEntryObj `json:"button"`
+	}{}
+	require.Equal(t, nil, json.Unmarshal(request.Body, &payload))
+	require.Equal(t, menu, payload.Buttons)
 }
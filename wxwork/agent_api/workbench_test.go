@@ -2,36 +2,59 @@ package agent_api
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	"github.com/lixinio/weixin/wxwork/agent"
 	"github.com/lixinio/weixin/wxwork/authorizer"
+	"github.com/lixinio/weixin/wxwork_suite"
 	"github.com/stretchr/testify/require"
 )
 
+const (
+	testCorpID        = "wxcorp"
+	testAgentID       = 1000002
+	testAgentSecret   = "agent_secret"
+	testSuiteID       = "wxsuite"
+	testSuiteSecret   = "suite_secret"
+	testSuiteCorpID   = "wxsuitecorp" // 授权给服务商的企业
+	testSuiteAgentID  = 1000003
+	testPermanentCode = "permanent_code"
+)
+
 type item struct {
 	client  *utils.Client
 	agentID int
 }
 
-func getClient() []*item {
-	rds := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := agent.New(corp, rds, rds, &agent.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
+// getClient 企业自建应用和服务商代开发的应用
+func getClient(t *testing.T, server *mockserver.Server) []*item {
+	ctx := context.Background()
+	cache := memory.NewMemory()
+	corp := wxwork.New(&wxwork.Config{Corpid: testCorpID}, server.ClientOption())
+	agent := agent.New(corp, cache, cache, &agent.Config{
+		AgentID: testAgentID,
+		Secret:  testAgentSecret,
 	})
 
-	authorizer := authorizer.NewLite(
-		rds, rds, test.WxWorkSuiteID,
-		test.WxWorkSuiteCorpID, test.WxWorkSuiteAgentID,
+	suite := wxwork_suite.New(cache, cache, &wxwork_suite.Config{
+		SuiteID:     testSuiteID,
+		SuiteSecret: testSuiteSecret,
+	}, nil, server.ClientOption())
+	require.Equal(t, nil, suite.UpdateTicket(ctx, "suite_ticket"))
+	authorizer := authorizer.New(
+		cache, cache, testSuiteID, testSuiteCorpID, testSuiteAgentID,
+		func(ctx context.Context) (string, int, error) {
+			result, err := suite.GetCorpToken(ctx, testSuiteCorpID, testPermanentCode)
+			if err != nil {
+				return "", 0, err
+			}
+			return result.AccessToken, result.ExpiresIn, nil
+		},
+		nil, server.ClientOption(),
 	)
 	return []*item{
 		{
@@ -45,8 +68,26 @@ func getClient() []*item {
 	}
 }
 
+func newTestServer() *mockserver.Server {
+	server := mockserver.New(
+		mockserver.OptWithApp(testCorpID, testAgentSecret),
+		mockserver.OptWithApp(testSuiteID, testSuiteSecret),
+	)
+	server.HandleJSON(apiSetWorkbenchTemplate, map[string]interface{}{})
+	return server
+}
+
+// lastTemplate 最后一次设置的模板
+func lastTemplate(t *testing.T, server *mockserver.Server) *WorkbenchTemplateParam {
+	param := &WorkbenchTemplateParam{}
+	require.Equal(t, nil, server.LastRequest(apiSetWorkbenchTemplate).Unmarshal(param))
+	return param
+}
+
 func TestSetWorkbenchImageTemplate(t *testing.T) {
-	items := getClient()
+	server := newTestServer()
+	defer server.Close()
+	items := getClient(t, server)
 	ctx := context.Background()
 
 	for _, item := range items {
@@ -64,12 +105,18 @@ func TestSetWorkbenchImageTemplate(t *testing.T) {
 				},
 			})
 			require.Equal(t, nil, err)
+			param := lastTemplate(t, server)
+			require.Equal(t, item.agentID, param.AgentID)
+			require.Equal(t, KeyTypeImage, param.Type)
+			require.Equal(t, "https://www.baidu.com", param.WorkBenchImage.URL)
 		}
 	}
 }
 
 func TestSetWorkbenchKeyDataTemplate(t *testing.T) {
-	items := getClient()
+	server := newTestServer()
+	defer server.Close()
+	items := getClient(t, server)
 	ctx := context.Background()
 
 	for _, item := range items {
@@ -107,12 +154,19 @@ func TestSetWorkbenchKeyDataTemplate(t *testing.T) {
 				},
 			})
 			require.Equal(t, nil, err)
+			param := lastTemplate(t, server)
+			require.Equal(t, item.agentID, param.AgentID)
+			require.Equal(t, KeyTypeKeyData, param.Type)
+			require.Equal(t, 4, len(param.WorkBenchKeyData.Items))
+			require.Equal(t, "待审批", param.WorkBenchKeyData.Items[0].Key)
 		}
 	}
 }
 
 func TestSetWorkbenchListTemplate(t *testing.T) {
-	items := getClient()
+	server := newTestServer()
+	defer server.Close()
+	items := getClient(t, server)
 	ctx := context.Background()
 
 	for _, item := range items {
@@ -142,12 +196,23 @@ func TestSetWorkbenchListTemplate(t *testing.T) {
 				},
 			})
 			require.Equal(t, nil, err)
+			param := lastTemplate(t, server)
+			require.Equal(t, item.agentID, param.AgentID)
+			require.Equal(t, KeyTypeList, param.Type)
+			require.Equal(t, 3, len(param.WorkBenchList.Items))
 		}
 	}
 }
 
 func TestGetWorkbenchTemplate(t *testing.T) {
-	items := getClient()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiGetWorkbenchTemplate, map[string]interface{}{
+		"type":              KeyTypeImage,
+		"image":             map[string]interface{}{"url": "https://www.baidu.com", "jump_url": "https://www.baidu.com"},
+		"replace_user_data": true,
+	})
+	items := getClient(t, server)
 	ctx := context.Background()
 
 	for _, item := range items {
@@ -155,7 +220,15 @@ func TestGetWorkbenchTemplate(t *testing.T) {
 		{
 			resp, err := api.GetWorkbenchTemplate(ctx, item.agentID)
 			require.Equal(t, nil, err)
-			fmt.Println("resp", resp)
+			require.Equal(t, KeyTypeImage, resp.Type)
+			require.True(t, resp.ReplaceUserData)
+			require.Equal(t, "https://www.baidu.com", resp.WorkBenchImage.URL)
+
+			payload := map[string]int{}
+			require.Equal(t, nil, server.LastRequest(apiGetWorkbenchTemplate).Unmarshal(&payload))
+			require.Equal(t, item.agentID, payload["agentid"])
 		}
 	}
+	// 代开发应用的 token 通过服务商换取
+	require.NotEqual(t, nil, server.LastRequest("/cgi-bin/service/get_corp_token"))
 }
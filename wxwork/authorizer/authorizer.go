@@ -38,7 +38,9 @@ func New(
 	suiteID, corpID string, agentID int,
 	accessTokenGetter RefreshAccessToken,
 	tokenRefreshHandler utils.TokenRefreshHandler, // 刷新callback
+	options ...utils.ClientOption,
 ) *Authorizer {
+	options = utils.ClientOptionsWithAppID(corpID, options)
 	accessTokenCache := utils.NewAccessTokenCache(
 		newAdapter(suiteID, corpID, agentID, accessTokenGetter),
 		cache, locker,
@@ -48,7 +50,7 @@ func New(
 		SuiteID:          suiteID,
 		CorpID:           corpID,
 		AgentID:          agentID,
-		Client:           utils.NewClient(WXServerUrl, accessTokenCache),
+		Client:           utils.NewClient(WXServerUrl, accessTokenCache, options...),
 		accessTokenCache: accessTokenCache,
 	}
 }
@@ -58,7 +60,9 @@ func NewLite(
 	cache utils.Cache,
 	locker utils.Lock,
 	suiteID, corpID string, agentID int,
+	options ...utils.ClientOption,
 ) *Authorizer {
+	options = utils.ClientOptionsWithAppID(corpID, options)
 	accessTokenCache := utils.NewAccessTokenCache(
 		newAdapter(
 			suiteID,
@@ -76,7 +80,7 @@ func NewLite(
 		SuiteID: suiteID,
 		CorpID:  corpID,
 		AgentID: agentID,
-		Client:  utils.NewClient(WXServerUrl, accessTokenCache),
+		Client:  utils.NewClient(WXServerUrl, accessTokenCache, options...),
 	}
 }
 
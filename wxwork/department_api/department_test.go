@@ -2,29 +2,117 @@ package department_api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	agentApi "github.com/lixinio/weixin/wxwork/agent"
 	"github.com/stretchr/testify/require"
 )
 
+const (
+	testCorpID             = "wxcorp"
+	testAgentID            = 1000002
+	testAgentSecret        = "agent_secret"
+	testAgentContactSecret = "contact_secret"
+)
+
+// fakeDepartments 模拟通讯录的部门
+type fakeDepartments struct {
+	mu          sync.Mutex
+	nextID      int
+	departments map[int]*DepartmentItem
+}
+
+func newFakeDepartments(server *mockserver.Server) *fakeDepartments {
+	f := &fakeDepartments{
+		nextID:      2,
+		departments: map[int]*DepartmentItem{1: {ID: 1, Name: "根部门"}},
+	}
+	server.Handle(apiCreate, f.create)
+	server.Handle(apiUpdate, f.update)
+	server.Handle(apiDelete, f.delete)
+	server.Handle(apiList, f.list)
+	return f
+}
+
+func (f *fakeDepartments) create(w http.ResponseWriter, r *http.Request) {
+	param := &CreateParam{}
+	_ = json.NewDecoder(r.Body).Decode(param)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID
+	f.nextID++
+	f.departments[id] = &DepartmentItem{ID: id, Name: param.Name, Parentid: param.Parentid}
+	writeJSON(w, map[string]interface{}{"errcode": 0, "errmsg": "created", "id": id})
+}
+
+func (f *fakeDepartments) update(w http.ResponseWriter, r *http.Request) {
+	param := &UpdateParam{}
+	_ = json.NewDecoder(r.Body).Decode(param)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	department, ok := f.departments[param.ID]
+	if !ok {
+		writeJSON(w, map[string]interface{}{"errcode": 60003, "errmsg": "department not found"})
+		return
+	}
+	department.Name = param.Name
+	writeJSON(w, map[string]interface{}{"errcode": 0, "errmsg": "updated"})
+}
+
+func (f *fakeDepartments) delete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.departments, id)
+	writeJSON(w, map[string]interface{}{"errcode": 0, "errmsg": "deleted"})
+}
+
+// list 返回 id 及其子部门, id 为空时返回全部
+func (f *fakeDepartments) list(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := []*DepartmentItem{}
+	for _, department := range f.departments {
+		if id == 0 || department.ID == id || department.Parentid == id {
+			result = append(result, department)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	writeJSON(w, map[string]interface{}{"errcode": 0, "errmsg": "ok", "department": result})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
 func TestDepartment(t *testing.T) {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
+	server := mockserver.New()
+	defer server.Close()
+	newFakeDepartments(server)
+
+	cache := memory.NewMemory()
 	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := agentApi.New(corp, redis, redis, &agentApi.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
+		Corpid: testCorpID,
+	}, server.ClientOption())
+	agent := agentApi.New(corp, cache, cache, &agentApi.Config{
+		AgentID: testAgentID,
+		Secret:  testAgentSecret,
 	})
-	agentContact := agentApi.New(corp, redis, redis, &agentApi.Config{
+	agentContact := agentApi.New(corp, cache, cache, &agentApi.Config{
 		AgentID: 0,
-		Secret:  test.AgentContactSecret,
+		Secret:  testAgentContactSecret,
 	})
 	ctx := context.Background()
 
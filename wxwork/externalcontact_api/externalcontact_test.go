@@ -2,31 +2,51 @@ package externalcontact_api
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	"github.com/lixinio/weixin/wxwork/agent"
 	"github.com/lixinio/weixin/wxwork/authorizer"
+	"github.com/lixinio/weixin/wxwork_suite"
 	"github.com/stretchr/testify/require"
 )
 
+const (
+	testCorpID         = "wxcorp"
+	testAgentID        = 1000002
+	testAgentSecret    = "agent_secret"
+	testSuiteID        = "wxsuite"
+	testSuiteSecret    = "suite_secret"
+	testSuiteCorpID    = "wxsuitecorp" // 授权给服务商的企业
+	testSuiteAgentID   = 1000003
+	testPermanentCode  = "permanent_code"
+	testUserID         = "zhangsan"
+	testExternalUserID = "woAJ2GCAAAXtWyujaWJHDDGi0mACHAAA"
+)
+
 type item struct {
 	client  *utils.Client
 	agentID int
 }
 
-func initWxWorkAgent() *item {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
+func newTestServer() *mockserver.Server {
+	return mockserver.New(
+		mockserver.OptWithApp(testCorpID, testAgentSecret),
+		mockserver.OptWithApp(testSuiteID, testSuiteSecret),
+	)
+}
+
+func initWxWorkAgent(server *mockserver.Server) *item {
+	cache := memory.NewMemory()
 	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := agent.New(corp, redis, redis, &agent.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
+		Corpid: testCorpID,
+	}, server.ClientOption())
+	agent := agent.New(corp, cache, cache, &agent.Config{
+		AgentID: testAgentID,
+		Secret:  testAgentSecret,
 	})
 
 	return &item{
@@ -35,13 +55,26 @@ func initWxWorkAgent() *item {
 	}
 }
 
-func initWxWorkSuiteAuthorizer() *item {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	corp := authorizer.NewLite(
-		redis, redis,
-		test.WxWorkSuiteID,
-		test.WxWorkSuiteCorpID,
-		test.WxWorkSuiteAgentID,
+func initWxWorkSuiteAuthorizer(t *testing.T, server *mockserver.Server) *item {
+	cache := memory.NewMemory()
+	suite := wxwork_suite.New(cache, cache, &wxwork_suite.Config{
+		SuiteID:     testSuiteID,
+		SuiteSecret: testSuiteSecret,
+	}, nil, server.ClientOption())
+	require.Equal(t, nil, suite.UpdateTicket(context.Background(), "suite_ticket"))
+	corp := authorizer.New(
+		cache, cache,
+		testSuiteID,
+		testSuiteCorpID,
+		testSuiteAgentID,
+		func(ctx context.Context) (string, int, error) {
+			result, err := suite.GetCorpToken(ctx, testSuiteCorpID, testPermanentCode)
+			if err != nil {
+				return "", 0, err
+			}
+			return result.AccessToken, result.ExpiresIn, nil
+		},
+		nil, server.ClientOption(),
 	)
 
 	return &item{
@@ -52,43 +85,88 @@ func initWxWorkSuiteAuthorizer() *item {
 
 func TestGetFollowUserList(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiGetFollowUserList, map[string]interface{}{
+		"follow_user": []string{testUserID, "lisi"},
+	})
+
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		api := NewApi(cli.client)
 
 		result, err := api.GetFollowUserList(ctx)
 		require.Equal(t, nil, err)
-		fmt.Println(result.FollowUsers)
+		require.Equal(t, []string{testUserID, "lisi"}, result.FollowUsers)
 	}
+	require.NotEqual(t, nil, server.LastRequest("/cgi-bin/service/get_corp_token"))
 }
 
 func TestGetExternalContactList(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiGetExternalContactList, map[string]interface{}{
+		"external_userid": []string{testExternalUserID},
+	})
+
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		api := NewApi(cli.client)
 
-		listResult, err := api.GetExternalContactList(ctx, test.UserID)
+		listResult, err := api.GetExternalContactList(ctx, testUserID)
 
 		require.Equal(t, nil, err)
-		fmt.Println(listResult.ExternalUserids)
+		require.Equal(t, []string{testExternalUserID}, listResult.ExternalUserids)
+		require.Equal(t, testUserID, server.LastRequest(apiGetExternalContactList).Query.Get("userid"))
 	}
 }
 
 func TestGetExternalUser(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiGetExternalContact, map[string]interface{}{
+		"external_contact": map[string]interface{}{
+			"external_userid": testExternalUserID,
+			"name":            "李四",
+			"type":            1,
+			"gender":          1,
+			"unionid":         "ozynqsulJFCZ2z1aYeS8h-nuasdAAA",
+		},
+		"follow_user": []map[string]interface{}{{
+			"userid":     testUserID,
+			"remark":     "李部长",
+			"createtime": 1525779812,
+			"tags": []map[string]interface{}{
+				{"group_name": "标签分组名称", "tag_name": "标签名称", "tag_id": "etAzJ2GAAAA", "type": 1},
+			},
+			"add_way": 1,
+		}},
+		"next_cursor": "",
+	})
+
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		api := NewApi(cli.client)
-		result, err := api.GetExternalContact(ctx, test.ExternalUserID, "")
+		result, err := api.GetExternalContact(ctx, testExternalUserID, "")
 
 		require.Equal(t, nil, err)
-		fmt.Println(result.ExternalContact)
+		require.Equal(t, testExternalUserID, result.ExternalContact.ExternalUserid)
+		require.Equal(t, "李四", result.ExternalContact.Name)
+		require.Equal(t, 1, len(result.FollowUsers))
+		require.Equal(t, testUserID, result.FollowUsers[0].Userid)
+		require.Equal(t, "etAzJ2GAAAA", result.FollowUsers[0].Tags[0].TagID)
+
+		query := server.LastRequest(apiGetExternalContact).Query
+		require.Equal(t, testExternalUserID, query.Get("external_userid"))
+		// cursor 为空时不传
+		require.False(t, query.Has("cursor"))
 	}
 }
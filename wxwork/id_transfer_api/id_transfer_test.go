@@ -2,31 +2,49 @@ package id_transfer_api
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	"github.com/lixinio/weixin/wxwork/agent"
 	"github.com/lixinio/weixin/wxwork/authorizer"
+	"github.com/lixinio/weixin/wxwork_suite"
 	"github.com/stretchr/testify/require"
 )
 
+const (
+	testCorpID        = "wxcorp"
+	testAgentID       = 1000002
+	testAgentSecret   = "agent_secret"
+	testSuiteID       = "wxsuite"
+	testSuiteSecret   = "suite_secret"
+	testSuiteCorpID   = "wxsuitecorp" // 授权给服务商的企业
+	testSuiteAgentID  = 1000003
+	testPermanentCode = "permanent_code"
+)
+
 type item struct {
 	client  *utils.Client
 	agentID int
 }
 
-func initWxWorkAgent() *item {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
+func newTestServer() *mockserver.Server {
+	return mockserver.New(
+		mockserver.OptWithApp(testCorpID, testAgentSecret),
+		mockserver.OptWithApp(testSuiteID, testSuiteSecret),
+	)
+}
+
+func initWxWorkAgent(server *mockserver.Server) *item {
+	cache := memory.NewMemory()
 	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := agent.New(corp, redis, redis, &agent.Config{
-		AgentID: test.AgentID,
-		Secret:  "",
+		Corpid: testCorpID,
+	}, server.ClientOption())
+	agent := agent.New(corp, cache, cache, &agent.Config{
+		AgentID: testAgentID,
+		Secret:  testAgentSecret,
 	})
 
 	return &item{
@@ -35,13 +53,26 @@ func initWxWorkAgent() *item {
 	}
 }
 
-func initWxWorkSuiteAuthorizer() *item {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	corp := authorizer.NewLite(
-		redis, redis,
-		test.WxWorkSuiteID,
-		test.WxWorkSuiteCorpID,
-		test.WxWorkSuiteAgentID,
+func initWxWorkSuiteAuthorizer(t *testing.T, server *mockserver.Server) *item {
+	cache := memory.NewMemory()
+	suite := wxwork_suite.New(cache, cache, &wxwork_suite.Config{
+		SuiteID:     testSuiteID,
+		SuiteSecret: testSuiteSecret,
+	}, nil, server.ClientOption())
+	require.Equal(t, nil, suite.UpdateTicket(context.Background(), "suite_ticket"))
+	corp := authorizer.New(
+		cache, cache,
+		testSuiteID,
+		testSuiteCorpID,
+		testSuiteAgentID,
+		func(ctx context.Context) (string, int, error) {
+			result, err := suite.GetCorpToken(ctx, testSuiteCorpID, testPermanentCode)
+			if err != nil {
+				return "", 0, err
+			}
+			return result.AccessToken, result.ExpiresIn, nil
+		},
+		nil, server.ClientOption(),
 	)
 
 	return &item{
@@ -52,15 +83,29 @@ func initWxWorkSuiteAuthorizer() *item {
 
 func TestIdTransferApi_UnionID2ExternalUserID(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
+	server.HandleJSON(apiUnionID2ExternalUserID, map[string]interface{}{
+		"errcode": 0, "errmsg": "ok", "external_userid": "wmexternal", "pending_id": "pending",
+	})
+
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		api := NewApi(cli.client)
 
-		result, err := api.UnionID2ExternalUserID(ctx, "xxx", "xxx", SubjectTypeEnterprise)
+		result, err := api.UnionID2ExternalUserID(ctx, "unionid", "openid", SubjectTypeProvider)
 		require.Nil(t, err)
-		require.Equal(t, result.ErrCode, 0)
-		fmt.Println(result)
+		require.Equal(t, result.ErrCode, int64(0))
+		require.Equal(t, "wmexternal", result.ExternalUserID)
+		require.Equal(t, "pending", result.PendingID)
+
+		param := &UnionID2ExternalUserIDParam{}
+		require.Equal(t, nil, server.LastRequest(apiUnionID2ExternalUserID).Unmarshal(param))
+		require.Equal(t, "unionid", param.UnionID)
+		require.Equal(t, "openid", param.OpenID)
+		require.Equal(t, SubjectTypeProvider, param.SubjectType)
 	}
+	require.NotEqual(t, nil, server.LastRequest("/cgi-bin/service/get_corp_token"))
 }
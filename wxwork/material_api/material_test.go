@@ -1,46 +1,130 @@
 package material_api
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
+	"image/color"
+	"image/png"
 	"io"
 	"net/http"
-	"os"
+	"sync"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	agentApi "github.com/lixinio/weixin/wxwork/agent"
 	"github.com/stretchr/testify/require"
 )
 
-func TestMaterialUrl(t *testing.T) {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
+const (
+	testCorpID      = "wxcorp"
+	testAgentID     = 1000002
+	testAgentSecret = "agent_secret"
+)
+
+func initAgent(server *mockserver.Server) *agentApi.Agent {
+	cache := memory.NewMemory()
 	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
+		Corpid: testCorpID,
+	}, server.ClientOption())
+	return agentApi.New(corp, cache, cache, &agentApi.Config{
+		AgentID: testAgentID,
+		Secret:  testAgentSecret,
 	})
-	agent := agentApi.New(corp, redis, redis, &agentApi.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
+}
+
+// fakeMaterials 模拟临时素材的存储, 上传之后可以下载
+type fakeMaterials struct {
+	server *mockserver.Server
+	mu     sync.Mutex
+	seq    int
+	files  map[string][]byte
+}
+
+func newFakeMaterials(server *mockserver.Server) *fakeMaterials {
+	m := &fakeMaterials{
+		server: server,
+		files:  map[string][]byte{},
+	}
+	server.Handle(apiUpload, m.upload)
+	server.Handle(apiUploadImg, m.upload)
+	server.Handle(apiGet, func(w http.ResponseWriter, r *http.Request) {
+		m.download(w, r.URL.Query().Get("media_id"))
 	})
-	ctx := context.Background()
+	server.Handle("/wwimg/", func(w http.ResponseWriter, r *http.Request) {
+		m.download(w, r.URL.Query().Get("media_id"))
+	})
+	return m
+}
 
-	materialApi := NewApi(agent.Client)
+func (m *fakeMaterials) upload(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("media")
+	if err != nil {
+		writeJSON(w, &utils.WeixinError{ErrCode: 41005, ErrMsg: "media data missing"})
+		return
+	}
+	content, _ := io.ReadAll(file)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	mediaID := fmt.Sprintf("media_%d", m.seq)
+	m.files[mediaID] = content
+
+	writeJSON(w, map[string]interface{}{
+		"media_id":   mediaID,
+		"type":       r.URL.Query().Get("type"),
+		"created_at": "1380000000",
+		"url":        m.server.URL + "/wwimg/?media_id=" + mediaID,
+	})
+}
 
-	file, err := os.Open(test.ImagePath)
-	require.Empty(t, err)
-	defer file.Close()
+func (m *fakeMaterials) download(w http.ResponseWriter, mediaID string) {
+	m.mu.Lock()
+	content, ok := m.files[mediaID]
+	m.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, &utils.WeixinError{ErrCode: 40007, ErrMsg: "invalid media_id"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(content)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func isWeixinError(err error, code int64) bool {
+	var wxError *utils.WeixinError
+	return errors.As(err, &wxError) && wxError.ErrCode == code
+}
+
+func testImage(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	buffer := &bytes.Buffer{}
+	require.Equal(t, nil, png.Encode(buffer, img))
+	return buffer.Bytes()
+}
+
+func TestMaterialUrl(t *testing.T) {
+	ctx := context.Background()
+	server := mockserver.New(mockserver.OptWithApp(testCorpID, testAgentSecret))
+	defer server.Close()
+	newFakeMaterials(server)
+	materialApi := NewApi(initAgent(server).Client)
 
-	url, err := materialApi.UploadImg(ctx, test.ImagePath, file)
+	url, err := materialApi.UploadImg(ctx, "a.png", bytes.NewReader(testImage(t)))
 	require.Empty(t, err)
-	fmt.Print(url)
 
 	// 下载
 	response, err := http.Get(url)
@@ -52,70 +136,33 @@ func TestMaterialUrl(t *testing.T) {
 }
 
 func TestMaterialID(t *testing.T) {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := agentApi.New(corp, redis, redis, &agentApi.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
-	})
 	ctx := context.Background()
+	server := mockserver.New(mockserver.OptWithApp(testCorpID, testAgentSecret))
+	defer server.Close()
+	newFakeMaterials(server)
+	materialApi := NewApi(initAgent(server).Client)
 
-	materialApi := NewApi(agent.Client)
-
-	file, err := os.Open(test.ImagePath)
+	content := testImage(t)
+	result, err := materialApi.Upload(ctx, "a.png", bytes.NewReader(content), MediaTypeImage)
 	require.Empty(t, err)
-	defer file.Close()
-
-	result, err := materialApi.Upload(ctx, test.ImagePath, file, MediaTypeImage)
-	require.Empty(t, err)
-	require.Equal(t, result.Type, MediaTypeImage)
-	fmt.Println(result.MediaID, result.CreatedAt, result.Type)
-
-	// 计算源文件hash
-	originHash := ""
-	{
-		file.Seek(0, 0)
-		hasher := sha256.New()
-		_, err = io.Copy(hasher, file)
-		require.Empty(t, err)
-		originHash = hex.EncodeToString(hasher.Sum(nil))
-	}
+	require.Equal(t, string(MediaTypeImage), result.Type)
+	require.Equal(t, string(MediaTypeImage), server.LastRequest(apiUpload).Query.Get("type"))
 
 	{
 		resp, err := materialApi.Get(ctx, result.MediaID)
 		require.Empty(t, err)
-
-		// 计算hash
-		hasher := sha256.New()
-		_, err = hasher.Write(resp)
-		require.Empty(t, err)
-
-		thisHash := hex.EncodeToString(hasher.Sum(nil))
-		require.Equal(t, thisHash, originHash)
+		require.Equal(t, content, resp)
 	}
 
 	{
-		r, w := io.Pipe()
-		go func(t *testing.T) {
-			defer w.Close()
-			err := materialApi.Save(ctx, result.MediaID, w)
-			require.Empty(t, err)
-		}(t)
-
-		// 计算hash
-		hasher := sha256.New()
-		_, err = io.Copy(hasher, r)
-		require.Empty(t, err)
-
-		thisHash := hex.EncodeToString(hasher.Sum(nil))
-		require.Equal(t, thisHash, originHash)
+		buffer := &bytes.Buffer{}
+		require.Equal(t, nil, materialApi.Save(ctx, result.MediaID, buffer))
+		require.Equal(t, content, buffer.Bytes())
 	}
 
 	{
 		mediaID := fmt.Sprintf("0%s", result.MediaID)
 		_, err := materialApi.Get(ctx, mediaID)
-		require.NotEmpty(t, err)
+		require.True(t, isWeixinError(err, 40007))
 	}
 }
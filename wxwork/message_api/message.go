@@ -74,7 +74,7 @@ func (api *MessageApi) SendTextMessage(
 	ctx context.Context, header *MessageHeader, content string,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &TextMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &TextMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "text",
@@ -113,7 +113,7 @@ func (api *MessageApi) SendTextCardMessage(
 	title, description, url, btntxt string,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &TextCardMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &TextCardMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "textcard",
@@ -163,7 +163,7 @@ func (api *MessageApi) SendNewsMessage(
 	articles []*NewsMessageParam,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &NewsMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &NewsMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "news",
@@ -197,7 +197,7 @@ func (api *MessageApi) SendMarkdownMessage(
 	ctx context.Context, header *MessageHeader, content string,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &MarkdownMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &MarkdownMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "markdown",
@@ -231,7 +231,7 @@ func (api *MessageApi) SendImageMessage(
 	ctx context.Context, header *MessageHeader, mediaID string,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &ImageMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &ImageMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "image",
@@ -265,7 +265,7 @@ func (api *MessageApi) SendVoiceMessage(
 	ctx context.Context, header *MessageHeader, mediaID string,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &VoiceMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &VoiceMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "voice",
@@ -301,7 +301,7 @@ func (api *MessageApi) SendVideoMessage(
 	ctx context.Context, header *MessageHeader, mediaID, title, desc string,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &VideoMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &VideoMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "video",
@@ -339,7 +339,7 @@ func (api *MessageApi) SendFileMessage(
 	ctx context.Context, header *MessageHeader, mediaID string,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &FileMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &FileMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "file",
@@ -383,7 +383,7 @@ func (api *MessageApi) SendMpNewsMessage(
 	articles []*MpNewsMessageParam,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &MpNewsMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &MpNewsMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "mpnews",
@@ -430,7 +430,7 @@ func (api *MessageApi) SendMpNoticeMessage(
 	msg *MpNoticeMessageParam,
 ) (*MessageResponse, error) {
 	result := &MessageResponse{}
-	if err := api.Client.HTTPPostJson(ctx, apiSend, &MpNoticeMessage{
+	if err := api.Client.HTTPPostJson(header.retryContext(ctx), apiSend, &MpNoticeMessage{
 		MessageHeader: header,
 		AgentID:       api.AgentID,
 		MsgType:       "miniprogram_notice",
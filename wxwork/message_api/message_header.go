@@ -1,6 +1,11 @@
 package message_api
 
-import "strings"
+import (
+	"context"
+	"strings"
+
+	"github.com/lixinio/weixin/utils"
+)
 
 type MessageHeader struct {
 	ToUser                 string `json:"touser,omitempty"`
@@ -32,6 +37,14 @@ func (h *MessageHeader) SetDuplicateCheckInterval(duplicateCheckInterval int) *M
 	return h
 }
 
+// 发送消息不是幂等的, 只有开启了重复消息检查才允许失败重试
+func (h *MessageHeader) retryContext(ctx context.Context) context.Context {
+	if h != nil && h.EnableDuplicateCheck == 1 {
+		return ctx
+	}
+	return utils.NewNoRetryContext(ctx)
+}
+
 /*
 touser	否	成员ID列表（消息接收者，多个接收者用‘|’分隔，最多支持1000个）。特殊情况：指定为@all，则向关注该企业应用的全部成员发送
 toparty	否	部门ID列表，多个接收者用‘|’分隔，最多支持100个。当touser为@all时忽略本参数
@@ -2,34 +2,48 @@ package message_api
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"net/http"
+	"strings"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	"github.com/lixinio/weixin/wxwork/agent"
 	"github.com/lixinio/weixin/wxwork/authorizer"
 	"github.com/lixinio/weixin/wxwork/material_api"
 	"github.com/lixinio/weixin/wxwork/user_api"
+	"github.com/lixinio/weixin/wxwork_suite"
 	"github.com/stretchr/testify/require"
 )
 
+const (
+	testCorpID        = "wxcorp"
+	testAgentID       = 1000002
+	testAgentSecret   = "agent_secret"
+	testSuiteID       = "wxsuite"
+	testSuiteSecret   = "suite_secret"
+	testSuiteCorpID   = "wxsuitecorp" // 授权给服务商的企业
+	testSuiteAgentID  = 1000003
+	testPermanentCode = "permanent_code"
+	testUserMobile    = "13800138000"
+	testUserID        = "test"
+)
+
 type item struct {
 	client  *utils.Client
 	agentID int
 }
 
-func initWxWorkAgent() *item {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
+func initWxWorkAgent(server *mockserver.Server) *item {
+	cache := memory.NewMemory()
 	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := agent.New(corp, redis, redis, &agent.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
+		Corpid: testCorpID,
+	}, server.ClientOption())
+	agent := agent.New(corp, cache, cache, &agent.Config{
+		AgentID: testAgentID,
+		Secret:  testAgentSecret,
 	})
 
 	return &item{
@@ -38,13 +52,26 @@ func initWxWorkAgent() *item {
 	}
 }
 
-func initWxWorkSuiteAuthorizer() *item {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	corp := authorizer.NewLite(
-		redis, redis,
-		test.WxWorkSuiteID,
-		test.WxWorkSuiteCorpID,
-		test.WxWorkSuiteAgentID,
+func initWxWorkSuiteAuthorizer(t *testing.T, server *mockserver.Server) *item {
+	cache := memory.NewMemory()
+	suite := wxwork_suite.New(cache, cache, &wxwork_suite.Config{
+		SuiteID:     testSuiteID,
+		SuiteSecret: testSuiteSecret,
+	}, nil, server.ClientOption())
+	require.Equal(t, nil, suite.UpdateTicket(context.Background(), "suite_ticket"))
+	corp := authorizer.New(
+		cache, cache,
+		testSuiteID,
+		testSuiteCorpID,
+		testSuiteAgentID,
+		func(ctx context.Context) (string, int, error) {
+			result, err := suite.GetCorpToken(ctx, testSuiteCorpID, testPermanentCode)
+			if err != nil {
+				return "", 0, err
+			}
+			return result.AccessToken, result.ExpiresIn, nil
+		},
+		nil, server.ClientOption(),
 	)
 
 	return &item{
@@ -53,15 +80,53 @@ func initWxWorkSuiteAuthorizer() *item {
 	}
 }
 
+// newTestServer 手机号查询userid, 上传素材和发送消息的接口
+func newTestServer() *mockserver.Server {
+	server := mockserver.New(
+		mockserver.OptWithApp(testCorpID, testAgentSecret),
+		mockserver.OptWithApp(testSuiteID, testSuiteSecret),
+	)
+	server.HandleJSON("/cgi-bin/user/getuserid", map[string]interface{}{"userid": testUserID})
+	server.Handle("/cgi-bin/media/upload", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"media_id":"media_id","created_at":"1380000000","type":"` +
+			r.URL.Query().Get("type") + `"}`))
+	})
+	server.HandleJSON("/cgi-bin/media/uploadimg", map[string]interface{}{
+		"url": "http://p.qpic.cn/pic_wework/image.png",
+	})
+	server.HandleJSON(apiSend, map[string]interface{}{"msgid": "msgid"})
+	return server
+}
+
+// requireSent 检查最后一次发送的消息
+func requireSent(
+	t *testing.T, server *mockserver.Server, cli *item, result *MessageResponse, msgType string,
+) map[string]interface{} {
+	require.Equal(t, "msgid", result.MsgID)
+	payload := map[string]interface{}{}
+	require.Equal(t, nil, server.LastRequest(apiSend).Unmarshal(&payload))
+	require.Equal(t, msgType, payload["msgtype"])
+	require.Equal(t, float64(cli.agentID), payload["agentid"])
+	require.Equal(t, testUserID, payload["touser"])
+	require.NotEqual(t, nil, payload[msgType])
+	return payload
+}
+
 func TestSendMessage(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		userApi := user_api.NewApi(cli.client)
-		userid, err := userApi.MobileGetUserId(ctx, test.AgentUserMobile)
+		userid, err := userApi.MobileGetUserId(ctx, testUserMobile)
 		require.Equal(t, nil, err)
+		mobile := map[string]string{}
+		require.Equal(t, nil, server.LastRequest("/cgi-bin/user/getuserid").Unmarshal(&mobile))
+		require.Equal(t, testUserMobile, mobile["mobile"])
 
 		messageApi := NewApi(cli.client, cli.agentID)
 
@@ -71,7 +136,7 @@ func TestSendMessage(t *testing.T) {
 			"你的快递已到，请携带工卡前往邮件中心领取。\n出发前可查看<a href=\"http://work.weixin.qq.com\">邮件中心视频实况</a>，聪明避开排队。",
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "text")
 
 		result, err = messageApi.SendTextCardMessage(
 			ctx,
@@ -82,7 +147,7 @@ func TestSendMessage(t *testing.T) {
 			"更多",
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "textcard")
 
 		result, err = messageApi.SendNewsMessage(
 			ctx,
@@ -103,7 +168,7 @@ func TestSendMessage(t *testing.T) {
 			},
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "news")
 
 		result, err = messageApi.SendMarkdownMessage(
 			ctx,
@@ -125,29 +190,29 @@ func TestSendMessage(t *testing.T) {
 			`,
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "markdown")
 	}
 }
 
 func TestSendImageMessage(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		materialApi := material_api.NewApi(cli.client)
 		messageApi := NewApi(cli.client, cli.agentID)
 
 		userApi := user_api.NewApi(cli.client)
-		userid, err := userApi.MobileGetUserId(ctx, test.AgentUserMobile)
+		userid, err := userApi.MobileGetUserId(ctx, testUserMobile)
 		require.Equal(t, nil, err)
-		file, err := os.Open(test.ImagePath)
-		require.Empty(t, err)
-		defer file.Close()
-
-		materialResult, err := materialApi.Upload(ctx, test.ImagePath, file, material_api.MediaTypeImage)
+		materialResult, err := materialApi.Upload(
+			ctx, "a.png", strings.NewReader("content"), material_api.MediaTypeImage,
+		)
 		require.Empty(t, err)
-		require.Equal(t, materialResult.Type, material_api.MediaTypeImage)
+		require.Equal(t, string(material_api.MediaTypeImage), materialResult.Type)
 
 		result, err := messageApi.SendImageMessage(
 			ctx,
@@ -155,7 +220,7 @@ func TestSendImageMessage(t *testing.T) {
 			materialResult.MediaID,
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "image")
 
 		result, err = messageApi.SendFileMessage(
 			ctx,
@@ -163,29 +228,29 @@ func TestSendImageMessage(t *testing.T) {
 			materialResult.MediaID,
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "file")
 	}
 }
 
 func TestSendVideoMessage(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		materialApi := material_api.NewApi(cli.client)
 		messageApi := NewApi(cli.client, cli.agentID)
 
 		userApi := user_api.NewApi(cli.client)
-		userid, err := userApi.MobileGetUserId(ctx, test.AgentUserMobile)
+		userid, err := userApi.MobileGetUserId(ctx, testUserMobile)
 		require.Equal(t, nil, err)
-		file, err := os.Open(test.VideoPath)
-		require.Empty(t, err)
-		defer file.Close()
-
-		materialResult, err := materialApi.Upload(ctx, test.VideoPath, file, material_api.MediaTypeVideo)
+		materialResult, err := materialApi.Upload(
+			ctx, "b.mp4", strings.NewReader("content"), material_api.MediaTypeVideo,
+		)
 		require.Empty(t, err)
-		require.Equal(t, materialResult.Type, material_api.MediaTypeVideo)
+		require.Equal(t, string(material_api.MediaTypeVideo), materialResult.Type)
 
 		result, err := messageApi.SendVideoMessage(
 			ctx,
@@ -195,7 +260,7 @@ func TestSendVideoMessage(t *testing.T) {
 			"",
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "video")
 
 		result, err = messageApi.SendFileMessage(
 			ctx,
@@ -203,29 +268,29 @@ func TestSendVideoMessage(t *testing.T) {
 			materialResult.MediaID,
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "file")
 	}
 }
 
 func TestSendAudioMessage(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		materialApi := material_api.NewApi(cli.client)
 		messageApi := NewApi(cli.client, cli.agentID)
 
 		userApi := user_api.NewApi(cli.client)
-		userid, err := userApi.MobileGetUserId(ctx, test.AgentUserMobile)
+		userid, err := userApi.MobileGetUserId(ctx, testUserMobile)
 		require.Equal(t, nil, err)
-		file, err := os.Open(test.AudioPath)
-		require.Empty(t, err)
-		defer file.Close()
-
-		materialResult, err := materialApi.Upload(ctx, test.AudioPath, file, material_api.MediaTypeVoice)
+		materialResult, err := materialApi.Upload(
+			ctx, "b.amr", strings.NewReader("content"), material_api.MediaTypeVoice,
+		)
 		require.Empty(t, err)
-		require.Equal(t, materialResult.Type, material_api.MediaTypeVoice)
+		require.Equal(t, string(material_api.MediaTypeVoice), materialResult.Type)
 
 		result, err := messageApi.SendVoiceMessage(
 			ctx,
@@ -233,7 +298,7 @@ func TestSendAudioMessage(t *testing.T) {
 			materialResult.MediaID,
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "voice")
 
 		result, err = messageApi.SendFileMessage(
 			ctx,
@@ -241,34 +306,32 @@ func TestSendAudioMessage(t *testing.T) {
 			materialResult.MediaID,
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "file")
 	}
 }
 
 func TestSendMpNewsMessage(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
 	for _, cli := range []*item{
-		initWxWorkAgent(),
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkAgent(server),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		materialApi := material_api.NewApi(cli.client)
 		messageApi := NewApi(cli.client, cli.agentID)
 
 		userApi := user_api.NewApi(cli.client)
-		userid, err := userApi.MobileGetUserId(ctx, test.AgentUserMobile)
+		userid, err := userApi.MobileGetUserId(ctx, testUserMobile)
 		require.Equal(t, nil, err)
-		file, err := os.Open(test.ImagePath)
-		require.Empty(t, err)
-		defer file.Close()
-
-		materialResult, err := materialApi.Upload(ctx, test.ImagePath, file, material_api.MediaTypeImage)
+		materialResult, err := materialApi.Upload(
+			ctx, "a.png", strings.NewReader("content"), material_api.MediaTypeImage,
+		)
 		require.Empty(t, err)
-		require.Equal(t, materialResult.Type, material_api.MediaTypeImage)
+		require.Equal(t, string(material_api.MediaTypeImage), materialResult.Type)
 
-		file.Seek(0, 0)
-		url, err := materialApi.UploadImg(ctx, test.ImagePath, file)
+		url, err := materialApi.UploadImg(ctx, "a.png", strings.NewReader("content"))
 		require.Empty(t, err)
-		fmt.Print(url)
 
 		result, err := messageApi.SendMpNewsMessage(
 			ctx,
@@ -279,23 +342,29 @@ func TestSendMpNewsMessage(t *testing.T) {
 					ThumbMediaID:     materialResult.MediaID,
 					Author:           "Author",
 					ContentSourceURL: "https://www.baidu.com",
-					Content:          fmt.Sprintf("Content <img src=\"%s\">", url),
+					Content:          "Content <img src=\"" + url + "\">",
 					Digest:           "Digest description",
 				},
 			},
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		payload := requireSent(t, server, cli, result, "mpnews")
+		articles := payload["mpnews"].(map[string]interface{})["articles"].([]interface{})
+		article := articles[0].(map[string]interface{})
+		require.Equal(t, "media_id", article["thumb_media_id"])
+		require.True(t, strings.Contains(article["content"].(string), url))
 	}
 }
 
 func TestSendMpNoticeMessage(t *testing.T) {
 	ctx := context.Background()
+	server := newTestServer()
+	defer server.Close()
 	for _, cli := range []*item{
-		initWxWorkSuiteAuthorizer(),
+		initWxWorkSuiteAuthorizer(t, server),
 	} {
 		userApi := user_api.NewApi(cli.client)
-		userid, err := userApi.MobileGetUserId(ctx, test.AgentUserMobile)
+		userid, err := userApi.MobileGetUserId(ctx, testUserMobile)
 		require.Equal(t, nil, err)
 
 		messageApi := NewApi(cli.client, cli.agentID)
@@ -330,6 +399,6 @@ func TestSendMpNoticeMessage(t *testing.T) {
 			},
 		)
 		require.Equal(t, nil, err)
-		fmt.Println(result.MsgID)
+		requireSent(t, server, cli, result, "miniprogram_notice")
 	}
 }
@@ -2,26 +2,31 @@ package oa_api
 
 import (
 	"context"
-	"fmt"
 	"strconv"
 	"testing"
 	"time"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	"github.com/lixinio/weixin/wxwork/agent"
 	"github.com/stretchr/testify/require"
 )
 
-func getOaApi() *OaApi {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
+const (
+	testCorpID      = "wxcorp"
+	testAgentID     = 1000002
+	testAgentSecret = "agent_secret"
+)
+
+func getOaApi(server *mockserver.Server) *OaApi {
+	cache := memory.NewMemory()
 	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := agent.New(corp, redis, redis, &agent.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
+		Corpid: testCorpID,
+	}, server.ClientOption())
+	agent := agent.New(corp, cache, cache, &agent.Config{
+		AgentID: testAgentID,
+		Secret:  testAgentSecret,
 	})
 	return NewApi(agent.Client)
 }
@@ -29,26 +34,73 @@ func getOaApi() *OaApi {
 func TestGetApprovalInfo(t *testing.T) {
 	// https://blog.csdn.net/qq_40600379/article/details/116104313
 	// 需要特定的agent
-	oaApi := getOaApi()
+	server := mockserver.New(mockserver.OptWithApp(testCorpID, testAgentSecret))
+	defer server.Close()
+	server.HandleJSON(apiGetApprovalInfo, map[string]interface{}{
+		"errcode": 0, "errmsg": "ok", "sp_no_list": []string{"201909270001", "201909270002"},
+	})
+	oaApi := getOaApi(server)
 	ctx := context.Background()
 
+	starttime := strconv.FormatInt(time.Now().Add(-20*24*time.Hour).Unix(), 10)
+	endtime := strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10)
 	result, err := oaApi.GetApprovalInfo(
-		ctx,
-		strconv.FormatInt(time.Now().Add(-20*24*time.Hour).Unix(), 10),
-		strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10),
-		0, 100, nil,
+		ctx, starttime, endtime, 0, 100,
+		[]TmplFilter{{Key: "sp_status", Value: "1"}},
 	)
 	require.Equal(t, nil, err)
-	fmt.Println(result.SpNolist)
+	require.Equal(t, []string{"201909270001", "201909270002"}, result.SpNolist)
+
+	payload := struct {
+		StartTime string       `json:"starttime"`
+		EndTime   string       `json:"endtime"`
+		Cursor    int          `json:"cursor"`
+		Size      int          `json:"size"`
+		Filters   []TmplFilter `json:"filters"`
+	}{}
+	require.Equal(t, nil, server.LastRequest(apiGetApprovalInfo).Unmarshal(&payload))
+	require.Equal(t, starttime, payload.StartTime)
+	require.Equal(t, endtime, payload.EndTime)
+	require.Equal(t, 100, payload.Size)
+	require.Equal(t, "sp_status", payload.Filters[0].Key)
 }
 
 func TestGetOpenApprovalData(t *testing.T) {
-	oaApi := getOaApi()
+	server := mockserver.New(mockserver.OptWithApp(testCorpID, testAgentSecret))
+	defer server.Close()
+	server.HandleJSON(apiGetOpenApprovalData, map[string]interface{}{
+		"errcode": 0,
+		"errmsg":  "ok",
+		"data": map[string]interface{}{
+			"ThirdNo":        "201806010001",
+			"OpenTemplateId": "1234567111",
+			"OpenSpName":     "付款",
+			"OpenSpstatus":   1,
+			"ApplyTime":      1527837645,
+			"ApplyUsername":  "jackiejjwu",
+			"approverstep":   0,
+			"ApprovalNodes": map[string]interface{}{
+				"ApprovalNode": []map[string]interface{}{{
+					"NodeStatus": 1, "NodeAttr": 1, "NodeType": 1,
+					"Items": map[string]interface{}{
+						"Item": []map[string]interface{}{{
+							"ItemName": "chauvetxiao", "ItemUserId": "chauvetxiao", "ItemStatus": 1,
+						}},
+					},
+				}},
+			},
+		},
+	})
+	oaApi := getOaApi(server)
 	ctx := context.Background()
 
-	result, err := oaApi.GetOpenApprovalData(
-		ctx, test.AgentOAThirdNo,
-	)
+	result, err := oaApi.GetOpenApprovalData(ctx, "201806010001")
 	require.Equal(t, nil, err)
-	fmt.Println(result)
+	require.Equal(t, "201806010001", result.Data.ThirdNo)
+	require.Equal(t, 1, result.Data.OpenSpStatus)
+	require.Equal(t, "chauvetxiao", result.Data.ApprovalNodes.ApprovalNode[0].Items.Item[0].ItemUserID)
+
+	payload := map[string]string{}
+	require.Equal(t, nil, server.LastRequest(apiGetOpenApprovalData).Unmarshal(&payload))
+	require.Equal(t, "201806010001", payload["thirdNo"])
 }
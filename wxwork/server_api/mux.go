@@ -0,0 +1,236 @@
+package server_api
+
+// 消息/事件路由, 根据 MsgType / Event / ChangeType 分发到注册的处理函数
+//
+//	mux := server_api.NewMux(serverApi)
+//	mux.OnText(func(ctx context.Context, msg *server_api.MessageText) (server_api.Reply, error) {
+//		return nil, nil
+//	})
+//	server_api.OnChangeContact(mux, server_api.EventTypeChangeContactCreateUser,
+//		func(ctx context.Context, event *server_api.EventChangeContactCreateUser) (server_api.Reply, error) {
+//			return nil, nil
+//		},
+//	)
+//	serverApi.ServeData(w, r, mux.ServeXML)
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/lixinio/weixin/utils"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Reply 被动回复的消息, 比如 *ReplyMessageText, nil 表示回复空串
+type Reply interface{}
+
+// HandlerFunc 处理 ParseXML 解析之后的消息/事件, m 为具体的类型, 比如 *MessageText
+type HandlerFunc func(ctx context.Context, message *Message, m any) (Reply, error)
+
+// Middleware 中间件, 可以用于日志/监控/去重等
+type Middleware func(HandlerFunc) HandlerFunc
+
+// 路由需要的字段
+type routeKey struct {
+	XMLName    xml.Name `xml:"xml"`
+	MsgType    string
+	Event      string
+	ChangeType string
+}
+
+type Mux struct {
+	server         *ServerApi
+	msgHandlers    map[string]HandlerFunc // MsgType => handler
+	eventHandlers  map[string]HandlerFunc // Event => handler
+	changeHandlers map[string]HandlerFunc // 通讯录变更 ChangeType => handler
+	fallback       HandlerFunc
+	middlewares    []Middleware
+}
+
+func NewMux(server *ServerApi) *Mux {
+	return &Mux{
+		server:         server,
+		msgHandlers:    map[string]HandlerFunc{},
+		eventHandlers:  map[string]HandlerFunc{},
+		changeHandlers: map[string]HandlerFunc{},
+	}
+}
+
+// Use 添加中间件, 先添加的在外层
+func (mux *Mux) Use(middlewares ...Middleware) {
+	mux.middlewares = append(mux.middlewares, middlewares...)
+}
+
+// Handle 注册消息的处理函数
+func (mux *Mux) Handle(msgType string, handler HandlerFunc) {
+	mux.msgHandlers[msgType] = handler
+}
+
+// HandleEvent 注册事件的处理函数
+func (mux *Mux) HandleEvent(event string, handler HandlerFunc) {
+	mux.eventHandlers[event] = handler
+}
+
+// HandleChangeContact 注册通讯录变更事件的处理函数, 优先于 HandleEvent(EventTypeChangeContact)
+func (mux *Mux) HandleChangeContact(changeType string, handler HandlerFunc) {
+	mux.changeHandlers[changeType] = handler
+}
+
+// Fallback 没有匹配的处理函数时调用, 缺省回复空串
+func (mux *Mux) Fallback(handler HandlerFunc) {
+	mux.fallback = handler
+}
+
+// typed 将强类型的处理函数转换为 HandlerFunc
+func typed[T any](handler func(context.Context, *T) (Reply, error)) HandlerFunc {
+	return func(ctx context.Context, message *Message, m any) (Reply, error) {
+		v, ok := m.(*T)
+		if !ok {
+			return nil, fmt.Errorf(
+				"unexpected message type %T, msgtype '%s'", m, message.MsgType,
+			)
+		}
+		return handler(ctx, v)
+	}
+}
+
+// OnMessage 注册强类型的消息处理函数, T 必须和 ParseXML 返回的类型一致
+func OnMessage[T any](
+	mux *Mux, msgType string, handler func(context.Context, *T) (Reply, error),
+) {
+	mux.Handle(msgType, typed(handler))
+}
+
+// OnEvent 注册强类型的事件处理函数, T 必须和 ParseXML 返回的类型一致
+func OnEvent[T any](
+	mux *Mux, event string, handler func(context.Context, *T) (Reply, error),
+) {
+	mux.HandleEvent(event, typed(handler))
+}
+
+// OnChangeContact 注册强类型的通讯录变更事件处理函数, T 必须和 ParseXML 返回的类型一致
+func OnChangeContact[T any](
+	mux *Mux, changeType string, handler func(context.Context, *T) (Reply, error),
+) {
+	mux.HandleChangeContact(changeType, typed(handler))
+}
+
+func (mux *Mux) OnText(handler func(context.Context, *MessageText) (Reply, error)) {
+	OnMessage(mux, MsgTypeText, handler)
+}
+
+func (mux *Mux) OnImage(handler func(context.Context, *MessageImage) (Reply, error)) {
+	OnMessage(mux, MsgTypeImage, handler)
+}
+
+func (mux *Mux) OnVoice(handler func(context.Context, *MessageVoice) (Reply, error)) {
+	OnMessage(mux, MsgTypeVoice, handler)
+}
+
+func (mux *Mux) OnVideo(handler func(context.Context, *MessageVideo) (Reply, error)) {
+	OnMessage(mux, MsgTypeVideo, handler)
+}
+
+func (mux *Mux) OnLocation(handler func(context.Context, *MessageLocation) (Reply, error)) {
+	OnMessage(mux, MsgTypeLocation, handler)
+}
+
+func (mux *Mux) OnLink(handler func(context.Context, *MessageLink) (Reply, error)) {
+	OnMessage(mux, MsgTypeLink, handler)
+}
+
+// OnSubscribe 成员关注/取消关注, 通过 Event 字段区分
+func (mux *Mux) OnSubscribe(handler func(context.Context, *EventSubscribe) (Reply, error)) {
+	OnEvent(mux, EventTypeSubscribe, handler)
+	OnEvent(mux, EventTypeUnSubscribe, handler)
+}
+
+func (mux *Mux) OnEnterAgent(handler func(context.Context, *EventEnterAgent) (Reply, error)) {
+	OnEvent(mux, EventTypeEnterAgent, handler)
+}
+
+func (mux *Mux) OnMenuClick(handler func(context.Context, *EventMenuClick) (Reply, error)) {
+	OnEvent(mux, EventTypeMenuClick, handler)
+}
+
+func (mux *Mux) OnTaskCardClick(
+	handler func(context.Context, *EventTaskCardClick) (Reply, error),
+) {
+	OnEvent(mux, EventTypeTaskCardClick, handler)
+}
+
+// Dispatch 解析并分发消息, 返回需要回复的消息
+func (mux *Mux) Dispatch(ctx context.Context, body []byte) (reply Reply, err error) {
+	key := &routeKey{}
+	if err = xml.Unmarshal(body, key); err != nil {
+		return nil, err
+	}
+
+	ctx, span := utils.StartCallbackSpan(
+		ctx, "dispatch",
+		attribute.String("weixin.msgtype", key.MsgType),
+		attribute.String("weixin.event", key.Event),
+		attribute.String("weixin.change_type", key.ChangeType),
+	)
+	defer func() {
+		utils.EndSpan(span, err)
+	}()
+
+	message, m, err := mux.server.ParseXML(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var handler HandlerFunc
+	if key.MsgType == MsgTypeEvent {
+		if key.Event == EventTypeChangeContact {
+			handler = mux.changeHandlers[key.ChangeType]
+		}
+		if handler == nil {
+			handler = mux.eventHandlers[key.Event]
+		}
+	} else {
+		handler = mux.msgHandlers[key.MsgType]
+	}
+	if handler == nil {
+		handler = mux.fallback
+	}
+	if handler == nil {
+		handler = func(context.Context, *Message, any) (Reply, error) {
+			return nil, nil
+		}
+	}
+
+	for i := len(mux.middlewares) - 1; i >= 0; i-- {
+		handler = mux.middlewares[i](handler)
+	}
+	return handler(ctx, message, m)
+}
+
+// ServeXML 实现 utils.XmlHandlerFunc, 用于 ServeData
+func (mux *Mux) ServeXML(w http.ResponseWriter, r *http.Request, body []byte) error {
+	reply, err := mux.Dispatch(r.Context(), body)
+	if err != nil {
+		return err
+	}
+
+	switch v := reply.(type) {
+	case nil:
+		return mux.server.response(w, r, nil)
+	case *ReplyMessageText:
+		return mux.server.ResponseText(w, r, v)
+	case *ReplyMessageImage:
+		return mux.server.ResponseImage(w, r, v)
+	case *ReplyMessageVoice:
+		return mux.server.ResponseVoice(w, r, v)
+	case *ReplyMessageVideo:
+		return mux.server.ResponseVideo(w, r, v)
+	case *ReplyMessageNews:
+		return mux.server.ResponseNews(w, r, v)
+	case *ReplyMessageTaskCard:
+		return mux.server.ResponseTaskCard(w, r, v)
+	default:
+		return fmt.Errorf("unsupported reply type %T", reply)
+	}
+}
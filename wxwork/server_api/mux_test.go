@@ -0,0 +1,48 @@
+package server_api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMux(t *testing.T) {
+	mux := NewMux(NewApi(1, "token", ""))
+
+	userIDs := []string{}
+	OnChangeContact(mux, EventTypeChangeContactCreateUser,
+		func(_ context.Context, event *EventChangeContactCreateUser) (Reply, error) {
+			userIDs = append(userIDs, event.UserID)
+			return nil, nil
+		},
+	)
+	events := []string{}
+	mux.HandleEvent(EventTypeChangeContact, func(_ context.Context, _ *Message, m any) (Reply, error) {
+		events = append(events, m.(*EventChangeContact).ChangeType)
+		return nil, nil
+	})
+
+	_, err := mux.Dispatch(context.Background(), []byte(`<xml>
+<ToUserName><![CDATA[toUser]]></ToUserName>
+<FromUserName><![CDATA[sys]]></FromUserName>
+<CreateTime>1403610513</CreateTime>
+<MsgType><![CDATA[event]]></MsgType>
+<Event><![CDATA[change_contact]]></Event>
+<ChangeType>create_user</ChangeType>
+<UserID><![CDATA[zhangsan]]></UserID>
+</xml>`))
+	require.Equal(t, nil, err)
+	require.Equal(t, []string{"zhangsan"}, userIDs)
+
+	_, err = mux.Dispatch(context.Background(), []byte(`<xml>
+<ToUserName><![CDATA[toUser]]></ToUserName>
+<FromUserName><![CDATA[sys]]></FromUserName>
+<CreateTime>1403610513</CreateTime>
+<MsgType><![CDATA[event]]></MsgType>
+<Event><![CDATA[change_contact]]></Event>
+<ChangeType>unknown_change</ChangeType>
+</xml>`))
+	require.Equal(t, nil, err)
+	require.Equal(t, []string{"unknown_change"}, events)
+}
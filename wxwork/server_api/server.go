@@ -17,13 +17,12 @@ package server_api
 // limitations under the License.
 
 import (
+	"context"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/lixinio/weixin/utils"
 )
@@ -32,44 +31,61 @@ type ServerApi struct {
 	AgentID        string
 	Token          string // 接收消息服务器配置（Token）
 	EncodingAESKey string // 接收消息服务器配置（EncodingAESKey）
+	// 解密之后允许的 receiveid, 企业应用为 corpid, 第三方应用的数据回调为授权企业的 corpid
+	// 为空时只校验 receiveid 和消息的 ToUserName 一致
+	ReceiverIDs []string
+	replayGuard *utils.ReplayGuard
 }
 
+// NewApi receiverIDs 为解密之后允许的 receiveid(企业应用传 corpid), 可以不传, 兼容之前的调用;
+// 不传时不校验 receiveid 是否属于自己, 只要求和明文的 ToUserName 一致, 无法拒绝其他企业的消息
 func NewApi(
 	agentID int,
 	token, encodingAESKey string,
+	receiverIDs ...string,
 ) *ServerApi {
 	return &ServerApi{
 		AgentID:        strconv.Itoa(agentID),
 		Token:          token,
 		EncodingAESKey: encodingAESKey,
+		ReceiverIDs:    receiverIDs,
 	}
 }
 
-func calcSignatureFromHttp(r *http.Request, token string) (string, string) {
-	echostr := r.URL.Query().Get("echostr")
-	return utils.CalcSignature(
-		r.URL.Query().Get("timestamp"),
-		r.URL.Query().Get("nonce"),
-		echostr,
-		token,
-	), echostr
+// SetReplayGuard 开启回调的防重放检查
+func (s *ServerApi) SetReplayGuard(guard *utils.ReplayGuard) {
+	s.replayGuard = guard
+}
+
+type receiverIDContext int
+
+var receiverIDContextKey = receiverIDContext(0)
+
+// replyReceiverID ServeData 解密得到的 receiveid, 用于加密回复
+func replyReceiverID(r *http.Request) string {
+	if receiverID, ok := r.Context().Value(receiverIDContextKey).(string); ok {
+		return receiverID
+	}
+	return ""
+}
+
+// crypto 签名和加解密, 回复缺省使用第一个 ReceiverIDs
+func (s *ServerApi) crypto() *utils.CallbackCrypto {
+	receiverID := s.AgentID
+	if len(s.ReceiverIDs) > 0 {
+		receiverID = s.ReceiverIDs[0]
+	}
+	return utils.NewCallbackCrypto(s.Token, s.EncodingAESKey, receiverID, s.ReceiverIDs...)
 }
 
 func (s *ServerApi) ServeEcho(w http.ResponseWriter, r *http.Request) error {
-	signature, echoStr := calcSignatureFromHttp(r, s.Token)
-	if echoStr != "" && signature == r.URL.Query().Get("msg_signature") {
-		// 解密 echoStr
-		_, msg, _, err := utils.AESDecryptMsg(echoStr, s.EncodingAESKey)
-		if err != nil {
-			utils.HttpAbortBadRequest(w)
-			return err
-		}
-		_, err = w.Write(msg)
-		return err
-	} else {
+	echo, err := s.crypto().VerifyEcho(r.URL.Query())
+	if err != nil {
 		utils.HttpAbortBadRequest(w)
-		return errors.New("signature dismatch")
+		return err
 	}
+	_, err = w.Write(echo)
+	return err
 }
 
 func (s *ServerApi) ServeData(
@@ -88,26 +104,35 @@ func (s *ServerApi) ServeData(
 		return err
 	}
 
-	// 验证签名
-	signature := utils.CalcSignature(
-		r.URL.Query().Get("timestamp"),
-		r.URL.Query().Get("nonce"),
-		encryptMsg.Encrypt,
-		s.Token,
-	)
-
-	if msgSignature := r.URL.Query().Get("msg_signature"); signature != msgSignature {
-		err = fmt.Errorf("signature dismatch %s != %s", signature, msgSignature)
-		return err
-	}
-
-	// 解密
-	var xmlMsg []byte
-	_, xmlMsg, _, err = utils.AESDecryptMsg(encryptMsg.Encrypt, s.EncodingAESKey)
+	// 验证签名, 解密
+	query := r.URL.Query()
+	xmlMsg, receiverID, err := s.crypto().OpenEnvelope(r.Context(), query, encryptMsg)
 	if err != nil {
 		return err
 	}
-	return processor(w, r, xmlMsg)
+	if len(s.ReceiverIDs) == 0 && receiverID != "" && receiverID != encryptMsg.ToUserName {
+		return fmt.Errorf(
+			"receiver id '%s' != tousername '%s', %w",
+			receiverID, encryptMsg.ToUserName, utils.ErrorReceiverIDMismatch,
+		)
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), receiverIDContextKey, receiverID))
+	return s.replayGuard.Guard(
+		r.Context(), query.Get("timestamp"), query.Get("nonce"), query.Get("msg_signature"),
+		func() error {
+			return processor(w, r, xmlMsg)
+		},
+	)
+}
+
+// ServeDataAsync 校验/解密之后交给 queue 异步处理, 立即回复空串
+func (s *ServerApi) ServeDataAsync(
+	w http.ResponseWriter,
+	r *http.Request,
+	queue utils.CallbackQueue,
+) error {
+	return s.ServeData(w, r, utils.NewAsyncXmlHandler(queue, ""))
 }
 
 /*
@@ -329,60 +354,13 @@ func parseEvent(body []byte) (_ any, err error) {
 	}
 }
 
-// Response 响应微信消息
+// Response 响应微信消息, 使用回调的 receiveid 加密
 func (s *ServerApi) response(
 	w http.ResponseWriter,
 	r *http.Request,
 	reply interface{},
 ) (err error) {
-	output := []byte("") // 默认回复
-	if reply != nil {
-		output, err = xml.Marshal(reply)
-		if err != nil {
-			return
-		}
-
-		// 加密
-		var message *ReplyEncryptMessage
-		message, err = s.encryptReplyMessage(output)
-		if err != nil {
-			return
-		}
-
-		output, err = xml.Marshal(message)
-		if err != nil {
-			return
-		}
-	}
-
-	_, err = w.Write(output)
-
-	return
-}
-
-// encryptReplyMessage 加密回复消息
-func (s *ServerApi) encryptReplyMessage(
-	rawXmlMsg []byte,
-) (replyEncryptMessage *ReplyEncryptMessage, err error) {
-	cipherText, err := utils.AESEncryptMsg(
-		[]byte(utils.GetRandString(16)),
-		rawXmlMsg,
-		s.AgentID,
-		s.EncodingAESKey,
-	)
-	if err != nil {
-		return
-	}
-	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
-	nonce := utils.GetRandString(6)
-	signature := utils.CalcSignature(timestamp, nonce, s.Token, cipherText)
-
-	return &ReplyEncryptMessage{
-		Encrypt:      CDATA(cipherText),
-		MsgSignature: CDATA(signature),
-		TimeStamp:    timestamp,
-		Nonce:        CDATA(nonce),
-	}, nil
+	return s.crypto().WriteReply(w, reply, replyReceiverID(r), "")
 }
 
 func (s *ServerApi) ResponseText(
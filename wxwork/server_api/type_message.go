@@ -18,6 +18,8 @@ import (
 	"encoding/xml"
 	"strconv"
 	"time"
+
+	"github.com/lixinio/weixin/utils"
 )
 
 const (
@@ -60,12 +62,7 @@ func (message *Message) Reply() *ReplyMessage {
 
 </xml>
 */
-type EncryptMessage struct {
-	XMLName    xml.Name `xml:"xml"`
-	ToUserName string
-	Encrypt    string
-	AgentID    string
-}
+type EncryptMessage = utils.EncryptMessage
 
 /*
 <xml>
@@ -14,15 +14,14 @@
 
 package server_api
 
-import "encoding/xml"
+import (
+	"encoding/xml"
 
-type CDATA string
+	"github.com/lixinio/weixin/utils"
+)
 
-func (c CDATA) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-	return e.EncodeElement(struct {
-		string `xml:",cdata"`
-	}{string(c)}, start)
-}
+// CDATA 和 加密回复 使用 utils 中的定义, 所有回调共用
+type CDATA = utils.CDATA
 
 const (
 	ReplyMsgTypeText     = "text"
@@ -42,24 +41,7 @@ type ReplyMessage struct {
 	MsgType      CDATA
 }
 
-/*
-加密处理后 的 回复 消息体
-<xml>
-
-	<Encrypt><![CDATA[msg_encrypt]]></Encrypt>
-	<MsgSignature><![CDATA[msg_signature]]></MsgSignature>
-	<TimeStamp>timestamp</TimeStamp>
-	<Nonce><![CDATA[nonce]]></Nonce>
-
-</xml>
-*/
-type ReplyEncryptMessage struct {
-	XMLName      xml.Name `xml:"xml"`
-	Encrypt      CDATA
-	MsgSignature CDATA
-	TimeStamp    string
-	Nonce        CDATA
-}
+type ReplyEncryptMessage = utils.ReplyEncryptMessage
 
 /*
 <xml>
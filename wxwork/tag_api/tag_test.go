@@ -2,31 +2,39 @@ package tag_api
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	"github.com/lixinio/weixin/wxwork/agent"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTag(t *testing.T) {
-	redis := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
-	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
+	server := mockserver.New(mockserver.OptWithApp("wxcorp", "agent_secret"))
+	defer server.Close()
+	server.HandleJSON(apiList, map[string]interface{}{
+		"errcode": 0,
+		"errmsg":  "ok",
+		"taglist": []map[string]interface{}{
+			{"tagid": "1", "tagname": "a"},
+			{"tagid": "2", "tagname": "b"},
+		},
 	})
-	agent := agent.New(corp, redis, redis, &agent.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
+
+	cache := memory.NewMemory()
+	corp := wxwork.New(&wxwork.Config{
+		Corpid: "wxcorp",
+	}, server.ClientOption())
+	agent := agent.New(corp, cache, cache, &agent.Config{
+		AgentID: 1000002,
+		Secret:  "agent_secret",
 	})
 	ctx := context.Background()
 
 	tagApi := NewApi(agent.Client)
 	taglist, err := tagApi.List(ctx)
 	require.Equal(t, nil, err)
-	for _, item := range taglist.TagList {
-		fmt.Println(item.TagID, item.TagName)
-	}
+	require.Equal(t, []TagItem{{TagID: "1", TagName: "a"}, {TagID: "2", TagName: "b"}}, taglist.TagList)
 }
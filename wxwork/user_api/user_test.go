@@ -4,28 +4,57 @@ import (
 	"context"
 	"testing"
 
-	"github.com/lixinio/weixin/test"
 	"github.com/lixinio/weixin/utils"
-	"github.com/lixinio/weixin/utils/redis"
+	"github.com/lixinio/weixin/utils/memory"
+	"github.com/lixinio/weixin/utils/mockserver"
 	"github.com/lixinio/weixin/wxwork"
 	"github.com/lixinio/weixin/wxwork/agent"
 	"github.com/lixinio/weixin/wxwork/authorizer"
+	"github.com/lixinio/weixin/wxwork_suite"
 	"github.com/stretchr/testify/require"
 )
 
-func getClient() []*utils.Client {
-	rds := redis.NewRedis(&redis.Config{RedisUrl: test.CacheUrl})
+const (
+	testCorpID        = "wxcorp"
+	testAgentID       = 1000002
+	testAgentSecret   = "agent_secret"
+	testSuiteID       = "wxsuite"
+	testSuiteSecret   = "suite_secret"
+	testSuiteCorpID   = "wxsuitecorp" // 授权给服务商的企业
+	testSuiteAgentID  = 1000003
+	testPermanentCode = "permanent_code"
+	testUserMobile    = "13800138000"
+	testUserID        = "test"
+	testOpenID        = "oDjGHs-1yCnGrRovBj2yHij5JAAA"
+	testRootDep       = 1
+)
+
+// getClient 企业自建应用和服务商代开发的应用
+func getClient(t *testing.T, server *mockserver.Server) []*utils.Client {
+	cache := memory.NewMemory()
 	corp := wxwork.New(&wxwork.Config{
-		Corpid: test.CorpID,
-	})
-	agent := agent.New(corp, rds, rds, &agent.Config{
-		AgentID: test.AgentID,
-		Secret:  test.AgentSecret,
+		Corpid: testCorpID,
+	}, server.ClientOption())
+	agent := agent.New(corp, cache, cache, &agent.Config{
+		AgentID: testAgentID,
+		Secret:  testAgentSecret,
 	})
 
-	authorizer := authorizer.NewLite(
-		rds, rds, test.WxWorkSuiteID,
-		test.WxWorkSuiteCorpID, test.WxWorkSuiteAgentID,
+	suite := wxwork_suite.New(cache, cache, &wxwork_suite.Config{
+		SuiteID:     testSuiteID,
+		SuiteSecret: testSuiteSecret,
+	}, nil, server.ClientOption())
+	require.Equal(t, nil, suite.UpdateTicket(context.Background(), "suite_ticket"))
+	authorizer := authorizer.New(
+		cache, cache, testSuiteID, testSuiteCorpID, testSuiteAgentID,
+		func(ctx context.Context) (string, int, error) {
+			result, err := suite.GetCorpToken(ctx, testSuiteCorpID, testPermanentCode)
+			if err != nil {
+				return "", 0, err
+			}
+			return result.AccessToken, result.ExpiresIn, nil
+		},
+		nil, server.ClientOption(),
 	)
 	return []*utils.Client{
 		agent.Client,
@@ -33,31 +62,70 @@ func getClient() []*utils.Client {
 	}
 }
 
+func newTestServer() *mockserver.Server {
+	server := mockserver.New(
+		mockserver.OptWithApp(testCorpID, testAgentSecret),
+		mockserver.OptWithApp(testSuiteID, testSuiteSecret),
+	)
+	user := map[string]interface{}{
+		"userid": testUserID, "name": "张三", "department": []int{testRootDep},
+		"mobile": testUserMobile, "gender": UserGenderMale, "status": UserStatusActivated,
+	}
+	server.HandleJSON(apiMobileGetUserId, map[string]interface{}{"userid": testUserID})
+	server.HandleJSON(apiGet, user)
+	server.HandleJSON(apiConvertToOpenId, map[string]interface{}{"openid": testOpenID})
+	server.HandleJSON(apiConvertToUserId, map[string]interface{}{"userid": testUserID})
+	server.HandleJSON(apiSimpleList, map[string]interface{}{
+		"userlist": []map[string]interface{}{{
+			"userid": testUserID, "name": "张三", "department": []int{testRootDep},
+		}},
+	})
+	server.HandleJSON(apiList, map[string]interface{}{
+		"userlist": []map[string]interface{}{user},
+	})
+	return server
+}
+
 func TestUser(t *testing.T) {
-	clis := getClient()
+	server := newTestServer()
+	defer server.Close()
+	clis := getClient(t, server)
 	ctx := context.Background()
 
 	for _, cli := range clis {
 		userApi := NewApi(cli)
 		{
-			userid, err := userApi.MobileGetUserId(ctx, test.AgentUserMobile)
+			userid, err := userApi.MobileGetUserId(ctx, testUserMobile)
 			require.Equal(t, nil, err)
+			require.Equal(t, testUserID, userid)
 
 			resp, err := userApi.Get(ctx, userid)
 			require.Equal(t, nil, err)
+			require.Equal(t, testUserID, resp.UserID)
+			require.Equal(t, testUserMobile, resp.Mobile)
+			require.Equal(t, userid, server.LastRequest(apiGet).Query.Get("userid"))
 
 			openID, err := userApi.ConvertToOpenId(ctx, resp.UserID)
 			require.Equal(t, nil, err)
+			require.Equal(t, testOpenID, openID)
 
 			newUserId, err := userApi.ConvertToUserId(ctx, openID)
 			require.Equal(t, nil, err)
 			require.Equal(t, userid, newUserId)
+			payload := map[string]string{}
+			require.Equal(t, nil, server.LastRequest(apiConvertToUserId).Unmarshal(&payload))
+			require.Equal(t, testOpenID, payload["openid"])
 
-			_, err = userApi.SimpleList(ctx, test.AgentRootDep, 1)
+			simpleList, err := userApi.SimpleList(ctx, testRootDep, 1)
 			require.Equal(t, nil, err)
+			require.Equal(t, testUserID, simpleList.UserList[0].UserID)
+			require.Equal(t, "1", server.LastRequest(apiSimpleList).Query.Get("department_id"))
+			require.Equal(t, "1", server.LastRequest(apiSimpleList).Query.Get("fetch_child"))
 
-			_, err = userApi.List(ctx, test.AgentRootDep, 1)
+			list, err := userApi.List(ctx, testRootDep, 1)
 			require.Equal(t, nil, err)
+			require.Equal(t, UserStatusActivated, list.UserList[0].Status)
 		}
 	}
+	require.NotEqual(t, nil, server.LastRequest("/cgi-bin/service/get_corp_token"))
 }
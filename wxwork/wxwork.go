@@ -11,16 +11,18 @@ type Config struct {
 }
 
 type WxWork struct {
-	Config *Config
+	Config  *Config
+	options []utils.ClientOption // 所有应用共享的Client配置
 }
 
-func New(config *Config) (corp *WxWork) {
+func New(config *Config, options ...utils.ClientOption) (corp *WxWork) {
 	instance := WxWork{
-		Config: config,
+		Config:  config,
+		options: utils.ClientOptionsWithAppID(config.Corpid, options),
 	}
 	return &instance
 }
 
-func (*WxWork) NewClient(accessTokenCache *utils.AccessTokenCache) *utils.Client {
-	return utils.NewClient(QyWXServerUrl, accessTokenCache)
+func (corp *WxWork) NewClient(accessTokenCache *utils.AccessTokenCache) *utils.Client {
+	return utils.NewClient(QyWXServerUrl, accessTokenCache, corp.options...)
 }
@@ -36,13 +36,15 @@ type WxWorkProvider struct {
 func New(
 	cache utils.Cache, locker utils.Lock, config *Config,
 	tokenRefreshHandler utils.TokenRefreshHandler, // 刷新callback
+	options ...utils.ClientOption,
 ) *WxWorkProvider {
+	options = utils.ClientOptionsWithAppID(config.CorpID, options)
 	accessTokenCache := utils.NewAccessTokenCache(
-		newAccessTokenAdaptor(config), cache, locker,
+		newAccessTokenAdaptor(config, options...), cache, locker,
 		utils.CacheClientTokenOptWithExpireBefore(tokenRefreshHandler),
 	)
 
-	client := utils.NewClient(WXServerUrl, accessTokenCache)
+	client := utils.NewClient(WXServerUrl, accessTokenCache, options...)
 	client.UpdateAccessTokenKey(accessTokenKey)
 
 	instance := &WxWorkProvider{
@@ -53,8 +55,10 @@ func New(
 	return instance
 }
 
-func NewLite(cache utils.Cache, locker utils.Lock, corpID string) *WxWorkProvider {
-	return New(cache, locker, &Config{CorpID: corpID}, nil)
+func NewLite(
+	cache utils.Cache, locker utils.Lock, corpID string, options ...utils.ClientOption,
+) *WxWorkProvider {
+	return New(cache, locker, &Config{CorpID: corpID}, nil, options...)
 }
 
 func (provider *WxWorkProvider) RefreshAccessToken(
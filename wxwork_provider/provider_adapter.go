@@ -58,10 +58,12 @@ func (ta *accessTokenAdaptor) GetAccessToken(
 	return result.AccessToken, result.ExpiresIn, nil
 }
 
-func newAccessTokenAdaptor(config *Config) *accessTokenAdaptor {
+func newAccessTokenAdaptor(
+	config *Config, options ...utils.ClientOption,
+) *accessTokenAdaptor {
 	adaptor := &accessTokenAdaptor{
 		config:    config,
-		client:    utils.NewClient(WXServerUrl, utils.EmptyClientAccessTokenGetter(0)),
+		client:    utils.NewClient(WXServerUrl, utils.EmptyClientAccessTokenGetter(0), options...),
 		tokenKey:  fmt.Sprintf("qywx.provider_access_token.%s", config.CorpID),
 		lockerKey: fmt.Sprintf("qywx.provider_access_token.%s.lock", config.CorpID),
 	}
@@ -1,8 +1,8 @@
 package wxwork_suite
 
 import (
+	"context"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,17 +12,22 @@ import (
 
 // https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/api/ThirdParty/token/authorize_event.html
 // https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/api/ThirdParty/token/component_verify_ticket.html
-/*
-启用 加密模式 后 收到的 消息格式
-<xml>
-    <ToUserName><![CDATA[]]></ToUserName>
-    <Encrypt><![CDATA[]]></Encrypt>
-</xml>
-*/
-type EncryptMessage struct {
-	XMLName    xml.Name `xml:"xml"`
-	ToUserName string
-	Encrypt    string
+type EncryptMessage = utils.EncryptMessage
+
+type receiverIDContext int
+
+var receiverIDContextKey = receiverIDContext(0)
+
+// SetReplayGuard 开启回调的防重放检查
+func (suite *WxWorkSuite) SetReplayGuard(guard *utils.ReplayGuard) {
+	suite.replayGuard = guard
+}
+
+// crypto 签名和加解密, 指令回调的 receiveid 为 suiteid, 数据回调为授权企业的 corpid
+func (suite *WxWorkSuite) crypto(receiverIDs ...string) *utils.CallbackCrypto {
+	return utils.NewCallbackCrypto(
+		suite.Config.Token, suite.Config.EncodingAESKey, suite.Config.SuiteID, receiverIDs...,
+	)
 }
 
 func (suite *WxWorkSuite) ServeData(
@@ -45,25 +50,39 @@ func (suite *WxWorkSuite) ServeData(
 		return fmt.Errorf("invalid tousername %s", encryptMsg.ToUserName)
 	}
 
-	// 验证签名
-	signature := utils.CalcSignature(
-		r.URL.Query().Get("timestamp"),
-		r.URL.Query().Get("nonce"),
-		encryptMsg.Encrypt,
-		suite.Config.Token,
-	)
-	if msgSignature := r.URL.Query().Get("msg_signature"); signature != msgSignature {
-		err = fmt.Errorf("signature dismatch %s != %s", signature, msgSignature)
-		return err
-	}
-
-	// 解密
-	var xmlMsg []byte
-	_, xmlMsg, _, err = utils.AESDecryptMsg(encryptMsg.Encrypt, suite.Config.EncodingAESKey)
+	// 验证签名, 解密, 指令回调的 receiveid 为 suiteid
+	query := r.URL.Query()
+	xmlMsg, receiverID, err := suite.crypto(suite.Config.SuiteID).OpenEnvelope(r.Context(), query, encryptMsg)
 	if err != nil {
 		return err
 	}
-	return processor(w, r, xmlMsg)
+
+	r = r.WithContext(context.WithValue(r.Context(), receiverIDContextKey, receiverID))
+	return suite.replayGuard.Guard(
+		r.Context(), query.Get("timestamp"), query.Get("nonce"), query.Get("msg_signature"),
+		func() error {
+			return processor(w, r, xmlMsg)
+		},
+	)
+}
+
+// ServeDataAsync 校验/解密之后交给 queue 异步处理, 立即回复 success
+func (suite *WxWorkSuite) ServeDataAsync(
+	w http.ResponseWriter,
+	r *http.Request,
+	queue utils.CallbackQueue,
+) error {
+	return suite.ServeData(w, r, utils.NewAsyncXmlHandler(queue, "success"))
+}
+
+// Response 加密回复, 使用回调的 receiveid, reply 为 nil 时回复 success
+func (suite *WxWorkSuite) Response(
+	w http.ResponseWriter,
+	r *http.Request,
+	reply interface{},
+) error {
+	receiverID, _ := r.Context().Value(receiverIDContextKey).(string)
+	return suite.crypto().WriteReply(w, reply, receiverID, "success")
 }
 
 // ParseXML 解析微信推送过来的消息/事件
@@ -263,29 +282,23 @@ func parseChangeContactEvent(body []byte, event *Event) (m interface{}, err erro
 	}
 }
 
-func calcSignatureFromHttp(r *http.Request, token string) (string, string) {
-	echostr := r.URL.Query().Get("echostr")
-	return utils.CalcSignature(
-		r.URL.Query().Get("timestamp"),
-		r.URL.Query().Get("nonce"),
-		echostr,
-		token,
-	), echostr
+// ServeEcho 校验指令回调URL, 解密 echostr, 不校验 receiveid
+func (suite *WxWorkSuite) ServeEcho(w http.ResponseWriter, r *http.Request) error {
+	return suite.ServeDataEcho(w, r)
 }
 
-func (suite *WxWorkSuite) ServeEcho(w http.ResponseWriter, r *http.Request) error {
-	signature, echoStr := calcSignatureFromHttp(r, suite.Config.Token)
-	if echoStr != "" && signature == r.URL.Query().Get("msg_signature") {
-		// 解密 echoStr
-		_, msg, _, err := utils.AESDecryptMsg(echoStr, suite.Config.EncodingAESKey)
-		if err != nil {
-			utils.HttpAbortBadRequest(w)
-			return err
-		}
-		_, err = w.Write(msg)
-		return err
-	} else {
+// ServeDataEcho 校验数据回调URL, echostr 的 receiveid 为授权企业的 corpid
+// 指定 corpIDs 时校验 receiveid, 为空不校验
+func (suite *WxWorkSuite) ServeDataEcho(
+	w http.ResponseWriter,
+	r *http.Request,
+	corpIDs ...string,
+) error {
+	echo, err := suite.crypto(corpIDs...).VerifyEcho(r.URL.Query())
+	if err != nil {
 		utils.HttpAbortBadRequest(w)
-		return errors.New("signature dismatch")
+		return err
 	}
+	_, err = w.Write(echo)
+	return err
 }
@@ -39,6 +39,7 @@ type WxWorkSuite struct {
 	Client           *utils.Client
 	ticketCache      *utils.AccessTokenCache
 	accessTokenCache *utils.AccessTokenCache
+	replayGuard      *utils.ReplayGuard
 }
 
 func New(
@@ -46,16 +47,18 @@ func New(
 	locker utils.Lock,
 	config *Config,
 	tokenRefreshHandler utils.TokenRefreshHandler, // 刷新callback
+	options ...utils.ClientOption,
 ) *WxWorkSuite {
+	options = utils.ClientOptionsWithAppID(config.SuiteID, options)
 	ticketCache := utils.NewAccessTokenCache(newTicketAdapter(config.SuiteID), cache, locker)
 	accessTokenCache := utils.NewAccessTokenCache(
-		newAccessTokenAdaptor(config, ticketCache),
+		newAccessTokenAdaptor(config, ticketCache, options...),
 		cache, locker,
 		utils.CacheClientTokenOptWithExpireBefore(tokenRefreshHandler),
 	)
 	instance := &WxWorkSuite{
 		Config:           config,
-		Client:           utils.NewClient(WXServerUrl, accessTokenCache),
+		Client:           utils.NewClient(WXServerUrl, accessTokenCache, options...),
 		ticketCache:      ticketCache,
 		accessTokenCache: accessTokenCache,
 	}
@@ -67,13 +70,15 @@ func NewLite(
 	cache utils.Cache,
 	locker utils.Lock,
 	suiteID string,
+	options ...utils.ClientOption,
 ) *WxWorkSuite {
+	options = utils.ClientOptionsWithAppID(suiteID, options)
 	config := &Config{SuiteID: suiteID}
 	instance := &WxWorkSuite{
 		Config: config,
 		Client: utils.NewClient(WXServerUrl, utils.NewAccessTokenCache(
 			newAccessTokenAdaptor(config, nil), cache, locker,
-		)),
+		), options...),
 	}
 	instance.Client.UpdateAccessTokenKey(accessTokenKey) // token的名称不一样
 	return instance
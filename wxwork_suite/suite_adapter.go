@@ -96,6 +96,7 @@ func (ta *accessTokenAdaptor) GetAccessToken(
 func newAccessTokenAdaptor(
 	config *Config,
 	ticketCache *utils.AccessTokenCache,
+	options ...utils.ClientOption,
 ) *accessTokenAdaptor {
 	adaptor := &accessTokenAdaptor{
 		config:      config,
@@ -104,7 +105,9 @@ func newAccessTokenAdaptor(
 		lockerKey:   fmt.Sprintf("qywx.suite_access_token.%s.lock", config.SuiteID),
 	}
 	if ticketCache != nil {
-		adaptor.client = utils.NewClient(WXServerUrl, utils.EmptyClientAccessTokenGetter(0))
+		adaptor.client = utils.NewClient(
+			WXServerUrl, utils.EmptyClientAccessTokenGetter(0), options...,
+		)
 	}
 	return adaptor
 }